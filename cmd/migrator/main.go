@@ -1,51 +1,168 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 
 	// Библиотека миграций
 	"github.com/golang-migrate/migrate/v4"
 	// Используем драйвер для modernc.org/sqlite
 	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	// Драйвер для PostgreSQL
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	// Драйвер для файловых миграций
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/storage/postgres"
+	"github.com/m1al04949/sso-gRPC/internal/storage/sqlite"
 	// Регистрируем драйвер SQLite
 	_ "modernc.org/sqlite"
 )
 
+// emailDuplicatesVersion is the migration that makes emails case-insensitive
+const emailDuplicatesVersion = 9
+
 func main() {
-	var storagePath, migrationsPath, migrationsTable string
+	var driver, storagePath, dsn, migrationsPath, migrationsTable, configPath string
+	var encryptSecrets bool
 
-	flag.StringVar(&storagePath, "storage-path", "", "path to storage")
+	flag.StringVar(&driver, "driver", "sqlite", "storage driver: sqlite or postgres")
+	flag.StringVar(&storagePath, "storage-path", "", "path to sqlite storage")
+	flag.StringVar(&dsn, "dsn", "", "postgres connection URL")
 	flag.StringVar(&migrationsPath, "migrations-path", "", "path to migrations")
 	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrations table")
+	flag.StringVar(&configPath, "config", "", "path to config file, required by --encrypt-secrets")
+	flag.BoolVar(&encryptSecrets, "encrypt-secrets", false,
+		"encrypt plaintext app secrets and re-encrypt secrets with the current KEK")
 	flag.Parse()
 
-	if storagePath == "" {
-		panic("storage-path is required")
-	}
 	if migrationsPath == "" {
 		panic("migrations-path is required")
 	}
-	m, err := migrate.New(
-		"file://"+migrationsPath,
-		fmt.Sprintf("sqlite://%s?x-migrations-table=%s", storagePath, migrationsTable),
-	)
+
+	var databaseURL string
+	switch driver {
+	case "sqlite":
+		if storagePath == "" {
+			panic("storage-path is required")
+		}
+		databaseURL = fmt.Sprintf("sqlite://%s?x-migrations-table=%s", storagePath, migrationsTable)
+	case "postgres":
+		if dsn == "" {
+			panic("dsn is required")
+		}
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		databaseURL = dsn + separator + "x-migrations-table=" + migrationsTable
+	default:
+		panic(fmt.Sprintf("unknown driver %q", driver))
+	}
+
+	m, err := migrate.New("file://"+migrationsPath, databaseURL)
 	if err != nil {
 		panic(err)
 	}
 
 	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("no migrations to apply")
-
-			return
+		if !errors.Is(err, migrate.ErrNoChange) {
+			panic(err)
 		}
 
+		fmt.Println("no migrations to apply")
+	} else {
+		fmt.Println("migrations applied successfully")
+	}
+
+	// Postgres schema starts with case-insensitive emails
+	if version, _, err := m.Version(); err == nil && driver == "sqlite" && version >= emailDuplicatesVersion {
+		mustReportEmailDuplicates(storagePath)
+	}
+
+	if encryptSecrets {
+		mustEncryptSecrets(driver, storagePath, dsn, configPath)
+	}
+}
+
+// secretsStorage re-encrypts secrets stored by any storage driver
+type secretsStorage interface {
+	ReencryptAppSecrets(ctx context.Context) (int, error)
+	ReencryptWebhookSecrets(ctx context.Context) (int, error)
+	Close()
+}
+
+// mustEncryptSecrets converts app secrets to the current KEK
+func mustEncryptSecrets(driver, storagePath, dsn, configPath string) {
+	if configPath == "" {
+		panic("config is required to encrypt secrets")
+	}
+	cfg := config.MustLoadByPath(configPath)
+
+	keyring, err := secrets.FromConfig(cfg.Secrets)
+	if err != nil {
+		panic(err)
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	dbCfg := cfg.DB
+	dbCfg.StoragePath = storagePath
+	dbCfg.DSN = dsn
+
+	var storage secretsStorage
+	if driver == "postgres" {
+		storage, err = postgres.New(log, dbCfg, keyring)
+	} else {
+		storage, err = sqlite.New(log, dbCfg, keyring)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Close()
+
+	n, err := storage.ReencryptAppSecrets(context.Background())
+	if err != nil {
 		panic(err)
 	}
 
-	fmt.Println("migrations applied successfully")
+	fmt.Printf("app secrets encrypted: %d\n", n)
+
+	n, err = storage.ReencryptWebhookSecrets(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("webhook secrets re-encrypted: %d\n", n)
+}
+
+// mustReportEmailDuplicates prints users whose emails differ from emails
+// of older users only by case. They have to be resolved by admins
+func mustReportEmailDuplicates(storagePath string) {
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	storage, err := sqlite.New(log, config.DBConfig{StoragePath: storagePath}, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Close()
+
+	duplicates, err := storage.EmailDuplicates(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	if len(duplicates) == 0 {
+		return
+	}
+
+	fmt.Printf("users with case-insensitive duplicate emails: %d\n", len(duplicates))
+	for _, d := range duplicates {
+		fmt.Printf("  user %d (%s) duplicates user %d in namespace %d\n", d.UserID, d.Email, d.KeptUserID, d.OrgID)
+	}
 }
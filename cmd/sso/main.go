@@ -27,11 +27,14 @@ func main() {
 		slog.Any("cfg", cfg))
 
 	// Initialize App
-	appl := app.New(log, cfg.GRPC.Port, cfg.DB, cfg.JWT.TokenTTL)
+	appl := app.New(log, cfg)
 
 	// gRPC Server Run
 	go appl.GRPCSrv.MustRun()
 
+	// Webhook dispatcher Run
+	go appl.Dispatcher.Run()
+
 	//Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -40,6 +43,9 @@ func main() {
 	log.Info("stopping application",
 		slog.String("signal", signalIn.String()))
 
+	// Webhook dispatcher stop
+	appl.Dispatcher.Stop()
+
 	// Storage stop
 	appl.Storage.Close()
 
@@ -2,34 +2,192 @@ package app
 
 import (
 	"log/slog"
-	"time"
+	"net/netip"
 
 	"github.com/m1al04949/sso-gRPC/internal/app/grpcapp"
 	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/events"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/passkey"
+	"github.com/m1al04949/sso-gRPC/internal/lib/risk"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/services/account"
+	"github.com/m1al04949/sso-gRPC/internal/services/admin"
+	"github.com/m1al04949/sso-gRPC/internal/services/apps"
+	"github.com/m1al04949/sso-gRPC/internal/services/audit"
 	"github.com/m1al04949/sso-gRPC/internal/services/auth"
-	"github.com/m1al04949/sso-gRPC/internal/storage/sqlite"
+	"github.com/m1al04949/sso-gRPC/internal/services/groups"
+	"github.com/m1al04949/sso-gRPC/internal/services/invitations"
+	"github.com/m1al04949/sso-gRPC/internal/services/orgs"
+	"github.com/m1al04949/sso-gRPC/internal/services/privacy"
+	"github.com/m1al04949/sso-gRPC/internal/services/profiles"
+	"github.com/m1al04949/sso-gRPC/internal/services/webhooks"
 )
 
 type App struct {
-	GRPCSrv *grpcapp.App
-	Storage *sqlite.Storage
+	GRPCSrv    *grpcapp.App
+	Dispatcher *webhooks.Dispatcher
+	Storage    Storage
 }
 
-func New(log *slog.Logger, grpcPort int, dbCfg config.DBConfig, tokenTTL time.Duration) *App {
+func New(log *slog.Logger, cfg *config.Config) *App {
+	// Init keyring for app secrets
+	keyring, err := secrets.FromConfig(cfg.Secrets)
+	if err != nil {
+		panic(err)
+	}
+
+	// Init notifiers of emails and text messages
+	notify, err := notifier.New(log, cfg.Notifier)
+	if err != nil {
+		panic(err)
+	}
+	smsNotify, err := notifier.New(log, cfg.SMSNotifier)
+	if err != nil {
+		panic(err)
+	}
+	channels := notifier.Channels{
+		notifier.ChannelEmail: notify,
+		notifier.ChannelSMS:   smsNotify,
+	}
+
+	// Init relying party of passkeys
+	relyingParty, err := passkey.New(passkey.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		Timeout:       cfg.WebAuthn.Timeout,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Init networks logins from are never risky
+	trustedNetworks := make([]netip.Prefix, 0, len(cfg.Risk.TrustedNetworks))
+	for _, network := range cfg.Risk.TrustedNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			panic(err)
+		}
+		trustedNetworks = append(trustedNetworks, prefix)
+	}
+
+	// Init event bus. It is fed by the outbox dispatcher below
+	bus := events.New(log, cfg.Events.HistorySize)
+
 	// Init storage
-	storage, err := sqlite.New(log, dbCfg)
+	storage, err := newStorage(log, cfg.DB, keyring)
 	if err != nil {
 		panic(err)
 	}
+	storage = newCachedStorage(log, storage, cfg.Cache)
 
 	// Init auth service
-	authService := auth.New(log, storage, storage, storage, tokenTTL)
+	authService := auth.New(log, storage, relyingParty, channels, auth.Config{
+		TokenTTL:          cfg.JWT.TokenTTL,
+		AdminAppID:        cfg.Admin.AppID,
+		EmailUniquePerOrg: cfg.Orgs.EmailUniquePerOrg,
+		IncludeGroups:     cfg.JWT.IncludeGroups,
+		ClaimsMetadata:    cfg.JWT.ClaimsMetadata,
+		MagicLinkTTL:      cfg.MagicLink.TTL,
+		MagicLinkURL:      cfg.MagicLink.URL,
+		OTP: auth.OTPConfig{
+			TTL:            cfg.OTP.TTL,
+			Digits:         cfg.OTP.Digits,
+			MaxAttempts:    cfg.OTP.MaxAttempts,
+			ResendInterval: cfg.OTP.ResendInterval,
+			MaxSends:       cfg.OTP.MaxSends,
+		},
+		WebAuthnTimeout: cfg.WebAuthn.Timeout,
+		Risk: auth.RiskConfig{
+			Config: risk.Config{
+				TrustedNetworks: trustedNetworks,
+				DormantAfter:    cfg.Risk.DormantAfter,
+				FailureWindow:   cfg.Risk.FailureWindow,
+			},
+			NotifyScore:      cfg.Risk.NotifyScore,
+			MFAScore:         cfg.Risk.MFAScore,
+			HistoryRetention: cfg.Risk.HistoryRetention,
+		},
+		TrustedDeviceTTL: cfg.TrustedDevices.TTL,
+		StepUp: auth.StepUpConfig{
+			MaxAge:   cfg.StepUp.MaxAge,
+			TokenTTL: cfg.StepUp.TokenTTL,
+		},
+	})
+
+	// Init audit log service
+	auditService := audit.New(log, storage, storage, audit.Config{
+		HashChain: cfg.Audit.HashChain,
+	})
+
+	// Init admin service
+	adminService := admin.New(log, storage, storage, admin.Config{
+		DeletedRetention: cfg.Users.DeletedRetention,
+	})
+
+	// Init apps registry service
+	appsService := apps.New(log, storage, storage, cfg.Apps.SecretGracePeriod)
+
+	// Init organizations service
+	orgsService := orgs.New(log, storage, storage, storage, storage)
+
+	// Init groups service
+	groupsService := groups.New(log, storage, storage)
+
+	// Init invitations service
+	invitationsService := invitations.New(log,
+		storage,
+		storage,
+		storage,
+		authService,
+		orgsService,
+		notify,
+		cfg.Invitations.TTL,
+	)
+
+	// Init profiles service
+	profilesService := profiles.New(log, storage, storage, storage, storage)
+
+	// Init account service
+	accountService := account.New(log, storage, storage, authService, storage, notify, account.Config{
+		EmailChangeTTL: cfg.Account.EmailChangeTTL,
+	})
+
+	// Init privacy service
+	privacyService := privacy.New(log, storage, storage, authService)
+
+	// Init webhooks service and dispatcher of outbox events
+	webhooksService := webhooks.New(log, storage)
+	dispatcher := webhooks.NewDispatcher(log, storage, storage, bus, webhooks.DispatcherConfig{
+		PollInterval:   cfg.Webhooks.PollInterval,
+		Timeout:        cfg.Webhooks.Timeout,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		RetryBaseDelay: cfg.Webhooks.RetryBaseDelay,
+		RetryMaxDelay:  cfg.Webhooks.RetryMaxDelay,
+	})
 
 	// Init app
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+	grpcApp := grpcapp.New(log,
+		authService,
+		auditService,
+		adminService,
+		appsService,
+		orgsService,
+		groupsService,
+		invitationsService,
+		profilesService,
+		accountService,
+		privacyService,
+		bus,
+		webhooksService,
+		cfg.Risk.DeviceMetadataKey,
+		cfg.GRPC.Port,
+	)
 
 	return &App{
-		GRPCSrv: grpcApp,
-		Storage: storage,
+		GRPCSrv:    grpcApp,
+		Dispatcher: dispatcher,
+		Storage:    storage,
 	}
 }
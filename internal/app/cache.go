@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/lru"
+	"github.com/m1al04949/sso-gRPC/internal/storage/cache"
+)
+
+// cachedStorage serves apps and users by id from cache. Writes which
+// change them go through the caches to invalidate them
+type cachedStorage struct {
+	Storage
+	log   *slog.Logger
+	apps  *cache.Apps
+	users *cache.Users
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// CacheStats are hit, miss and eviction counters of storage caches
+type CacheStats struct {
+	Apps  lru.Stats
+	Users lru.Stats
+}
+
+// newCachedStorage wraps storage with caches unless both are off.
+// Cache stats are logged every cacheCfg.StatsInterval and on close
+func newCachedStorage(log *slog.Logger, storage Storage, cacheCfg config.CacheConfig) Storage {
+	if cacheCfg.AppsSize <= 0 && cacheCfg.UsersSize <= 0 {
+		return storage
+	}
+
+	s := &cachedStorage{
+		Storage: storage,
+		log:     log,
+		apps:    cache.NewApps(storage, cacheCfg.AppsSize, cacheCfg.AppsTTL),
+		users:   cache.NewUsers(storage, cacheCfg.UsersSize, cacheCfg.UsersTTL),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if cacheCfg.StatsInterval > 0 {
+		go s.reportStats(cacheCfg.StatsInterval)
+	} else {
+		close(s.done)
+	}
+
+	return s
+}
+
+// Stats returns counters of storage caches since start
+func (s *cachedStorage) Stats() CacheStats {
+	return CacheStats{Apps: s.apps.Stats(), Users: s.users.Stats()}
+}
+
+func (s *cachedStorage) App(ctx context.Context, appID int) (models.App, error) {
+	return s.apps.App(ctx, appID)
+}
+
+func (s *cachedStorage) UpdateApp(ctx context.Context, appID int, name string) error {
+	return s.apps.UpdateApp(ctx, appID, name)
+}
+
+func (s *cachedStorage) RotateAppSecret(ctx context.Context, appID int, secret string, prevExpiresAt time.Time) error {
+	return s.apps.RotateAppSecret(ctx, appID, secret, prevExpiresAt)
+}
+
+func (s *cachedStorage) DeleteApp(ctx context.Context, appID int) error {
+	return s.apps.DeleteApp(ctx, appID)
+}
+
+func (s *cachedStorage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	return s.users.UserByID(ctx, userID)
+}
+
+func (s *cachedStorage) SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error {
+	return s.users.SetUserIdentifiers(ctx, userID, username, phone)
+}
+
+func (s *cachedStorage) SetUserStatus(
+	ctx context.Context,
+	userID int64,
+	from models.AccountStatus,
+	to models.AccountStatus,
+	reason string,
+	now time.Time,
+) error {
+	return s.users.SetUserStatus(ctx, userID, from, to, reason, now)
+}
+
+func (s *cachedStorage) SetPassResetRequired(ctx context.Context, userID int64, required bool) error {
+	return s.users.SetPassResetRequired(ctx, userID, required)
+}
+
+func (s *cachedStorage) SetPassword(ctx context.Context, userID int64, passHash []byte) error {
+	return s.users.SetPassword(ctx, userID, passHash)
+}
+
+func (s *cachedStorage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	return s.users.SetAdmin(ctx, userID, isAdmin)
+}
+
+func (s *cachedStorage) SetUserMFAChannel(ctx context.Context, userID int64, channel string) error {
+	return s.users.SetUserMFAChannel(ctx, userID, channel)
+}
+
+func (s *cachedStorage) EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error {
+	return s.users.EraseUser(ctx, userID, erasedBy, now)
+}
+
+func (s *cachedStorage) ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error {
+	return s.users.ConfirmEmailChange(ctx, id, now)
+}
+
+func (s *cachedStorage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return s.users.PurgeDeletedUsers(ctx, deletedBefore)
+}
+
+// Close stops stats reporting, logs final stats and closes storage
+func (s *cachedStorage) Close() {
+	close(s.stop)
+	<-s.done
+
+	s.logStats()
+
+	s.Storage.Close()
+}
+
+// reportStats logs cache stats every interval until storage is closed
+func (s *cachedStorage) reportStats(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.logStats()
+		}
+	}
+}
+
+func (s *cachedStorage) logStats() {
+	stats := s.Stats()
+	s.log.Info("storage cache stats",
+		slog.Group("apps",
+			slog.Uint64("hits", stats.Apps.Hits),
+			slog.Uint64("misses", stats.Apps.Misses),
+			slog.Uint64("evictions", stats.Apps.Evictions)),
+		slog.Group("users",
+			slog.Uint64("hits", stats.Users.Hits),
+			slog.Uint64("misses", stats.Users.Misses),
+			slog.Uint64("evictions", stats.Users.Evictions)),
+	)
+}
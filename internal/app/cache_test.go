@@ -0,0 +1,86 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/lru"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a log output safe to read while stats are reported
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestCachedStorageStats(t *testing.T) {
+	s, err := memory.New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.DBConfig{})
+	require.NoError(t, err)
+
+	var out syncBuffer
+	storage := newCachedStorage(slog.New(slog.NewTextHandler(&out, nil)), s, config.CacheConfig{
+		AppsSize:      10,
+		AppsTTL:       time.Minute,
+		UsersSize:     10,
+		UsersTTL:      time.Minute,
+		StatsInterval: 10 * time.Millisecond,
+	})
+	cached, ok := storage.(*cachedStorage)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	appID, err := s.SaveApp(ctx, "app", "secret", 0)
+	require.NoError(t, err)
+	userID, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = cached.App(ctx, appID)
+		require.NoError(t, err)
+	}
+	_, err = cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	_, err = cached.UserByID(ctx, userID+1)
+	require.Error(t, err)
+
+	assert.Equal(t, CacheStats{
+		Apps:  lru.Stats{Hits: 2, Misses: 1},
+		Users: lru.Stats{Misses: 2},
+	}, cached.Stats())
+
+	// Stats are logged while running, not only on close
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "apps.hits=2")
+	}, time.Second, 10*time.Millisecond)
+
+	cached.Close()
+	reports := strings.Count(out.String(), "storage cache stats")
+	assert.GreaterOrEqual(t, reports, 2)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, reports, strings.Count(out.String(), "storage cache stats"), "stats reported after close")
+}
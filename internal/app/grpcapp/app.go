@@ -5,7 +5,22 @@ import (
 	"log/slog"
 	"net"
 
+	accountgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/account"
+	admingrpc "github.com/m1al04949/sso-gRPC/internal/grpc/admin"
+	appsgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/apps"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/audit"
 	authgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/auth"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/clientinfo"
+	devicesgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/devices"
+	eventsgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/events"
+	groupsgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/groups"
+	invitationsgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/invitations"
+	orgsgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/orgs"
+	passkeysgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/passkeys"
+	privacygrpc "github.com/m1al04949/sso-gRPC/internal/grpc/privacy"
+	profilesgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/profiles"
+	webhooksgrpc "github.com/m1al04949/sso-gRPC/internal/grpc/webhooks"
 
 	"google.golang.org/grpc"
 )
@@ -16,10 +31,110 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
-	gRPCServer := grpc.NewServer()
+// AuthService is used for auth, passkeys and trusted devices APIs and
+// for authorization of other APIs
+type AuthService interface {
+	authgrpc.Auth
+	passkeysgrpc.Passkeys
+	devicesgrpc.TrustedDevices
+	authz.Authorizer
+}
+
+// AuditService records calls and gives admins access to the audit log
+type AuditService interface {
+	audit.Recorder
+	admingrpc.AuditLog
+}
+
+func New(
+	log *slog.Logger,
+	authService AuthService,
+	auditService AuditService,
+	adminService admingrpc.Admin,
+	appsService appsgrpc.Apps,
+	orgsService orgsgrpc.Orgs,
+	groupsService groupsgrpc.Groups,
+	invitationsService invitationsgrpc.Invitations,
+	profilesService profilesgrpc.Profiles,
+	accountService accountgrpc.Account,
+	privacyService privacygrpc.Privacy,
+	eventWatcher eventsgrpc.Watcher,
+	webhooksService webhooksgrpc.Webhooks,
+	deviceMetadataKey string,
+	port int,
+) *App {
+	// Access rules by service or full method name
+	rules := map[string]authz.Level{
+		authgrpc.ReauthenticateMethod: authz.LevelUser,
+		authgrpc.ChangePasswordMethod: authz.LevelUser,
+
+		admingrpc.ServiceName:  authz.LevelAdmin,
+		appsgrpc.ServiceName:   authz.LevelAdmin,
+		orgsgrpc.ServiceName:   authz.LevelUser,
+		groupsgrpc.ServiceName: authz.LevelAdmin,
+
+		invitationsgrpc.ServiceName:  authz.LevelUser,
+		invitationsgrpc.AcceptMethod: authz.LevelPublic,
+
+		profilesgrpc.ServiceName: authz.LevelUser,
+
+		accountgrpc.ServiceName:              authz.LevelUser,
+		accountgrpc.ConfirmEmailChangeMethod: authz.LevelPublic,
+		accountgrpc.CancelEmailChangeMethod:  authz.LevelPublic,
+
+		privacygrpc.ServiceName:  authz.LevelUser,
+		passkeysgrpc.ServiceName: authz.LevelUser,
+		devicesgrpc.ServiceName:  authz.LevelUser,
+
+		eventsgrpc.ServiceName:   authz.LevelAdmin,
+		webhooksgrpc.ServiceName: authz.LevelAdmin,
+	}
+
+	// Sensitive services and methods require recent strong authentication.
+	// Events stream outlives any step-up, so it isn't listed
+	stepUp := map[string]bool{
+		admingrpc.ServiceName:    true,
+		appsgrpc.ServiceName:     true,
+		groupsgrpc.ServiceName:   true,
+		webhooksgrpc.ServiceName: true,
+
+		authgrpc.ChangePasswordMethod:               true,
+		accountgrpc.ChangeEmailMethod:               true,
+		accountgrpc.SetMFAMethod:                    true,
+		privacygrpc.ExportUserDataMethod:            true,
+		privacygrpc.EraseUserMethod:                 true,
+		passkeysgrpc.BeginPasskeyRegistrationMethod: true,
+		passkeysgrpc.DeletePasskeyMethod:            true,
+		devicesgrpc.RevokeTrustedDeviceMethod:       true,
+		devicesgrpc.RevokeAllTrustedDevicesMethod:   true,
+	}
+
+	// Audit goes first to record calls denied by authorization
+	gRPCServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			audit.UnaryServerInterceptor(auditService),
+			authz.UnaryServerInterceptor(authService, rules, stepUp),
+			clientinfo.UnaryServerInterceptor(deviceMetadataKey),
+		),
+		grpc.ChainStreamInterceptor(
+			audit.StreamServerInterceptor(auditService),
+			authz.StreamServerInterceptor(authService, rules, stepUp),
+		),
+	)
 
 	authgrpc.Register(gRPCServer, authService)
+	admingrpc.Register(gRPCServer, adminService, auditService)
+	appsgrpc.Register(gRPCServer, appsService)
+	orgsgrpc.Register(gRPCServer, orgsService)
+	groupsgrpc.Register(gRPCServer, groupsService)
+	invitationsgrpc.Register(gRPCServer, invitationsService)
+	profilesgrpc.Register(gRPCServer, profilesService)
+	accountgrpc.Register(gRPCServer, accountService)
+	privacygrpc.Register(gRPCServer, privacyService)
+	passkeysgrpc.Register(gRPCServer, authService)
+	devicesgrpc.Register(gRPCServer, authService)
+	eventsgrpc.Register(gRPCServer, eventWatcher)
+	webhooksgrpc.Register(gRPCServer, webhooksService)
 
 	return &App{
 		log:        log,
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/services/account"
+	"github.com/m1al04949/sso-gRPC/internal/services/admin"
+	"github.com/m1al04949/sso-gRPC/internal/services/apps"
+	"github.com/m1al04949/sso-gRPC/internal/services/audit"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/services/groups"
+	"github.com/m1al04949/sso-gRPC/internal/services/invitations"
+	"github.com/m1al04949/sso-gRPC/internal/services/orgs"
+	"github.com/m1al04949/sso-gRPC/internal/services/privacy"
+	"github.com/m1al04949/sso-gRPC/internal/services/profiles"
+	"github.com/m1al04949/sso-gRPC/internal/services/webhooks"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/m1al04949/sso-gRPC/internal/storage/postgres"
+	"github.com/m1al04949/sso-gRPC/internal/storage/sqlite"
+)
+
+// Storage is implemented by every storage backend
+type Storage interface {
+	auth.Storage
+	audit.EventSaver
+	audit.EventProvider
+	admin.UserProvider
+	admin.UserManager
+	apps.AppProvider
+	apps.AppManager
+	orgs.OrgProvider
+	orgs.OrgManager
+	orgs.UserProvider
+	groups.GroupProvider
+	groups.GroupManager
+	invitations.InvitationStorage
+	invitations.AppProvider
+	invitations.GrantManager
+	profiles.ProfileProvider
+	profiles.ProfileSaver
+	profiles.UserProvider
+	profiles.IdentifierSaver
+	account.UserProvider
+	account.EmailChangeStorage
+	account.MFAStorage
+	privacy.DataProvider
+	privacy.Eraser
+	webhooks.Outbox
+	webhooks.DeliveryStorage
+	webhooks.WebhookStorage
+	Close()
+}
+
+// newStorage opens storage backend selected by db.driver
+func newStorage(log *slog.Logger, dbCfg config.DBConfig, keyring *secrets.Keyring) (Storage, error) {
+	switch dbCfg.Driver {
+	case "sqlite":
+		storage, err := sqlite.New(log, dbCfg, keyring)
+		if err != nil {
+			return nil, err
+		}
+
+		return storage, nil
+	case "postgres":
+		storage, err := postgres.New(log, dbCfg, keyring)
+		if err != nil {
+			return nil, err
+		}
+
+		return storage, nil
+	case "memory":
+		storage, err := memory.New(log, dbCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return storage, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q", dbCfg.Driver)
+}
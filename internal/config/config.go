@@ -1,7 +1,9 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -11,20 +13,203 @@ import (
 )
 
 type Config struct {
-	Env  string     `yaml:"env" env-default:"local"`
-	DB   DBConfig   `yaml:"db"`
-	JWT  JWTConfig  `yaml:"jwt"`
-	GRPC GRPCConfig `yaml:"grpc"`
+	Env            string               `yaml:"env" env-default:"local"`
+	DB             DBConfig             `yaml:"db"`
+	JWT            JWTConfig            `yaml:"jwt"`
+	GRPC           GRPCConfig           `yaml:"grpc"`
+	Admin          AdminConfig          `yaml:"admin"`
+	Apps           AppsConfig           `yaml:"apps"`
+	Secrets        SecretsConfig        `yaml:"secrets"`
+	Orgs           OrgsConfig           `yaml:"orgs"`
+	Notifier       NotifierConfig       `yaml:"notifier"`
+	SMSNotifier    NotifierConfig       `yaml:"sms_notifier"`
+	Invitations    InvitationsConfig    `yaml:"invitations"`
+	Account        AccountConfig        `yaml:"account"`
+	Users          UsersConfig          `yaml:"users"`
+	Audit          AuditConfig          `yaml:"audit"`
+	Events         EventsConfig         `yaml:"events"`
+	Webhooks       WebhooksConfig       `yaml:"webhooks"`
+	MagicLink      MagicLinkConfig      `yaml:"magic_link"`
+	OTP            OTPConfig            `yaml:"otp"`
+	WebAuthn       WebAuthnConfig       `yaml:"webauthn"`
+	Risk           RiskConfig           `yaml:"risk"`
+	TrustedDevices TrustedDevicesConfig `yaml:"trusted_devices"`
+	StepUp         StepUpConfig         `yaml:"step_up"`
+	Cache          CacheConfig          `yaml:"cache"`
 }
 
+// DBConfig selects storage backend: "sqlite" stores data in file at
+// storage_path, "postgres" connects to database by dsn, "memory" keeps
+// data in process memory until it stops and loads apps from seed_path
 type DBConfig struct {
-	StoragePath string `yaml:"storage_path" env-required:"true"`
+	Driver      string `yaml:"driver" env:"SSO_DB_DRIVER" env-default:"sqlite"`
+	StoragePath string `yaml:"storage_path"`
 	BusyTimeout string `yaml:"busy_timeout"`
 	JournalMode string `yaml:"journal_mode"`
+	// Synchronous and ForeignKeys set sqlite pragmas of the same names
+	Synchronous string `yaml:"synchronous"`
+	ForeignKeys bool   `yaml:"foreign_keys"`
+	// MaxReadConns limits sqlite pool of reading connections. Writes
+	// always go through a single connection
+	MaxReadConns int    `yaml:"max_read_conns" env-default:"4"`
+	DSN          string `yaml:"dsn" env:"SSO_DB_DSN"`
+	SeedPath     string `yaml:"seed_path" env:"SSO_DB_SEED_PATH"`
 }
 
 type JWTConfig struct {
 	TokenTTL time.Duration `yaml:"token_ttl" env-required:"true"`
+	// IncludeGroups adds names of user groups to the groups claim
+	IncludeGroups bool `yaml:"include_groups"`
+	// ClaimsMetadata lists admin metadata keys projected into the metadata claim
+	ClaimsMetadata []string `yaml:"claims_metadata"`
+}
+
+// AdminConfig binds admin rights to tokens of one app. Other apps sign
+// tokens with their own secrets and could otherwise act as any admin
+type AdminConfig struct {
+	AppID int `yaml:"app_id" env:"SSO_ADMIN_APP_ID" env-required:"true"`
+}
+
+type AppsConfig struct {
+	SecretGracePeriod time.Duration `yaml:"secret_grace_period" env-default:"24h"`
+}
+
+type OrgsConfig struct {
+	// EmailUniquePerOrg allows the same email to be registered
+	// in different organizations as separate users
+	EmailUniquePerOrg bool `yaml:"email_unique_per_org"`
+}
+
+// NotifierConfig selects how notifications are delivered:
+// "log" writes them to the log, "file" appends them to file_path.
+// Notifier sends emails, SMS notifier sends text messages
+type NotifierConfig struct {
+	Type     string `yaml:"type" env-default:"log"`
+	FilePath string `yaml:"file_path"`
+}
+
+type InvitationsConfig struct {
+	TTL time.Duration `yaml:"ttl" env-default:"72h"`
+}
+
+type UsersConfig struct {
+	// DeletedRetention is how long deleted users can be restored before purge
+	DeletedRetention time.Duration `yaml:"deleted_retention" env-default:"720h"`
+}
+
+type AuditConfig struct {
+	// HashChain links audit events by hash to detect tampering
+	HashChain bool `yaml:"hash_chain"`
+}
+
+type EventsConfig struct {
+	// HistorySize is how many recent events watchers can resume from
+	HistorySize int `yaml:"history_size" env-default:"10000"`
+}
+
+// WebhooksConfig tunes webhook dispatcher. Delay before retry doubles
+// after each failed attempt up to retry_max_delay
+type WebhooksConfig struct {
+	PollInterval   time.Duration `yaml:"poll_interval" env-default:"500ms"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"10s"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"8"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" env-default:"10s"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay" env-default:"1h"`
+}
+
+// MagicLinkConfig tunes passwordless login links. URL is the page links
+// lead to, it passes code and app_id from its query to ConsumeMagicLink
+type MagicLinkConfig struct {
+	TTL time.Duration `yaml:"ttl" env-default:"15m"`
+	URL string        `yaml:"url" env-default:"http://localhost/magic-link"`
+}
+
+// OTPConfig tunes one-time codes of passwordless login and second factor.
+// Attempts are counted across resends of the code
+type OTPConfig struct {
+	TTL            time.Duration `yaml:"ttl" env-default:"5m"`
+	Digits         int           `yaml:"digits" env-default:"6"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"5"`
+	ResendInterval time.Duration `yaml:"resend_interval" env-default:"30s"`
+	MaxSends       int           `yaml:"max_sends" env-default:"3"`
+}
+
+// WebAuthnConfig describes relying party of passkeys. RPID is the domain
+// passkeys are scoped to, RPOrigins are origins of pages that run
+// ceremonies. Timeout limits how long a ceremony can be finished
+type WebAuthnConfig struct {
+	RPID          string        `yaml:"rp_id" env-default:"localhost"`
+	RPDisplayName string        `yaml:"rp_display_name" env-default:"SSO"`
+	RPOrigins     []string      `yaml:"rp_origins" env-default:"http://localhost"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"5m"`
+}
+
+// RiskConfig tunes risk-based login. Device fingerprint is read from
+// gRPC metadata by DeviceMetadataKey, trusted networks are CIDRs. Logins
+// scored at least MFAScore require second factor, logins scored at least
+// NotifyScore are reported to user. Zero score turns the action off
+type RiskConfig struct {
+	DeviceMetadataKey string        `yaml:"device_metadata_key" env-default:"x-device-fingerprint"`
+	TrustedNetworks   []string      `yaml:"trusted_networks"`
+	DormantAfter      time.Duration `yaml:"dormant_after" env-default:"720h"`
+	FailureWindow     time.Duration `yaml:"failure_window" env-default:"15m"`
+	NotifyScore       int           `yaml:"notify_score" env-default:"40"`
+	MFAScore          int           `yaml:"mfa_score" env-default:"70"`
+	HistoryRetention  time.Duration `yaml:"history_retention" env-default:"2160h"`
+}
+
+// TrustedDevicesConfig tunes devices users skip second factor on. TTL
+// is how long device stays trusted
+type TrustedDevicesConfig struct {
+	TTL time.Duration `yaml:"ttl" env-default:"720h"`
+}
+
+// StepUpConfig tunes step-up authentication. Sensitive operations
+// require authentication made within MaxAge, tokens issued by
+// reauthentication live for TokenTTL
+type StepUpConfig struct {
+	MaxAge   time.Duration `yaml:"max_age" env-default:"10m"`
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"5m"`
+}
+
+// CacheConfig bounds in-memory caches of apps and users by id. Changes
+// made by other instances are seen once cached values expire after TTL.
+// Zero size turns cache off. Hit and miss counters are logged every
+// StatsInterval, zero logs them only on shutdown
+type CacheConfig struct {
+	AppsSize      int           `yaml:"apps_size" env-default:"1000"`
+	AppsTTL       time.Duration `yaml:"apps_ttl" env-default:"5m"`
+	UsersSize     int           `yaml:"users_size" env-default:"10000"`
+	UsersTTL      time.Duration `yaml:"users_ttl" env-default:"30s"`
+	StatsInterval time.Duration `yaml:"stats_interval" env-default:"5m"`
+}
+
+type AccountConfig struct {
+	EmailChangeTTL time.Duration `yaml:"email_change_ttl" env-default:"24h"`
+}
+
+// SecretsConfig holds key encryption keys (KEK) used to encrypt app
+// secrets at rest. Keys are base64 encoded 32 bytes. RequireEncrypted
+// fails reads of secrets still stored in plaintext, turn it on once
+// migrator encrypted them
+type SecretsConfig struct {
+	KEKID            string            `yaml:"kek_id" env:"SSO_KEK_ID" env-default:"default"`
+	KEK              string            `yaml:"kek" env:"SSO_KEK"`
+	KEKFile          string            `yaml:"kek_file" env:"SSO_KEK_FILE"`
+	PreviousKEKs     map[string]string `yaml:"previous_keks"`
+	RequireEncrypted bool              `yaml:"require_encrypted" env:"SSO_SECRETS_REQUIRE_ENCRYPTED"`
+}
+
+// String hides keys from logs, config is logged on startup
+func (c SecretsConfig) String() string {
+	return fmt.Sprintf("{KEKID:%s KEKFile:%s}", c.KEKID, c.KEKFile)
+}
+
+func (c SecretsConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		KEKID   string
+		KEKFile string
+	}{c.KEKID, c.KEKFile})
 }
 
 type GRPCConfig struct {
@@ -1,7 +1,25 @@
 package models
 
+import "time"
+
 type App struct {
 	ID     int
 	Name   string
 	Secret string
+	// OrgID is the organization app belongs to, 0 for global apps
+	OrgID int64
+	// PrevSecret is the secret replaced by the last rotation.
+	// Tokens signed with it stay valid until PrevSecretExpiresAt
+	PrevSecret          string
+	PrevSecretExpiresAt time.Time
+}
+
+// VerificationSecrets returns secrets tokens of the app can be verified with
+func (a App) VerificationSecrets(now time.Time) []string {
+	secrets := []string{a.Secret}
+	if a.PrevSecret != "" && now.Before(a.PrevSecretExpiresAt) {
+		secrets = append(secrets, a.PrevSecret)
+	}
+
+	return secrets
 }
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEvent is a record of security relevant action. Action is a full
+// gRPC method name, Result is a gRPC status code name. Target refers to
+// the object of action as "kind:value", e.g. "user:42". Zero ActorID
+// means the actor is anonymous
+type AuditEvent struct {
+	ID        int64
+	Time      time.Time
+	ActorID   int64
+	Action    string
+	Target    string
+	AppID     int
+	IP        string
+	UserAgent string
+	Result    string
+	// PrevHash and Hash chain events to detect tampering. They are empty
+	// for events recorded with hash chaining off
+	PrevHash string
+	Hash     string
+	// Redacted event had personal data of erased user removed, its
+	// Hash no longer matches the content
+	Redacted bool
+}
+
+// Kinds of audit event targets
+const (
+	AuditTargetUser       = "user"
+	AuditTargetIdentifier = "identifier"
+	AuditTargetApp        = "app"
+	AuditTargetOrg        = "org"
+	AuditTargetGroup      = "group"
+	AuditTargetInvitation = "invitation"
+	AuditTargetWebhook    = "webhook"
+)
+
+// AuditTarget formats target of audit event
+func AuditTarget(kind string, value any) string {
+	return fmt.Sprintf("%s:%v", kind, value)
+}
+
+// AuditFilter narrows audit events down. Zero fields match any
+type AuditFilter struct {
+	ActorID int64
+	Action  string
+	Target  string
+	AppID   int
+	Result  string
+	From    time.Time
+	To      time.Time
+}
+
+// ChainHash returns hash of event linked to the hash of previous event.
+// Event id is assigned on insert and is not hashed, order of events is
+// fixed by the chain itself
+func (e AuditEvent) ChainHash(prevHash string) string {
+	fields := []string{
+		prevHash,
+		strconv.FormatInt(e.Time.Unix(), 10),
+		strconv.FormatInt(e.ActorID, 10),
+		e.Action,
+		e.Target,
+		strconv.Itoa(e.AppID),
+		e.IP,
+		e.UserAgent,
+		e.Result,
+	}
+
+	// Unit separator keeps field boundaries unambiguous
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEvent_ChainHash(t *testing.T) {
+	event := AuditEvent{
+		Time:    time.Unix(1700000000, 0),
+		ActorID: 1,
+		Action:  "/auth.Auth/Login",
+		Target:  AuditTarget(AuditTargetIdentifier, "user@example.com"),
+		Result:  "OK",
+	}
+
+	hash := event.ChainHash("")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, event.ChainHash(""))
+	assert.NotEqual(t, hash, event.ChainHash("prev"))
+
+	// Event id is not part of the hash
+	event.ID = 42
+	assert.Equal(t, hash, event.ChainHash(""))
+
+	event.Result = "Unauthenticated"
+	assert.NotEqual(t, hash, event.ChainHash(""))
+}
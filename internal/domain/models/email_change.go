@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// EmailChange is a request to change user email. It is applied once
+// the new address is confirmed and can be cancelled from the old one.
+// Zero time fields are not set
+type EmailChange struct {
+	ID          int64
+	UserID      int64
+	OldEmail    string
+	NewEmail    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	ConfirmedAt time.Time
+	CancelledAt time.Time
+}
+
+// Pending reports whether email change can still be confirmed or cancelled
+func (c EmailChange) Pending(now time.Time) bool {
+	return c.ConfirmedAt.IsZero() && c.CancelledAt.IsZero() && now.Before(c.ExpiresAt)
+}
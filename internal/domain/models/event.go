@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Types of auth events
+const (
+	EventUserRegistered        = "user.registered"
+	EventUserLoggedIn          = "user.logged_in"
+	EventUserLoginFailed       = "user.login_failed"
+	EventUserReauthenticated   = "user.reauthenticated"
+	EventUserPassResetRequired = "user.password_reset_required"
+	EventUserPasswordChanged   = "user.password_changed"
+	// EventUserStatusChanged revokes access of users that are no longer active
+	EventUserStatusChanged = "user.status_changed"
+)
+
+// ValidEventType reports whether t is a known type of event
+func ValidEventType(t string) bool {
+	switch t {
+	case EventUserRegistered,
+		EventUserLoggedIn,
+		EventUserLoginFailed,
+		EventUserReauthenticated,
+		EventUserPassResetRequired,
+		EventUserPasswordChanged,
+		EventUserStatusChanged:
+		return true
+	}
+
+	return false
+}
+
+// Event is a notification about something that happened to user.
+// ID is a sequence number assigned by outbox or event bus. Zero UserID
+// and AppID mean the event is not related to particular user or app
+type Event struct {
+	ID     int64
+	Type   string
+	Time   time.Time
+	UserID int64
+	OrgID  int64
+	AppID  int
+	// Data holds details specific to event type
+	Data map[string]string
+}
+
+// EventFilter narrows events down. Zero fields match any
+type EventFilter struct {
+	Types []string
+	AppID int
+}
+
+// Match reports whether event passes filter
+func (f EventFilter) Match(event Event) bool {
+	if f.AppID != 0 && event.AppID != f.AppID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+
+	return false
+}
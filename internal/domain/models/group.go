@@ -0,0 +1,10 @@
+package models
+
+// RoleAdmin assigned directly or through a group grants admin rights
+const RoleAdmin = "admin"
+
+type Group struct {
+	ID    int64
+	Name  string
+	Roles []string
+}
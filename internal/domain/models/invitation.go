@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Invitation invites user by email to an organization or an app
+// with pre-assigned role. Zero time fields are not set
+type Invitation struct {
+	ID         int64
+	Email      string
+	OrgID      int64
+	AppID      int
+	Role       string
+	CreatedBy  int64
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	AcceptedAt time.Time
+	AcceptedBy int64
+	RevokedAt  time.Time
+}
+
+// Pending reports whether invitation can still be accepted
+func (i Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt.IsZero() && i.RevokedAt.IsZero() && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoginAttempt is a password check or a completed login of user. History
+// of attempts is what risk of new login is assessed against. DeviceHash
+// is hash of device fingerprint sent by client, empty if none was sent
+type LoginAttempt struct {
+	ID         int64
+	UserID     int64
+	AppID      int
+	DeviceHash string
+	IP         string
+	UserAgent  string
+	Succeeded  bool
+	CreatedAt  time.Time
+}
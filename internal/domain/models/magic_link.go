@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MagicLink lets user log in to the app without password. Link is
+// single-use, zero ConsumedAt means it wasn't used yet
+type MagicLink struct {
+	ID         int64
+	UserID     int64
+	AppID      int
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	ConsumedAt time.Time
+}
@@ -0,0 +1,17 @@
+package models
+
+const (
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+type Org struct {
+	ID   int64
+	Name string
+}
+
+type OrgMember struct {
+	OrgID  int64
+	UserID int64
+	Role   string
+}
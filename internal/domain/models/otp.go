@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// OTPPurpose is what one-time code confirms
+type OTPPurpose string
+
+const (
+	// OTPPurposeLogin is a passwordless login
+	OTPPurposeLogin OTPPurpose = "login"
+	// OTPPurposeMFA is a second factor of login with password
+	OTPPurposeMFA OTPPurpose = "mfa"
+	// OTPPurposePasswordlessMFA is a second factor of login with
+	// magic link or one-time code
+	OTPPurposePasswordlessMFA OTPPurpose = "passwordless_mfa"
+	// OTPPurposeStepUp is a second factor of reauthentication
+	OTPPurposeStepUp OTPPurpose = "step_up"
+)
+
+// OTPChallenge is a one-time code sent to user through a notification
+// channel. Code can be resent, attempts to enter it are counted across
+// all sends. Zero ConsumedAt means the code wasn't used yet
+type OTPChallenge struct {
+	ID         int64
+	UserID     int64
+	AppID      int
+	Purpose    OTPPurpose
+	Channel    string
+	CodeHash   string
+	Attempts   int
+	Sends      int
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastSentAt time.Time
+	ConsumedAt time.Time
+}
+
+// Pending reports whether code of challenge can still be entered
+func (c OTPChallenge) Pending(now time.Time) bool {
+	return c.ConsumedAt.IsZero() && now.Before(c.ExpiresAt)
+}
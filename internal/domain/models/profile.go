@@ -0,0 +1,27 @@
+package models
+
+// Profile is user information that is not used for authentication
+type Profile struct {
+	UserID      int64
+	DisplayName string
+	Locale      string
+	TimeZone    string
+	AvatarURL   string
+	// Metadata is edited by the user
+	Metadata map[string]any
+	// AdminMetadata is edited by admins only and is read-only for the user
+	AdminMetadata map[string]any
+}
+
+// ClaimsMetadata returns values of given admin metadata keys. Relying
+// parties trust signed claims, so user metadata is never projected
+func (p Profile) ClaimsMetadata(keys []string) map[string]any {
+	claims := make(map[string]any)
+	for _, key := range keys {
+		if v, ok := p.AdminMetadata[key]; ok {
+			claims[key] = v
+		}
+	}
+
+	return claims
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile_ClaimsMetadata(t *testing.T) {
+	p := Profile{
+		Metadata:      map[string]any{"plan": "enterprise", "department": "sales", "theme": "dark"},
+		AdminMetadata: map[string]any{"department": "support"},
+	}
+
+	// User can't put own values into signed claims
+	assert.Equal(t, map[string]any{"department": "support"}, p.ClaimsMetadata([]string{"department", "plan"}))
+	assert.Empty(t, p.ClaimsMetadata([]string{"theme"}))
+}
@@ -0,0 +1,42 @@
+package models
+
+// AccountStatus is a state of user account. Only active users can log in
+type AccountStatus string
+
+const (
+	StatusPendingVerification AccountStatus = "pending_verification"
+	StatusActive              AccountStatus = "active"
+	StatusSuspended           AccountStatus = "suspended"
+	StatusLocked              AccountStatus = "locked"
+	StatusDeleted             AccountStatus = "deleted"
+)
+
+// StatusReasonErased is the reason of deleted status of erased users
+const StatusReasonErased = "erased"
+
+// statusTransitions lists statuses account can move to from each status
+var statusTransitions = map[AccountStatus][]AccountStatus{
+	StatusPendingVerification: {StatusActive, StatusDeleted},
+	StatusActive:              {StatusPendingVerification, StatusSuspended, StatusLocked, StatusDeleted},
+	StatusSuspended:           {StatusActive, StatusDeleted},
+	StatusLocked:              {StatusActive, StatusSuspended, StatusDeleted},
+	StatusDeleted:             {StatusActive},
+}
+
+// Valid reports whether status is known
+func (s AccountStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+
+	return ok
+}
+
+// CanTransitionTo reports whether account can move from s to status
+func (s AccountStatus) CanTransitionTo(status AccountStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == status {
+			return true
+		}
+	}
+
+	return false
+}
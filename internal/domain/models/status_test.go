@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountStatus_CanTransitionTo(t *testing.T) {
+	assert.True(t, StatusActive.CanTransitionTo(StatusSuspended))
+	assert.True(t, StatusSuspended.CanTransitionTo(StatusActive))
+	assert.True(t, StatusDeleted.CanTransitionTo(StatusActive))
+	assert.False(t, StatusDeleted.CanTransitionTo(StatusSuspended))
+	assert.False(t, StatusActive.CanTransitionTo(StatusActive))
+	assert.False(t, AccountStatus("unknown").CanTransitionTo(StatusActive))
+
+	assert.True(t, StatusLocked.Valid())
+	assert.False(t, AccountStatus("unknown").Valid())
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TrustedDevice is a device user passed second factor on and chose to
+// skip it there until ExpiresAt. Device presents a random token stored
+// by hash. UserAgent and IP are of the call that trusted the device
+type TrustedDevice struct {
+	ID         int64
+	UserID     int64
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+}
@@ -1,7 +1,48 @@
 package models
 
+import "time"
+
 type User struct {
-	ID       int64
+	ID int64
+	// OrgID is the namespace email is unique in. It is 0 unless
+	// emails are unique per organization
+	OrgID    int64
 	Email    string
+	Username string
+	Phone    string
 	PassHash []byte
+	IsAdmin  bool
+	// Disabled is set unless Status is active
+	Disabled          bool
+	PassResetRequired bool
+	Status            AccountStatus
+	StatusReason      string
+	StatusChangedAt   time.Time
+	// MFAChannel is the channel of one-time codes required after
+	// password, empty if second factor is off
+	MFAChannel string
+	Roles      []string
+	// Groups are names of groups user belongs to
+	Groups []string
+	// Metadata is profile metadata projected into token claims
+	Metadata map[string]any
+}
+
+// UserFilter narrows down users listing. Zero values mean no filtering
+type UserFilter struct {
+	Email    string
+	IsAdmin  *bool
+	Disabled *bool
+	Role     string
+	OrgID    int64
+	Status   AccountStatus
+}
+
+// EmailDuplicate is a user whose email differs from email of an older
+// user only by case. It is found when emails become case-insensitive
+type EmailDuplicate struct {
+	UserID     int64
+	KeptUserID int64
+	OrgID      int64
+	Email      string
 }
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey registered by user. SignCount is the
+// last signature counter reported by authenticator, it detects cloned
+// authenticators. Zero LastUsedAt means the passkey wasn't used yet
+type WebAuthnCredential struct {
+	ID              int64
+	UserID          int64
+	Name            string
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	SignCount       uint32
+	Transports      []string
+	BackupEligible  bool
+	BackupState     bool
+	CreatedAt       time.Time
+	LastUsedAt      time.Time
+}
+
+// WebAuthnSession keeps state of registration or assertion ceremony
+// between its begin and finish calls. Zero UserID means user is not
+// known until the passkey is presented
+type WebAuthnSession struct {
+	ID        int64
+	UserID    int64
+	AppID     int
+	Purpose   WebAuthnPurpose
+	Data      []byte
+	ExpiresAt time.Time
+}
+
+// WebAuthnPurpose is what WebAuthn ceremony is run for
+type WebAuthnPurpose string
+
+const (
+	WebAuthnPurposeRegistration WebAuthnPurpose = "registration"
+	// WebAuthnPurposeLogin is login by passkey alone
+	WebAuthnPurposeLogin WebAuthnPurpose = "login"
+	// WebAuthnPurposeMFA is a second factor of login with password
+	WebAuthnPurposeMFA WebAuthnPurpose = "mfa"
+	// WebAuthnPurposePasswordlessMFA is a second factor of login with
+	// magic link or one-time code
+	WebAuthnPurposePasswordlessMFA WebAuthnPurpose = "passwordless_mfa"
+	// WebAuthnPurposeStepUp is a second factor of reauthentication
+	WebAuthnPurposeStepUp WebAuthnPurpose = "step_up"
+)
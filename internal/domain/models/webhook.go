@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Webhook delivers auth events of its app and events not related to any
+// app to URL. Events of an organization are delivered only to webhooks of
+// its apps. Empty EventTypes subscribe to all event types
+type Webhook struct {
+	ID    int64
+	AppID int
+	// OrgID is organization of the app, zero for apps of no organization
+	OrgID      int64
+	URL        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+}
+
+// Accepts reports whether event is delivered to webhook
+func (w Webhook) Accepts(event Event) bool {
+	if event.AppID != 0 && event.AppID != w.AppID {
+		return false
+	}
+	if event.OrgID != 0 && event.OrgID != w.OrgID {
+		return false
+	}
+
+	return EventFilter{Types: w.EventTypes}.Match(event)
+}
+
+// DeliveryStatus is a state of webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that ran out of attempts. It is retried
+	// only when replayed
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an attempt to deliver event to webhook.
+// Zero time fields are not set
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	Event         Event
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	DeliveredAt   time.Time
+}
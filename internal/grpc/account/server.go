@@ -0,0 +1,129 @@
+package account
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/account"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Account interface {
+	ChangeEmail(ctx context.Context, userID int64, password string, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, code string) error
+	CancelEmailChange(ctx context.Context, code string) error
+	SetMFAChannel(ctx context.Context, userID int64, password string, channel string) error
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAccountServer
+	account Account
+}
+
+var (
+	// ServiceName is used to require authenticated user for account methods
+	ServiceName = ssov1.Account_ServiceDesc.ServiceName
+	// ConfirmEmailChangeMethod is called with code sent by email
+	ConfirmEmailChangeMethod = "/" + ServiceName + "/ConfirmEmailChange"
+	// CancelEmailChangeMethod is called with code sent by email
+	CancelEmailChangeMethod = "/" + ServiceName + "/CancelEmailChange"
+	// ChangeEmailMethod and SetMFAMethod change how user signs in
+	ChangeEmailMethod = "/" + ServiceName + "/ChangeEmail"
+	SetMFAMethod      = "/" + ServiceName + "/SetMFA"
+)
+
+func Register(gRPC *grpc.Server, account Account) {
+	ssov1.RegisterAccountServer(gRPC, &serverAPI{account: account})
+}
+
+func (s *serverAPI) ChangeEmail(ctx context.Context, req *ssov1.ChangeEmailRequest) (*ssov1.ChangeEmailResponse, error) {
+	// Validation
+	if err := validation.ValidateChangeEmail(req); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	if err := s.account.ChangeEmail(ctx, userID, req.GetPassword(), req.GetNewEmail()); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &ssov1.ChangeEmailResponse{}, nil
+}
+
+func (s *serverAPI) ConfirmEmailChange(
+	ctx context.Context,
+	req *ssov1.ConfirmEmailChangeRequest,
+) (*ssov1.ConfirmEmailChangeResponse, error) {
+	// Validation
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if err := s.account.ConfirmEmailChange(ctx, req.GetCode()); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &ssov1.ConfirmEmailChangeResponse{}, nil
+}
+
+func (s *serverAPI) CancelEmailChange(
+	ctx context.Context,
+	req *ssov1.CancelEmailChangeRequest,
+) (*ssov1.CancelEmailChangeResponse, error) {
+	// Validation
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if err := s.account.CancelEmailChange(ctx, req.GetCode()); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &ssov1.CancelEmailChangeResponse{}, nil
+}
+
+func (s *serverAPI) SetMFA(ctx context.Context, req *ssov1.SetMFARequest) (*ssov1.SetMFAResponse, error) {
+	// Validation
+	if req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	if err := s.account.SetMFAChannel(ctx, userID, req.GetPassword(), req.GetChannel()); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &ssov1.SetMFAResponse{}, nil
+}
+
+func accountError(err error) error {
+	switch {
+	case errors.Is(err, account.ErrInvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid password")
+	case errors.Is(err, account.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, account.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, account.ErrInvalidEmail):
+		return status.Error(codes.InvalidArgument, "invalid new email")
+	case errors.Is(err, account.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, "email already taken")
+	case errors.Is(err, account.ErrCodeInvalid):
+		return status.Error(codes.FailedPrecondition, "code is invalid or expired")
+	case errors.Is(err, account.ErrInvalidChannel):
+		return status.Error(codes.InvalidArgument, "channel must be email, sms, webauthn or empty")
+	case errors.Is(err, account.ErrPhoneRequired):
+		return status.Error(codes.FailedPrecondition, "phone is required for sms channel")
+	case errors.Is(err, account.ErrPasskeyRequired):
+		return status.Error(codes.FailedPrecondition, "passkey is required for webauthn channel")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
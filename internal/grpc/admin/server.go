@@ -0,0 +1,352 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/admin"
+	"github.com/m1al04949/sso-gRPC/internal/services/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Admin interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	UserByEmail(ctx context.Context, email string) (models.User, error)
+	ListUsers(
+		ctx context.Context,
+		filter models.UserFilter,
+		pageToken string,
+		pageSize int,
+	) (users []models.User, nextPageToken string, err error)
+	SetDisabled(ctx context.Context, userID int64, disabled bool) error
+	ForcePasswordReset(ctx context.Context, userID int64, required bool) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	SetRoles(ctx context.Context, userID int64, roles []string) error
+	SetStatus(ctx context.Context, userID int64, status models.AccountStatus, reason string) error
+	DeleteUser(ctx context.Context, userID int64, reason string) error
+	RestoreUser(ctx context.Context, userID int64, reason string) error
+	PurgeDeletedUsers(ctx context.Context) (int64, error)
+}
+
+// AuditLog gives admins access to the audit log
+type AuditLog interface {
+	Query(
+		ctx context.Context,
+		filter models.AuditFilter,
+		pageToken string,
+		pageSize int,
+	) (events []models.AuditEvent, nextPageToken string, err error)
+	Verify(ctx context.Context) (audit.Verification, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAdminServer
+	admin    Admin
+	auditLog AuditLog
+}
+
+// ServiceName is used to guard all admin methods with admin authorization
+var ServiceName = ssov1.Admin_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, admin Admin, auditLog AuditLog) {
+	ssov1.RegisterAdminServer(gRPC, &serverAPI{admin: admin, auditLog: auditLog})
+}
+
+func (s *serverAPI) GetUser(ctx context.Context, req *ssov1.GetUserRequest) (*ssov1.GetUserResponse, error) {
+	// Validation
+	if err := validation.ValidateGetUser(req); err != nil {
+		return nil, err
+	}
+
+	var (
+		user models.User
+		err  error
+	)
+	if req.GetUserId() != 0 {
+		user, err = s.admin.UserByID(ctx, req.GetUserId())
+	} else {
+		user, err = s.admin.UserByEmail(ctx, req.GetEmail())
+	}
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (s *serverAPI) ListUsers(ctx context.Context, req *ssov1.ListUsersRequest) (*ssov1.ListUsersResponse, error) {
+	filter := models.UserFilter{
+		Email:    req.GetEmail(),
+		IsAdmin:  req.IsAdmin,
+		Disabled: req.Disabled,
+		Role:     req.GetRole(),
+		OrgID:    req.GetOrgId(),
+		Status:   models.AccountStatus(req.GetStatus()),
+	}
+
+	users, nextPageToken, err := s.admin.ListUsers(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &ssov1.ListUsersResponse{
+		Users:         make([]*ssov1.User, 0, len(users)),
+		NextPageToken: nextPageToken,
+	}
+	for _, user := range users {
+		resp.Users = append(resp.Users, toProtoUser(user))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DisableUser(ctx context.Context, req *ssov1.DisableUserRequest) (*ssov1.DisableUserResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetDisabled(ctx, req.GetUserId(), true); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.DisableUserResponse{}, nil
+}
+
+func (s *serverAPI) EnableUser(ctx context.Context, req *ssov1.EnableUserRequest) (*ssov1.EnableUserResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetDisabled(ctx, req.GetUserId(), false); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.EnableUserResponse{}, nil
+}
+
+func (s *serverAPI) ForcePasswordReset(
+	ctx context.Context,
+	req *ssov1.ForcePasswordResetRequest,
+) (*ssov1.ForcePasswordResetResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	// Unset flag keeps the former meaning of the call
+	required := req.Required == nil || req.GetRequired()
+
+	if err := s.admin.ForcePasswordReset(ctx, req.GetUserId(), required); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.ForcePasswordResetResponse{}, nil
+}
+
+func (s *serverAPI) SetAdmin(ctx context.Context, req *ssov1.SetAdminRequest) (*ssov1.SetAdminResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetAdmin(ctx, req.GetUserId(), req.GetIsAdmin()); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.SetAdminResponse{}, nil
+}
+
+func (s *serverAPI) SetRoles(ctx context.Context, req *ssov1.SetRolesRequest) (*ssov1.SetRolesResponse, error) {
+	// Validation
+	if err := validation.ValidateSetRoles(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetRoles(ctx, req.GetUserId(), req.GetRoles()); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.SetRolesResponse{}, nil
+}
+
+func (s *serverAPI) DeleteUser(ctx context.Context, req *ssov1.DeleteUserRequest) (*ssov1.DeleteUserResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.DeleteUser(ctx, req.GetUserId(), req.GetReason()); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.DeleteUserResponse{}, nil
+}
+
+func (s *serverAPI) SetUserStatus(
+	ctx context.Context,
+	req *ssov1.SetUserStatusRequest,
+) (*ssov1.SetUserStatusResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetStatus(ctx, req.GetUserId(), models.AccountStatus(req.GetStatus()), req.GetReason()); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.SetUserStatusResponse{}, nil
+}
+
+func (s *serverAPI) RestoreUser(ctx context.Context, req *ssov1.RestoreUserRequest) (*ssov1.RestoreUserResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.RestoreUser(ctx, req.GetUserId(), req.GetReason()); err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.RestoreUserResponse{}, nil
+}
+
+func (s *serverAPI) PurgeDeletedUsers(
+	ctx context.Context,
+	req *ssov1.PurgeDeletedUsersRequest,
+) (*ssov1.PurgeDeletedUsersResponse, error) {
+	n, err := s.admin.PurgeDeletedUsers(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &ssov1.PurgeDeletedUsersResponse{Purged: n}, nil
+}
+
+func (s *serverAPI) QueryAuditLog(
+	ctx context.Context,
+	req *ssov1.QueryAuditLogRequest,
+) (*ssov1.QueryAuditLogResponse, error) {
+	filter := models.AuditFilter{
+		ActorID: req.GetActorId(),
+		Action:  req.GetAction(),
+		Target:  req.GetTarget(),
+		AppID:   int(req.GetAppId()),
+		Result:  req.GetResult(),
+		From:    timeOrZero(req.GetFrom()),
+		To:      timeOrZero(req.GetTo()),
+	}
+
+	events, nextPageToken, err := s.auditLog.Query(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &ssov1.QueryAuditLogResponse{
+		Events:        make([]*ssov1.AuditEvent, 0, len(events)),
+		NextPageToken: nextPageToken,
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toProtoAuditEvent(event))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) VerifyAuditLog(
+	ctx context.Context,
+	req *ssov1.VerifyAuditLogRequest,
+) (*ssov1.VerifyAuditLogResponse, error) {
+	result, err := s.auditLog.Verify(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.VerifyAuditLogResponse{
+		Valid:         result.Valid(),
+		Checked:       result.Checked,
+		BrokenEventId: result.BrokenEventID,
+	}, nil
+}
+
+func userError(err error) error {
+	switch {
+	case errors.Is(err, admin.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, admin.ErrInvalidStatus):
+		return status.Error(codes.InvalidArgument, "invalid status")
+	case errors.Is(err, admin.ErrTransitionNotAllowed):
+		return status.Error(codes.FailedPrecondition, "status transition not allowed")
+	case errors.Is(err, admin.ErrRetentionExpired):
+		return status.Error(codes.FailedPrecondition, "retention period of deleted user expired")
+	case errors.Is(err, admin.ErrStatusConflict):
+		return status.Error(codes.Aborted, "user status changed concurrently")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toProtoUser(user models.User) *ssov1.User {
+	return &ssov1.User{
+		Id:                    user.ID,
+		OrgId:                 user.OrgID,
+		Email:                 user.Email,
+		Username:              user.Username,
+		Phone:                 user.Phone,
+		IsAdmin:               user.IsAdmin,
+		Disabled:              user.Disabled,
+		PasswordResetRequired: user.PassResetRequired,
+		Status:                string(user.Status),
+		StatusReason:          user.StatusReason,
+		StatusChangedAt:       unixOrZero(user.StatusChangedAt),
+		Roles:                 user.Roles,
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(unix, 0)
+}
+
+func toProtoAuditEvent(event models.AuditEvent) *ssov1.AuditEvent {
+	return &ssov1.AuditEvent{
+		Id:        event.ID,
+		Time:      event.Time.Unix(),
+		ActorId:   event.ActorID,
+		Action:    event.Action,
+		Target:    event.Target,
+		AppId:     int32(event.AppID),
+		Ip:        event.IP,
+		UserAgent: event.UserAgent,
+		Result:    event.Result,
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+	}
+}
@@ -0,0 +1,141 @@
+package apps
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/apps"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Apps interface {
+	CreateApp(ctx context.Context, name string, orgID int64) (models.App, error)
+	ListApps(ctx context.Context, orgID int64) ([]models.App, error)
+	UpdateApp(ctx context.Context, appID int, name string) error
+	RotateSecret(ctx context.Context, appID int) (models.App, error)
+	DeleteApp(ctx context.Context, appID int) error
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAppsServer
+	apps Apps
+}
+
+// ServiceName is used to guard all apps methods with admin authorization
+var ServiceName = ssov1.Apps_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, apps Apps) {
+	ssov1.RegisterAppsServer(gRPC, &serverAPI{apps: apps})
+}
+
+func (s *serverAPI) CreateApp(ctx context.Context, req *ssov1.CreateAppRequest) (*ssov1.CreateAppResponse, error) {
+	// Validation
+	if err := validation.ValidateAppName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.CreateApp(ctx, req.GetName(), req.GetOrgId())
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	return &ssov1.CreateAppResponse{
+		AppId:  int32(app.ID),
+		Secret: app.Secret,
+	}, nil
+}
+
+func (s *serverAPI) ListApps(ctx context.Context, req *ssov1.ListAppsRequest) (*ssov1.ListAppsResponse, error) {
+	apps, err := s.apps.ListApps(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &ssov1.ListAppsResponse{Apps: make([]*ssov1.App, 0, len(apps))}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, &ssov1.App{
+			Id:                  int32(app.ID),
+			Name:                app.Name,
+			OrgId:               app.OrgID,
+			PrevSecretExpiresAt: unixOrZero(app),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) UpdateApp(ctx context.Context, req *ssov1.UpdateAppRequest) (*ssov1.UpdateAppResponse, error) {
+	// Validation
+	if err := validation.ValidateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateAppName(req.GetName()); err != nil {
+		return nil, err
+	}
+
+	if err := s.apps.UpdateApp(ctx, int(req.GetAppId()), req.GetName()); err != nil {
+		return nil, appError(err)
+	}
+
+	return &ssov1.UpdateAppResponse{}, nil
+}
+
+func (s *serverAPI) RotateAppSecret(
+	ctx context.Context,
+	req *ssov1.RotateAppSecretRequest,
+) (*ssov1.RotateAppSecretResponse, error) {
+	// Validation
+	if err := validation.ValidateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	app, err := s.apps.RotateSecret(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	return &ssov1.RotateAppSecretResponse{
+		Secret:              app.Secret,
+		PrevSecretExpiresAt: unixOrZero(app),
+	}, nil
+}
+
+func (s *serverAPI) DeleteApp(ctx context.Context, req *ssov1.DeleteAppRequest) (*ssov1.DeleteAppResponse, error) {
+	// Validation
+	if err := validation.ValidateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.apps.DeleteApp(ctx, int(req.GetAppId())); err != nil {
+		return nil, appError(err)
+	}
+
+	return &ssov1.DeleteAppResponse{}, nil
+}
+
+func appError(err error) error {
+	if errors.Is(err, apps.ErrAppNotFound) {
+		return status.Error(codes.NotFound, "app not found")
+	}
+	if errors.Is(err, apps.ErrOrgNotFound) {
+		return status.Error(codes.NotFound, "organization not found")
+	}
+	if errors.Is(err, apps.ErrAppExists) {
+		return status.Error(codes.AlreadyExists, "app already exists")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func unixOrZero(app models.App) int64 {
+	if app.PrevSecretExpiresAt.IsZero() {
+		return 0
+	}
+
+	return app.PrevSecretExpiresAt.Unix()
+}
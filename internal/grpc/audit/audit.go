@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"net"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const userAgentHeader = "user-agent"
+
+type Recorder interface {
+	Record(ctx context.Context, event models.AuditEvent) error
+}
+
+type actorKey struct{}
+
+// SetActor sets id of authenticated user as the actor of audited call
+func SetActor(ctx context.Context, userID int64) {
+	if actor, ok := ctx.Value(actorKey{}).(*int64); ok {
+		*actor = userID
+	}
+}
+
+// UnaryServerInterceptor records every call to the audit log with its
+// result. It must run before authorization so that denied calls are
+// recorded too
+func UnaryServerInterceptor(recorder Recorder) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		actor := new(int64)
+
+		resp, err := handler(context.WithValue(ctx, actorKey{}, actor), req)
+
+		record(ctx, recorder, info.FullMethod, *actor, err, req, resp)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming
+// methods. Target is taken from the first received message
+func StreamServerInterceptor(recorder Recorder) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		actor := new(int64)
+		stream := &serverStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), actorKey{}, actor),
+		}
+
+		err := handler(srv, stream)
+
+		record(ss.Context(), recorder, info.FullMethod, *actor, err, stream.firstMsg)
+
+		return err
+	}
+}
+
+// serverStream overrides context of the wrapped stream and keeps
+// the first received message
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	firstMsg any
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.firstMsg == nil {
+		s.firstMsg = m
+	}
+
+	return err
+}
+
+func record(ctx context.Context, recorder Recorder, method string, actorID int64, err error, msgs ...any) {
+	event := models.AuditEvent{
+		ActorID: actorID,
+		Action:  method,
+		Target:  target(msgs...),
+		AppID:   appID(msgs...),
+		Result:  status.Code(err).String(),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		event.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(event.IP); err == nil {
+			event.IP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(userAgentHeader); len(values) > 0 {
+			event.UserAgent = values[0]
+		}
+	}
+
+	// Recorder logs failures itself, audit must not fail the call.
+	// The call is recorded even if client has gone
+	_ = recorder.Record(context.WithoutCancel(ctx), event)
+}
+
+// targetExtractors find target of call in request or response messages.
+// The most specific kind of target wins
+var targetExtractors = []func(msg any) string{
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetUserId() int64 }); ok && m.GetUserId() != 0 {
+			return models.AuditTarget(models.AuditTargetUser, m.GetUserId())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetIdentifier() string }); ok && m.GetIdentifier() != "" {
+			return models.AuditTarget(models.AuditTargetIdentifier, m.GetIdentifier())
+		}
+		if m, ok := msg.(interface{ GetEmail() string }); ok && m.GetEmail() != "" {
+			return models.AuditTarget(models.AuditTargetIdentifier, m.GetEmail())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetGroupId() int64 }); ok && m.GetGroupId() != 0 {
+			return models.AuditTarget(models.AuditTargetGroup, m.GetGroupId())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetInvitationId() int64 }); ok && m.GetInvitationId() != 0 {
+			return models.AuditTarget(models.AuditTargetInvitation, m.GetInvitationId())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetWebhookId() int64 }); ok && m.GetWebhookId() != 0 {
+			return models.AuditTarget(models.AuditTargetWebhook, m.GetWebhookId())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetOrgId() int64 }); ok && m.GetOrgId() != 0 {
+			return models.AuditTarget(models.AuditTargetOrg, m.GetOrgId())
+		}
+		return ""
+	},
+	func(msg any) string {
+		if m, ok := msg.(interface{ GetAppId() int32 }); ok && m.GetAppId() != 0 {
+			return models.AuditTarget(models.AuditTargetApp, m.GetAppId())
+		}
+		return ""
+	},
+}
+
+func target(msgs ...any) string {
+	for _, extract := range targetExtractors {
+		for _, msg := range msgs {
+			if target := extract(msg); target != "" {
+				return target
+			}
+		}
+	}
+
+	return ""
+}
+
+func appID(msgs ...any) int {
+	for _, msg := range msgs {
+		if m, ok := msg.(interface{ GetAppId() int32 }); ok && m.GetAppId() != 0 {
+			return int(m.GetAppId())
+		}
+	}
+
+	return 0
+}
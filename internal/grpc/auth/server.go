@@ -5,6 +5,8 @@ import (
 	"errors"
 
 	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
 	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
 	"github.com/m1al04949/sso-gRPC/internal/services/auth"
 	"google.golang.org/grpc"
@@ -15,19 +17,81 @@ import (
 type Auth interface {
 	Login(
 		ctx context.Context,
-		email string,
+		login string,
 		password string,
 		appID int,
+		deviceToken string,
 	) (token string, err error)
 	RegisterNewUser(
 		ctx context.Context,
 		email string,
 		password string,
+		orgID int64,
 	) (userID int64, err error)
 	IsAdmin(
 		ctx context.Context,
 		userID int64,
 	) (bool, error)
+	RequestMagicLink(
+		ctx context.Context,
+		email string,
+		appID int,
+	) error
+	ConsumeMagicLink(
+		ctx context.Context,
+		code string,
+		appID int,
+		deviceToken string,
+	) (token string, err error)
+	RequestLoginCode(
+		ctx context.Context,
+		login string,
+		appID int,
+		channel notifier.Channel,
+	) (challenge string, err error)
+	VerifyLoginCode(
+		ctx context.Context,
+		challenge string,
+		code string,
+		appID int,
+		deviceToken string,
+		trustDevice bool,
+	) (token string, newDeviceToken string, err error)
+	ResendLoginCode(
+		ctx context.Context,
+		challenge string,
+		appID int,
+	) error
+	BeginPasskeyLogin(
+		ctx context.Context,
+		appID int,
+	) (challenge string, options []byte, err error)
+	FinishPasskeyLogin(
+		ctx context.Context,
+		challenge string,
+		credential []byte,
+		appID int,
+		trustDevice bool,
+	) (token string, deviceToken string, err error)
+	Reauthenticate(
+		ctx context.Context,
+		userID int64,
+		password string,
+		appID int,
+	) (token string, err error)
+	ResetPassword(
+		ctx context.Context,
+		login string,
+		password string,
+		newPassword string,
+		appID int,
+	) error
+	ChangePassword(
+		ctx context.Context,
+		userID int64,
+		password string,
+		newPassword string,
+	) error
 }
 
 type serverAPI struct {
@@ -35,6 +99,13 @@ type serverAPI struct {
 	auth Auth
 }
 
+var (
+	// ReauthenticateMethod and ChangePasswordMethod are called by logged
+	// in users, so they require access token unlike other auth methods
+	ReauthenticateMethod = "/" + ssov1.Auth_ServiceDesc.ServiceName + "/Reauthenticate"
+	ChangePasswordMethod = "/" + ssov1.Auth_ServiceDesc.ServiceName + "/ChangePassword"
+)
+
 func Register(gRPC *grpc.Server, auth Auth) {
 	ssov1.RegisterAuthServer(gRPC, &serverAPI{auth: auth})
 
@@ -48,16 +119,27 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.
 	}
 
 	// Login via auth service
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	login := req.GetIdentifier()
+	if login == "" {
+		login = req.GetEmail()
+	}
+
+	token, err := s.auth.Login(ctx, login, req.GetPassword(), int(req.GetAppId()), req.GetDeviceToken())
 	if err != nil {
+		// Login continues with code of second factor
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return &ssov1.LoginResponse{
+				MfaChallenge:       mfaErr.Challenge,
+				MfaChannel:         string(mfaErr.Channel),
+				MfaWebauthnOptions: string(mfaErr.WebAuthnOptions),
+			}, nil
+		}
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
-		if errors.Is(err, auth.ErrAppNotFound) {
-			return nil, status.Error(codes.NotFound, "app not found")
-		}
 
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, loginError(err)
 	}
 
 	return &ssov1.LoginResponse{
@@ -65,17 +147,44 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.
 	}, nil
 }
 
+// loginError maps errors shared by all login methods to status
+func loginError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, auth.ErrUserDisabled):
+		return status.Error(codes.PermissionDenied, "user is disabled")
+	case errors.Is(err, auth.ErrUserPendingVerification):
+		return status.Error(codes.FailedPrecondition, "account is pending verification")
+	case errors.Is(err, auth.ErrUserLocked):
+		return status.Error(codes.ResourceExhausted, "account is locked")
+	case errors.Is(err, auth.ErrUserDeleted):
+		return status.Error(codes.NotFound, "account is deleted")
+	case errors.Is(err, auth.ErrPassResetRequired):
+		return status.Error(codes.FailedPrecondition, "password reset required")
+	case errors.Is(err, auth.ErrNotOrgMember):
+		return status.Error(codes.PermissionDenied, "user is not a member of app organization")
+	case errors.Is(err, auth.ErrOTPThrottled):
+		return status.Error(codes.ResourceExhausted, "code was sent recently")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
 func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
 	// Validation
 	if err := validation.ValidateRegister(req); err != nil {
 		return nil, err
 	}
 
-	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
+	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword(), req.GetOrgId())
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "user already exists")
 		}
+		if errors.Is(err, auth.ErrOrgNotFound) {
+			return nil, status.Error(codes.NotFound, "organization not found")
+		}
 
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -100,3 +209,265 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
+
+func (s *serverAPI) RequestMagicLink(
+	ctx context.Context,
+	req *ssov1.RequestMagicLinkRequest,
+) (*ssov1.RequestMagicLinkResponse, error) {
+	// Validation
+	if err := validation.ValidateRequestMagicLink(req); err != nil {
+		return nil, err
+	}
+
+	err := s.auth.RequestMagicLink(ctx, req.GetEmail(), int(req.GetAppId()))
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "app not found")
+		}
+
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.RequestMagicLinkResponse{}, nil
+}
+
+func (s *serverAPI) ConsumeMagicLink(
+	ctx context.Context,
+	req *ssov1.ConsumeMagicLinkRequest,
+) (*ssov1.ConsumeMagicLinkResponse, error) {
+	// Validation
+	if err := validation.ValidateConsumeMagicLink(req); err != nil {
+		return nil, err
+	}
+
+	token, err := s.auth.ConsumeMagicLink(ctx, req.GetCode(), int(req.GetAppId()), req.GetDeviceToken())
+	if err != nil {
+		// Login continues with second factor
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return &ssov1.ConsumeMagicLinkResponse{
+				MfaChallenge:       mfaErr.Challenge,
+				MfaChannel:         string(mfaErr.Channel),
+				MfaWebauthnOptions: string(mfaErr.WebAuthnOptions),
+			}, nil
+		}
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			return nil, status.Error(codes.FailedPrecondition, "magic link is invalid or expired")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.ConsumeMagicLinkResponse{Token: token}, nil
+}
+
+func (s *serverAPI) RequestLoginCode(
+	ctx context.Context,
+	req *ssov1.RequestLoginCodeRequest,
+) (*ssov1.RequestLoginCodeResponse, error) {
+	// Validation
+	if err := validation.ValidateRequestLoginCode(req); err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.auth.RequestLoginCode(ctx, req.GetLogin(), int(req.GetAppId()), notifier.Channel(req.GetChannel()))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidChannel) {
+			return nil, status.Error(codes.InvalidArgument, "channel must be email or sms")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.RequestLoginCodeResponse{Challenge: challenge}, nil
+}
+
+func (s *serverAPI) VerifyLoginCode(
+	ctx context.Context,
+	req *ssov1.VerifyLoginCodeRequest,
+) (*ssov1.VerifyLoginCodeResponse, error) {
+	// Validation
+	if err := validation.ValidateVerifyLoginCode(req); err != nil {
+		return nil, err
+	}
+
+	token, deviceToken, err := s.auth.VerifyLoginCode(ctx,
+		req.GetChallenge(),
+		req.GetCode(),
+		int(req.GetAppId()),
+		req.GetDeviceToken(),
+		req.GetTrustDevice(),
+	)
+	if err != nil {
+		// Passwordless login continues with second factor
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return &ssov1.VerifyLoginCodeResponse{
+				MfaChallenge:       mfaErr.Challenge,
+				MfaChannel:         string(mfaErr.Channel),
+				MfaWebauthnOptions: string(mfaErr.WebAuthnOptions),
+			}, nil
+		}
+		if errors.Is(err, auth.ErrInvalidOTP) {
+			return nil, status.Error(codes.FailedPrecondition, "code is invalid or expired")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.VerifyLoginCodeResponse{Token: token, DeviceToken: deviceToken}, nil
+}
+
+func (s *serverAPI) ResendLoginCode(
+	ctx context.Context,
+	req *ssov1.ResendLoginCodeRequest,
+) (*ssov1.ResendLoginCodeResponse, error) {
+	// Validation
+	if err := validation.ValidateResendLoginCode(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.ResendLoginCode(ctx, req.GetChallenge(), int(req.GetAppId())); err != nil {
+		if errors.Is(err, auth.ErrInvalidOTP) {
+			return nil, status.Error(codes.FailedPrecondition, "code is invalid or expired")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.ResendLoginCodeResponse{}, nil
+}
+
+func (s *serverAPI) BeginPasskeyLogin(
+	ctx context.Context,
+	req *ssov1.BeginPasskeyLoginRequest,
+) (*ssov1.BeginPasskeyLoginResponse, error) {
+	// Validation
+	if err := validation.ValidateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	challenge, options, err := s.auth.BeginPasskeyLogin(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, loginError(err)
+	}
+
+	return &ssov1.BeginPasskeyLoginResponse{Challenge: challenge, Options: string(options)}, nil
+}
+
+func (s *serverAPI) FinishPasskeyLogin(
+	ctx context.Context,
+	req *ssov1.FinishPasskeyLoginRequest,
+) (*ssov1.FinishPasskeyLoginResponse, error) {
+	// Validation
+	if err := validation.ValidateFinishPasskeyLogin(req); err != nil {
+		return nil, err
+	}
+
+	token, deviceToken, err := s.auth.FinishPasskeyLogin(ctx,
+		req.GetChallenge(),
+		[]byte(req.GetCredential()),
+		int(req.GetAppId()),
+		req.GetTrustDevice(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidPasskey):
+			return nil, status.Error(codes.InvalidArgument, "invalid passkey")
+		case errors.Is(err, auth.ErrInvalidPasskeySession):
+			return nil, status.Error(codes.FailedPrecondition, "challenge is invalid or expired")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.FinishPasskeyLoginResponse{Token: token, DeviceToken: deviceToken}, nil
+}
+
+func (s *serverAPI) Reauthenticate(
+	ctx context.Context,
+	req *ssov1.ReauthenticateRequest,
+) (*ssov1.ReauthenticateResponse, error) {
+	// Validation
+	if err := validation.ValidateReauthenticate(req); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	token, err := s.auth.Reauthenticate(ctx, userID, req.GetPassword(), int(req.GetAppId()))
+	if err != nil {
+		// Reauthentication continues with second factor the same way login does
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return &ssov1.ReauthenticateResponse{
+				MfaChallenge:       mfaErr.Challenge,
+				MfaChannel:         string(mfaErr.Channel),
+				MfaWebauthnOptions: string(mfaErr.WebAuthnOptions),
+			}, nil
+		}
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid password")
+		case errors.Is(err, auth.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.ReauthenticateResponse{Token: token}, nil
+}
+
+func (s *serverAPI) ResetPassword(
+	ctx context.Context,
+	req *ssov1.ResetPasswordRequest,
+) (*ssov1.ResetPasswordResponse, error) {
+	// Validation
+	if err := validation.ValidateResetPassword(req); err != nil {
+		return nil, err
+	}
+
+	err := s.auth.ResetPassword(ctx, req.GetLogin(), req.GetPassword(), req.GetNewPassword(), int(req.GetAppId()))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid login or password")
+		case errors.Is(err, auth.ErrPassResetNotRequired):
+			return nil, status.Error(codes.FailedPrecondition, "password reset is not required")
+		case errors.Is(err, auth.ErrPasswordReused):
+			return nil, status.Error(codes.InvalidArgument, "new password is the same as current")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.ResetPasswordResponse{}, nil
+}
+
+func (s *serverAPI) ChangePassword(
+	ctx context.Context,
+	req *ssov1.ChangePasswordRequest,
+) (*ssov1.ChangePasswordResponse, error) {
+	// Validation
+	if err := validation.ValidateChangePassword(req); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	if err := s.auth.ChangePassword(ctx, userID, req.GetPassword(), req.GetNewPassword()); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid password")
+		case errors.Is(err, auth.ErrPasswordReused):
+			return nil, status.Error(codes.InvalidArgument, "new password is the same as current")
+		case errors.Is(err, auth.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		return nil, loginError(err)
+	}
+
+	return &ssov1.ChangePasswordResponse{}, nil
+}
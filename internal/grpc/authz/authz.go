@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/m1al04949/sso-gRPC/internal/grpc/audit"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// Level is an access level required to call methods of gRPC service
+type Level int
+
+const (
+	// LevelPublic doesn't require access token
+	LevelPublic Level = iota
+	// LevelUser requires a valid access token of enabled user
+	LevelUser
+	// LevelAdmin requires a valid access token of enabled admin
+	LevelAdmin
+)
+
+type Authorizer interface {
+	AuthenticateUser(ctx context.Context, token string) (userID int64, err error)
+	AuthorizeAdmin(ctx context.Context, token string) (userID int64, err error)
+	AuthorizeStepUp(ctx context.Context, token string) error
+}
+
+type userIDKey struct{}
+
+// UnaryServerInterceptor requires access token in the authorization
+// metadata for methods listed in rules. Methods in stepUp also require
+// token of recent strong authentication. Rules and stepUp are keyed by
+// service name or by full method name, which takes precedence
+func UnaryServerInterceptor(
+	authorizer Authorizer,
+	rules map[string]Level,
+	stepUp map[string]bool,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		level := methodRule(rules, info.FullMethod)
+		if level == LevelPublic {
+			return handler(ctx, req)
+		}
+
+		userID, err := authorize(ctx, authorizer, level, methodRule(stepUp, info.FullMethod))
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(context.WithValue(ctx, userIDKey{}, userID), req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming methods
+func StreamServerInterceptor(
+	authorizer Authorizer,
+	rules map[string]Level,
+	stepUp map[string]bool,
+) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		level := methodRule(rules, info.FullMethod)
+		if level == LevelPublic {
+			return handler(srv, ss)
+		}
+
+		userID, err := authorize(ss.Context(), authorizer, level, methodRule(stepUp, info.FullMethod))
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), userIDKey{}, userID),
+		})
+	}
+}
+
+// serverStream overrides context of the wrapped stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// UserID returns id of the user authorized by interceptor
+func UserID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(userIDKey{}).(int64)
+
+	return id, ok
+}
+
+func authorize(ctx context.Context, authorizer Authorizer, level Level, stepUp bool) (int64, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int64
+	switch level {
+	case LevelAdmin:
+		userID, err = authorizer.AuthorizeAdmin(ctx, token)
+	default:
+		userID, err = authorizer.AuthenticateUser(ctx, token)
+	}
+	if userID != 0 {
+		audit.SetActor(ctx, userID)
+	}
+	if err == nil && stepUp {
+		err = authorizer.AuthorizeStepUp(ctx, token)
+	}
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return 0, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return 0, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		if errors.Is(err, auth.ErrStepUpRequired) {
+			return 0, status.Error(codes.Unauthenticated, "recent strong authentication required")
+		}
+
+		return 0, status.Error(codes.Internal, "internal error")
+	}
+
+	return userID, nil
+}
+
+func methodRule[T any](rules map[string]T, fullMethod string) T {
+	if rule, ok := rules[fullMethod]; ok {
+		return rule
+	}
+
+	return rules[service(fullMethod)]
+}
+
+// service returns service name of full method name "/package.Service/Method"
+func service(fullMethod string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+
+	return name
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	return strings.TrimPrefix(values[0], bearerPrefix), nil
+}
@@ -0,0 +1,49 @@
+package clientinfo
+
+import (
+	"context"
+	"net"
+
+	"github.com/m1al04949/sso-gRPC/internal/lib/clientinfo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const userAgentHeader = "user-agent"
+
+// UnaryServerInterceptor puts IP address, user agent and device
+// fingerprint of client into context of the call. Fingerprint is taken
+// from metadata by deviceKey
+func UnaryServerInterceptor(deviceKey string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(clientinfo.NewContext(ctx, fromIncoming(ctx, deviceKey)), req)
+	}
+}
+
+func fromIncoming(ctx context.Context, deviceKey string) clientinfo.Info {
+	var info clientinfo.Info
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(info.IP); err == nil {
+			info.IP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(userAgentHeader); len(values) > 0 {
+			info.UserAgent = values[0]
+		}
+		if values := md.Get(deviceKey); len(values) > 0 {
+			info.Device = values[0]
+		}
+	}
+
+	return info
+}
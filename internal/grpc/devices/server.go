@@ -0,0 +1,115 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TrustedDevices manages devices the calling user skips second factor on
+type TrustedDevices interface {
+	ListTrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error)
+	RevokeTrustedDevice(ctx context.Context, userID int64, deviceID int64) error
+	RevokeTrustedDevices(ctx context.Context, userID int64) (int64, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedTrustedDevicesServer
+	devices TrustedDevices
+}
+
+var (
+	// ServiceName is used to require authenticated user for trusted devices methods
+	ServiceName = ssov1.TrustedDevices_ServiceDesc.ServiceName
+	// RevokeTrustedDeviceMethod and RevokeAllTrustedDevicesMethod bring
+	// second factor back on devices of user
+	RevokeTrustedDeviceMethod     = "/" + ServiceName + "/RevokeTrustedDevice"
+	RevokeAllTrustedDevicesMethod = "/" + ServiceName + "/RevokeAllTrustedDevices"
+)
+
+func Register(gRPC *grpc.Server, devices TrustedDevices) {
+	ssov1.RegisterTrustedDevicesServer(gRPC, &serverAPI{devices: devices})
+}
+
+func (s *serverAPI) ListTrustedDevices(
+	ctx context.Context,
+	req *ssov1.ListTrustedDevicesRequest,
+) (*ssov1.ListTrustedDevicesResponse, error) {
+	userID, _ := authz.UserID(ctx)
+
+	devices, err := s.devices.ListTrustedDevices(ctx, userID)
+	if err != nil {
+		return nil, deviceError(err)
+	}
+
+	resp := &ssov1.ListTrustedDevicesResponse{Devices: make([]*ssov1.TrustedDevice, 0, len(devices))}
+	for _, device := range devices {
+		resp.Devices = append(resp.Devices, &ssov1.TrustedDevice{
+			Id:         device.ID,
+			UserAgent:  device.UserAgent,
+			Ip:         device.IP,
+			CreatedAt:  device.CreatedAt.Unix(),
+			ExpiresAt:  device.ExpiresAt.Unix(),
+			LastUsedAt: unixOrZero(device.LastUsedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeTrustedDevice(
+	ctx context.Context,
+	req *ssov1.RevokeTrustedDeviceRequest,
+) (*ssov1.RevokeTrustedDeviceResponse, error) {
+	// Validation
+	if err := validation.ValidateDeviceID(req.GetDeviceId()); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	if err := s.devices.RevokeTrustedDevice(ctx, userID, req.GetDeviceId()); err != nil {
+		return nil, deviceError(err)
+	}
+
+	return &ssov1.RevokeTrustedDeviceResponse{}, nil
+}
+
+func (s *serverAPI) RevokeAllTrustedDevices(
+	ctx context.Context,
+	req *ssov1.RevokeAllTrustedDevicesRequest,
+) (*ssov1.RevokeAllTrustedDevicesResponse, error) {
+	userID, _ := authz.UserID(ctx)
+
+	revoked, err := s.devices.RevokeTrustedDevices(ctx, userID)
+	if err != nil {
+		return nil, deviceError(err)
+	}
+
+	return &ssov1.RevokeAllTrustedDevicesResponse{Revoked: revoked}, nil
+}
+
+func deviceError(err error) error {
+	if errors.Is(err, auth.ErrTrustedDeviceNotFound) {
+		return status.Error(codes.NotFound, "trusted device not found")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watcher streams events relayed from the outbox. The feed stays empty
+// unless the webhooks dispatcher runs, it is the only publisher
+type Watcher interface {
+	Watch(
+		ctx context.Context,
+		cursor string,
+		filter models.EventFilter,
+		send func(event models.Event, cursor string) error,
+	) error
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedEventsServer
+	watcher Watcher
+}
+
+// ServiceName is used to guard event feed with admin authorization
+var ServiceName = ssov1.Events_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, watcher Watcher) {
+	ssov1.RegisterEventsServer(gRPC, &serverAPI{watcher: watcher})
+}
+
+func (s *serverAPI) WatchEvents(req *ssov1.WatchEventsRequest, stream ssov1.Events_WatchEventsServer) error {
+	ctx := stream.Context()
+
+	filter := models.EventFilter{
+		Types: req.GetTypes(),
+		AppID: int(req.GetAppId()),
+	}
+
+	err := s.watcher.Watch(ctx, req.GetCursor(), filter, func(event models.Event, cursor string) error {
+		return stream.Send(&ssov1.Event{
+			Cursor: cursor,
+			Type:   event.Type,
+			Time:   event.Time.Unix(),
+			UserId: event.UserID,
+			OrgId:  event.OrgID,
+			AppId:  int32(event.AppID),
+			Data:   event.Data,
+		})
+	})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, events.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, "invalid cursor")
+	case errors.Is(err, events.ErrCursorExpired):
+		return status.Error(codes.OutOfRange, "cursor expired, resume without cursor")
+	case ctx.Err() != nil:
+		// Client has gone or deadline exceeded
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
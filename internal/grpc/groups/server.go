@@ -0,0 +1,200 @@
+package groups
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/groups"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Groups interface {
+	CreateGroup(ctx context.Context, name string, roles []string) (int64, error)
+	ListGroups(ctx context.Context) ([]models.Group, error)
+	SetGroupRoles(ctx context.Context, groupID int64, roles []string) error
+	DeleteGroup(ctx context.Context, groupID int64) error
+	AddMember(ctx context.Context, groupID int64, userID int64) error
+	RemoveMember(ctx context.Context, groupID int64, userID int64) error
+	ListMembers(ctx context.Context, groupID int64) ([]int64, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	EffectiveRoles(ctx context.Context, userID int64) ([]string, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedGroupsServer
+	groups Groups
+}
+
+// ServiceName is used to guard all groups methods with admin authorization
+var ServiceName = ssov1.Groups_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, groups Groups) {
+	ssov1.RegisterGroupsServer(gRPC, &serverAPI{groups: groups})
+}
+
+func (s *serverAPI) CreateGroup(ctx context.Context, req *ssov1.CreateGroupRequest) (*ssov1.CreateGroupResponse, error) {
+	// Validation
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if err := validation.ValidateRoles(req.GetRoles()); err != nil {
+		return nil, err
+	}
+
+	id, err := s.groups.CreateGroup(ctx, req.GetName(), req.GetRoles())
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.CreateGroupResponse{GroupId: id}, nil
+}
+
+func (s *serverAPI) ListGroups(ctx context.Context, req *ssov1.ListGroupsRequest) (*ssov1.ListGroupsResponse, error) {
+	var (
+		groups []models.Group
+		err    error
+	)
+	if req.GetUserId() != 0 {
+		groups, err = s.groups.UserGroups(ctx, req.GetUserId())
+	} else {
+		groups, err = s.groups.ListGroups(ctx)
+	}
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	resp := &ssov1.ListGroupsResponse{Groups: make([]*ssov1.Group, 0, len(groups))}
+	for _, group := range groups {
+		resp.Groups = append(resp.Groups, &ssov1.Group{
+			Id:    group.ID,
+			Name:  group.Name,
+			Roles: group.Roles,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) SetGroupRoles(
+	ctx context.Context,
+	req *ssov1.SetGroupRolesRequest,
+) (*ssov1.SetGroupRolesResponse, error) {
+	// Validation
+	if err := validation.ValidateGroupID(req.GetGroupId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateRoles(req.GetRoles()); err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.SetGroupRoles(ctx, req.GetGroupId(), req.GetRoles()); err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.SetGroupRolesResponse{}, nil
+}
+
+func (s *serverAPI) DeleteGroup(ctx context.Context, req *ssov1.DeleteGroupRequest) (*ssov1.DeleteGroupResponse, error) {
+	// Validation
+	if err := validation.ValidateGroupID(req.GetGroupId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.DeleteGroup(ctx, req.GetGroupId()); err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.DeleteGroupResponse{}, nil
+}
+
+func (s *serverAPI) AddGroupMember(
+	ctx context.Context,
+	req *ssov1.AddGroupMemberRequest,
+) (*ssov1.AddGroupMemberResponse, error) {
+	// Validation
+	if err := validation.ValidateGroupID(req.GetGroupId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.AddMember(ctx, req.GetGroupId(), req.GetUserId()); err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.AddGroupMemberResponse{}, nil
+}
+
+func (s *serverAPI) RemoveGroupMember(
+	ctx context.Context,
+	req *ssov1.RemoveGroupMemberRequest,
+) (*ssov1.RemoveGroupMemberResponse, error) {
+	// Validation
+	if err := validation.ValidateGroupID(req.GetGroupId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.RemoveMember(ctx, req.GetGroupId(), req.GetUserId()); err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.RemoveGroupMemberResponse{}, nil
+}
+
+func (s *serverAPI) ListGroupMembers(
+	ctx context.Context,
+	req *ssov1.ListGroupMembersRequest,
+) (*ssov1.ListGroupMembersResponse, error) {
+	// Validation
+	if err := validation.ValidateGroupID(req.GetGroupId()); err != nil {
+		return nil, err
+	}
+
+	ids, err := s.groups.ListMembers(ctx, req.GetGroupId())
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.ListGroupMembersResponse{UserIds: ids}, nil
+}
+
+func (s *serverAPI) GetEffectiveRoles(
+	ctx context.Context,
+	req *ssov1.GetEffectiveRolesRequest,
+) (*ssov1.GetEffectiveRolesResponse, error) {
+	// Validation
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.groups.EffectiveRoles(ctx, req.GetUserId())
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.GetEffectiveRolesResponse{Roles: roles}, nil
+}
+
+func groupError(err error) error {
+	switch {
+	case errors.Is(err, groups.ErrGroupNotFound):
+		return status.Error(codes.NotFound, "group not found")
+	case errors.Is(err, groups.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, groups.ErrGroupMemberNotFound):
+		return status.Error(codes.NotFound, "group member not found")
+	case errors.Is(err, groups.ErrGroupExists):
+		return status.Error(codes.AlreadyExists, "group already exists")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,162 @@
+package invitations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/invitations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Invitations interface {
+	CreateInvitation(
+		ctx context.Context,
+		actorID int64,
+		inv models.Invitation,
+	) (invitation models.Invitation, code string, err error)
+	ListInvitations(ctx context.Context, actorID int64, orgID int64) ([]models.Invitation, error)
+	RevokeInvitation(ctx context.Context, actorID int64, id int64) error
+	AcceptInvitation(ctx context.Context, code string, password string) (userID int64, err error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedInvitationsServer
+	invitations Invitations
+}
+
+var (
+	// ServiceName is used to require authenticated user for invitations methods
+	ServiceName = ssov1.Invitations_ServiceDesc.ServiceName
+	// AcceptMethod is called by invited users who may have no account yet
+	AcceptMethod = "/" + ServiceName + "/AcceptInvitation"
+)
+
+func Register(gRPC *grpc.Server, invitations Invitations) {
+	ssov1.RegisterInvitationsServer(gRPC, &serverAPI{invitations: invitations})
+}
+
+func (s *serverAPI) CreateInvitation(
+	ctx context.Context,
+	req *ssov1.CreateInvitationRequest,
+) (*ssov1.CreateInvitationResponse, error) {
+	// Validation
+	if err := validation.ValidateCreateInvitation(req); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	inv, code, err := s.invitations.CreateInvitation(ctx, actorID, models.Invitation{
+		Email: req.GetEmail(),
+		OrgID: req.GetOrgId(),
+		AppID: int(req.GetAppId()),
+		Role:  req.GetRole(),
+	})
+	if err != nil {
+		return nil, invitationError(err)
+	}
+
+	return &ssov1.CreateInvitationResponse{
+		Invitation: toProtoInvitation(inv),
+		Code:       code,
+	}, nil
+}
+
+func (s *serverAPI) ListInvitations(
+	ctx context.Context,
+	req *ssov1.ListInvitationsRequest,
+) (*ssov1.ListInvitationsResponse, error) {
+	actorID, _ := authz.UserID(ctx)
+
+	invs, err := s.invitations.ListInvitations(ctx, actorID, req.GetOrgId())
+	if err != nil {
+		return nil, invitationError(err)
+	}
+
+	resp := &ssov1.ListInvitationsResponse{Invitations: make([]*ssov1.Invitation, 0, len(invs))}
+	for _, inv := range invs {
+		resp.Invitations = append(resp.Invitations, toProtoInvitation(inv))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeInvitation(
+	ctx context.Context,
+	req *ssov1.RevokeInvitationRequest,
+) (*ssov1.RevokeInvitationResponse, error) {
+	// Validation
+	if req.GetInvitationId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invitation_id is required")
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	if err := s.invitations.RevokeInvitation(ctx, actorID, req.GetInvitationId()); err != nil {
+		return nil, invitationError(err)
+	}
+
+	return &ssov1.RevokeInvitationResponse{}, nil
+}
+
+func (s *serverAPI) AcceptInvitation(
+	ctx context.Context,
+	req *ssov1.AcceptInvitationRequest,
+) (*ssov1.AcceptInvitationResponse, error) {
+	// Validation
+	if err := validation.ValidateAcceptInvitation(req); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.invitations.AcceptInvitation(ctx, req.GetCode(), req.GetPassword())
+	if err != nil {
+		return nil, invitationError(err)
+	}
+
+	return &ssov1.AcceptInvitationResponse{UserId: userID}, nil
+}
+
+func invitationError(err error) error {
+	switch {
+	case errors.Is(err, invitations.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, invitations.ErrInvitationNotFound):
+		return status.Error(codes.NotFound, "invitation not found")
+	case errors.Is(err, invitations.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, invitations.ErrOrgNotFound):
+		return status.Error(codes.NotFound, "organization not found")
+	case errors.Is(err, invitations.ErrInvitationInvalid):
+		return status.Error(codes.FailedPrecondition, "invitation is invalid or expired")
+	case errors.Is(err, invitations.ErrInvalidTarget):
+		return status.Error(codes.InvalidArgument, "org_id or app_id of the same organization is required")
+	case errors.Is(err, invitations.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, "role must be admin or member, app invitation can't grant admin")
+	case errors.Is(err, invitations.ErrInvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid email or password")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toProtoInvitation(inv models.Invitation) *ssov1.Invitation {
+	return &ssov1.Invitation{
+		Id:         inv.ID,
+		Email:      inv.Email,
+		OrgId:      inv.OrgID,
+		AppId:      int32(inv.AppID),
+		Role:       inv.Role,
+		CreatedBy:  inv.CreatedBy,
+		ExpiresAt:  inv.ExpiresAt.Unix(),
+		AcceptedBy: inv.AcceptedBy,
+		Pending:    inv.Pending(time.Now()),
+		Revoked:    !inv.RevokedAt.IsZero(),
+	}
+}
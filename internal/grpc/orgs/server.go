@@ -0,0 +1,212 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/orgs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Orgs interface {
+	CreateOrg(ctx context.Context, actorID int64, name string) (int64, error)
+	ListOrgs(ctx context.Context, actorID int64) ([]models.Org, error)
+	DeleteOrg(ctx context.Context, actorID int64, orgID int64) error
+	SetMember(ctx context.Context, actorID int64, member models.OrgMember) error
+	RemoveMember(ctx context.Context, actorID int64, orgID int64, userID int64) error
+	ListMembers(ctx context.Context, actorID int64, orgID int64) ([]models.OrgMember, error)
+	ListApps(ctx context.Context, actorID int64, orgID int64) ([]models.App, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedOrgsServer
+	orgs Orgs
+}
+
+// ServiceName is used to require authenticated user for all orgs methods
+var ServiceName = ssov1.Orgs_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, orgs Orgs) {
+	ssov1.RegisterOrgsServer(gRPC, &serverAPI{orgs: orgs})
+}
+
+func (s *serverAPI) CreateOrg(ctx context.Context, req *ssov1.CreateOrgRequest) (*ssov1.CreateOrgResponse, error) {
+	// Validation
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	orgID, err := s.orgs.CreateOrg(ctx, actorID, req.GetName())
+	if err != nil {
+		return nil, orgError(err)
+	}
+
+	return &ssov1.CreateOrgResponse{OrgId: orgID}, nil
+}
+
+func (s *serverAPI) ListOrgs(ctx context.Context, req *ssov1.ListOrgsRequest) (*ssov1.ListOrgsResponse, error) {
+	actorID, _ := authz.UserID(ctx)
+
+	orgs, err := s.orgs.ListOrgs(ctx, actorID)
+	if err != nil {
+		return nil, orgError(err)
+	}
+
+	resp := &ssov1.ListOrgsResponse{Orgs: make([]*ssov1.Org, 0, len(orgs))}
+	for _, org := range orgs {
+		resp.Orgs = append(resp.Orgs, &ssov1.Org{Id: org.ID, Name: org.Name})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeleteOrg(ctx context.Context, req *ssov1.DeleteOrgRequest) (*ssov1.DeleteOrgResponse, error) {
+	// Validation
+	if err := validation.ValidateOrgID(req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	if err := s.orgs.DeleteOrg(ctx, actorID, req.GetOrgId()); err != nil {
+		return nil, orgError(err)
+	}
+
+	return &ssov1.DeleteOrgResponse{}, nil
+}
+
+func (s *serverAPI) SetOrgMember(
+	ctx context.Context,
+	req *ssov1.SetOrgMemberRequest,
+) (*ssov1.SetOrgMemberResponse, error) {
+	// Validation
+	if err := validation.ValidateOrgID(req.GetOrgId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	role := req.GetRole()
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+
+	err := s.orgs.SetMember(ctx, actorID, models.OrgMember{
+		OrgID:  req.GetOrgId(),
+		UserID: req.GetUserId(),
+		Role:   role,
+	})
+	if err != nil {
+		return nil, orgError(err)
+	}
+
+	return &ssov1.SetOrgMemberResponse{}, nil
+}
+
+func (s *serverAPI) RemoveOrgMember(
+	ctx context.Context,
+	req *ssov1.RemoveOrgMemberRequest,
+) (*ssov1.RemoveOrgMemberResponse, error) {
+	// Validation
+	if err := validation.ValidateOrgID(req.GetOrgId()); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	if err := s.orgs.RemoveMember(ctx, actorID, req.GetOrgId(), req.GetUserId()); err != nil {
+		return nil, orgError(err)
+	}
+
+	return &ssov1.RemoveOrgMemberResponse{}, nil
+}
+
+func (s *serverAPI) ListOrgMembers(
+	ctx context.Context,
+	req *ssov1.ListOrgMembersRequest,
+) (*ssov1.ListOrgMembersResponse, error) {
+	// Validation
+	if err := validation.ValidateOrgID(req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	members, err := s.orgs.ListMembers(ctx, actorID, req.GetOrgId())
+	if err != nil {
+		return nil, orgError(err)
+	}
+
+	resp := &ssov1.ListOrgMembersResponse{Members: make([]*ssov1.OrgMember, 0, len(members))}
+	for _, member := range members {
+		resp.Members = append(resp.Members, &ssov1.OrgMember{
+			UserId: member.UserID,
+			Role:   member.Role,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) ListOrgApps(
+	ctx context.Context,
+	req *ssov1.ListOrgAppsRequest,
+) (*ssov1.ListOrgAppsResponse, error) {
+	// Validation
+	if err := validation.ValidateOrgID(req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	apps, err := s.orgs.ListApps(ctx, actorID, req.GetOrgId())
+	if err != nil {
+		return nil, orgError(err)
+	}
+
+	resp := &ssov1.ListOrgAppsResponse{Apps: make([]*ssov1.App, 0, len(apps))}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, &ssov1.App{
+			Id:    int32(app.ID),
+			Name:  app.Name,
+			OrgId: app.OrgID,
+		})
+	}
+
+	return resp, nil
+}
+
+func orgError(err error) error {
+	switch {
+	case errors.Is(err, orgs.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, orgs.ErrOrgNotFound):
+		return status.Error(codes.NotFound, "organization not found")
+	case errors.Is(err, orgs.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, orgs.ErrOrgMemberNotFound):
+		return status.Error(codes.NotFound, "organization member not found")
+	case errors.Is(err, orgs.ErrOrgExists):
+		return status.Error(codes.AlreadyExists, "organization already exists")
+	case errors.Is(err, orgs.ErrOrgHasApps):
+		return status.Error(codes.FailedPrecondition, "organization has apps")
+	case errors.Is(err, orgs.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, "role must be admin or member")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,159 @@
+package passkeys
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Passkeys manages passkeys of the calling user. Login by passkey is
+// part of Auth API
+type Passkeys interface {
+	BeginPasskeyRegistration(ctx context.Context, userID int64) (challenge string, options []byte, err error)
+	FinishPasskeyRegistration(
+		ctx context.Context,
+		userID int64,
+		challenge string,
+		credential []byte,
+		name string,
+	) (passkeyID int64, err error)
+	ListPasskeys(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	DeletePasskey(ctx context.Context, userID int64, passkeyID int64) error
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedPasskeysServer
+	passkeys Passkeys
+}
+
+var (
+	// ServiceName is used to require authenticated user for passkeys methods
+	ServiceName = ssov1.Passkeys_ServiceDesc.ServiceName
+	// BeginPasskeyRegistrationMethod adds a way to sign in as user
+	BeginPasskeyRegistrationMethod = "/" + ServiceName + "/BeginPasskeyRegistration"
+	// DeletePasskeyMethod removes a way to sign in, possibly the second factor
+	DeletePasskeyMethod = "/" + ServiceName + "/DeletePasskey"
+)
+
+func Register(gRPC *grpc.Server, passkeys Passkeys) {
+	ssov1.RegisterPasskeysServer(gRPC, &serverAPI{passkeys: passkeys})
+}
+
+func (s *serverAPI) BeginPasskeyRegistration(
+	ctx context.Context,
+	req *ssov1.BeginPasskeyRegistrationRequest,
+) (*ssov1.BeginPasskeyRegistrationResponse, error) {
+	userID, _ := authz.UserID(ctx)
+
+	challenge, options, err := s.passkeys.BeginPasskeyRegistration(ctx, userID)
+	if err != nil {
+		return nil, passkeyError(err)
+	}
+
+	return &ssov1.BeginPasskeyRegistrationResponse{Challenge: challenge, Options: string(options)}, nil
+}
+
+func (s *serverAPI) FinishPasskeyRegistration(
+	ctx context.Context,
+	req *ssov1.FinishPasskeyRegistrationRequest,
+) (*ssov1.FinishPasskeyRegistrationResponse, error) {
+	// Validation
+	if err := validation.ValidateFinishPasskeyRegistration(req); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	passkeyID, err := s.passkeys.FinishPasskeyRegistration(ctx,
+		userID,
+		req.GetChallenge(),
+		[]byte(req.GetCredential()),
+		req.GetName(),
+	)
+	if err != nil {
+		return nil, passkeyError(err)
+	}
+
+	return &ssov1.FinishPasskeyRegistrationResponse{PasskeyId: passkeyID}, nil
+}
+
+func (s *serverAPI) ListPasskeys(
+	ctx context.Context,
+	req *ssov1.ListPasskeysRequest,
+) (*ssov1.ListPasskeysResponse, error) {
+	userID, _ := authz.UserID(ctx)
+
+	creds, err := s.passkeys.ListPasskeys(ctx, userID)
+	if err != nil {
+		return nil, passkeyError(err)
+	}
+
+	resp := &ssov1.ListPasskeysResponse{Passkeys: make([]*ssov1.Passkey, 0, len(creds))}
+	for _, cred := range creds {
+		resp.Passkeys = append(resp.Passkeys, &ssov1.Passkey{
+			Id:             cred.ID,
+			Name:           cred.Name,
+			Transports:     cred.Transports,
+			BackupEligible: cred.BackupEligible,
+			BackupState:    cred.BackupState,
+			CreatedAt:      cred.CreatedAt.Unix(),
+			LastUsedAt:     unixOrZero(cred.LastUsedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeletePasskey(
+	ctx context.Context,
+	req *ssov1.DeletePasskeyRequest,
+) (*ssov1.DeletePasskeyResponse, error) {
+	// Validation
+	if err := validation.ValidatePasskeyID(req.GetPasskeyId()); err != nil {
+		return nil, err
+	}
+
+	userID, _ := authz.UserID(ctx)
+
+	if err := s.passkeys.DeletePasskey(ctx, userID, req.GetPasskeyId()); err != nil {
+		return nil, passkeyError(err)
+	}
+
+	return &ssov1.DeletePasskeyResponse{}, nil
+}
+
+func passkeyError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, auth.ErrInvalidPasskey):
+		return status.Error(codes.InvalidArgument, "invalid passkey")
+	case errors.Is(err, auth.ErrInvalidPasskeySession):
+		return status.Error(codes.FailedPrecondition, "challenge is invalid or expired")
+	case errors.Is(err, auth.ErrPasskeyExists):
+		return status.Error(codes.AlreadyExists, "passkey already registered")
+	case errors.Is(err, auth.ErrPasskeyNotFound):
+		return status.Error(codes.NotFound, "passkey not found")
+	case errors.Is(err, auth.ErrLastPasskey):
+		return status.Error(codes.FailedPrecondition, "last passkey is used as second factor")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
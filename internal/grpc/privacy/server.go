@@ -0,0 +1,86 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/services/privacy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Privacy interface {
+	ExportUserData(ctx context.Context, actorID int64, userID int64, send func(privacy.Section) error) error
+	EraseUser(ctx context.Context, actorID int64, userID int64, password string) error
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedPrivacyServer
+	privacy Privacy
+}
+
+var (
+	// ServiceName is used to require authenticated user for privacy methods
+	ServiceName = ssov1.Privacy_ServiceDesc.ServiceName
+	// ExportUserDataMethod hands out everything held about user
+	ExportUserDataMethod = "/" + ServiceName + "/ExportUserData"
+	// EraseUserMethod deletes account of user for good
+	EraseUserMethod = "/" + ServiceName + "/EraseUser"
+)
+
+func Register(gRPC *grpc.Server, privacy Privacy) {
+	ssov1.RegisterPrivacyServer(gRPC, &serverAPI{privacy: privacy})
+}
+
+func (s *serverAPI) ExportUserData(req *ssov1.ExportUserDataRequest, stream ssov1.Privacy_ExportUserDataServer) error {
+	ctx := stream.Context()
+	actorID, _ := authz.UserID(ctx)
+
+	err := s.privacy.ExportUserData(ctx, actorID, req.GetUserId(), func(section privacy.Section) error {
+		return stream.Send(&ssov1.ExportUserDataResponse{
+			Section: section.Name,
+			Data:    section.Data,
+		})
+	})
+	if err != nil {
+		return privacyError(err)
+	}
+
+	return nil
+}
+
+func (s *serverAPI) EraseUser(ctx context.Context, req *ssov1.EraseUserRequest) (*ssov1.EraseUserResponse, error) {
+	actorID, _ := authz.UserID(ctx)
+
+	// Validation
+	if (req.GetUserId() == 0 || req.GetUserId() == actorID) && req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required to erase own account")
+	}
+
+	if err := s.privacy.EraseUser(ctx, actorID, req.GetUserId(), req.GetPassword()); err != nil {
+		return nil, privacyError(err)
+	}
+
+	return &ssov1.EraseUserResponse{}, nil
+}
+
+func privacyError(err error) error {
+	if st, ok := status.FromError(err); ok {
+		// Error of stream send is already a status
+		return st.Err()
+	}
+
+	switch {
+	case errors.Is(err, privacy.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, privacy.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, privacy.ErrInvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid password")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,179 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/grpc/authz"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/services/profiles"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Profiles interface {
+	GetProfile(ctx context.Context, actorID int64, userID int64) (models.Profile, error)
+	UpdateProfile(ctx context.Context, actorID int64, userID int64, update profiles.Update) (models.Profile, error)
+	SetIdentifiers(
+		ctx context.Context,
+		actorID int64,
+		userID int64,
+		username *string,
+		phone *string,
+	) (models.User, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedProfilesServer
+	profiles Profiles
+}
+
+// ServiceName is used to require authenticated user for profiles methods
+var ServiceName = ssov1.Profiles_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, profiles Profiles) {
+	ssov1.RegisterProfilesServer(gRPC, &serverAPI{profiles: profiles})
+}
+
+func (s *serverAPI) GetProfile(ctx context.Context, req *ssov1.GetProfileRequest) (*ssov1.GetProfileResponse, error) {
+	actorID, _ := authz.UserID(ctx)
+
+	profile, err := s.profiles.GetProfile(ctx, actorID, req.GetUserId())
+	if err != nil {
+		return nil, profileError(err)
+	}
+
+	resp, err := toProtoProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssov1.GetProfileResponse{Profile: resp}, nil
+}
+
+func (s *serverAPI) UpdateProfile(
+	ctx context.Context,
+	req *ssov1.UpdateProfileRequest,
+) (*ssov1.UpdateProfileResponse, error) {
+	update := profiles.Update{
+		DisplayName: req.DisplayName,
+		Locale:      req.Locale,
+		TimeZone:    req.TimeZone,
+		AvatarURL:   req.AvatarUrl,
+	}
+
+	// Validation
+	var err error
+	if req.Metadata != nil {
+		if update.Metadata, err = parseMetadata(req.GetMetadata()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "metadata must be a JSON object")
+		}
+	}
+	if req.AdminMetadata != nil {
+		if update.AdminMetadata, err = parseMetadata(req.GetAdminMetadata()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "admin_metadata must be a JSON object")
+		}
+	}
+
+	actorID, _ := authz.UserID(ctx)
+
+	profile, err := s.profiles.UpdateProfile(ctx, actorID, req.GetUserId(), update)
+	if err != nil {
+		return nil, profileError(err)
+	}
+
+	resp, err := toProtoProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssov1.UpdateProfileResponse{Profile: resp}, nil
+}
+
+func (s *serverAPI) SetLoginIdentifiers(
+	ctx context.Context,
+	req *ssov1.SetLoginIdentifiersRequest,
+) (*ssov1.SetLoginIdentifiersResponse, error) {
+	actorID, _ := authz.UserID(ctx)
+
+	user, err := s.profiles.SetIdentifiers(ctx, actorID, req.GetUserId(), req.Username, req.Phone)
+	if err != nil {
+		return nil, profileError(err)
+	}
+
+	return &ssov1.SetLoginIdentifiersResponse{
+		Username: user.Username,
+		Phone:    user.Phone,
+	}, nil
+}
+
+// parseMetadata parses JSON object. Empty string clears metadata
+func parseMetadata(raw string) (map[string]any, error) {
+	metadata := map[string]any{}
+	if raw == "" {
+		return metadata, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	return metadata, nil
+}
+
+func profileError(err error) error {
+	switch {
+	case errors.Is(err, profiles.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, profiles.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, profiles.ErrInvalidUsername):
+		return status.Error(codes.InvalidArgument, identifiers.ErrInvalidUsername.Error())
+	case errors.Is(err, profiles.ErrInvalidPhone):
+		return status.Error(codes.InvalidArgument, identifiers.ErrInvalidPhone.Error())
+	case errors.Is(err, profiles.ErrUsernameExists):
+		return status.Error(codes.AlreadyExists, "username already taken")
+	case errors.Is(err, profiles.ErrPhoneExists):
+		return status.Error(codes.AlreadyExists, "phone already taken")
+	case errors.Is(err, profiles.ErrInvalidProfile):
+		return status.Error(codes.InvalidArgument, "invalid display name, locale, time zone, avatar url or metadata")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toProtoProfile(profile models.Profile) (*ssov1.Profile, error) {
+	metadata, err := json.Marshal(orEmpty(profile.Metadata))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	adminMetadata, err := json.Marshal(orEmpty(profile.AdminMetadata))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.Profile{
+		UserId:        profile.UserID,
+		DisplayName:   profile.DisplayName,
+		Locale:        profile.Locale,
+		TimeZone:      profile.TimeZone,
+		AvatarUrl:     profile.AvatarURL,
+		Metadata:      string(metadata),
+		AdminMetadata: string(adminMetadata),
+	}, nil
+}
+
+func orEmpty(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		return map[string]any{}
+	}
+
+	return metadata
+}
@@ -0,0 +1,175 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+
+	ssov1 "github.com/m1al04949/contracts/contracts/gen/go/sso"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/validation"
+	"github.com/m1al04949/sso-gRPC/internal/services/webhooks"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Webhooks interface {
+	CreateWebhook(ctx context.Context, appID int, url string, eventTypes []string) (models.Webhook, error)
+	ListWebhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int64) error
+	ListDeliveries(ctx context.Context, webhookID int64, status models.DeliveryStatus) ([]models.WebhookDelivery, error)
+	ReplayDeliveries(ctx context.Context, webhookID int64, deliveryID int64) (int64, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedWebhooksServer
+	webhooks Webhooks
+}
+
+// ServiceName is used to guard all webhooks methods with admin authorization
+var ServiceName = ssov1.Webhooks_ServiceDesc.ServiceName
+
+func Register(gRPC *grpc.Server, webhooks Webhooks) {
+	ssov1.RegisterWebhooksServer(gRPC, &serverAPI{webhooks: webhooks})
+}
+
+func (s *serverAPI) CreateWebhook(
+	ctx context.Context,
+	req *ssov1.CreateWebhookRequest,
+) (*ssov1.CreateWebhookResponse, error) {
+	// Validation
+	if err := validation.ValidateCreateWebhook(req); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhooks.CreateWebhook(ctx, int(req.GetAppId()), req.GetUrl(), req.GetEventTypes())
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &ssov1.CreateWebhookResponse{
+		Webhook: toProtoWebhook(webhook),
+		Secret:  webhook.Secret,
+	}, nil
+}
+
+func (s *serverAPI) ListWebhooks(
+	ctx context.Context,
+	req *ssov1.ListWebhooksRequest,
+) (*ssov1.ListWebhooksResponse, error) {
+	list, err := s.webhooks.ListWebhooks(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	resp := &ssov1.ListWebhooksResponse{Webhooks: make([]*ssov1.Webhook, 0, len(list))}
+	for _, webhook := range list {
+		resp.Webhooks = append(resp.Webhooks, toProtoWebhook(webhook))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeleteWebhook(
+	ctx context.Context,
+	req *ssov1.DeleteWebhookRequest,
+) (*ssov1.DeleteWebhookResponse, error) {
+	// Validation
+	if err := validation.ValidateWebhookID(req.GetWebhookId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.webhooks.DeleteWebhook(ctx, req.GetWebhookId()); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &ssov1.DeleteWebhookResponse{}, nil
+}
+
+func (s *serverAPI) ListWebhookDeliveries(
+	ctx context.Context,
+	req *ssov1.ListWebhookDeliveriesRequest,
+) (*ssov1.ListWebhookDeliveriesResponse, error) {
+	// Validation
+	if err := validation.ValidateWebhookID(req.GetWebhookId()); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhooks.ListDeliveries(ctx, req.GetWebhookId(), models.DeliveryStatus(req.GetStatus()))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	resp := &ssov1.ListWebhookDeliveriesResponse{
+		Deliveries: make([]*ssov1.WebhookDelivery, 0, len(deliveries)),
+	}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, &ssov1.WebhookDelivery{
+			Id:            delivery.ID,
+			EventId:       delivery.Event.ID,
+			EventType:     delivery.Event.Type,
+			Status:        string(delivery.Status),
+			Attempts:      int32(delivery.Attempts),
+			NextAttemptAt: delivery.NextAttemptAt.Unix(),
+			LastError:     delivery.LastError,
+			DeliveredAt:   unixOrZero(delivery),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) ReplayWebhookDeliveries(
+	ctx context.Context,
+	req *ssov1.ReplayWebhookDeliveriesRequest,
+) (*ssov1.ReplayWebhookDeliveriesResponse, error) {
+	// Validation
+	if err := validation.ValidateWebhookID(req.GetWebhookId()); err != nil {
+		return nil, err
+	}
+
+	n, err := s.webhooks.ReplayDeliveries(ctx, req.GetWebhookId(), req.GetDeliveryId())
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &ssov1.ReplayWebhookDeliveriesResponse{Replayed: n}, nil
+}
+
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, webhooks.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		return status.Error(codes.NotFound, "webhook not found")
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return status.Error(codes.NotFound, "webhook delivery not found")
+	case errors.Is(err, webhooks.ErrInvalidURL):
+		return status.Error(codes.InvalidArgument, "url must be an absolute http or https url")
+	case errors.Is(err, webhooks.ErrInvalidEventType):
+		return status.Error(codes.InvalidArgument, "unknown event type")
+	case errors.Is(err, webhooks.ErrInvalidDeliveryStatus):
+		return status.Error(codes.InvalidArgument, "invalid delivery status")
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+// toProtoWebhook converts webhook without its secret
+func toProtoWebhook(webhook models.Webhook) *ssov1.Webhook {
+	return &ssov1.Webhook{
+		Id:         webhook.ID,
+		AppId:      int32(webhook.AppID),
+		Url:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		CreatedAt:  webhook.CreatedAt.Unix(),
+	}
+}
+
+func unixOrZero(delivery models.WebhookDelivery) int64 {
+	if delivery.DeliveredAt.IsZero() {
+		return 0
+	}
+
+	return delivery.DeliveredAt.Unix()
+}
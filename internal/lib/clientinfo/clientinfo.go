@@ -0,0 +1,27 @@
+// Package clientinfo passes what is known about client of a call from
+// transport to services
+package clientinfo
+
+import "context"
+
+// Info describes client of a call. Device is the fingerprint client
+// sends to be recognized on later calls, empty if it sent none
+type Info struct {
+	IP        string
+	UserAgent string
+	Device    string
+}
+
+type infoKey struct{}
+
+// NewContext returns context carrying info about client
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// FromContext returns info about client, zero if context has none
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(infoKey{}).(Info)
+
+	return info
+}
@@ -0,0 +1,53 @@
+package codes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+const codeLen = 32
+
+// New returns random single-use code to hand out to user and its hash
+// to be stored instead of the code
+func New() (code string, hash string, err error) {
+	b := make([]byte, codeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	code = base64.RawURLEncoding.EncodeToString(b)
+
+	return code, Hash(code), nil
+}
+
+// Hash returns hash code is stored and looked up by
+func Hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// NewNumeric returns random code of digits user can type in. Short codes
+// are easy to brute force by hash, so they have to be hashed along with
+// a secret value, see HashWith
+func NewNumeric(digits int) (string, error) {
+	var b strings.Builder
+	for range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+
+	return b.String(), nil
+}
+
+// HashWith returns hash of code salted with secret
+func HashWith(secret string, code string) string {
+	return Hash(secret + "." + code)
+}
@@ -0,0 +1,24 @@
+package codes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNumeric(t *testing.T) {
+	code, err := NewNumeric(6)
+	require.NoError(t, err)
+
+	assert.Len(t, code, 6)
+	for _, c := range code {
+		assert.True(t, c >= '0' && c <= '9', "code %q has non-digit", code)
+	}
+}
+
+func TestHashWith(t *testing.T) {
+	assert.Equal(t, HashWith("secret", "123456"), HashWith("secret", "123456"))
+	assert.NotEqual(t, HashWith("secret", "123456"), HashWith("other", "123456"))
+	assert.NotEqual(t, Hash("123456"), HashWith("secret", "123456"))
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// batchSize is how many events a watcher takes from history at once
+const batchSize = 100
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorExpired = errors.New("cursor expired")
+)
+
+// Bus is an in-memory event bus. It keeps the most recent events so
+// that watchers can resume from a cursor. Cursors are bound to the bus
+// instance and expire on restart or when the event is evicted.
+// Services do not publish to the bus directly: the webhooks dispatcher
+// publishes outbox events once it relays them, so watchers see events
+// only while the dispatcher runs and within its poll interval
+type Bus struct {
+	log   *slog.Logger
+	epoch int64
+
+	mu      sync.Mutex
+	lastID  int64
+	history []models.Event
+	// published is closed and replaced on every publish to wake watchers
+	published chan struct{}
+}
+
+// New returns event bus keeping up to historySize recent events
+func New(log *slog.Logger, historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = 1
+	}
+
+	return &Bus{
+		log:       log,
+		epoch:     time.Now().UnixNano(),
+		history:   make([]models.Event, historySize),
+		published: make(chan struct{}),
+	}
+}
+
+// Publish assigns event the next ID and delivers it to watchers.
+// Zero event time means now. It never blocks on watchers
+func (b *Bus) Publish(event models.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	b.history[b.index(event.ID)] = event
+
+	close(b.published)
+	b.published = make(chan struct{})
+
+	b.log.Debug("event published",
+		slog.String("op", "events.Publish"),
+		slog.Int64("id", event.ID),
+		slog.String("type", event.Type),
+	)
+}
+
+// Watch passes events matching filter to send along with cursor to
+// resume after them until ctx is done or send fails. Empty cursor
+// watches events published from now on
+func (b *Bus) Watch(
+	ctx context.Context,
+	cursor string,
+	filter models.EventFilter,
+	send func(event models.Event, cursor string) error,
+) error {
+	const op = "events.Watch"
+
+	afterID, err := b.parseCursor(cursor)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		events, published, err := b.after(afterID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range events {
+			afterID = event.ID
+			if !filter.Match(event) {
+				continue
+			}
+			if err := send(event, b.cursor(event.ID)); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-published:
+		}
+	}
+}
+
+// after returns events published after afterID and channel which is
+// closed on the next publish
+func (b *Bus) after(afterID int64) ([]models.Event, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if afterID > b.lastID {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	oldestID := max(b.lastID-int64(len(b.history))+1, 1)
+	if afterID < oldestID-1 {
+		return nil, nil, ErrCursorExpired
+	}
+
+	var events []models.Event
+	for id := afterID + 1; id <= b.lastID && len(events) < batchSize; id++ {
+		events = append(events, b.history[b.index(id)])
+	}
+
+	return events, b.published, nil
+}
+
+func (b *Bus) index(id int64) int {
+	return int((id - 1) % int64(len(b.history)))
+}
+
+// cursor encodes bus epoch with event id
+func (b *Bus) cursor(eventID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", b.epoch, eventID)))
+}
+
+func (b *Bus) parseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		return b.lastID, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	var epoch, eventID int64
+	if _, err := fmt.Sscanf(string(raw), "%d.%d", &epoch, &eventID); err != nil {
+		return 0, ErrInvalidCursor
+	}
+	if epoch != b.epoch {
+		return 0, ErrCursorExpired
+	}
+
+	return eventID, nil
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errStop = errors.New("stop")
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// collect watches bus from cursor until n events are received
+func collect(t *testing.T, b *Bus, cursor string, filter models.EventFilter, n int) ([]models.Event, string) {
+	t.Helper()
+
+	var (
+		events []models.Event
+		last   string
+	)
+	err := b.Watch(context.Background(), cursor, filter, func(event models.Event, cursor string) error {
+		events = append(events, event)
+		last = cursor
+		if len(events) == n {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+
+	return events, last
+}
+
+func TestBus_WatchResumesFromCursor(t *testing.T) {
+	b := New(discardLogger(), 10)
+
+	b.Publish(models.Event{Type: models.EventUserRegistered, UserID: 1})
+	b.Publish(models.Event{Type: models.EventUserLoggedIn, UserID: 1, AppID: 2})
+	b.Publish(models.Event{Type: models.EventUserLoggedIn, UserID: 1, AppID: 3})
+
+	first, cursor := collect(t, b, b.cursor(0), models.EventFilter{}, 1)
+	assert.Equal(t, models.EventUserRegistered, first[0].Type)
+	assert.False(t, first[0].Time.IsZero())
+
+	rest, _ := collect(t, b, cursor, models.EventFilter{Types: []string{models.EventUserLoggedIn}, AppID: 3}, 1)
+	assert.Equal(t, int64(3), rest[0].ID)
+}
+
+func TestBus_WatchWaitsForEvents(t *testing.T) {
+	b := New(discardLogger(), 10)
+	b.Publish(models.Event{Type: models.EventUserRegistered})
+
+	// Empty cursor skips events published before watching
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := b.Watch(ctx, "", models.EventFilter{}, func(models.Event, string) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go b.Publish(models.Event{Type: models.EventUserLoggedIn})
+
+	events, _ := collect(t, b, b.cursor(1), models.EventFilter{}, 1)
+	assert.Equal(t, models.EventUserLoggedIn, events[0].Type)
+}
+
+func TestBus_WatchCursorErrors(t *testing.T) {
+	b := New(discardLogger(), 2)
+	for range 3 {
+		b.Publish(models.Event{Type: models.EventUserLoggedIn})
+	}
+
+	send := func(models.Event, string) error { return nil }
+	ctx := context.Background()
+
+	assert.ErrorIs(t, b.Watch(ctx, b.cursor(0), models.EventFilter{}, send), ErrCursorExpired)
+	assert.ErrorIs(t, b.Watch(ctx, b.cursor(4), models.EventFilter{}, send), ErrInvalidCursor)
+	assert.ErrorIs(t, b.Watch(ctx, "???", models.EventFilter{}, send), ErrInvalidCursor)
+	assert.ErrorIs(t, b.Watch(ctx, New(discardLogger(), 2).cursor(1), models.EventFilter{}, send),
+		ErrCursorExpired)
+}
@@ -0,0 +1,68 @@
+package identifiers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Kind is a kind of login identifier
+type Kind int
+
+const (
+	KindEmail Kind = iota
+	KindUsername
+	KindPhone
+)
+
+var (
+	ErrInvalidUsername = errors.New("username must be 3-32 letters, digits, '.', '_' or '-' starting with a letter")
+	ErrInvalidPhone    = errors.New("phone must be in international format")
+)
+
+var (
+	usernameRe = regexp.MustCompile(`^[a-z][a-z0-9._-]{2,31}$`)
+	phoneRe    = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// phoneSeparators are stripped from phone numbers
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+)
+
+// Detect returns kind of login. Emails contain '@', phone numbers
+// start with '+', anything else is a username
+func Detect(login string) Kind {
+	login = strings.TrimSpace(login)
+
+	switch {
+	case strings.Contains(login, "@"):
+		return KindEmail
+	case strings.HasPrefix(login, "+"):
+		return KindPhone
+	}
+
+	return KindUsername
+}
+
+// CanonicalEmail returns email emails are compared by
+func CanonicalEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CanonicalUsername returns lowercased username or error if it's invalid
+func CanonicalUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernameRe.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+
+	return username, nil
+}
+
+// CanonicalPhone returns phone number in E.164 format or error if it's invalid
+func CanonicalPhone(phone string) (string, error) {
+	phone = phoneSeparators.Replace(strings.TrimSpace(phone))
+	if !phoneRe.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+
+	return phone, nil
+}
@@ -0,0 +1,34 @@
+package identifiers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, KindEmail, Detect("Foo@Example.com"))
+	assert.Equal(t, KindPhone, Detect(" +1 (555) 010-0000"))
+	assert.Equal(t, KindUsername, Detect("john.doe"))
+}
+
+func TestCanonical(t *testing.T) {
+	assert.Equal(t, "foo@example.com", CanonicalEmail(" Foo@Example.COM "))
+
+	username, err := CanonicalUsername("John.Doe")
+	require.NoError(t, err)
+	assert.Equal(t, "john.doe", username)
+
+	_, err = CanonicalUsername("1john")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	_, err = CanonicalUsername("jo")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+
+	phone, err := CanonicalPhone("+1 (555) 010-0000")
+	require.NoError(t, err)
+	assert.Equal(t, "+15550100000", phone)
+
+	_, err = CanonicalPhone("5550100000")
+	assert.ErrorIs(t, err, ErrInvalidPhone)
+}
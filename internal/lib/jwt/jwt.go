@@ -1,13 +1,34 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/m1al04949/sso-gRPC/internal/domain/models"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the fields of token issued by NewToken
+type Claims struct {
+	UID   int64
+	Email string
+	AppID int
+	OrgID int64
+	Auth  Authentication
+}
+
+// Authentication is when and how user authenticated to get the token.
+// It is put into auth_time, amr and acr claims, zero values are omitted
+type Authentication struct {
+	Time    time.Time
+	Methods []string
+	Level   string
+}
+
+func NewToken(user models.User, app models.App, auth Authentication, duration time.Duration) (string, error) {
 
 	token := jwt.New(jwt.SigningMethodHS256)
 
@@ -16,6 +37,24 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 	claims["email"] = user.Email
 	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.ID
+	if app.OrgID != 0 {
+		claims["org_id"] = app.OrgID
+	}
+	if len(user.Groups) > 0 {
+		claims["groups"] = user.Groups
+	}
+	if len(user.Metadata) > 0 {
+		claims["metadata"] = user.Metadata
+	}
+	if !auth.Time.IsZero() {
+		claims["auth_time"] = auth.Time.Unix()
+	}
+	if len(auth.Methods) > 0 {
+		claims["amr"] = auth.Methods
+	}
+	if auth.Level != "" {
+		claims["acr"] = auth.Level
+	}
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -24,3 +63,76 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 
 	return tokenString, nil
 }
+
+// AppID returns app_id claim without verifying the token signature.
+// It is used to find out which app secret the token must be verified with
+func AppID(tokenString string) (int, error) {
+	var claims jwt.MapClaims
+
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	appID, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: app_id claim is missing", ErrInvalidToken)
+	}
+
+	return int(appID), nil
+}
+
+// Parse verifies token signature and expiration with the app secret
+// and returns its claims. During the grace period after secret rotation
+// tokens signed with the previous secret are accepted too
+func Parse(tokenString string, app models.App) (Claims, error) {
+	var (
+		claims jwt.MapClaims
+		err    error
+	)
+
+	for _, secret := range app.VerificationSecrets(time.Now()) {
+		claims = jwt.MapClaims{}
+
+		_, err = jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithExpirationRequired())
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
+		}
+	}
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	uid, _ := claims["uid"].(float64)
+	appID, _ := claims["app_id"].(float64)
+	email, _ := claims["email"].(string)
+	orgID, _ := claims["org_id"].(float64)
+	authTime, _ := claims["auth_time"].(float64)
+	acr, _ := claims["acr"].(string)
+
+	var auth Authentication
+	if authTime != 0 {
+		auth.Time = time.Unix(int64(authTime), 0)
+	}
+	auth.Level = acr
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, m := range amr {
+			if method, ok := m.(string); ok {
+				auth.Methods = append(auth.Methods, method)
+			}
+		}
+	}
+
+	if int(appID) != app.ID {
+		return Claims{}, fmt.Errorf("%w: app_id mismatch", ErrInvalidToken)
+	}
+
+	return Claims{
+		UID:   int64(uid),
+		Email: email,
+		AppID: int(appID),
+		OrgID: int64(orgID),
+		Auth:  auth,
+	}, nil
+}
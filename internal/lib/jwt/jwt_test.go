@@ -24,7 +24,7 @@ func TestNewToken_Happy(t *testing.T) {
 	ttl := 1 * time.Hour
 
 	t.Run("successful token generation", func(t *testing.T) {
-		token, err := NewToken(user, app, ttl)
+		token, err := NewToken(user, app, Authentication{}, ttl)
 		require.NoError(t, err)
 		assert.NotEmpty(t, token)
 
@@ -40,9 +40,137 @@ func TestNewToken_Happy(t *testing.T) {
 		assert.Equal(t, float64(user.ID), claims["uid"])
 		assert.Equal(t, user.Email, claims["email"])
 		assert.Equal(t, float64(app.ID), claims["app_id"])
+		assert.NotContains(t, claims, "org_id")
+		assert.NotContains(t, claims, "groups")
 
 		expectedExp := time.Now().Add(ttl).Unix()
 		actualExp := int64(claims["exp"].(float64))
 		assert.InDelta(t, expectedExp, actualExp, 1)
 	})
 }
+
+func TestParse(t *testing.T) {
+	user := models.User{
+		ID:    42,
+		Email: "test@example.com",
+	}
+
+	app := models.App{
+		ID:     1,
+		Secret: "test-secret",
+	}
+
+	token, err := NewToken(user, app, Authentication{}, time.Hour)
+	require.NoError(t, err)
+
+	t.Run("valid token", func(t *testing.T) {
+		appID, err := AppID(token)
+		require.NoError(t, err)
+		assert.Equal(t, app.ID, appID)
+
+		claims, err := Parse(token, app)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, claims.UID)
+		assert.Equal(t, user.Email, claims.Email)
+		assert.Equal(t, app.ID, claims.AppID)
+	})
+
+	t.Run("organization app", func(t *testing.T) {
+		orgApp := models.App{ID: 2, Secret: "org-secret", OrgID: 7}
+
+		orgToken, err := NewToken(user, orgApp, Authentication{}, time.Hour)
+		require.NoError(t, err)
+
+		claims, err := Parse(orgToken, orgApp)
+		require.NoError(t, err)
+		assert.Equal(t, orgApp.OrgID, claims.OrgID)
+	})
+
+	t.Run("user groups", func(t *testing.T) {
+		member := user
+		member.Groups = []string{"developers", "ops"}
+
+		groupsToken, err := NewToken(member, app, Authentication{}, time.Hour)
+		require.NoError(t, err)
+
+		parsed, err := jwt.Parse(groupsToken, func(*jwt.Token) (interface{}, error) {
+			return []byte(app.Secret), nil
+		})
+		require.NoError(t, err)
+
+		claims := parsed.Claims.(jwt.MapClaims)
+		assert.Equal(t, []interface{}{"developers", "ops"}, claims["groups"])
+	})
+
+	t.Run("profile metadata", func(t *testing.T) {
+		member := user
+		member.Metadata = map[string]any{"plan": "pro"}
+
+		metadataToken, err := NewToken(member, app, Authentication{}, time.Hour)
+		require.NoError(t, err)
+
+		parsed, err := jwt.Parse(metadataToken, func(*jwt.Token) (interface{}, error) {
+			return []byte(app.Secret), nil
+		})
+		require.NoError(t, err)
+
+		claims := parsed.Claims.(jwt.MapClaims)
+		assert.Equal(t, map[string]interface{}{"plan": "pro"}, claims["metadata"])
+	})
+
+	t.Run("authentication", func(t *testing.T) {
+		authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+		authToken, err := NewToken(user, app, Authentication{
+			Time:    authTime,
+			Methods: []string{"pwd", "otp", "mfa"},
+			Level:   "aal2",
+		}, time.Hour)
+		require.NoError(t, err)
+
+		claims, err := Parse(authToken, app)
+		require.NoError(t, err)
+		assert.True(t, authTime.Equal(claims.Auth.Time))
+		assert.Equal(t, []string{"pwd", "otp", "mfa"}, claims.Auth.Methods)
+		assert.Equal(t, "aal2", claims.Auth.Level)
+
+		claims, err = Parse(token, app)
+		require.NoError(t, err)
+		assert.Zero(t, claims.Auth)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		_, err := Parse(token, models.App{ID: app.ID, Secret: "other-secret"})
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("previous secret in grace period", func(t *testing.T) {
+		rotated := models.App{
+			ID:                  app.ID,
+			Secret:              "new-secret",
+			PrevSecret:          app.Secret,
+			PrevSecretExpiresAt: time.Now().Add(time.Hour),
+		}
+
+		claims, err := Parse(token, rotated)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, claims.UID)
+
+		rotated.PrevSecretExpiresAt = time.Now().Add(-time.Second)
+		_, err = Parse(token, rotated)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired, err := NewToken(user, app, Authentication{}, -time.Minute)
+		require.NoError(t, err)
+
+		_, err = Parse(expired, app)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, err := AppID("not-a-token")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
@@ -0,0 +1,138 @@
+// Package lru is a size and TTL bounded least recently used cache
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats counts lookups and evictions of cache since it was created
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// Cache keeps up to size entries for ttl each, cache of zero size keeps
+// nothing. It is safe for concurrent use
+type Cache[K comparable, V any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	items map[K]*list.Element
+	// order holds entries from the most to the least recently used
+	order *list.List
+	// version grows on every invalidation, see Version
+	version uint64
+	stats   Stats
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// New returns cache of up to size entries kept for ttl
+func New[K comparable, V any](size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		size:  max(size, 0),
+		ttl:   ttl,
+		now:   time.Now,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Get returns unexpired value by key and marks it recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if ok && c.now().Before(elem.Value.(*entry[K, V]).expiresAt) {
+		c.order.MoveToFront(elem)
+		c.stats.Hits++
+
+		return elem.Value.(*entry[K, V]).value, true
+	}
+	if ok {
+		c.remove(elem)
+	}
+	c.stats.Misses++
+
+	var zero V
+	return zero, false
+}
+
+// Version returns current version of cache. Read value from the source
+// after taking the version and pass it to Add, so the value is dropped
+// if it was invalidated while being read
+func (c *Cache[K, V]) Version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.version
+}
+
+// Add stores value by key unless cache was invalidated since version.
+// The least recently used entry is evicted when cache is full
+func (c *Cache[K, V]) Add(key K, value V, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version || c.size == 0 {
+		return
+	}
+
+	e := &entry[K, V]{key: key, value: value, expiresAt: c.now().Add(c.ttl)}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Remove invalidates value by key
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Purge invalidates all values
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	clear(c.items)
+	c.order.Init()
+}
+
+// Stats returns counters of cache
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+func (c *Cache[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry[K, V]).key)
+}
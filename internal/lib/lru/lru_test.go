@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New[int, string](2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Add(1, "one", c.Version())
+	c.Add(2, "two", c.Version())
+
+	// 1 is used, so 2 is evicted by 3
+	_, ok := c.Get(1)
+	assert.True(t, ok)
+	c.Add(3, "three", c.Version())
+
+	_, ok = c.Get(2)
+	assert.False(t, ok)
+	v, ok := c.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, "three", v)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get(1)
+	assert.False(t, ok)
+
+	assert.Equal(t, Stats{Hits: 2, Misses: 2, Evictions: 1}, c.Stats())
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	c := New[int, string](10, time.Minute)
+
+	c.Add(1, "one", c.Version())
+	c.Remove(1)
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+
+	// Value read before invalidation is not cached
+	version := c.Version()
+	c.Remove(2)
+	c.Add(1, "stale", version)
+	_, ok = c.Get(1)
+	assert.False(t, ok)
+
+	c.Add(1, "one", c.Version())
+	c.Add(2, "two", c.Version())
+	c.Purge()
+	_, ok = c.Get(1)
+	assert.False(t, ok)
+	_, ok = c.Get(2)
+	assert.False(t, ok)
+}
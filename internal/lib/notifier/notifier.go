@@ -0,0 +1,160 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+)
+
+const (
+	typeLog  = "log"
+	typeFile = "file"
+)
+
+// Message is a notification delivered to user
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Channel is the way messages reach user. Recipient of email is an email
+// address, recipient of SMS is a phone number
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// Channels routes messages to notifiers of channels
+type Channels map[Channel]Notifier
+
+// Send sends message through notifier of the channel
+func (c Channels) Send(ctx context.Context, channel Channel, msg Message) error {
+	n, ok := c[channel]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownChannel, channel)
+	}
+
+	return n.Send(ctx, msg)
+}
+
+// New returns notifier configured by type
+func New(log *slog.Logger, cfg config.NotifierConfig) (Notifier, error) {
+	const op = "notifier.New"
+
+	switch cfg.Type {
+	case typeLog, "":
+		return NewLog(log), nil
+	case typeFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("%s: file_path is required for file notifier", op)
+		}
+
+		return NewFile(cfg.FilePath), nil
+	}
+
+	return nil, fmt.Errorf("%s: unknown notifier type %q", op, cfg.Type)
+}
+
+// Log writes messages to the log. It is meant for local development
+type Log struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *Log {
+	return &Log{log: log}
+}
+
+func (l *Log) Send(_ context.Context, msg Message) error {
+	l.log.Info("notification",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
+
+// File appends messages to a file as JSON lines. It is meant for tests
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Send(_ context.Context, msg Message) error {
+	const op = "notifier.File.Send"
+
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Messages reads messages sent to the file
+func (f *File) Messages() ([]Message, error) {
+	const op = "notifier.File.Messages"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	var msgs []Message
+	dec := json.NewDecoder(file)
+	for dec.More() {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
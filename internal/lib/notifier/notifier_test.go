@@ -0,0 +1,48 @@
+package notifier
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_SendMessages(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "notifications.jsonl"))
+
+	msgs, err := f.Messages()
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	require.NoError(t, f.Send(context.Background(), Message{To: "a@test.com", Subject: "first", Body: "1"}))
+	require.NoError(t, f.Send(context.Background(), Message{To: "b@test.com", Subject: "second", Body: "2"}))
+
+	msgs, err = f.Messages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "a@test.com", msgs[0].To)
+	assert.Equal(t, "second", msgs[1].Subject)
+	assert.False(t, msgs[1].SentAt.IsZero())
+}
+
+func TestChannels_Send(t *testing.T) {
+	email := NewFile(filepath.Join(t.TempDir(), "email.jsonl"))
+	sms := NewFile(filepath.Join(t.TempDir(), "sms.jsonl"))
+	channels := Channels{ChannelEmail: email, ChannelSMS: sms}
+
+	require.NoError(t, channels.Send(context.Background(), ChannelSMS, Message{To: "+15550100", Body: "1"}))
+
+	msgs, err := sms.Messages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "+15550100", msgs[0].To)
+
+	msgs, err = email.Messages()
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	err = channels.Send(context.Background(), Channel("pigeon"), Message{})
+	assert.ErrorIs(t, err, ErrUnknownChannel)
+}
@@ -0,0 +1,314 @@
+package passkey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid webauthn response")
+	ErrCloneDetected   = errors.New("authenticator may be cloned")
+)
+
+// Config describes relying party. Origins are origins of pages that run
+// ceremonies, RPID is the domain passkeys are scoped to
+type Config struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	Timeout       time.Duration
+}
+
+// User is an account passkeys are registered for
+type User struct {
+	ID          int64
+	Name        string
+	Credentials []models.WebAuthnCredential
+}
+
+// RelyingParty runs WebAuthn registration and assertion ceremonies.
+// Options it returns are JSON passed to navigator.credentials as is.
+// Session is kept on server until the ceremony is finished with response
+// of the authenticator
+type RelyingParty struct {
+	webauthn *webauthn.WebAuthn
+}
+
+func New(cfg Config) (*RelyingParty, error) {
+	const op = "passkey.New"
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &RelyingParty{webauthn: wa}, nil
+}
+
+// BeginRegistration starts registration of new passkey. Passkeys the
+// user already has are excluded, so authenticator isn't registered twice
+func (rp *RelyingParty) BeginRegistration(user User) (options []byte, session []byte, err error) {
+	wu := newWebAuthnUser(user)
+
+	creation, data, err := rp.webauthn.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return marshal(creation, data)
+}
+
+// FinishRegistration verifies response of authenticator to registration
+// and returns new passkey of the user
+func (rp *RelyingParty) FinishRegistration(
+	user User,
+	session []byte,
+	response []byte,
+) (models.WebAuthnCredential, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	cred, err := rp.webauthn.CreateCredential(newWebAuthnUser(user), data, parsed)
+	if err != nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+
+	return models.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}, nil
+}
+
+// BeginLogin starts assertion by one of passkeys of the known user
+func (rp *RelyingParty) BeginLogin(user User) (options []byte, session []byte, err error) {
+	assertion, data, err := rp.webauthn.BeginLogin(newWebAuthnUser(user))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return marshal(assertion, data)
+}
+
+// BeginDiscoverableLogin starts assertion by any passkey, user is known
+// from the passkey. It replaces password, so user verification is required
+func (rp *RelyingParty) BeginDiscoverableLogin() (options []byte, session []byte, err error) {
+	assertion, data, err := rp.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return marshal(assertion, data)
+}
+
+// FinishLogin verifies assertion of the known user and returns used
+// passkey with updated counter and backup state
+func (rp *RelyingParty) FinishLogin(
+	user User,
+	session []byte,
+	response []byte,
+) (models.WebAuthnCredential, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	cred, err := rp.webauthn.ValidateLogin(newWebAuthnUser(user), data, parsed)
+	if err != nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	return usedCredential(user, cred)
+}
+
+// FinishDiscoverableLogin verifies assertion by passkey of user found by
+// lookup and returns the user and used passkey with updated counter
+func (rp *RelyingParty) FinishDiscoverableLogin(
+	session []byte,
+	response []byte,
+	lookup func(userID int64) (User, error),
+) (User, models.WebAuthnCredential, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return User{}, models.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return User{}, models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	var (
+		user      User
+		lookupErr error
+	)
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		userID, ok := UserIDFromHandle(userHandle)
+		if !ok {
+			return nil, fmt.Errorf("malformed user handle")
+		}
+
+		user, lookupErr = lookup(userID)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+
+		return newWebAuthnUser(user), nil
+	}
+
+	cred, err := rp.webauthn.ValidateDiscoverableLogin(handler, data, parsed)
+	if lookupErr != nil {
+		// Lookup errors are reported by caller, e.g. unknown user
+		return User{}, models.WebAuthnCredential{}, lookupErr
+	}
+	if err != nil {
+		return User{}, models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	used, err := usedCredential(user, cred)
+	if err != nil {
+		return User{}, models.WebAuthnCredential{}, err
+	}
+
+	return user, used, nil
+}
+
+// UserHandle returns WebAuthn user handle of user id. Handle is opaque
+// to authenticators and holds no personal data
+func UserHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// UserIDFromHandle returns user id of WebAuthn user handle
+func UserIDFromHandle(handle []byte) (int64, bool) {
+	if len(handle) != 8 {
+		return 0, false
+	}
+
+	return int64(binary.BigEndian.Uint64(handle)), true
+}
+
+// usedCredential returns stored passkey of user updated by assertion
+func usedCredential(user User, cred *webauthn.Credential) (models.WebAuthnCredential, error) {
+	if cred.Authenticator.CloneWarning {
+		return models.WebAuthnCredential{}, ErrCloneDetected
+	}
+
+	for _, stored := range user.Credentials {
+		if bytes.Equal(stored.CredentialID, cred.ID) {
+			stored.SignCount = cred.Authenticator.SignCount
+			stored.BackupState = cred.Flags.BackupState
+
+			return stored, nil
+		}
+	}
+
+	return models.WebAuthnCredential{}, fmt.Errorf("%w: unknown credential", ErrInvalidResponse)
+}
+
+func marshal(options any, data *webauthn.SessionData) ([]byte, []byte, error) {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return opts, session, nil
+}
+
+// webAuthnUser adapts User to the library
+type webAuthnUser struct {
+	user        User
+	credentials []webauthn.Credential
+}
+
+func newWebAuthnUser(user User) *webAuthnUser {
+	credentials := make([]webauthn.Credential, 0, len(user.Credentials))
+	for _, c := range user.Credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return UserHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
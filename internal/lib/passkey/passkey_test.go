@@ -0,0 +1,137 @@
+package passkey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/passkey/passkeytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const origin = "https://sso.example.com"
+
+func newRelyingParty(t *testing.T) *RelyingParty {
+	t.Helper()
+
+	rp, err := New(Config{
+		RPID:          "sso.example.com",
+		RPDisplayName: "SSO",
+		RPOrigins:     []string{origin},
+		Timeout:       time.Minute,
+	})
+	require.NoError(t, err)
+
+	return rp
+}
+
+// register registers passkey of authenticator for user
+func register(t *testing.T, rp *RelyingParty, auth *passkeytest.Authenticator, user User) models.WebAuthnCredential {
+	t.Helper()
+
+	options, session, err := rp.BeginRegistration(user)
+	require.NoError(t, err)
+
+	response, err := auth.Create(options)
+	require.NoError(t, err)
+
+	cred, err := rp.FinishRegistration(user, session, response)
+	require.NoError(t, err)
+
+	return cred
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	rp := newRelyingParty(t)
+	auth, err := passkeytest.New(origin)
+	require.NoError(t, err)
+
+	user := User{ID: 42, Name: "user@example.com"}
+
+	cred := register(t, rp, auth, user)
+	assert.Equal(t, int64(42), cred.UserID)
+	assert.Equal(t, auth.CredentialID(), cred.CredentialID)
+	assert.Equal(t, []string{"internal"}, cred.Transports)
+	assert.True(t, cred.BackupEligible)
+
+	cred.ID = 7
+	user.Credentials = []models.WebAuthnCredential{cred}
+
+	// Second factor of known user
+	options, session, err := rp.BeginLogin(user)
+	require.NoError(t, err)
+	response, err := auth.Get(options)
+	require.NoError(t, err)
+
+	used, err := rp.FinishLogin(user, session, response)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), used.ID)
+	assert.Equal(t, uint32(1), used.SignCount)
+	user.Credentials = []models.WebAuthnCredential{used}
+
+	// Passkey alone, user is found by handle
+	options, session, err = rp.BeginDiscoverableLogin()
+	require.NoError(t, err)
+	response, err = auth.Get(options)
+	require.NoError(t, err)
+
+	found, used, err := rp.FinishDiscoverableLogin(session, response, func(userID int64) (User, error) {
+		assert.Equal(t, int64(42), userID)
+
+		return user, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), found.ID)
+	assert.Equal(t, uint32(2), used.SignCount)
+}
+
+func TestLoginRejected(t *testing.T) {
+	rp := newRelyingParty(t)
+	auth, err := passkeytest.New(origin)
+	require.NoError(t, err)
+
+	user := User{ID: 1, Name: "user@example.com"}
+	user.Credentials = []models.WebAuthnCredential{register(t, rp, auth, user)}
+
+	t.Run("replayed response", func(t *testing.T) {
+		options, session, err := rp.BeginLogin(user)
+		require.NoError(t, err)
+		response, err := auth.Get(options)
+		require.NoError(t, err)
+
+		used, err := rp.FinishLogin(user, session, response)
+		require.NoError(t, err)
+		user.Credentials = []models.WebAuthnCredential{used}
+
+		options, session, err = rp.BeginLogin(user)
+		require.NoError(t, err)
+		_, err = rp.FinishLogin(user, session, response)
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+	})
+
+	t.Run("phishing origin", func(t *testing.T) {
+		options, session, err := rp.BeginLogin(user)
+		require.NoError(t, err)
+
+		auth.Origin = "https://sso.example.com.evil.test"
+		defer func() { auth.Origin = origin }()
+
+		response, err := auth.Get(options)
+		require.NoError(t, err)
+		_, err = rp.FinishLogin(user, session, response)
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		auth.SignCount = 0
+
+		options, session, err := rp.BeginLogin(user)
+		require.NoError(t, err)
+		response, err := auth.Get(options)
+		require.NoError(t, err)
+
+		_, err = rp.FinishLogin(user, session, response)
+		assert.ErrorIs(t, err, ErrCloneDetected)
+	})
+}
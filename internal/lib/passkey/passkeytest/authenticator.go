@@ -0,0 +1,203 @@
+// Package passkeytest provides software WebAuthn authenticator, so
+// passkey ceremonies can be tested without hardware
+package passkeytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Authenticator flags of authenticator data
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+)
+
+// Authenticator is a platform authenticator holding a single ES256
+// passkey with "none" attestation. It answers options produced by
+// passkey.RelyingParty with responses as browsers send them
+type Authenticator struct {
+	Origin string
+	// SignCount is incremented by each assertion. Set it back to make
+	// authenticator look cloned
+	SignCount uint32
+
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	rpID         string
+	userHandle   []byte
+}
+
+func New(origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{Origin: origin, key: key, credentialID: credentialID}, nil
+}
+
+// CredentialID returns id of the passkey
+func (a *Authenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+type creationOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"publicKey"`
+}
+
+type assertionOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RPID      string `json:"rpId"`
+	} `json:"publicKey"`
+}
+
+// Create makes passkey for registration options and returns
+// registration response
+func (a *Authenticator) Create(options []byte) ([]byte, error) {
+	var opts creationOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	userHandle, err := base64.RawURLEncoding.DecodeString(opts.PublicKey.User.ID)
+	if err != nil {
+		return nil, fmt.Errorf("user id: %w", err)
+	}
+	a.rpID = opts.PublicKey.RP.ID
+	a.userHandle = userHandle
+
+	clientData, err := a.clientData("webauthn.create", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	coseKey, err := cbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagBackupEligible | flagBackupState | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // zero AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestation, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.response(map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"attestationObject": b64(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// Get signs assertion options with the passkey and returns assertion
+// response
+func (a *Authenticator) Get(options []byte) ([]byte, error) {
+	if a.userHandle == nil {
+		return nil, errors.New("passkey is not created")
+	}
+
+	var opts assertionOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+	if opts.PublicKey.RPID != a.rpID {
+		return nil, fmt.Errorf("passkey is scoped to %q, not %q", a.rpID, opts.PublicKey.RPID)
+	}
+
+	clientData, err := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SignCount++
+	authData := a.authData(flagUserPresent | flagUserVerified | flagBackupEligible | flagBackupState)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return a.response(map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(sig),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *Authenticator) clientData(typ string, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	data := append(rpIDHash[:], flags)
+
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) response(response map[string]any) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"id":                      b64(a.credentialID),
+		"rawId":                   b64(a.credentialID),
+		"type":                    "public-key",
+		"authenticatorAttachment": "platform",
+		"clientExtensionResults":  map[string]any{},
+		"response":                response,
+	})
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
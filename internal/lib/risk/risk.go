@@ -0,0 +1,152 @@
+// Package risk scores login attempts against login history of user
+package risk
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// Reasons login is considered risky
+const (
+	ReasonNewDevice      = "new_device"
+	ReasonUnknownDevice  = "unknown_device"
+	ReasonNewNetwork     = "new_network"
+	ReasonDormant        = "dormant"
+	ReasonFailedAttempts = "failed_attempts"
+)
+
+// Weights of signals. Score of a login is the sum of weights of its reasons
+const (
+	weightNewDevice     = 40
+	weightUnknownDevice = 15
+	weightNewNetwork    = 30
+	weightDormant       = 20
+	weightFailure       = 15
+	maxFailuresWeight   = 45
+)
+
+// Config tunes evaluation. Logins from TrustedNetworks are never from a
+// new network. Failed attempts are counted within FailureWindow
+type Config struct {
+	TrustedNetworks []netip.Prefix
+	DormantAfter    time.Duration
+	FailureWindow   time.Duration
+}
+
+// Attempt is the login being evaluated
+type Attempt struct {
+	DeviceHash string
+	IP         string
+	Time       time.Time
+}
+
+// Assessment is the score of login with reasons it adds up from
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+// Evaluate scores attempt against history of user, newest first. Device
+// is new if it has never logged in, network is new if no login came from
+// the same /24 IPv4 or /64 IPv6 network. Users without successful logins
+// have no baseline, so only failed attempts count for them
+func Evaluate(cfg Config, attempt Attempt, history []models.LoginAttempt) Assessment {
+	network, _ := networkOf(attempt.IP)
+
+	var (
+		a         Assessment
+		lastLogin time.Time
+		knownDev  bool
+		knownNet  bool
+		hasLogins bool
+		failures  int
+	)
+
+	for _, h := range history {
+		if !h.Succeeded {
+			if attempt.Time.Sub(h.CreatedAt) <= cfg.FailureWindow {
+				failures++
+			}
+
+			continue
+		}
+
+		hasLogins = true
+		if h.CreatedAt.After(lastLogin) {
+			lastLogin = h.CreatedAt
+		}
+		if attempt.DeviceHash != "" && h.DeviceHash == attempt.DeviceHash {
+			knownDev = true
+		}
+		if n, ok := networkOf(h.IP); ok && n == network {
+			knownNet = true
+		}
+	}
+
+	if hasLogins {
+		switch {
+		case attempt.DeviceHash == "":
+			a.add(ReasonUnknownDevice, weightUnknownDevice)
+		case !knownDev:
+			a.add(ReasonNewDevice, weightNewDevice)
+		}
+
+		if network.IsValid() && !knownNet && !trusted(cfg.TrustedNetworks, attempt.IP) {
+			a.add(ReasonNewNetwork, weightNewNetwork)
+		}
+
+		if cfg.DormantAfter > 0 && attempt.Time.Sub(lastLogin) > cfg.DormantAfter {
+			a.add(ReasonDormant, weightDormant)
+		}
+	}
+
+	if failures > 0 {
+		a.add(ReasonFailedAttempts, min(failures*weightFailure, maxFailuresWeight))
+	}
+
+	return a
+}
+
+func (a *Assessment) add(reason string, weight int) {
+	a.Score += weight
+	a.Reasons = append(a.Reasons, reason)
+}
+
+// networkOf returns network of IP address logins from it are alike in
+func networkOf(ip string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+
+	network, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return network, true
+}
+
+func trusted(networks []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
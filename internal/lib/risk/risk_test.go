@@ -0,0 +1,89 @@
+package risk
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	cfg := Config{
+		TrustedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		DormantAfter:    30 * 24 * time.Hour,
+		FailureWindow:   15 * time.Minute,
+	}
+	history := []models.LoginAttempt{
+		{DeviceHash: "laptop", IP: "203.0.113.7", Succeeded: true, CreatedAt: now.Add(-time.Hour)},
+		{DeviceHash: "phone", IP: "2001:db8:1:2::1", Succeeded: true, CreatedAt: now.Add(-48 * time.Hour)},
+	}
+
+	tests := []struct {
+		name    string
+		attempt Attempt
+		history []models.LoginAttempt
+		score   int
+		reasons []string
+	}{
+		{
+			name:    "known device and network",
+			attempt: Attempt{DeviceHash: "laptop", IP: "203.0.113.200", Time: now},
+			history: history,
+		},
+		{
+			name:    "known device from known IPv6 network",
+			attempt: Attempt{DeviceHash: "phone", IP: "2001:db8:1:2::ff", Time: now},
+			history: history,
+		},
+		{
+			name:    "first login has no baseline",
+			attempt: Attempt{DeviceHash: "laptop", IP: "198.51.100.1", Time: now},
+		},
+		{
+			name:    "new device from new network",
+			attempt: Attempt{DeviceHash: "tablet", IP: "198.51.100.1", Time: now},
+			history: history,
+			score:   weightNewDevice + weightNewNetwork,
+			reasons: []string{ReasonNewDevice, ReasonNewNetwork},
+		},
+		{
+			name:    "no fingerprint from trusted network",
+			attempt: Attempt{IP: "10.1.2.3", Time: now},
+			history: history,
+			score:   weightUnknownDevice,
+			reasons: []string{ReasonUnknownDevice},
+		},
+		{
+			name:    "dormant account",
+			attempt: Attempt{DeviceHash: "laptop", IP: "203.0.113.7", Time: now.Add(60 * 24 * time.Hour)},
+			history: history,
+			score:   weightDormant,
+			reasons: []string{ReasonDormant},
+		},
+		{
+			name:    "failed attempts within window",
+			attempt: Attempt{DeviceHash: "laptop", IP: "203.0.113.7", Time: now},
+			history: append([]models.LoginAttempt{
+				{CreatedAt: now.Add(-time.Minute)},
+				{CreatedAt: now.Add(-2 * time.Minute)},
+				{CreatedAt: now.Add(-3 * time.Minute)},
+				{CreatedAt: now.Add(-4 * time.Minute)},
+				{CreatedAt: now.Add(-time.Hour)},
+			}, history...),
+			score:   maxFailuresWeight,
+			reasons: []string{ReasonFailedAttempts},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Evaluate(cfg, tt.attempt, tt.history)
+
+			assert.Equal(t, tt.score, a.Score)
+			assert.Equal(t, tt.reasons, a.Reasons)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+)
+
+// Encrypted values look like "enc:v1:<key id>:<base64(nonce|ciphertext)>"
+const (
+	prefix  = "enc:v1:"
+	keySize = 32
+)
+
+var (
+	ErrNoKEK         = errors.New("key encryption key is not configured")
+	ErrInvalidKEK    = errors.New("key encryption key must be 32 bytes encoded in base64")
+	ErrUnknownKEK    = errors.New("unknown key encryption key")
+	ErrMalformed     = errors.New("malformed encrypted value")
+	ErrNotEncrypted  = errors.New("value is not encrypted")
+	ErrDecryptFailed = errors.New("failed to decrypt value")
+)
+
+// Keyring encrypts values with the current key encryption key (KEK)
+// and decrypts values encrypted with the current or any previous one
+type Keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+	// requireEncrypted rejects values stored in plaintext
+	requireEncrypted bool
+}
+
+// New returns keyring with current KEK and previous KEKs indexed by id.
+// Keys are base64 encoded 32 bytes
+func New(currentID string, current string, previous map[string]string) (*Keyring, error) {
+	const op = "secrets.New"
+
+	if current == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoKEK)
+	}
+
+	k := &Keyring{
+		currentID: currentID,
+		keys:      make(map[string]cipher.AEAD, len(previous)+1),
+	}
+
+	for id, key := range previous {
+		if err := k.add(id, key); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, id, err)
+		}
+	}
+	if err := k.add(currentID, current); err != nil {
+		return nil, fmt.Errorf("%s: key %q: %w", op, currentID, err)
+	}
+
+	return k, nil
+}
+
+// FromConfig builds keyring from config. KEK is taken from kek option
+// (SSO_KEK environment variable) or read from kek_file
+func FromConfig(cfg config.SecretsConfig) (*Keyring, error) {
+	const op = "secrets.FromConfig"
+
+	kek := cfg.KEK
+	if kek == "" && cfg.KEKFile != "" {
+		b, err := os.ReadFile(cfg.KEKFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		kek = strings.TrimSpace(string(b))
+	}
+
+	k, err := New(cfg.KEKID, kek, cfg.PreviousKEKs)
+	if err != nil {
+		return nil, err
+	}
+	k.requireEncrypted = cfg.RequireEncrypted
+
+	return k, nil
+}
+
+// RequireEncrypted reports whether values stored in plaintext before
+// encryption at rest was turned on must be rejected instead of read
+func (k *Keyring) RequireEncrypted() bool {
+	return k.requireEncrypted
+}
+
+// Encrypt encrypts value with the current KEK
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.keys[k.currentID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.currentID))
+
+	return prefix + k.currentID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value encrypted by Encrypt with any known KEK
+func (k *Keyring) Decrypt(value string) (string, error) {
+	id, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKEK, id)
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsReencrypt reports whether value is plaintext or encrypted
+// with other than current KEK
+func (k *Keyring) NeedsReencrypt(value string) bool {
+	id, _, err := parse(value)
+
+	return err != nil || id != k.currentID
+}
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func (k *Keyring) add(id string, key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != keySize {
+		return ErrInvalidKEK
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.keys[id] = aead
+
+	return nil
+}
+
+func parse(value string) (string, []byte, error) {
+	if !IsEncrypted(value) {
+		return "", nil, ErrNotEncrypted
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", nil, ErrMalformed
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, ErrMalformed
+	}
+
+	return id, sealed, nil
+}
@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oldKEK = "b2xkLWtleS1lbmNyeXB0aW9uLWtleS0zMi1ieXRlcyE="
+	newKEK = "bmV3LWtleS1lbmNyeXB0aW9uLWtleS0zMi1ieXRlcyE="
+)
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	k, err := New("v1", oldKEK, nil)
+	require.NoError(t, err)
+
+	encrypted, err := k.Encrypt("test-secret")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "test-secret")
+	assert.False(t, k.NeedsReencrypt(encrypted))
+
+	decrypted, err := k.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "test-secret", decrypted)
+
+	_, err = k.Decrypt("test-secret")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+	assert.True(t, k.NeedsReencrypt("test-secret"))
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old, err := New("v1", oldKEK, nil)
+	require.NoError(t, err)
+
+	encrypted, err := old.Encrypt("test-secret")
+	require.NoError(t, err)
+
+	rotated, err := New("v2", newKEK, map[string]string{"v1": oldKEK})
+	require.NoError(t, err)
+	assert.True(t, rotated.NeedsReencrypt(encrypted))
+
+	decrypted, err := rotated.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "test-secret", decrypted)
+
+	withoutOld, err := New("v2", newKEK, nil)
+	require.NoError(t, err)
+
+	_, err = withoutOld.Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrUnknownKEK)
+}
+
+func TestNew_InvalidKEK(t *testing.T) {
+	_, err := New("v1", "", nil)
+	assert.ErrorIs(t, err, ErrNoKEK)
+
+	_, err = New("v1", "c2hvcnQ=", nil)
+	assert.ErrorIs(t, err, ErrInvalidKEK)
+}
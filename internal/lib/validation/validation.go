@@ -11,8 +11,8 @@ const (
 )
 
 func ValidateLogin(req *ssov1.LoginRequest) error {
-	if req.GetEmail() == "" {
-		return status.Error(codes.InvalidArgument, "email is required")
+	if req.GetEmail() == "" && req.GetIdentifier() == "" {
+		return status.Error(codes.InvalidArgument, "email or identifier is required")
 	}
 
 	if req.GetPassword() == "" {
@@ -45,3 +45,277 @@ func ValidateIsAdmin(req *ssov1.IsAdminRequest) error {
 
 	return nil
 }
+
+func ValidateRequestMagicLink(req *ssov1.RequestMagicLinkRequest) error {
+	if req.GetEmail() == "" {
+		return status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateConsumeMagicLink(req *ssov1.ConsumeMagicLinkRequest) error {
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateRequestLoginCode(req *ssov1.RequestLoginCodeRequest) error {
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateVerifyLoginCode(req *ssov1.VerifyLoginCodeRequest) error {
+	if req.GetChallenge() == "" {
+		return status.Error(codes.InvalidArgument, "challenge is required")
+	}
+
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateResendLoginCode(req *ssov1.ResendLoginCodeRequest) error {
+	if req.GetChallenge() == "" {
+		return status.Error(codes.InvalidArgument, "challenge is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateFinishPasskeyLogin(req *ssov1.FinishPasskeyLoginRequest) error {
+	if req.GetChallenge() == "" {
+		return status.Error(codes.InvalidArgument, "challenge is required")
+	}
+
+	if req.GetCredential() == "" {
+		return status.Error(codes.InvalidArgument, "credential is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateReauthenticate(req *ssov1.ReauthenticateRequest) error {
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateResetPassword(req *ssov1.ResetPasswordRequest) error {
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if req.GetNewPassword() == "" {
+		return status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateChangePassword(req *ssov1.ChangePasswordRequest) error {
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if req.GetNewPassword() == "" {
+		return status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	return nil
+}
+
+func ValidateUserID(userID int64) error {
+	if userID == emptyValue {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	return nil
+}
+
+func ValidateGetUser(req *ssov1.GetUserRequest) error {
+	if req.GetUserId() == emptyValue && req.GetEmail() == "" {
+		return status.Error(codes.InvalidArgument, "user_id or email is required")
+	}
+
+	return nil
+}
+
+func ValidateSetRoles(req *ssov1.SetRolesRequest) error {
+	if err := ValidateUserID(req.GetUserId()); err != nil {
+		return err
+	}
+
+	return ValidateRoles(req.GetRoles())
+}
+
+func ValidateRoles(roles []string) error {
+	for _, role := range roles {
+		if role == "" {
+			return status.Error(codes.InvalidArgument, "role can't be empty")
+		}
+	}
+
+	return nil
+}
+
+func ValidateAppID(appID int32) error {
+	if appID == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateAppName(name string) error {
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	return nil
+}
+
+func ValidateOrgID(orgID int64) error {
+	if orgID == emptyValue {
+		return status.Error(codes.InvalidArgument, "org_id is required")
+	}
+
+	return nil
+}
+
+func ValidateGroupID(groupID int64) error {
+	if groupID == emptyValue {
+		return status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	return nil
+}
+
+func ValidateCreateInvitation(req *ssov1.CreateInvitationRequest) error {
+	if req.GetEmail() == "" {
+		return status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if req.GetOrgId() == emptyValue && req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "org_id or app_id is required")
+	}
+
+	return nil
+}
+
+func ValidateAcceptInvitation(req *ssov1.AcceptInvitationRequest) error {
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	return nil
+}
+
+func ValidateChangeEmail(req *ssov1.ChangeEmailRequest) error {
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if req.GetNewEmail() == "" {
+		return status.Error(codes.InvalidArgument, "new_email is required")
+	}
+
+	return nil
+}
+
+func ValidateCreateWebhook(req *ssov1.CreateWebhookRequest) error {
+	if err := ValidateAppID(req.GetAppId()); err != nil {
+		return err
+	}
+
+	if req.GetUrl() == "" {
+		return status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	return nil
+}
+
+func ValidateWebhookID(webhookID int64) error {
+	if webhookID == emptyValue {
+		return status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	return nil
+}
+
+func ValidateFinishPasskeyRegistration(req *ssov1.FinishPasskeyRegistrationRequest) error {
+	if req.GetChallenge() == "" {
+		return status.Error(codes.InvalidArgument, "challenge is required")
+	}
+
+	if req.GetCredential() == "" {
+		return status.Error(codes.InvalidArgument, "credential is required")
+	}
+
+	return nil
+}
+
+func ValidatePasskeyID(passkeyID int64) error {
+	if passkeyID == emptyValue {
+		return status.Error(codes.InvalidArgument, "passkey_id is required")
+	}
+
+	return nil
+}
+
+func ValidateDeviceID(deviceID int64) error {
+	if deviceID == emptyValue {
+		return status.Error(codes.InvalidArgument, "device_id is required")
+	}
+
+	return nil
+}
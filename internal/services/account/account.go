@@ -0,0 +1,310 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Account lets users manage their own accounts
+type Account struct {
+	log                *slog.Logger
+	userProvider       UserProvider
+	emailChangeStorage EmailChangeStorage
+	credentials        CredentialsChecker
+	mfaStorage         MFAStorage
+	notifier           notifier.Notifier
+	cfg                Config
+}
+
+// Config tunes Account service behaviour
+type Config struct {
+	// EmailChangeTTL is how long email change can be confirmed
+	EmailChangeTTL time.Duration
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	OrgUser(ctx context.Context, namespace int64, email string) (models.User, error)
+}
+
+type EmailChangeStorage interface {
+	SaveEmailChange(ctx context.Context, change models.EmailChange, codeHash string, cancelHash string) (int64, error)
+	EmailChangeByCode(ctx context.Context, codeHash string) (models.EmailChange, error)
+	EmailChangeByCancelCode(ctx context.Context, cancelHash string) (models.EmailChange, error)
+	ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error
+	CancelEmailChange(ctx context.Context, id int64, now time.Time) error
+}
+
+type MFAStorage interface {
+	SetUserMFAChannel(ctx context.Context, userID int64, channel string) error
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+}
+
+type CredentialsChecker interface {
+	CheckCredentials(ctx context.Context, orgID int64, email string, password string) (models.User, error)
+}
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrEmailTaken         = errors.New("email already taken")
+	ErrCodeInvalid        = errors.New("code is invalid or expired")
+	ErrInvalidChannel     = errors.New("invalid second factor channel")
+	ErrPhoneRequired      = errors.New("phone is required for sms channel")
+	ErrPasskeyRequired    = errors.New("passkey is required for webauthn channel")
+)
+
+// New returns a new instance of Account service
+func New(
+	log *slog.Logger,
+	userProvider UserProvider,
+	emailChangeStorage EmailChangeStorage,
+	credentials CredentialsChecker,
+	mfaStorage MFAStorage,
+	notifier notifier.Notifier,
+	cfg Config,
+) *Account {
+	return &Account{
+		log:                log,
+		userProvider:       userProvider,
+		emailChangeStorage: emailChangeStorage,
+		credentials:        credentials,
+		mfaStorage:         mfaStorage,
+		notifier:           notifier,
+		cfg:                cfg,
+	}
+}
+
+// ChangeEmail checks password of user and starts email change. The new
+// address gets confirmation code, the old one gets cancellation code.
+// Email is changed only after confirmation
+func (a *Account) ChangeEmail(ctx context.Context, userID int64, password string, newEmail string) error {
+	const op = "Account.ChangeEmail"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	if identifiers.Detect(newEmail) != identifiers.KindEmail {
+		log.Warn("invalid new email")
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	if _, err := a.credentials.CheckCredentials(ctx, user.OrgID, user.Email, password); err != nil {
+		return a.accountError(op, err)
+	}
+
+	if identifiers.CanonicalEmail(newEmail) == identifiers.CanonicalEmail(user.Email) {
+		log.Warn("new email is the same as current")
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	// Uniqueness is enforced on confirmation, this check
+	// only spares sending codes for taken emails
+	_, err = a.userProvider.OrgUser(ctx, user.OrgID, newEmail)
+	if err == nil {
+		log.Warn("new email is already taken")
+
+		return fmt.Errorf("%s: %w", op, ErrEmailTaken)
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return a.accountError(op, err)
+	}
+
+	code, codeHash, err := codes.New()
+	if err != nil {
+		return a.accountError(op, err)
+	}
+	cancelCode, cancelHash, err := codes.New()
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	now := time.Now()
+	change := models.EmailChange{
+		UserID:    userID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.cfg.EmailChangeTTL),
+	}
+
+	change.ID, err = a.emailChangeStorage.SaveEmailChange(ctx, change, codeHash, cancelHash)
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	err = a.notifier.Send(ctx, notifier.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Use code %s to confirm your new email. It expires at %s.",
+			code, change.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	err = a.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Your email is being changed",
+		Body: fmt.Sprintf("Your email is being changed to %s. If it wasn't you, use code %s to cancel the change.",
+			newEmail, cancelCode),
+	})
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	log.Info("email change started", slog.Int64("email_change_id", change.ID))
+
+	return nil
+}
+
+// ConfirmEmailChange applies email change confirmed by code sent
+// to the new address
+func (a *Account) ConfirmEmailChange(ctx context.Context, code string) error {
+	const op = "Account.ConfirmEmailChange"
+
+	log := a.log.With(slog.String("op", op))
+
+	change, err := a.emailChangeStorage.EmailChangeByCode(ctx, codes.Hash(code))
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	now := time.Now()
+	if !change.Pending(now) {
+		log.Warn("email change is not pending", slog.Int64("email_change_id", change.ID))
+
+		return fmt.Errorf("%s: %w", op, ErrCodeInvalid)
+	}
+
+	if err := a.emailChangeStorage.ConfirmEmailChange(ctx, change.ID, now); err != nil {
+		return a.accountError(op, err)
+	}
+
+	log.Info("email changed", slog.Int64("email_change_id", change.ID), slog.Int64("user_id", change.UserID))
+
+	return nil
+}
+
+// CancelEmailChange cancels email change by code sent to the old address
+func (a *Account) CancelEmailChange(ctx context.Context, code string) error {
+	const op = "Account.CancelEmailChange"
+
+	log := a.log.With(slog.String("op", op))
+
+	change, err := a.emailChangeStorage.EmailChangeByCancelCode(ctx, codes.Hash(code))
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	if err := a.emailChangeStorage.CancelEmailChange(ctx, change.ID, time.Now()); err != nil {
+		return a.accountError(op, err)
+	}
+
+	log.Info("email change cancelled", slog.Int64("email_change_id", change.ID), slog.Int64("user_id", change.UserID))
+
+	return nil
+}
+
+// SetMFAChannel checks password of user and sets channel one-time codes
+// are sent through after password on login. Empty channel turns second
+// factor off. SMS codes are sent to the phone login identifier of user.
+// Webauthn channel asks for one of registered passkeys instead of code
+func (a *Account) SetMFAChannel(ctx context.Context, userID int64, password string, channel string) error {
+	const op = "Account.SetMFAChannel"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	switch notifier.Channel(channel) {
+	case "", notifier.ChannelEmail, notifier.ChannelSMS, auth.MFAWebAuthn:
+	default:
+		log.Warn("invalid channel", slog.String("channel", channel))
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidChannel)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return a.accountError(op, err)
+	}
+
+	if _, err := a.credentials.CheckCredentials(ctx, user.OrgID, user.Email, password); err != nil {
+		return a.accountError(op, err)
+	}
+
+	if notifier.Channel(channel) == notifier.ChannelSMS && user.Phone == "" {
+		log.Warn("user has no phone")
+
+		return fmt.Errorf("%s: %w", op, ErrPhoneRequired)
+	}
+
+	if notifier.Channel(channel) == auth.MFAWebAuthn {
+		creds, err := a.mfaStorage.WebAuthnCredentials(ctx, userID)
+		if err != nil {
+			return a.accountError(op, err)
+		}
+		if len(creds) == 0 {
+			log.Warn("user has no passkeys")
+
+			return fmt.Errorf("%s: %w", op, ErrPasskeyRequired)
+		}
+	}
+
+	if err := a.mfaStorage.SetUserMFAChannel(ctx, userID, channel); err != nil {
+		return a.accountError(op, err)
+	}
+
+	log.Info("second factor channel set", slog.String("channel", channel))
+
+	return nil
+}
+
+func (a *Account) accountError(op string, err error) error {
+	log := a.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		log.Warn("invalid credentials", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	case errors.Is(err, auth.ErrUserInactive), errors.Is(err, auth.ErrPassResetRequired):
+		log.Warn("user can't manage account", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	case errors.Is(err, storage.ErrEmailChangeNotFound):
+		log.Warn("email change not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrCodeInvalid)
+	case errors.Is(err, storage.ErrUserExists):
+		log.Warn("email already taken", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrEmailTaken)
+	}
+
+	log.Error("account operation failed", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
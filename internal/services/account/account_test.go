@@ -0,0 +1,182 @@
+package account
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAccount struct {
+	*Account
+	storage *memory.Storage
+	auth    *auth.Auth
+	mail    *notifier.File
+}
+
+func newTestAccount(t *testing.T, emailChangeTTL time.Duration) testAccount {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	mail := notifier.NewFile(filepath.Join(t.TempDir(), "mail.jsonl"))
+	a := auth.New(log, s, nil, nil, auth.Config{TokenTTL: time.Hour})
+
+	return testAccount{
+		Account: New(log, s, s, a, s, mail, Config{EmailChangeTTL: emailChangeTTL}),
+		storage: s,
+		auth:    a,
+		mail:    mail,
+	}
+}
+
+var codeRe = regexp.MustCompile(`code (\S+) to`)
+
+// codes returns the last codes sent to confirm and to cancel email change
+func (a testAccount) codes(t *testing.T, oldEmail string, newEmail string) (string, string) {
+	t.Helper()
+
+	messages, err := a.mail.Messages()
+	require.NoError(t, err)
+
+	var confirm, cancel string
+	for _, msg := range messages {
+		m := codeRe.FindStringSubmatch(msg.Body)
+		require.NotNil(t, m, msg.Body)
+		switch msg.To {
+		case newEmail:
+			confirm = m[1]
+		case oldEmail:
+			cancel = m[1]
+		}
+	}
+	require.NotEmpty(t, confirm)
+	require.NotEmpty(t, cancel)
+
+	return confirm, cancel
+}
+
+func (a testAccount) email(t *testing.T, userID int64) string {
+	t.Helper()
+
+	user, err := a.storage.UserByID(context.Background(), userID)
+	require.NoError(t, err)
+
+	return user.Email
+}
+
+func TestChangeEmail(t *testing.T) {
+	a := newTestAccount(t, time.Hour)
+	ctx := context.Background()
+
+	id, err := a.auth.RegisterNewUser(ctx, "old@example.com", "password", 0)
+	require.NoError(t, err)
+	_, err = a.auth.RegisterNewUser(ctx, "taken@example.com", "password", 0)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, a.ChangeEmail(ctx, id, "wrong", "new@example.com"), ErrInvalidCredentials)
+	assert.ErrorIs(t, a.ChangeEmail(ctx, id, "password", "OLD@example.com"), ErrInvalidEmail)
+	assert.ErrorIs(t, a.ChangeEmail(ctx, id, "password", "taken@example.com"), ErrEmailTaken)
+
+	require.NoError(t, a.ChangeEmail(ctx, id, "password", "new@example.com"))
+	confirm, cancel := a.codes(t, "old@example.com", "new@example.com")
+
+	// Email stays until the new address confirms it
+	assert.Equal(t, "old@example.com", a.email(t, id))
+	assert.ErrorIs(t, a.ConfirmEmailChange(ctx, cancel), ErrCodeInvalid)
+
+	require.NoError(t, a.ConfirmEmailChange(ctx, confirm))
+	assert.Equal(t, "new@example.com", a.email(t, id))
+
+	assert.ErrorIs(t, a.ConfirmEmailChange(ctx, confirm), ErrCodeInvalid)
+	assert.ErrorIs(t, a.CancelEmailChange(ctx, cancel), ErrCodeInvalid)
+}
+
+func TestCancelEmailChange(t *testing.T) {
+	a := newTestAccount(t, time.Hour)
+	ctx := context.Background()
+
+	id, err := a.auth.RegisterNewUser(ctx, "old@example.com", "password", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, a.ChangeEmail(ctx, id, "password", "new@example.com"))
+	confirm, cancel := a.codes(t, "old@example.com", "new@example.com")
+
+	assert.ErrorIs(t, a.CancelEmailChange(ctx, confirm), ErrCodeInvalid)
+	require.NoError(t, a.CancelEmailChange(ctx, cancel))
+
+	assert.ErrorIs(t, a.ConfirmEmailChange(ctx, confirm), ErrCodeInvalid)
+	assert.ErrorIs(t, a.CancelEmailChange(ctx, cancel), ErrCodeInvalid)
+	assert.Equal(t, "old@example.com", a.email(t, id))
+}
+
+func TestEmailChangeExpiry(t *testing.T) {
+	// Change expires as soon as it starts
+	a := newTestAccount(t, time.Nanosecond)
+	ctx := context.Background()
+
+	id, err := a.auth.RegisterNewUser(ctx, "old@example.com", "password", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, a.ChangeEmail(ctx, id, "password", "new@example.com"))
+	confirm, _ := a.codes(t, "old@example.com", "new@example.com")
+
+	assert.ErrorIs(t, a.ConfirmEmailChange(ctx, confirm), ErrCodeInvalid)
+	assert.Equal(t, "old@example.com", a.email(t, id))
+}
+
+func TestEmailChangeRace(t *testing.T) {
+	a := newTestAccount(t, time.Hour)
+	ctx := context.Background()
+
+	// Users want the same free email and confirm at once
+	var confirms []string
+	for _, email := range []string{"first@example.com", "second@example.com", "third@example.com"} {
+		id, err := a.auth.RegisterNewUser(ctx, email, "password", 0)
+		require.NoError(t, err)
+		require.NoError(t, a.ChangeEmail(ctx, id, "password", "wanted@example.com"))
+
+		confirm, _ := a.codes(t, email, "wanted@example.com")
+		confirms = append(confirms, confirm)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(confirms))
+	)
+	for i, confirm := range confirms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = a.ConfirmEmailChange(ctx, confirm)
+		}()
+	}
+	wg.Wait()
+
+	var won int
+	for _, err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	}
+	assert.Equal(t, 1, won)
+
+	_, err := a.storage.User(ctx, "wanted@example.com")
+	require.NoError(t, err)
+}
@@ -0,0 +1,361 @@
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type Admin struct {
+	log          *slog.Logger
+	userProvider UserProvider
+	userManager  UserManager
+	cfg          Config
+}
+
+// Config tunes Admin service behaviour
+type Config struct {
+	// DeletedRetention is how long deleted users can be restored
+	DeletedRetention time.Duration
+}
+
+type UserProvider interface {
+	User(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	Users(ctx context.Context, filter models.UserFilter, afterID int64, limit int) ([]models.User, error)
+	UserRoles(ctx context.Context, userID int64) ([]string, error)
+}
+
+type UserManager interface {
+	SetUserStatus(
+		ctx context.Context,
+		userID int64,
+		from models.AccountStatus,
+		to models.AccountStatus,
+		reason string,
+		now time.Time,
+	) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	SetPassResetRequired(ctx context.Context, userID int64, required bool) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+}
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidPageToken     = errors.New("invalid page token")
+	ErrInvalidStatus        = errors.New("invalid account status")
+	ErrTransitionNotAllowed = errors.New("account status transition not allowed")
+	ErrRetentionExpired     = errors.New("deleted user retention period expired")
+	ErrStatusConflict       = errors.New("account status changed concurrently")
+)
+
+// New returns a new instance of Admin service
+func New(
+	log *slog.Logger,
+	userProvider UserProvider,
+	userManager UserManager,
+	cfg Config,
+) *Admin {
+	return &Admin{
+		log:          log,
+		userProvider: userProvider,
+		userManager:  userManager,
+		cfg:          cfg,
+	}
+}
+
+// UserByID returns user with his roles by id
+func (a *Admin) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "Admin.UserByID"
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, a.userError(op, err)
+	}
+
+	return a.withRoles(ctx, op, user)
+}
+
+// UserByEmail returns user with his roles by email
+func (a *Admin) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	const op = "Admin.UserByEmail"
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		return models.User{}, a.userError(op, err)
+	}
+
+	return a.withRoles(ctx, op, user)
+}
+
+// ListUsers returns a page of users matching filter and token of the next page.
+// Empty next page token means there are no more users
+func (a *Admin) ListUsers(
+	ctx context.Context,
+	filter models.UserFilter,
+	pageToken string,
+	pageSize int,
+) ([]models.User, string, error) {
+	const op = "Admin.ListUsers"
+
+	afterID, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	// Fetch one extra user to find out whether there is a next page
+	users, err := a.userProvider.Users(ctx, filter, afterID, pageSize+1)
+	if err != nil {
+		a.log.Error("failed to list users", slog.String("op", op), sl.Err(err))
+
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nextPageToken string
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextPageToken = encodePageToken(users[pageSize-1].ID)
+	}
+
+	return users, nextPageToken, nil
+}
+
+// SetDisabled suspends or activates user. Disabled user can't login
+func (a *Admin) SetDisabled(ctx context.Context, userID int64, disabled bool) error {
+	const op = "Admin.SetDisabled"
+
+	status := models.StatusActive
+	if disabled {
+		status = models.StatusSuspended
+	}
+
+	if err := a.SetStatus(ctx, userID, status, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetStatus moves user account to status if the transition is allowed.
+// Deleted users can be restored to active within retention period
+func (a *Admin) SetStatus(ctx context.Context, userID int64, status models.AccountStatus, reason string) error {
+	const op = "Admin.SetStatus"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.String("status", string(status)),
+	)
+
+	if !status.Valid() {
+		log.Warn("invalid status")
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidStatus)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return a.userError(op, err)
+	}
+
+	if user.Status == status {
+		log.Info("user already has the status")
+
+		return nil
+	}
+
+	if !user.Status.CanTransitionTo(status) {
+		log.Warn("status transition not allowed", slog.String("from", string(user.Status)))
+
+		return fmt.Errorf("%s: %w", op, ErrTransitionNotAllowed)
+	}
+
+	now := time.Now()
+	if user.Status == models.StatusDeleted {
+		if user.StatusReason == models.StatusReasonErased {
+			log.Warn("erased user can't be restored")
+
+			return fmt.Errorf("%s: %w", op, ErrTransitionNotAllowed)
+		}
+		if now.Sub(user.StatusChangedAt) > a.cfg.DeletedRetention {
+			log.Warn("retention period of deleted user expired")
+
+			return fmt.Errorf("%s: %w", op, ErrRetentionExpired)
+		}
+	}
+
+	log.Info("changing user status", slog.String("from", string(user.Status)))
+
+	if err := a.userManager.SetUserStatus(ctx, userID, user.Status, status, reason, now); err != nil {
+		return a.userError(op, err)
+	}
+
+	return nil
+}
+
+// RestoreUser restores deleted user within retention period
+func (a *Admin) RestoreUser(ctx context.Context, userID int64, reason string) error {
+	const op = "Admin.RestoreUser"
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return a.userError(op, err)
+	}
+	if user.Status != models.StatusDeleted {
+		a.log.Warn("user is not deleted", slog.String("op", op), slog.Int64("user_id", userID))
+
+		return fmt.Errorf("%s: %w", op, ErrTransitionNotAllowed)
+	}
+
+	if err := a.SetStatus(ctx, userID, models.StatusActive, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users whose retention period
+// expired. Returns number of deleted users
+func (a *Admin) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	const op = "Admin.PurgeDeletedUsers"
+
+	n, err := a.userManager.PurgeDeletedUsers(ctx, time.Now().Add(-a.cfg.DeletedRetention))
+	if err != nil {
+		return 0, a.userError(op, err)
+	}
+
+	a.log.Info("deleted users purged", slog.String("op", op), slog.Int64("count", n))
+
+	return n, nil
+}
+
+// ForcePasswordReset makes user reset password before next login, or
+// lets user log in with the current password again if not required
+func (a *Admin) ForcePasswordReset(ctx context.Context, userID int64, required bool) error {
+	const op = "Admin.ForcePasswordReset"
+
+	a.log.Info("setting password reset flag",
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Bool("required", required),
+	)
+
+	if err := a.userManager.SetPassResetRequired(ctx, userID, required); err != nil {
+		return a.userError(op, err)
+	}
+
+	return nil
+}
+
+// SetAdmin grants or revokes admin rights
+func (a *Admin) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "Admin.SetAdmin"
+
+	a.log.Info("changing user admin flag",
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Bool("is_admin", isAdmin),
+	)
+
+	if err := a.userManager.SetAdmin(ctx, userID, isAdmin); err != nil {
+		return a.userError(op, err)
+	}
+
+	return nil
+}
+
+// SetRoles replaces user roles with given ones
+func (a *Admin) SetRoles(ctx context.Context, userID int64, roles []string) error {
+	const op = "Admin.SetRoles"
+
+	a.log.Info("setting user roles",
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Any("roles", roles),
+	)
+
+	if err := a.userManager.SetUserRoles(ctx, userID, roles); err != nil {
+		return a.userError(op, err)
+	}
+
+	return nil
+}
+
+// DeleteUser soft deletes user. Deleted user can be restored within
+// retention period and is purged after it
+func (a *Admin) DeleteUser(ctx context.Context, userID int64, reason string) error {
+	const op = "Admin.DeleteUser"
+
+	if err := a.SetStatus(ctx, userID, models.StatusDeleted, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *Admin) withRoles(ctx context.Context, op string, user models.User) (models.User, error) {
+	roles, err := a.userProvider.UserRoles(ctx, user.ID)
+	if err != nil {
+		a.log.Error("failed to get user roles", slog.String("op", op), sl.Err(err))
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	user.Roles = roles
+
+	return user, nil
+}
+
+func (a *Admin) userError(op string, err error) error {
+	if errors.Is(err, storage.ErrUserNotFound) {
+		a.log.Warn("user not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if errors.Is(err, storage.ErrStatusConflict) {
+		a.log.Warn("user status changed concurrently", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrStatusConflict)
+	}
+
+	a.log.Error("storage error", slog.String("op", op), sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func encodePageToken(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(raw), 10, 64)
+}
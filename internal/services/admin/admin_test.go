@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const retention = 24 * time.Hour
+
+func newTestAdmin(t *testing.T) (*Admin, *memory.Storage) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	return New(log, s, s, Config{DeletedRetention: retention}), s
+}
+
+// userWithStatus registers active user and moves it to status age ago
+func userWithStatus(
+	t *testing.T,
+	s *memory.Storage,
+	status models.AccountStatus,
+	reason string,
+	age time.Duration,
+) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+
+	id, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	if status != models.StatusActive {
+		require.NoError(t, s.SetUserStatus(ctx, id, models.StatusActive, status, reason, time.Now().Add(-age)))
+	}
+
+	return id
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.AccountStatus
+		reason  string
+		age     time.Duration
+		to      models.AccountStatus
+		wantErr error
+	}{
+		{name: "suspend", from: models.StatusActive, to: models.StatusSuspended},
+		{name: "activate suspended", from: models.StatusSuspended, to: models.StatusActive},
+		{name: "suspend locked", from: models.StatusLocked, to: models.StatusSuspended},
+		{name: "delete", from: models.StatusSuspended, to: models.StatusDeleted},
+		{name: "same status", from: models.StatusLocked, to: models.StatusLocked},
+		{name: "lock suspended", from: models.StatusSuspended, to: models.StatusLocked, wantErr: ErrTransitionNotAllowed},
+		{name: "suspend deleted", from: models.StatusDeleted, to: models.StatusSuspended, wantErr: ErrTransitionNotAllowed},
+		{name: "unknown status", from: models.StatusActive, to: "banned", wantErr: ErrInvalidStatus},
+		{name: "restore within retention", from: models.StatusDeleted, age: retention - time.Hour, to: models.StatusActive},
+		{
+			name:    "restore after retention",
+			from:    models.StatusDeleted,
+			age:     retention + time.Hour,
+			to:      models.StatusActive,
+			wantErr: ErrRetentionExpired,
+		},
+		{
+			name:    "restore erased",
+			from:    models.StatusDeleted,
+			reason:  models.StatusReasonErased,
+			to:      models.StatusActive,
+			wantErr: ErrTransitionNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newTestAdmin(t)
+			ctx := context.Background()
+			id := userWithStatus(t, s, tt.from, tt.reason, tt.age)
+
+			err := a.SetStatus(ctx, id, tt.to, "")
+
+			user, getErr := s.UserByID(ctx, id)
+			require.NoError(t, getErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.from, user.Status)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.to, user.Status)
+			assert.Equal(t, tt.to != models.StatusActive, user.Disabled)
+		})
+	}
+
+	a, _ := newTestAdmin(t)
+	assert.ErrorIs(t, a.SetStatus(context.Background(), 1, models.StatusSuspended, ""), ErrUserNotFound)
+}
+
+func TestRestoreUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.AccountStatus
+		reason  string
+		age     time.Duration
+		wantErr error
+	}{
+		{name: "deleted", from: models.StatusDeleted, age: time.Hour},
+		{name: "not deleted", from: models.StatusSuspended, wantErr: ErrTransitionNotAllowed},
+		{name: "retention expired", from: models.StatusDeleted, age: 2 * retention, wantErr: ErrRetentionExpired},
+		{name: "erased", from: models.StatusDeleted, reason: models.StatusReasonErased, wantErr: ErrTransitionNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newTestAdmin(t)
+			id := userWithStatus(t, s, tt.from, tt.reason, tt.age)
+
+			err := a.RestoreUser(context.Background(), id, "mistake")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			user, err := s.UserByID(context.Background(), id)
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusActive, user.Status)
+			assert.Equal(t, "mistake", user.StatusReason)
+		})
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	a, s := newTestAdmin(t)
+	ctx := context.Background()
+
+	save := func(email string, status models.AccountStatus, age time.Duration) int64 {
+		id, err := s.SaveUser(ctx, email, []byte("hash"))
+		require.NoError(t, err)
+		if status != models.StatusActive {
+			require.NoError(t, s.SetUserStatus(ctx, id, models.StatusActive, status, "", time.Now().Add(-age)))
+		}
+		return id
+	}
+
+	expired := save("expired@example.com", models.StatusDeleted, 2*retention)
+	recent := save("recent@example.com", models.StatusDeleted, time.Hour)
+	suspended := save("suspended@example.com", models.StatusSuspended, 2*retention)
+
+	n, err := a.PurgeDeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	_, err = a.UserByID(ctx, expired)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	for _, id := range []int64{recent, suspended} {
+		_, err = a.UserByID(ctx, id)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,192 @@
+package apps
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const secretLen = 32
+
+type Apps struct {
+	log               *slog.Logger
+	appProvider       AppProvider
+	appManager        AppManager
+	secretGracePeriod time.Duration
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+	Apps(ctx context.Context, orgID int64) ([]models.App, error)
+}
+
+type AppManager interface {
+	SaveApp(ctx context.Context, name string, secret string, orgID int64) (int, error)
+	UpdateApp(ctx context.Context, appID int, name string) error
+	RotateAppSecret(ctx context.Context, appID int, secret string, prevExpiresAt time.Time) error
+	DeleteApp(ctx context.Context, appID int) error
+}
+
+var (
+	ErrAppNotFound = errors.New("app not found")
+	ErrAppExists   = errors.New("app already exists")
+	ErrOrgNotFound = errors.New("organization not found")
+)
+
+// New returns a new instance of Apps service
+func New(
+	log *slog.Logger,
+	appProvider AppProvider,
+	appManager AppManager,
+	secretGracePeriod time.Duration,
+) *Apps {
+	return &Apps{
+		log:               log,
+		appProvider:       appProvider,
+		appManager:        appManager,
+		secretGracePeriod: secretGracePeriod,
+	}
+}
+
+// CreateApp registers new app with generated secret. Non-zero orgID
+// scopes the app to the organization
+func (a *Apps) CreateApp(ctx context.Context, name string, orgID int64) (models.App, error) {
+	const op = "Apps.CreateApp"
+
+	log := a.log.With(slog.String("op", op), slog.String("name", name), slog.Int64("org_id", orgID))
+
+	log.Info("creating app")
+
+	secret, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.appManager.SaveApp(ctx, name, secret, orgID)
+	if err != nil {
+		return models.App{}, a.appError(op, err)
+	}
+
+	log.Info("app created", slog.Int("app_id", id))
+
+	return models.App{
+		ID:     id,
+		Name:   name,
+		Secret: secret,
+		OrgID:  orgID,
+	}, nil
+}
+
+// ListApps returns registered apps. Non-zero orgID returns only apps of the organization
+func (a *Apps) ListApps(ctx context.Context, orgID int64) ([]models.App, error) {
+	const op = "Apps.ListApps"
+
+	apps, err := a.appProvider.Apps(ctx, orgID)
+	if err != nil {
+		return nil, a.appError(op, err)
+	}
+
+	return apps, nil
+}
+
+// UpdateApp renames app
+func (a *Apps) UpdateApp(ctx context.Context, appID int, name string) error {
+	const op = "Apps.UpdateApp"
+
+	a.log.Info("updating app",
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("name", name),
+	)
+
+	if err := a.appManager.UpdateApp(ctx, appID, name); err != nil {
+		return a.appError(op, err)
+	}
+
+	return nil
+}
+
+// RotateSecret generates new app secret. Tokens signed with the old
+// secret stay valid during the grace period
+func (a *Apps) RotateSecret(ctx context.Context, appID int) (models.App, error) {
+	const op = "Apps.RotateSecret"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	log.Info("rotating app secret")
+
+	secret, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	prevExpiresAt := time.Now().Add(a.secretGracePeriod)
+
+	if err := a.appManager.RotateAppSecret(ctx, appID, secret, prevExpiresAt); err != nil {
+		return models.App{}, a.appError(op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		return models.App{}, a.appError(op, err)
+	}
+
+	log.Info("app secret rotated", slog.Time("prev_secret_expires_at", prevExpiresAt))
+
+	return app, nil
+}
+
+// DeleteApp deletes app. Tokens of the app can't be verified anymore
+func (a *Apps) DeleteApp(ctx context.Context, appID int) error {
+	const op = "Apps.DeleteApp"
+
+	a.log.Info("deleting app", slog.String("op", op), slog.Int("app_id", appID))
+
+	if err := a.appManager.DeleteApp(ctx, appID); err != nil {
+		return a.appError(op, err)
+	}
+
+	return nil
+}
+
+func (a *Apps) appError(op string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrAppNotFound):
+		a.log.Warn("app not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	case errors.Is(err, storage.ErrOrgNotFound):
+		a.log.Warn("organization not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+	case errors.Is(err, storage.ErrAppExists):
+		a.log.Warn("app already exists", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrAppExists)
+	}
+
+	a.log.Error("storage error", slog.String("op", op), sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, secretLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,163 @@
+package apps
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApps(t *testing.T, gracePeriod time.Duration) (*Apps, *memory.Storage) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	return New(log, s, s, gracePeriod), s
+}
+
+func TestCreateApp(t *testing.T) {
+	a, s := newTestApps(t, time.Hour)
+	ctx := context.Background()
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	_, err = a.CreateApp(ctx, "taken", 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		appName string
+		orgID   int64
+		wantErr error
+	}{
+		{name: "global", appName: "global"},
+		{name: "in org", appName: "scoped", orgID: orgID},
+		{name: "missing org", appName: "orphan", orgID: orgID + 1, wantErr: ErrOrgNotFound},
+		{name: "taken name", appName: "taken", wantErr: ErrAppExists},
+		{name: "taken name in org", appName: "taken", orgID: orgID, wantErr: ErrAppExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := a.CreateApp(ctx, tt.appName, tt.orgID)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, app.Secret)
+
+			stored, err := s.App(ctx, app.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.orgID, stored.OrgID)
+			assert.Equal(t, app.Secret, stored.Secret)
+		})
+	}
+}
+
+func TestListApps(t *testing.T) {
+	a, s := newTestApps(t, time.Hour)
+	ctx := context.Background()
+
+	orgA, err := s.SaveOrg(ctx, "a")
+	require.NoError(t, err)
+	orgB, err := s.SaveOrg(ctx, "b")
+	require.NoError(t, err)
+
+	global, err := a.CreateApp(ctx, "global", 0)
+	require.NoError(t, err)
+	appA, err := a.CreateApp(ctx, "a", orgA)
+	require.NoError(t, err)
+	appB, err := a.CreateApp(ctx, "b", orgB)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		orgID int64
+		want  []int
+	}{
+		{name: "all", want: []int{global.ID, appA.ID, appB.ID}},
+		{name: "org a", orgID: orgA, want: []int{appA.ID}},
+		{name: "org b", orgID: orgB, want: []int{appB.ID}},
+		{name: "org without apps", orgID: orgB + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apps, err := a.ListApps(ctx, tt.orgID)
+			require.NoError(t, err)
+
+			var ids []int
+			for _, app := range apps {
+				ids = append(ids, app.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		prevValid   bool
+	}{
+		{name: "grace period", gracePeriod: time.Hour, prevValid: true},
+		{name: "no grace period"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestApps(t, tt.gracePeriod)
+			ctx := context.Background()
+
+			created, err := a.CreateApp(ctx, "app", 0)
+			require.NoError(t, err)
+
+			rotated, err := a.RotateSecret(ctx, created.ID)
+			require.NoError(t, err)
+			assert.NotEqual(t, created.Secret, rotated.Secret)
+			assert.Equal(t, created.Secret, rotated.PrevSecret)
+
+			want := []string{rotated.Secret}
+			if tt.prevValid {
+				want = append(want, created.Secret)
+			}
+			assert.Equal(t, want, rotated.VerificationSecrets(time.Now()))
+			assert.Equal(t, []string{rotated.Secret}, rotated.VerificationSecrets(time.Now().Add(tt.gracePeriod+time.Second)))
+		})
+	}
+}
+
+func TestMissingApp(t *testing.T) {
+	a, _ := newTestApps(t, time.Hour)
+	ctx := context.Background()
+
+	created, err := a.CreateApp(ctx, "app", 0)
+	require.NoError(t, err)
+	require.NoError(t, a.DeleteApp(ctx, created.ID))
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "update", call: func() error { return a.UpdateApp(ctx, created.ID, "renamed") }},
+		{name: "rotate", call: func() error { _, err := a.RotateSecret(ctx, created.ID); return err }},
+		{name: "delete", call: func() error { return a.DeleteApp(ctx, created.ID) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.call(), ErrAppNotFound)
+		})
+	}
+}
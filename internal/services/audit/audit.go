@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// verifyBatchSize is how many events are verified at once
+	verifyBatchSize = 500
+)
+
+// Audit records security events to the append-only audit log
+type Audit struct {
+	log           *slog.Logger
+	eventSaver    EventSaver
+	eventProvider EventProvider
+	cfg           Config
+}
+
+// Config tunes Audit service behaviour
+type Config struct {
+	// HashChain links every recorded event to the previous one by hash
+	HashChain bool
+}
+
+type EventSaver interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent, chained bool) (int64, error)
+}
+
+type EventProvider interface {
+	AuditEvents(ctx context.Context, filter models.AuditFilter, beforeID int64, limit int) ([]models.AuditEvent, error)
+	ChainedAuditEvents(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error)
+}
+
+// Verification is result of audit log hash chain verification.
+// BrokenEventID is the first event which doesn't match the chain
+type Verification struct {
+	Checked       int64
+	BrokenEventID int64
+}
+
+// Valid reports whether hash chain is intact
+func (v Verification) Valid() bool {
+	return v.BrokenEventID == 0
+}
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// New returns a new instance of Audit service
+func New(
+	log *slog.Logger,
+	eventSaver EventSaver,
+	eventProvider EventProvider,
+	cfg Config,
+) *Audit {
+	return &Audit{
+		log:           log,
+		eventSaver:    eventSaver,
+		eventProvider: eventProvider,
+		cfg:           cfg,
+	}
+}
+
+// Record appends event to the audit log. Zero event time means now
+func (a *Audit) Record(ctx context.Context, event models.AuditEvent) error {
+	const op = "Audit.Record"
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if _, err := a.eventSaver.SaveAuditEvent(ctx, event, a.cfg.HashChain); err != nil {
+		a.log.Error("failed to record audit event",
+			slog.String("op", op),
+			slog.String("action", event.Action),
+			sl.Err(err),
+		)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Query returns a page of audit events matching filter, newest first,
+// and token of the next page. Empty next page token means there are no
+// more events
+func (a *Audit) Query(
+	ctx context.Context,
+	filter models.AuditFilter,
+	pageToken string,
+	pageSize int,
+) ([]models.AuditEvent, string, error) {
+	const op = "Audit.Query"
+
+	beforeID, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	// Fetch one extra event to find out whether there is a next page
+	events, err := a.eventProvider.AuditEvents(ctx, filter, beforeID, pageSize+1)
+	if err != nil {
+		a.log.Error("failed to query audit events", slog.String("op", op), sl.Err(err))
+
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nextPageToken string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		nextPageToken = encodePageToken(events[pageSize-1].ID)
+	}
+
+	return events, nextPageToken, nil
+}
+
+// Verify walks the hash chain of audit log and reports the first event
+// that was altered or follows a removed one. Removal of the newest
+// events can't be detected by the chain alone. Hash of event redacted by
+// erasure can't be recomputed, such event is checked to be linked and to
+// hold no personal data, the storage keeps its other columns unchanged
+func (a *Audit) Verify(ctx context.Context) (Verification, error) {
+	const op = "Audit.Verify"
+
+	log := a.log.With(slog.String("op", op))
+
+	var (
+		result   Verification
+		prevHash string
+		afterID  int64
+	)
+	for {
+		events, err := a.eventProvider.ChainedAuditEvents(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			log.Error("failed to get audit events", sl.Err(err))
+
+			return Verification{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range events {
+			if event.PrevHash != prevHash || !intact(event, prevHash) {
+				log.Warn("audit log hash chain is broken", slog.Int64("event_id", event.ID))
+
+				result.BrokenEventID = event.ID
+
+				return result, nil
+			}
+
+			result.Checked++
+			prevHash = event.Hash
+			afterID = event.ID
+		}
+
+		if len(events) < verifyBatchSize {
+			break
+		}
+	}
+
+	log.Info("audit log hash chain verified", slog.Int64("checked", result.Checked))
+
+	return result, nil
+}
+
+func encodePageToken(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(raw), 10, 64)
+}
+
+// intact reports whether event matches its hash. Redaction only clears ip
+// and user agent and replaces identifier in target with user id
+func intact(event models.AuditEvent, prevHash string) bool {
+	if !event.Redacted {
+		return event.ChainHash(prevHash) == event.Hash
+	}
+
+	kind, _, _ := strings.Cut(event.Target, ":")
+
+	return event.Hash != "" && event.IP == "" && event.UserAgent == "" && kind != models.AuditTargetIdentifier
+}
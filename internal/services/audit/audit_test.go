@@ -0,0 +1,207 @@
+package audit_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/services/audit"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// events serves a fixed, possibly tampered, audit log
+type events []models.AuditEvent
+
+func (e events) AuditEvents(context.Context, models.AuditFilter, int64, int) ([]models.AuditEvent, error) {
+	return e, nil
+}
+
+func (e events) ChainedAuditEvents(_ context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	var page []models.AuditEvent
+	for _, event := range e {
+		if event.ID > afterID && len(page) < limit {
+			page = append(page, event)
+		}
+	}
+	return page, nil
+}
+
+func (e events) SaveAuditEvent(context.Context, models.AuditEvent, bool) (int64, error) {
+	panic("read only")
+}
+
+// chain records three events with hash chaining on and returns them
+func chain(t *testing.T) []models.AuditEvent {
+	t.Helper()
+
+	s, err := memory.New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.DBConfig{})
+	require.NoError(t, err)
+	a := audit.New(slog.New(slog.NewTextHandler(io.Discard, nil)), s, s, audit.Config{HashChain: true})
+
+	ctx := context.Background()
+	for _, target := range []string{"identifier:bob@example.com", "user:7", "app:1"} {
+		require.NoError(t, a.Record(ctx, models.AuditEvent{
+			Time:      time.Unix(1700000000, 0),
+			ActorID:   7,
+			Action:    "/auth.Auth/Login",
+			Target:    target,
+			IP:        "10.0.0.1",
+			UserAgent: "agent",
+			Result:    "OK",
+		}))
+	}
+
+	recorded, err := s.ChainedAuditEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, recorded, 3)
+
+	return recorded
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(e []models.AuditEvent) []models.AuditEvent
+		broken int64
+	}{
+		{
+			name:   "intact",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent { return e },
+		},
+		{
+			name: "altered result",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				e[1].Result = "PermissionDenied"
+				return e
+			},
+			broken: 2,
+		},
+		{
+			name: "removed event",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				return append(e[:1], e[2])
+			},
+			broken: 3,
+		},
+		{
+			name: "redacted",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				e[0].Target, e[0].IP, e[0].UserAgent, e[0].Redacted = "user:7", "", "", true
+				e[1].IP, e[1].UserAgent, e[1].Redacted = "", "", true
+				return e
+			},
+		},
+		{
+			name: "redacted keeps ip",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				e[1].UserAgent, e[1].Redacted = "", true
+				return e
+			},
+			broken: 2,
+		},
+		{
+			name: "redacted keeps identifier",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				e[0].IP, e[0].UserAgent, e[0].Redacted = "", "", true
+				return e
+			},
+			broken: 1,
+		},
+		{
+			name: "redacted relinked",
+			tamper: func(e []models.AuditEvent) []models.AuditEvent {
+				e[2].PrevHash, e[2].IP, e[2].UserAgent, e[2].Redacted = e[0].Hash, "", "", true
+				return e
+			},
+			broken: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := events(tt.tamper(chain(t)))
+			a := audit.New(slog.New(slog.NewTextHandler(io.Discard, nil)), log, log, audit.Config{HashChain: true})
+
+			result, err := a.Verify(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.broken, result.BrokenEventID)
+			assert.Equal(t, tt.broken == 0, result.Valid())
+		})
+	}
+}
+
+func TestVerifyRecorded(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	chained := audit.New(log, s, s, audit.Config{HashChain: true})
+	unchained := audit.New(log, s, s, audit.Config{})
+
+	// Events recorded with chaining off are skipped, the chain spans batches
+	ctx := context.Background()
+	for i := range 501 {
+		a := chained
+		if i%100 == 0 {
+			a = unchained
+		}
+		require.NoError(t, a.Record(ctx, models.AuditEvent{Action: "/auth.Auth/Login", Result: "OK"}))
+	}
+
+	result, err := chained.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid())
+	assert.EqualValues(t, 495, result.Checked)
+}
+
+func TestQuery(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+	a := audit.New(log, s, s, audit.Config{})
+
+	ctx := context.Background()
+	for _, result := range []string{"OK", "PermissionDenied", "OK", "OK", "PermissionDenied"} {
+		require.NoError(t, a.Record(ctx, models.AuditEvent{ActorID: 7, Action: "/auth.Auth/Login", Result: result}))
+	}
+
+	tests := []struct {
+		name    string
+		filter  models.AuditFilter
+		size    int
+		pages   [][]int64
+		wantErr error
+	}{
+		{name: "one page", pages: [][]int64{{5, 4, 3, 2, 1}}},
+		{name: "pages", size: 2, pages: [][]int64{{5, 4}, {3, 2}, {1}}},
+		{name: "filtered", filter: models.AuditFilter{Result: "OK"}, size: 2, pages: [][]int64{{4, 3}, {1}}},
+		{name: "other actor", filter: models.AuditFilter{ActorID: 8}, pages: [][]int64{nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token string
+			for i, want := range tt.pages {
+				events, next, err := a.Query(ctx, tt.filter, token, tt.size)
+				require.NoError(t, err)
+
+				var ids []int64
+				for _, event := range events {
+					ids = append(ids, event.ID)
+				}
+				assert.Equal(t, want, ids)
+				assert.Equal(t, i < len(tt.pages)-1, next != "", "page %d", i)
+				token = next
+			}
+		})
+	}
+
+	_, _, err = a.Query(ctx, models.AuditFilter{}, "not a token", 0)
+	assert.ErrorIs(t, err, audit.ErrInvalidPageToken)
+}
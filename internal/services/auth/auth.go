@@ -8,26 +8,85 @@ import (
 	"time"
 
 	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
 	"github.com/m1al04949/sso-gRPC/internal/lib/jwt"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/passkey"
 	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
 	"github.com/m1al04949/sso-gRPC/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log             *slog.Logger
+	userSaver       UserSaver
+	userProvider    UserProvider
+	appProvider     AppProvider
+	orgProvider     OrgProvider
+	groupProvider   GroupProvider
+	profileProvider ProfileProvider
+	eventSaver      EventSaver
+	magicLinks      MagicLinkStorage
+	otpStorage      OTPStorage
+	webAuthnStorage WebAuthnStorage
+	relyingParty    *passkey.RelyingParty
+	loginAttempts   LoginAttemptStorage
+	trustedDevices  TrustedDeviceStorage
+	notifiers       notifier.Channels
+	cfg             Config
+}
+
+// Config tunes Auth service behaviour
+type Config struct {
+	TokenTTL time.Duration
+	// AdminAppID is the only app whose tokens carry admin rights.
+	// Tokens of admins issued to other apps are refused
+	AdminAppID int
+	// EmailUniquePerOrg makes users of organization apps
+	// looked up in the organization namespace
+	EmailUniquePerOrg bool
+	// IncludeGroups adds names of user groups to token claims
+	IncludeGroups bool
+	// ClaimsMetadata lists admin metadata keys added to token claims
+	ClaimsMetadata []string
+	// MagicLinkTTL is how long magic link can be used
+	MagicLinkTTL time.Duration
+	// MagicLinkURL is the page magic links lead to. Code and app id
+	// are added to its query
+	MagicLinkURL string
+	OTP          OTPConfig
+	// WebAuthnTimeout is how long passkey ceremony can be finished
+	WebAuthnTimeout time.Duration
+	Risk            RiskConfig
+	// TrustedDeviceTTL is how long second factor is skipped on device
+	// user trusted
+	TrustedDeviceTTL time.Duration
+	StepUp           StepUpConfig
+}
+
+// OTPConfig tunes one-time codes of passwordless login and second factor
+type OTPConfig struct {
+	TTL         time.Duration
+	Digits      int
+	MaxAttempts int
+	// ResendInterval is the least time between codes sent to user
+	ResendInterval time.Duration
+	// MaxSends limits how many times code of one challenge is sent
+	MaxSends int
 }
 
 type UserSaver interface {
 	SaveUser(ctx context.Context, email string, passHash []byte) (uid int64, err error)
+	SaveOrgUser(ctx context.Context, namespace int64, orgID int64, email string, passHash []byte) (uid int64, err error)
+	SetPassword(ctx context.Context, userID int64, passHash []byte) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
+	OrgUser(ctx context.Context, namespace int64, email string) (models.User, error)
+	UserByUsername(ctx context.Context, namespace int64, username string) (models.User, error)
+	UserByPhone(ctx context.Context, namespace int64, phone string) (models.User, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
@@ -35,6 +94,76 @@ type AppProvider interface {
 	App(ctx context.Context, appID int) (models.App, error)
 }
 
+type OrgProvider interface {
+	OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error)
+}
+
+type GroupProvider interface {
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+}
+
+type ProfileProvider interface {
+	UserProfile(ctx context.Context, userID int64) (models.Profile, error)
+}
+
+// EventSaver records events which are not caused by state changes.
+// Events of state changes are recorded by storage along with them
+type EventSaver interface {
+	SaveEvent(ctx context.Context, event models.Event) error
+}
+
+type MagicLinkStorage interface {
+	SaveMagicLink(ctx context.Context, link models.MagicLink, codeHash string) (int64, error)
+	ConsumeMagicLink(ctx context.Context, codeHash string, appID int, now time.Time) (models.MagicLink, error)
+}
+
+type OTPStorage interface {
+	SaveOTPChallenge(ctx context.Context, ch models.OTPChallenge, challengeHash string) (int64, error)
+	OTPChallenge(ctx context.Context, challengeHash string) (models.OTPChallenge, error)
+	LatestOTPChallenge(ctx context.Context, userID int64, appID int) (models.OTPChallenge, error)
+	AddOTPAttempt(ctx context.Context, id int64, maxAttempts int) error
+	ResendOTPChallenge(ctx context.Context, id int64, codeHash string, now time.Time) error
+	ConsumeOTPChallenge(ctx context.Context, id int64, now time.Time) error
+}
+
+type WebAuthnStorage interface {
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (int64, error)
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnCredentialUse(ctx context.Context, id int64, signCount uint32, backupState bool, now time.Time) error
+	DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error
+	SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession, sessionHash string, now time.Time) (int64, error)
+	TakeWebAuthnSession(ctx context.Context, sessionHash string, now time.Time) (models.WebAuthnSession, error)
+}
+
+type LoginAttemptStorage interface {
+	SaveLoginAttempt(ctx context.Context, attempt models.LoginAttempt, keepAfter time.Time) (int64, error)
+	LoginAttempts(ctx context.Context, userID int64, since time.Time, limit int) ([]models.LoginAttempt, error)
+}
+
+type TrustedDeviceStorage interface {
+	SaveTrustedDevice(ctx context.Context, device models.TrustedDevice, tokenHash string) (int64, error)
+	UseTrustedDevice(ctx context.Context, userID int64, tokenHash string, now time.Time) error
+	TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error)
+	DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error
+	DeleteTrustedDevices(ctx context.Context, userID int64) (int64, error)
+}
+
+// Storage is everything Auth keeps in storage
+type Storage interface {
+	UserSaver
+	UserProvider
+	AppProvider
+	OrgProvider
+	GroupProvider
+	ProfileProvider
+	EventSaver
+	MagicLinkStorage
+	OTPStorage
+	WebAuthnStorage
+	LoginAttemptStorage
+	TrustedDeviceStorage
+}
+
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidAppID       = errors.New("invalid app id")
@@ -42,85 +171,444 @@ var (
 	ErrAppNotFound        = errors.New("app not found")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidPass        = errors.New("invalid email or password")
+	// ErrUserInactive is wrapped by errors of accounts that are not active
+	ErrUserInactive            = errors.New("user is not active")
+	ErrUserDisabled            = fmt.Errorf("%w: account is suspended", ErrUserInactive)
+	ErrUserPendingVerification = fmt.Errorf("%w: account is pending verification", ErrUserInactive)
+	ErrUserLocked              = fmt.Errorf("%w: account is locked", ErrUserInactive)
+	ErrUserDeleted             = fmt.Errorf("%w: account is deleted", ErrUserInactive)
+	ErrPassResetRequired       = errors.New("password reset required")
+	ErrPassResetNotRequired    = errors.New("password reset is not required")
+	ErrPasswordReused          = errors.New("new password is the same as current")
+	ErrInvalidToken            = errors.New("invalid token")
+	ErrPermissionDenied        = errors.New("permission denied")
+	ErrOrgNotFound             = errors.New("organization not found")
+	ErrNotOrgMember            = errors.New("user is not a member of app organization")
+	ErrInvalidMagicLink        = errors.New("magic link is invalid or expired")
+	ErrInvalidOTP              = errors.New("code is invalid or expired")
+	ErrOTPThrottled            = errors.New("code was sent recently")
+	ErrInvalidChannel          = errors.New("invalid notification channel")
+	ErrInvalidPasskey          = errors.New("invalid passkey")
+	ErrInvalidPasskeySession   = errors.New("passkey session is invalid or expired")
+	ErrPasskeyExists           = errors.New("passkey already registered")
+	ErrPasskeyNotFound         = errors.New("passkey not found")
+	ErrLastPasskey             = errors.New("last passkey is used as second factor")
+	ErrTrustedDeviceNotFound   = errors.New("trusted device not found")
+	ErrStepUpRequired          = errors.New("recent strong authentication required")
+	// ErrMFARequired is matched by MFARequiredError
+	ErrMFARequired = errors.New("second factor required")
 )
 
 // New return a new instance Auth service
-func New(log *slog.Logger,
-	userSaver UserSaver,
-	userProvider UserProvider,
-	appProvider AppProvider,
-	tokenTTL time.Duration,
+func New(
+	log *slog.Logger,
+	authStorage Storage,
+	relyingParty *passkey.RelyingParty,
+	notifiers notifier.Channels,
+	cfg Config,
 ) *Auth {
 	return &Auth{
-		log:          log,
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:             log,
+		userSaver:       authStorage,
+		userProvider:    authStorage,
+		appProvider:     authStorage,
+		orgProvider:     authStorage,
+		groupProvider:   authStorage,
+		profileProvider: authStorage,
+		eventSaver:      authStorage,
+		magicLinks:      authStorage,
+		otpStorage:      authStorage,
+		webAuthnStorage: authStorage,
+		relyingParty:    relyingParty,
+		loginAttempts:   authStorage,
+		trustedDevices:  authStorage,
+		notifiers:       notifiers,
+		cfg:             cfg,
 	}
 }
 
 // Login checks if user with given credentials exists in the system
-// and returns access token. If user exists, but password incorrect,
-// returns error. If user doesn't exists, returns error
+// and returns access token. User logs in by email, username or phone
+// number. If user exists, but password incorrect, returns error.
+// If user doesn't exists, returns error. Users with second factor get
+// one-time code or passkey assertion and MFARequiredError instead of token.
+// Risky logins of users without second factor get code by email. Second
+// factor is skipped on device trusted by user that presents its token
 func (a *Auth) Login(
 	ctx context.Context,
-	email string,
+	login string,
 	password string,
 	appID int,
+	deviceToken string,
 ) (string, error) {
 	const op = "auth.Login"
 
-	log := a.log.With(slog.String("op", op), slog.String("email", email))
+	log := a.log.With(slog.String("op", op), slog.String("login", login))
 
 	log.Info("attempt to login user")
 
-	user, err := a.userProvider.User(ctx, email)
+	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found", sl.Err(err))
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
 
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
 		}
 
-		log.Error("failed to get user", sl.Err(err))
-
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", sl.Err(err))
+	user, err := a.checkCredentials(ctx, log, app.OrgID, login, password)
+	if err != nil {
+		a.saveLoginFailed(ctx, log, app, login, err)
 
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	app, err := a.appProvider.App(ctx, appID)
+	method, err := a.secondFactor(ctx, log, user, app, loginMethodPassword, deviceToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := a.completeLogin(ctx, log, user, app, method)
 	if err != nil {
+		a.saveLoginFailed(ctx, log, app, login, err)
+
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	return token, nil
+}
+
+// Methods user logged in with, recorded in logged in events
+const (
+	loginMethodPassword    = "password"
+	loginMethodMagicLink   = "magic_link"
+	loginMethodOTP         = "otp"
+	loginMethodPasswordOTP = "password_otp"
+	// Passkey alone is both possession and user verification
+	loginMethodPasskey         = "passkey"
+	loginMethodPasswordPasskey = "password_passkey"
+	// Second factor was passed on the device before
+	loginMethodPasswordTrustedDevice = "password_trusted_device"
+	// Second factor after magic link or one-time code
+	loginMethodPasswordlessOTP           = "passwordless_otp"
+	loginMethodPasswordlessPasskey       = "passwordless_passkey"
+	loginMethodPasswordlessTrustedDevice = "passwordless_trusted_device"
+)
+
+// secondFactor decides if login by the first factor method needs second
+// factor: the one user set up or, for risky logins, code sent by email.
+// Second factor is skipped on device user trusted. Returns method to
+// complete login with or MFARequiredError
+func (a *Auth) secondFactor(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	method string,
+	deviceToken string,
+) (string, error) {
+	assessment, err := a.assessLogin(ctx, user)
+	if err != nil {
+		return "", err
+	}
+
+	channel := notifier.Channel(user.MFAChannel)
+	if channel == "" && a.stepUpRequired(assessment) {
+		log.Warn("risky login requires second factor",
+			slog.Int("risk_score", assessment.Score),
+			slog.Any("risk_reasons", assessment.Reasons),
+		)
+
+		channel = notifier.ChannelEmail
+	}
+	if channel == "" {
+		return method, nil
+	}
+
+	passwordless := method != loginMethodPassword
+
+	if a.deviceTrusted(ctx, log, user, deviceToken) {
+		log.Info("second factor skipped on trusted device")
+
+		if passwordless {
+			return loginMethodPasswordlessTrustedDevice, nil
+		}
+
+		return loginMethodPasswordTrustedDevice, nil
+	}
+
+	if channel == MFAWebAuthn {
+		purpose := models.WebAuthnPurposeMFA
+		if passwordless {
+			purpose = models.WebAuthnPurposePasswordlessMFA
+		}
+
+		mfaErr, err := a.beginPasskeyMFA(ctx, user, app, purpose)
+		if err != nil {
+			return "", err
+		}
+
+		log.Info("second factor required", slog.String("channel", user.MFAChannel))
+
+		return "", mfaErr
+	}
+
+	purpose := models.OTPPurposeMFA
+	if passwordless {
+		purpose = models.OTPPurposePasswordlessMFA
+	}
+
+	challenge, err := a.sendOTP(ctx, log, user, app, purpose, channel)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("second factor required", slog.String("channel", string(channel)))
+
+	return "", &MFARequiredError{Challenge: challenge, Channel: channel}
+}
+
+// completeLogin checks that authenticated user may use the app and
+// returns access token the same way for every login method
+func (a *Auth) completeLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	method string,
+) (string, error) {
+	if err := a.checkOrgMember(ctx, log, user, app); err != nil {
+		return "", err
+	}
+
 	log.Info("user login succesfull")
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	token, err := a.issueToken(ctx, log, user, app, authentication(method, time.Now()), a.cfg.TokenTTL)
 	if err != nil {
-		a.log.Error("failed to generate token", sl.Err(err))
+		return "", err
+	}
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	a.saveEvent(ctx, log, models.Event{
+		Type:   models.EventUserLoggedIn,
+		UserID: user.ID,
+		OrgID:  app.OrgID,
+		AppID:  app.ID,
+		Data:   map[string]string{"method": method},
+	})
+
+	a.recordLogin(ctx, log, user, app)
+
+	return token, nil
+}
+
+// checkOrgMember checks that user is a member of organization of the app
+func (a *Auth) checkOrgMember(ctx context.Context, log *slog.Logger, user models.User, app models.App) error {
+	if app.OrgID == 0 {
+		return nil
+	}
+
+	if _, err := a.orgProvider.OrgMember(ctx, app.OrgID, user.ID); err != nil {
+		if errors.Is(err, storage.ErrOrgMemberNotFound) {
+			log.Warn("user is not a member of app organization", slog.Int64("org_id", app.OrgID))
+
+			return ErrNotOrgMember
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// saveEvent records event. Failure to record doesn't fail the operation
+func (a *Auth) saveEvent(ctx context.Context, log *slog.Logger, event models.Event) {
+	if err := a.eventSaver.SaveEvent(ctx, event); err != nil {
+		log.Error("failed to save event", slog.String("type", event.Type), sl.Err(err))
+	}
+}
+
+// saveLoginFailed records failed login attempt with its reason
+func (a *Auth) saveLoginFailed(ctx context.Context, log *slog.Logger, app models.App, login string, err error) {
+	var reason string
+	switch {
+	case errors.Is(err, ErrInvalidCredentials):
+		reason = "invalid_credentials"
+	case errors.Is(err, ErrUserInactive):
+		reason = "inactive"
+	case errors.Is(err, ErrPassResetRequired):
+		reason = "password_reset_required"
+	case errors.Is(err, ErrNotOrgMember):
+		reason = "not_org_member"
+	case errors.Is(err, ErrInvalidOTP):
+		reason = "invalid_code"
+	default:
+		// Internal errors are not login failures
+		return
+	}
+
+	a.saveEvent(ctx, log, models.Event{
+		Type:  models.EventUserLoginFailed,
+		OrgID: app.OrgID,
+		AppID: app.ID,
+		Data:  map[string]string{"login": login, "reason": reason},
+	})
+}
+
+// CheckCredentials checks password of enabled user registered in the
+// organization namespace and returns the user
+func (a *Auth) CheckCredentials(
+	ctx context.Context,
+	orgID int64,
+	email string,
+	password string,
+) (models.User, error) {
+	const op = "auth.CheckCredentials"
+
+	log := a.log.With(slog.String("op", op), slog.String("email", email))
+
+	user, err := a.checkCredentials(ctx, log, orgID, email, password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (a *Auth) checkCredentials(
+	ctx context.Context,
+	log *slog.Logger,
+	orgID int64,
+	email string,
+	password string,
+) (models.User, error) {
+	user, err := a.checkPassword(ctx, log, orgID, email, password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if user.PassResetRequired {
+		log.Warn("user has to reset password")
+
+		return models.User{}, ErrPassResetRequired
+	}
+
+	return user, nil
+}
+
+// checkPassword checks password of active user, whether or not the user
+// has to reset it
+func (a *Auth) checkPassword(
+	ctx context.Context,
+	log *slog.Logger,
+	orgID int64,
+	email string,
+	password string,
+) (models.User, error) {
+	user, err := a.orgUser(ctx, orgID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+
+			return models.User{}, ErrInvalidCredentials
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+
+		return models.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
+		log.Info("invalid credentials", sl.Err(err))
+
+		a.saveLoginAttempt(ctx, log, user.ID, 0, false)
+
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// statusError returns error for user that can't log in due to account status
+func statusError(user models.User) error {
+	switch user.Status {
+	case models.StatusActive:
+		if user.Disabled {
+			return ErrUserDisabled
+		}
+
+		return nil
+	case models.StatusPendingVerification:
+		return ErrUserPendingVerification
+	case models.StatusLocked:
+		return ErrUserLocked
+	case models.StatusDeleted:
+		return ErrUserDeleted
+	}
+
+	return ErrUserDisabled
+}
+
+// issueToken returns access token of user for app valid for ttl
+func (a *Auth) issueToken(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	auth jwt.Authentication,
+	ttl time.Duration,
+) (string, error) {
+	if a.cfg.IncludeGroups {
+		groups, err := a.groupProvider.UserGroups(ctx, user.ID)
+		if err != nil {
+			log.Error("failed to get user groups", sl.Err(err))
+
+			return "", err
+		}
+		for _, group := range groups {
+			user.Groups = append(user.Groups, group.Name)
+		}
+	}
+
+	if len(a.cfg.ClaimsMetadata) > 0 {
+		profile, err := a.profileProvider.UserProfile(ctx, user.ID)
+		if err != nil {
+			log.Error("failed to get user profile", sl.Err(err))
+
+			return "", err
+		}
+		user.Metadata = profile.ClaimsMetadata(a.cfg.ClaimsMetadata)
+	}
+
+	token, err := jwt.NewToken(user, app, auth, ttl)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+
+		return "", err
 	}
 
 	return token, nil
 }
 
 // RegusterNewUser register new users in the system and return
-// user ID. If username already exists, return error
+// user ID. If username already exists, return error. Non-zero orgID
+// makes the user a member of the organization
 func (a *Auth) RegisterNewUser(
 	ctx context.Context,
 	email string,
 	pass string,
+	orgID int64,
 ) (int64, error) {
 	const op = "auth.RegisterNewUser"
 
-	log := a.log.With(slog.String("op", op), slog.String("email", email))
+	log := a.log.With(slog.String("op", op), slog.String("email", email), slog.Int64("org_id", orgID))
 
 	log.Info("registering new user")
 
@@ -132,13 +620,23 @@ func (a *Auth) RegisterNewUser(
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.userSaver.SaveUser(ctx, email, passHash)
+	var id int64
+	if orgID == 0 {
+		id, err = a.userSaver.SaveUser(ctx, email, passHash)
+	} else {
+		id, err = a.userSaver.SaveOrgUser(ctx, a.namespace(orgID), orgID, email, passHash)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Warn("user is already exists", sl.Err(err))
 
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			log.Warn("organization not found", sl.Err(err))
+
+			return 0, fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+		}
 
 		log.Error("failed to save new user", sl.Err(err))
 
@@ -150,7 +648,7 @@ func (a *Auth) RegisterNewUser(
 	return id, nil
 }
 
-// IsAdmin checks if user is admin
+// IsAdmin checks if user is admin directly or through roles of his groups
 func (a *Auth) IsAdmin(
 	ctx context.Context,
 	userID int64,
@@ -167,7 +665,7 @@ func (a *Auth) IsAdmin(
 
 	isAdmin, err := a.userProvider.IsAdmin(ctx, userID)
 	if err != nil {
-		if errors.Is(err, storage.ErrAppNotFound) {
+		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Warn("user not found", sl.Err(err))
 
 			return false, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
@@ -179,3 +677,169 @@ func (a *Auth) IsAdmin(
 
 	return isAdmin, nil
 }
+
+// AuthenticateUser verifies access token issued by Login and checks
+// that its owner exists and is not disabled. Services grant admins
+// extra rights, so tokens of admins have to be issued to the admin app.
+// Returns user ID, which is also returned along with ErrPermissionDenied
+// for admin tokens of other apps
+func (a *Auth) AuthenticateUser(
+	ctx context.Context,
+	token string,
+) (int64, error) {
+	const op = "Auth.AuthenticateUser"
+
+	user, claims, err := a.tokenOwner(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.AppID != a.cfg.AdminAppID {
+		isAdmin, err := a.userProvider.IsAdmin(ctx, user.ID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if isAdmin {
+			a.log.Warn("admin token is issued to another app",
+				slog.String("op", op),
+				slog.Int64("user_id", user.ID),
+				slog.Int("app_id", claims.AppID),
+			)
+
+			return user.ID, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+		}
+	}
+
+	return user.ID, nil
+}
+
+// AuthorizeAdmin verifies access token issued by Login to the admin app
+// and checks that its owner is an enabled admin. Returns admin user ID.
+// Token owner ID is returned along with ErrPermissionDenied for non-admins
+// and tokens of other apps
+func (a *Auth) AuthorizeAdmin(
+	ctx context.Context,
+	token string,
+) (int64, error) {
+	const op = "Auth.AuthorizeAdmin"
+
+	user, claims, err := a.tokenOwner(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Every app can sign tokens of any user with its own secret
+	if claims.AppID != a.cfg.AdminAppID {
+		a.log.Warn("token of another app is not allowed to use admin api",
+			slog.String("op", op),
+			slog.Int64("user_id", user.ID),
+			slog.Int("app_id", claims.AppID),
+		)
+
+		return user.ID, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	isAdmin, err := a.userProvider.IsAdmin(ctx, user.ID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !isAdmin {
+		a.log.Warn("user is not allowed to use admin api",
+			slog.String("op", op),
+			slog.Int64("user_id", user.ID),
+		)
+
+		return user.ID, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	return user.ID, nil
+}
+
+// tokenOwner verifies token with its app secret and returns enabled
+// token owner with token claims
+func (a *Auth) tokenOwner(ctx context.Context, token string) (models.User, jwt.Claims, error) {
+	log := a.log.With(slog.String("op", "Auth.tokenOwner"))
+
+	appID, err := jwt.AppID(token)
+	if err != nil {
+		log.Warn("failed to parse token", sl.Err(err))
+
+		return models.User{}, jwt.Claims{}, ErrInvalidToken
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("token app not found", slog.Int("app_id", appID))
+
+			return models.User{}, jwt.Claims{}, ErrInvalidToken
+		}
+
+		return models.User{}, jwt.Claims{}, err
+	}
+
+	claims, err := jwt.Parse(token, app)
+	if err != nil {
+		log.Warn("invalid token", sl.Err(err))
+
+		return models.User{}, jwt.Claims{}, ErrInvalidToken
+	}
+
+	user, err := a.userProvider.UserByID(ctx, claims.UID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("token owner not found", slog.Int64("user_id", claims.UID))
+
+			return models.User{}, jwt.Claims{}, ErrInvalidToken
+		}
+
+		return models.User{}, jwt.Claims{}, err
+	}
+
+	if user.Disabled {
+		log.Warn("token owner is disabled", slog.Int64("user_id", user.ID))
+
+		return models.User{}, jwt.Claims{}, ErrPermissionDenied
+	}
+
+	return user, claims, nil
+}
+
+// orgUser returns user by email, username or phone number
+// from the organization namespace
+func (a *Auth) orgUser(ctx context.Context, orgID int64, login string) (models.User, error) {
+	namespace := a.namespace(orgID)
+
+	switch identifiers.Detect(login) {
+	case identifiers.KindUsername:
+		username, err := identifiers.CanonicalUsername(login)
+		if err != nil {
+			return models.User{}, storage.ErrUserNotFound
+		}
+
+		return a.userProvider.UserByUsername(ctx, namespace, username)
+	case identifiers.KindPhone:
+		phone, err := identifiers.CanonicalPhone(login)
+		if err != nil {
+			return models.User{}, storage.ErrUserNotFound
+		}
+
+		return a.userProvider.UserByPhone(ctx, namespace, phone)
+	}
+
+	if namespace != 0 {
+		return a.userProvider.OrgUser(ctx, namespace, login)
+	}
+
+	return a.userProvider.User(ctx, login)
+}
+
+// namespace returns namespace users of organization emails are unique in
+func (a *Auth) namespace(orgID int64) int64 {
+	if a.cfg.EmailUniquePerOrg {
+		return orgID
+	}
+
+	return 0
+}
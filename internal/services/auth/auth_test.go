@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuth(t *testing.T) (*Auth, *memory.Storage, int) {
+	t.Helper()
+
+	return newConfiguredAuth(t, Config{TokenTTL: time.Hour}, nil)
+}
+
+func newConfiguredAuth(t *testing.T, cfg Config, notifiers notifier.Channels) (*Auth, *memory.Storage, int) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	appID, err := s.SaveApp(context.Background(), "test", "test-secret", 0)
+	require.NoError(t, err)
+
+	a := New(log, s, nil, notifiers, cfg)
+
+	return a, s, appID
+}
+
+func TestRegisterLogin(t *testing.T) {
+	a, _, appID := newTestAuth(t)
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "User@Example.com", "password", 0)
+	require.NoError(t, err)
+	assert.NotZero(t, id)
+
+	_, err = a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	token, err := a.Login(ctx, "user@example.com", "password", appID, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	_, err = a.Login(ctx, "user@example.com", "wrong", appID, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Login(ctx, "missing@example.com", "password", appID, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Login(ctx, "user@example.com", "password", appID+1, "")
+	assert.ErrorIs(t, err, ErrAppNotFound)
+}
+
+func TestAuthorizeAdmin(t *testing.T) {
+	a, s, adminAppID := newConfiguredAuth(t, Config{TokenTTL: time.Hour, AdminAppID: 1}, nil)
+	require.Equal(t, 1, adminAppID)
+	ctx := context.Background()
+
+	otherAppID, err := s.SaveApp(ctx, "other", "other-secret", 0)
+	require.NoError(t, err)
+
+	adminID, err := a.RegisterNewUser(ctx, "admin@example.com", "password", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.SetAdmin(ctx, adminID, true))
+	_, err = a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		email     string
+		appID     int
+		wantAdmin error
+		wantUser  error
+	}{
+		{"admin of admin app", "admin@example.com", adminAppID, nil, nil},
+		{"admin of other app", "admin@example.com", otherAppID, ErrPermissionDenied, ErrPermissionDenied},
+		{"user of admin app", "user@example.com", adminAppID, ErrPermissionDenied, nil},
+		{"user of other app", "user@example.com", otherAppID, ErrPermissionDenied, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := a.Login(ctx, tt.email, "password", tt.appID, "")
+			require.NoError(t, err)
+
+			_, err = a.AuthorizeAdmin(ctx, token)
+			if tt.wantAdmin != nil {
+				assert.ErrorIs(t, err, tt.wantAdmin)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, err = a.AuthenticateUser(ctx, token)
+			if tt.wantUser != nil {
+				assert.ErrorIs(t, err, tt.wantUser)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = a.AuthorizeAdmin(ctx, "invalid")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/clientinfo"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// ListTrustedDevices returns unexpired devices user trusted
+func (a *Auth) ListTrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error) {
+	const op = "auth.ListTrustedDevices"
+
+	devices, err := a.trustedDevices.TrustedDevices(ctx, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// RevokeTrustedDevice makes device of user ask for second factor again
+func (a *Auth) RevokeTrustedDevice(ctx context.Context, userID int64, deviceID int64) error {
+	const op = "auth.RevokeTrustedDevice"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID), slog.Int64("device_id", deviceID))
+
+	if err := a.trustedDevices.DeleteTrustedDevice(ctx, userID, deviceID); err != nil {
+		if errors.Is(err, storage.ErrTrustedDeviceNotFound) {
+			log.Warn("trusted device not found")
+
+			return fmt.Errorf("%s: %w", op, ErrTrustedDeviceNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("trusted device revoked")
+
+	return nil
+}
+
+// RevokeTrustedDevices makes all devices of user ask for second factor
+// again and returns how many devices were revoked
+func (a *Auth) RevokeTrustedDevices(ctx context.Context, userID int64) (int64, error) {
+	const op = "auth.RevokeTrustedDevices"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	n, err := a.trustedDevices.DeleteTrustedDevices(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("trusted devices revoked", slog.Int64("count", n))
+
+	return n, nil
+}
+
+// trustDevice trusts device of the call for TrustedDeviceTTL and returns
+// its token. Failure to trust doesn't fail the login, empty token is
+// returned instead
+func (a *Auth) trustDevice(ctx context.Context, log *slog.Logger, user models.User) string {
+	token, tokenHash, err := codes.New()
+	if err != nil {
+		log.Error("failed to generate device token", sl.Err(err))
+
+		return ""
+	}
+
+	client := clientinfo.FromContext(ctx)
+	now := time.Now()
+
+	id, err := a.trustedDevices.SaveTrustedDevice(ctx, models.TrustedDevice{
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.cfg.TrustedDeviceTTL),
+	}, tokenHash)
+	if err != nil {
+		log.Error("failed to trust device", sl.Err(err))
+
+		return ""
+	}
+
+	log.Info("device trusted", slog.Int64("device_id", id))
+
+	return token
+}
+
+// deviceTrusted reports whether token is of unexpired device trusted by user
+func (a *Auth) deviceTrusted(ctx context.Context, log *slog.Logger, user models.User, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	if err := a.trustedDevices.UseTrustedDevice(ctx, user.ID, codes.Hash(token), time.Now()); err != nil {
+		if errors.Is(err, storage.ErrTrustedDeviceNotFound) {
+			log.Warn("device is not trusted")
+		} else {
+			log.Error("failed to check trusted device", sl.Err(err))
+		}
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loginCodeRe = regexp.MustCompile(`^(\S+) is your login code`)
+
+// loginWithCode logs user with second factor in and returns access and
+// device tokens
+func loginWithCode(t *testing.T, a *Auth, mail *notifier.File, appID int, trustDevice bool) (string, string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	_, err := a.Login(ctx, "user@example.com", "password", appID, "")
+	var mfaErr *MFARequiredError
+	require.ErrorAs(t, err, &mfaErr)
+
+	token, deviceToken, err := a.VerifyLoginCode(ctx, mfaErr.Challenge, lastCode(t, mail), appID, "", trustDevice)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	return token, deviceToken
+}
+
+func TestTrustedDevices(t *testing.T) {
+	mail := notifier.NewFile(filepath.Join(t.TempDir(), "mail.jsonl"))
+	a, s, appID := newConfiguredAuth(t, Config{
+		TokenTTL:         time.Hour,
+		OTP:              OTPConfig{TTL: time.Minute, Digits: 6, MaxAttempts: 3, MaxSends: 3},
+		TrustedDeviceTTL: time.Hour,
+	}, notifier.Channels{notifier.ChannelEmail: mail})
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserMFAChannel(ctx, id, string(notifier.ChannelEmail)))
+
+	// Device is remembered only if asked to
+	_, deviceToken := loginWithCode(t, a, mail, appID, false)
+	assert.Empty(t, deviceToken)
+
+	_, deviceToken = loginWithCode(t, a, mail, appID, true)
+	require.NotEmpty(t, deviceToken)
+
+	// Trusted device skips second factor, others don't
+	token, err := a.Login(ctx, "user@example.com", "password", appID, deviceToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	_, err = a.Login(ctx, "user@example.com", "password", appID, "other")
+	assert.ErrorIs(t, err, ErrMFARequired)
+
+	devices, err := a.ListTrustedDevices(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.False(t, devices[0].LastUsedAt.IsZero())
+
+	// Revoked device asks for second factor again
+	assert.ErrorIs(t, a.RevokeTrustedDevice(ctx, id+1, devices[0].ID), ErrTrustedDeviceNotFound)
+	require.NoError(t, a.RevokeTrustedDevice(ctx, id, devices[0].ID))
+	assert.ErrorIs(t, a.RevokeTrustedDevice(ctx, id, devices[0].ID), ErrTrustedDeviceNotFound)
+
+	_, err = a.Login(ctx, "user@example.com", "password", appID, deviceToken)
+	assert.ErrorIs(t, err, ErrMFARequired)
+
+	_, first := loginWithCode(t, a, mail, appID, true)
+	_, second := loginWithCode(t, a, mail, appID, true)
+
+	n, err := a.RevokeTrustedDevices(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	for _, deviceToken := range []string{first, second} {
+		_, err = a.Login(ctx, "user@example.com", "password", appID, deviceToken)
+		assert.ErrorIs(t, err, ErrMFARequired)
+	}
+}
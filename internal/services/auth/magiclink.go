@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// RequestMagicLink sends user a single-use link to log in to the app
+// without password. Unknown and inactive users get nothing, but no
+// error is returned to not disclose which emails are registered
+func (a *Auth) RequestMagicLink(ctx context.Context, email string, appID int) error {
+	const op = "auth.RequestMagicLink"
+
+	log := a.log.With(slog.String("op", op), slog.String("email", email), slog.Int("app_id", appID))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.orgUser(ctx, app.OrgID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		return nil
+	}
+
+	code, codeHash, err := codes.New()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	link := models.MagicLink{
+		UserID:    user.ID,
+		AppID:     app.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.cfg.MagicLinkTTL),
+	}
+
+	link.ID, err = a.magicLinks.SaveMagicLink(ctx, link, codeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.notifiers.Send(ctx, notifier.ChannelEmail, notifier.Message{
+		To:      user.Email,
+		Subject: "Your login link for " + app.Name,
+		Body: fmt.Sprintf("Follow the link to log in to %s: %s\nIt can be used once and expires at %s.",
+			app.Name, a.magicLinkURL(app, signMagicLink(app.Secret, app.ID, code)),
+			link.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("magic link sent", slog.Int64("magic_link_id", link.ID))
+
+	return nil
+}
+
+// ConsumeMagicLink exchanges code of magic link for access token the
+// same way Login does: second factor is required from users who set it
+// up and on risky logins unless device is trusted, then MFARequiredError
+// is returned. Code is accepted only by the app it was issued for and
+// only once
+func (a *Auth) ConsumeMagicLink(ctx context.Context, code string, appID int, deviceToken string) (string, error) {
+	const op = "auth.ConsumeMagicLink"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Forged codes are rejected without touching storage
+	code, ok := verifyMagicLink(app, code, time.Now())
+	if !ok {
+		log.Warn("invalid magic link signature")
+
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+	}
+
+	link, err := a.magicLinks.ConsumeMagicLink(ctx, codes.Hash(code), app.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrMagicLinkNotFound) {
+			log.Warn("magic link is used, expired or unknown")
+
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", link.UserID))
+
+	user, err := a.userProvider.UserByID(ctx, link.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("magic link owner not found")
+
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Account could change since the link was sent
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassResetRequired {
+		log.Warn("user has to reset password")
+
+		a.saveLoginFailed(ctx, log, app, user.Email, ErrPassResetRequired)
+
+		return "", fmt.Errorf("%s: %w", op, ErrPassResetRequired)
+	}
+
+	method, err := a.secondFactor(ctx, log, user, app, loginMethodMagicLink, deviceToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := a.completeLogin(ctx, log, user, app, method)
+	if err != nil {
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// magicLinkURL returns link to the magic link page with code and app id
+func (a *Auth) magicLinkURL(app models.App, code string) string {
+	query := url.Values{}
+	query.Set("code", code)
+	query.Set("app_id", strconv.Itoa(app.ID))
+
+	sep := "?"
+	if strings.Contains(a.cfg.MagicLinkURL, "?") {
+		sep = "&"
+	}
+
+	return a.cfg.MagicLinkURL + sep + query.Encode()
+}
+
+// signMagicLink appends to code its signature with the app secret.
+// Signature binds code to the app
+func signMagicLink(secret string, appID int, code string) string {
+	return code + "." + magicLinkSignature(secret, appID, code)
+}
+
+// verifyMagicLink checks signature of code with secrets of the app and
+// returns the code without signature
+func verifyMagicLink(app models.App, signed string, now time.Time) (string, bool) {
+	code, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return "", false
+	}
+
+	for _, secret := range app.VerificationSecrets(now) {
+		if hmac.Equal([]byte(sig), []byte(magicLinkSignature(secret, app.ID, code))) {
+			return code, true
+		}
+	}
+
+	return "", false
+}
+
+func magicLinkSignature(secret string, appID int, code string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "magic_link.%d.%s", appID, code)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/jwt"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyMagicLink(t *testing.T) {
+	now := time.Now()
+	app := models.App{ID: 1, Secret: "new", PrevSecret: "old", PrevSecretExpiresAt: now.Add(time.Hour)}
+
+	tests := []struct {
+		name   string
+		signed string
+		app    models.App
+		ok     bool
+	}{
+		{"current secret", signMagicLink("new", 1, "code"), app, true},
+		{"previous secret in grace period", signMagicLink("old", 1, "code"), app, true},
+		{"previous secret expired", signMagicLink("old", 1, "code"),
+			models.App{ID: 1, Secret: "new", PrevSecret: "old", PrevSecretExpiresAt: now.Add(-time.Hour)}, false},
+		{"other app", signMagicLink("new", 2, "code"), app, false},
+		{"tampered code", "other." + magicLinkSignature("new", 1, "code"), app, false},
+		{"no signature", "code", app, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := verifyMagicLink(tt.app, tt.signed, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && code != "code" {
+				t.Fatalf("code = %q, want %q", code, "code")
+			}
+		})
+	}
+}
+
+var magicLinkRe = regexp.MustCompile(`(http\S+)`)
+
+// requestMagicLink sends magic link to user and returns its code
+func requestMagicLink(t *testing.T, a *Auth, mail *notifier.File, appID int) string {
+	t.Helper()
+
+	require.NoError(t, a.RequestMagicLink(context.Background(), "user@example.com", appID))
+
+	messages, err := mail.Messages()
+	require.NoError(t, err)
+	require.NotEmpty(t, messages)
+	m := magicLinkRe.FindStringSubmatch(messages[len(messages)-1].Body)
+	require.NotNil(t, m)
+
+	link, err := url.Parse(m[1])
+	require.NoError(t, err)
+
+	return link.Query().Get("code")
+}
+
+func TestConsumeMagicLink(t *testing.T) {
+	mail := notifier.NewFile(filepath.Join(t.TempDir(), "mail.jsonl"))
+	a, s, appID := newConfiguredAuth(t, Config{
+		TokenTTL:         time.Hour,
+		MagicLinkTTL:     time.Minute,
+		MagicLinkURL:     "http://localhost/magic-link",
+		OTP:              OTPConfig{TTL: time.Minute, Digits: 6, MaxAttempts: 3, MaxSends: 3},
+		TrustedDeviceTTL: time.Hour,
+	}, notifier.Channels{notifier.ChannelEmail: mail})
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+	app, err := s.App(ctx, appID)
+	require.NoError(t, err)
+
+	code := requestMagicLink(t, a, mail, appID)
+	token, err := a.ConsumeMagicLink(ctx, code, appID, "")
+	require.NoError(t, err)
+	claims, err := jwt.Parse(token, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"otp"}, claims.Auth.Methods)
+
+	_, err = a.ConsumeMagicLink(ctx, code, appID, "")
+	assert.ErrorIs(t, err, ErrInvalidMagicLink)
+
+	// User with second factor has to pass it after the link
+	require.NoError(t, s.SetUserMFAChannel(ctx, id, string(notifier.ChannelEmail)))
+
+	_, err = a.ConsumeMagicLink(ctx, requestMagicLink(t, a, mail, appID), appID, "")
+	var mfaErr *MFARequiredError
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Equal(t, notifier.ChannelEmail, mfaErr.Channel)
+
+	token, deviceToken, err := a.VerifyLoginCode(ctx, mfaErr.Challenge, lastCode(t, mail), appID, "", true)
+	require.NoError(t, err)
+	require.NotEmpty(t, deviceToken)
+	claims, err = jwt.Parse(token, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"otp", "mfa"}, claims.Auth.Methods)
+	assert.Equal(t, acrMultiFactor, claims.Auth.Level)
+
+	// Trusted device skips second factor, but token stays single-factor
+	token, err = a.ConsumeMagicLink(ctx, requestMagicLink(t, a, mail, appID), appID, deviceToken)
+	require.NoError(t, err)
+	claims, err = jwt.Parse(token, app)
+	require.NoError(t, err)
+	assert.Equal(t, acrSingleFactor, claims.Auth.Level)
+}
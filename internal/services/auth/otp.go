@@ -0,0 +1,442 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// MFARequiredError is returned by Login and passwordless logins when the
+// first factor is right, but user has to confirm login with one-time code
+// sent through Channel. Code is
+// verified by VerifyLoginCode along with Challenge. With MFAWebAuthn
+// channel nothing is sent: WebAuthnOptions are passed to the passkey and
+// its assertion is verified by FinishPasskeyLogin along with Challenge
+type MFARequiredError struct {
+	Challenge       string
+	Channel         notifier.Channel
+	WebAuthnOptions []byte
+}
+
+func (e *MFARequiredError) Error() string {
+	if e.Channel == MFAWebAuthn {
+		return fmt.Sprintf("%s: passkey assertion", ErrMFARequired)
+	}
+
+	return fmt.Sprintf("%s: code sent by %s", ErrMFARequired, e.Channel)
+}
+
+func (e *MFARequiredError) Is(target error) bool {
+	return target == ErrMFARequired
+}
+
+// RequestLoginCode sends user one-time code to log in to the app without
+// password and returns challenge the code is verified with. Empty channel
+// is SMS for logins by phone and email otherwise. Unknown and inactive
+// users, users without phone for SMS and users asking for code too often
+// get nothing, but a challenge is returned anyway to not disclose which
+// logins are registered
+func (a *Auth) RequestLoginCode(
+	ctx context.Context,
+	login string,
+	appID int,
+	channel notifier.Channel,
+) (string, error) {
+	const op = "auth.RequestLoginCode"
+
+	log := a.log.With(slog.String("op", op), slog.String("login", login), slog.Int("app_id", appID))
+
+	if channel == "" {
+		channel = notifier.ChannelEmail
+		if identifiers.Detect(login) == identifiers.KindPhone {
+			channel = notifier.ChannelSMS
+		}
+	}
+	if channel != notifier.ChannelEmail && channel != notifier.ChannelSMS {
+		log.Warn("invalid channel", slog.String("channel", string(channel)))
+
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidChannel)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.orgUser(ctx, app.OrgID, login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+
+			return decoyChallenge(op)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		return decoyChallenge(op)
+	}
+
+	if recipient(user, channel) == "" {
+		log.Warn("user has no recipient for channel", slog.String("channel", string(channel)))
+
+		return decoyChallenge(op)
+	}
+
+	challenge, err := a.sendOTP(ctx, log, user, app, models.OTPPurposeLogin, channel)
+	if err != nil {
+		// Unknown logins are never throttled, so known ones look the same
+		if errors.Is(err, ErrOTPThrottled) {
+			return decoyChallenge(op)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// VerifyLoginCode exchanges one-time code of challenge for access token.
+// Challenge is either from RequestLoginCode or from MFARequiredError of
+// Login or Reauthenticate. Every attempt counts, code can be used only
+// once. Code of RequestLoginCode replaces password, not second factor, so
+// it is followed by MFARequiredError the same way Login does. Device
+// passing second factor of login can be trusted, then its token is
+// returned along with access token
+func (a *Auth) VerifyLoginCode(
+	ctx context.Context,
+	challenge string,
+	code string,
+	appID int,
+	deviceToken string,
+	trustDevice bool,
+) (string, string, error) {
+	const op = "auth.VerifyLoginCode"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	app, ch, err := a.pendingChallenge(ctx, log, challenge, appID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", ch.UserID), slog.Int64("otp_challenge_id", ch.ID))
+
+	user, err := a.userProvider.UserByID(ctx, ch.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("challenge owner not found")
+
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Attempt is counted before comparison, so limit holds
+	// for concurrent attempts
+	if err := a.otpStorage.AddOTPAttempt(ctx, ch.ID, a.cfg.OTP.MaxAttempts); err != nil {
+		if errors.Is(err, storage.ErrOTPChallengeNotFound) {
+			log.Warn("no attempts left")
+
+			a.saveLoginFailed(ctx, log, app, user.Email, ErrInvalidOTP)
+
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	codeHash := codes.HashWith(challenge, code)
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(ch.CodeHash)) != 1 {
+		log.Info("invalid code")
+
+		a.saveLoginFailed(ctx, log, app, user.Email, ErrInvalidOTP)
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+	}
+
+	if err := a.otpStorage.ConsumeOTPChallenge(ctx, ch.ID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrOTPChallengeNotFound) {
+			log.Warn("challenge is already used or expired")
+
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Account could change since the code was sent
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassResetRequired {
+		log.Warn("user has to reset password")
+
+		a.saveLoginFailed(ctx, log, app, user.Email, ErrPassResetRequired)
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrPassResetRequired)
+	}
+
+	var method string
+	switch ch.Purpose {
+	case models.OTPPurposeLogin:
+		method, err = a.secondFactor(ctx, log, user, app, loginMethodOTP, deviceToken)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+	case models.OTPPurposePasswordlessMFA:
+		method = loginMethodPasswordlessOTP
+	default:
+		method = loginMethodPasswordOTP
+	}
+
+	if ch.Purpose == models.OTPPurposeStepUp {
+		token, err := a.completeStepUp(ctx, log, user, app, method)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return token, "", nil
+	}
+
+	token, err := a.completeLogin(ctx, log, user, app, method)
+	if err != nil {
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var newDeviceToken string
+	if trustDevice && (ch.Purpose == models.OTPPurposeMFA || ch.Purpose == models.OTPPurposePasswordlessMFA) {
+		newDeviceToken = a.trustDevice(ctx, log, user)
+	}
+
+	return token, newDeviceToken, nil
+}
+
+// ResendLoginCode sends new code of challenge through the same channel.
+// The previous code stops working, attempts are not reset
+func (a *Auth) ResendLoginCode(ctx context.Context, challenge string, appID int) error {
+	const op = "auth.ResendLoginCode"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	app, ch, err := a.pendingChallenge(ctx, log, challenge, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", ch.UserID), slog.Int64("otp_challenge_id", ch.ID))
+
+	now := time.Now()
+	if now.Sub(ch.LastSentAt) < a.cfg.OTP.ResendInterval || ch.Sends >= a.cfg.OTP.MaxSends {
+		log.Warn("resend throttled", slog.Int("sends", ch.Sends))
+
+		return fmt.Errorf("%s: %w", op, ErrOTPThrottled)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, ch.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("challenge owner not found")
+
+			return fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := codes.NewNumeric(a.cfg.OTP.Digits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.otpStorage.ResendOTPChallenge(ctx, ch.ID, codes.HashWith(challenge, code), now); err != nil {
+		if errors.Is(err, storage.ErrOTPChallengeNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidOTP)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	channel := notifier.Channel(ch.Channel)
+	if err := a.sendCode(ctx, user, app, channel, code, ch.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("code resent", slog.String("channel", ch.Channel))
+
+	return nil
+}
+
+// pendingChallenge returns app and pending challenge issued for it
+func (a *Auth) pendingChallenge(
+	ctx context.Context,
+	log *slog.Logger,
+	challenge string,
+	appID int,
+) (models.App, models.OTPChallenge, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return models.App{}, models.OTPChallenge{}, ErrAppNotFound
+		}
+
+		return models.App{}, models.OTPChallenge{}, err
+	}
+
+	ch, err := a.otpStorage.OTPChallenge(ctx, codes.Hash(challenge))
+	if err != nil {
+		if errors.Is(err, storage.ErrOTPChallengeNotFound) {
+			log.Warn("challenge not found")
+
+			return models.App{}, models.OTPChallenge{}, ErrInvalidOTP
+		}
+
+		return models.App{}, models.OTPChallenge{}, err
+	}
+
+	if ch.AppID != app.ID || !ch.Pending(time.Now()) {
+		log.Warn("challenge is not pending", slog.Int64("otp_challenge_id", ch.ID))
+
+		return models.App{}, models.OTPChallenge{}, ErrInvalidOTP
+	}
+
+	return app, ch, nil
+}
+
+// sendOTP starts new challenge and sends its code to user
+func (a *Auth) sendOTP(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	purpose models.OTPPurpose,
+	channel notifier.Channel,
+) (string, error) {
+	now := time.Now()
+
+	latest, err := a.otpStorage.LatestOTPChallenge(ctx, user.ID, app.ID)
+	if err != nil && !errors.Is(err, storage.ErrOTPChallengeNotFound) {
+		return "", err
+	}
+	// Used code doesn't hold back the next one, e.g. second factor
+	// after login by code
+	if err == nil && latest.ConsumedAt.IsZero() && now.Sub(latest.LastSentAt) < a.cfg.OTP.ResendInterval {
+		log.Warn("code was sent recently", slog.Int64("otp_challenge_id", latest.ID))
+
+		return "", ErrOTPThrottled
+	}
+
+	challenge, challengeHash, err := codes.New()
+	if err != nil {
+		return "", err
+	}
+	code, err := codes.NewNumeric(a.cfg.OTP.Digits)
+	if err != nil {
+		return "", err
+	}
+
+	ch := models.OTPChallenge{
+		UserID:     user.ID,
+		AppID:      app.ID,
+		Purpose:    purpose,
+		Channel:    string(channel),
+		CodeHash:   codes.HashWith(challenge, code),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(a.cfg.OTP.TTL),
+		LastSentAt: now,
+	}
+
+	ch.ID, err = a.otpStorage.SaveOTPChallenge(ctx, ch, challengeHash)
+	if err != nil {
+		log.Error("failed to save otp challenge", sl.Err(err))
+
+		return "", err
+	}
+
+	if err := a.sendCode(ctx, user, app, channel, code, ch.ExpiresAt); err != nil {
+		log.Error("failed to send code", sl.Err(err))
+
+		return "", err
+	}
+
+	log.Info("code sent",
+		slog.Int64("otp_challenge_id", ch.ID),
+		slog.String("purpose", string(purpose)),
+		slog.String("channel", string(channel)),
+	)
+
+	return challenge, nil
+}
+
+// sendCode sends code to user through the channel
+func (a *Auth) sendCode(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	channel notifier.Channel,
+	code string,
+	expiresAt time.Time,
+) error {
+	to := recipient(user, channel)
+	if to == "" {
+		return fmt.Errorf("%w: user has no %s recipient", ErrInvalidChannel, channel)
+	}
+
+	return a.notifiers.Send(ctx, channel, notifier.Message{
+		To:      to,
+		Subject: "Your login code for " + app.Name,
+		Body: fmt.Sprintf("%s is your login code for %s. It expires at %s.",
+			code, app.Name, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// recipient returns address of user in the channel
+func recipient(user models.User, channel notifier.Channel) string {
+	switch channel {
+	case notifier.ChannelEmail:
+		return user.Email
+	case notifier.ChannelSMS:
+		return user.Phone
+	}
+
+	return ""
+}
+
+// decoyChallenge returns challenge that matches no code
+func decoyChallenge(op string) (string, error) {
+	challenge, _, err := codes.New()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/lib/jwt"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lastCode returns code of the last message sent through notifier
+func lastCode(t *testing.T, n *notifier.File) string {
+	t.Helper()
+
+	messages, err := n.Messages()
+	require.NoError(t, err)
+	require.NotEmpty(t, messages)
+	m := loginCodeRe.FindStringSubmatch(messages[len(messages)-1].Body)
+	require.NotNil(t, m)
+
+	return m[1]
+}
+
+func TestLoginCodeRequiresSecondFactor(t *testing.T) {
+	dir := t.TempDir()
+	mail := notifier.NewFile(filepath.Join(dir, "mail.jsonl"))
+	sms := notifier.NewFile(filepath.Join(dir, "sms.jsonl"))
+	a, s, appID := newConfiguredAuth(t, Config{
+		TokenTTL: time.Hour,
+		OTP:      OTPConfig{TTL: time.Minute, Digits: 6, MaxAttempts: 3, ResendInterval: time.Hour, MaxSends: 3},
+	}, notifier.Channels{notifier.ChannelEmail: mail, notifier.ChannelSMS: sms})
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserIdentifiers(ctx, id, "user", "+15550001"))
+	require.NoError(t, s.SetUserMFAChannel(ctx, id, string(notifier.ChannelSMS)))
+	app, err := s.App(ctx, appID)
+	require.NoError(t, err)
+
+	// Email code replaces password, SMS is still required
+	challenge, err := a.RequestLoginCode(ctx, "user@example.com", appID, notifier.ChannelEmail)
+	require.NoError(t, err)
+
+	_, _, err = a.VerifyLoginCode(ctx, challenge, lastCode(t, mail), appID, "", false)
+	var mfaErr *MFARequiredError
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Equal(t, notifier.ChannelSMS, mfaErr.Channel)
+
+	token, _, err := a.VerifyLoginCode(ctx, mfaErr.Challenge, lastCode(t, sms), appID, "", false)
+	require.NoError(t, err)
+	claims, err := jwt.Parse(token, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"otp", "mfa"}, claims.Auth.Methods)
+	assert.Equal(t, acrMultiFactor, claims.Auth.Level)
+}
+
+func TestLoginCodeThrottleIsHidden(t *testing.T) {
+	mail := notifier.NewFile(filepath.Join(t.TempDir(), "mail.jsonl"))
+	a, _, appID := newConfiguredAuth(t, Config{
+		TokenTTL: time.Hour,
+		OTP:      OTPConfig{TTL: time.Minute, Digits: 6, MaxAttempts: 3, ResendInterval: time.Hour, MaxSends: 3},
+	}, notifier.Channels{notifier.ChannelEmail: mail})
+	ctx := context.Background()
+
+	_, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+
+	// Registered and unknown emails answer repeated requests the same way
+	for _, email := range []string{"user@example.com", "missing@example.com"} {
+		for range 2 {
+			challenge, err := a.RequestLoginCode(ctx, email, appID, notifier.ChannelEmail)
+			require.NoError(t, err, email)
+			assert.NotEmpty(t, challenge)
+		}
+	}
+
+	messages, err := mail.Messages()
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ResetPassword replaces password of user who has to reset it before
+// login. User proves the current password, as the user can't log in to
+// change it. Second factor of user stays required on next login
+func (a *Auth) ResetPassword(
+	ctx context.Context,
+	login string,
+	password string,
+	newPassword string,
+	appID int,
+) error {
+	const op = "auth.ResetPassword"
+
+	log := a.log.With(slog.String("op", op), slog.String("login", login))
+
+	log.Info("resetting password")
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.checkPassword(ctx, log, app.OrgID, login, password)
+	if err != nil {
+		a.saveLoginFailed(ctx, log, app, login, err)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.PassResetRequired {
+		log.Warn("user doesn't have to reset password")
+
+		return fmt.Errorf("%s: %w", op, ErrPassResetNotRequired)
+	}
+
+	if err := a.setPassword(ctx, log, user, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset", slog.Int64("user_id", user.ID))
+
+	return nil
+}
+
+// ChangePassword replaces password of logged in user who proves the
+// current one
+func (a *Auth) ChangePassword(ctx context.Context, userID int64, password string, newPassword string) error {
+	const op = "auth.ChangePassword"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	log.Info("changing password")
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	checked, err := a.checkPassword(ctx, log, user.OrgID, user.Email, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if checked.ID != user.ID {
+		log.Warn("user is not registered in own namespace")
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := a.setPassword(ctx, log, checked, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed")
+
+	return nil
+}
+
+// setPassword stores hash of new password of user, that also clears
+// the password reset flag. Current password can't be reused
+func (a *Auth) setPassword(ctx context.Context, log *slog.Logger, user models.User, newPassword string) error {
+	if bcrypt.CompareHashAndPassword(user.PassHash, []byte(newPassword)) == nil {
+		log.Warn("new password is the same as current")
+
+		return ErrPasswordReused
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate hash password", sl.Err(err))
+
+		return err
+	}
+
+	if err := a.userSaver.SetPassword(ctx, user.ID, passHash); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+
+		log.Error("failed to save password", sl.Err(err))
+
+		return err
+	}
+
+	return nil
+}
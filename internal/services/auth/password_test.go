@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPassword(t *testing.T) {
+	a, s, appID := newTestAuth(t)
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+
+	err = a.ResetPassword(ctx, "user@example.com", "password", "new-password", appID)
+	assert.ErrorIs(t, err, ErrPassResetNotRequired)
+
+	require.NoError(t, s.SetPassResetRequired(ctx, id, true))
+	_, err = a.Login(ctx, "user@example.com", "password", appID, "")
+	assert.ErrorIs(t, err, ErrPassResetRequired)
+
+	err = a.ResetPassword(ctx, "user@example.com", "wrong", "new-password", appID)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	err = a.ResetPassword(ctx, "user@example.com", "password", "password", appID)
+	assert.ErrorIs(t, err, ErrPasswordReused)
+
+	require.NoError(t, a.ResetPassword(ctx, "user@example.com", "password", "new-password", appID))
+
+	_, err = a.Login(ctx, "user@example.com", "password", appID, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	token, err := a.Login(ctx, "user@example.com", "new-password", appID, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
+
+func TestChangePassword(t *testing.T) {
+	a, _, appID := newTestAuth(t)
+	ctx := context.Background()
+
+	id, err := a.RegisterNewUser(ctx, "user@example.com", "password", 0)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, a.ChangePassword(ctx, id+1, "password", "new-password"), ErrUserNotFound)
+	assert.ErrorIs(t, a.ChangePassword(ctx, id, "wrong", "new-password"), ErrInvalidCredentials)
+	assert.ErrorIs(t, a.ChangePassword(ctx, id, "password", "password"), ErrPasswordReused)
+
+	require.NoError(t, a.ChangePassword(ctx, id, "password", "new-password"))
+
+	_, err = a.Login(ctx, "user@example.com", "password", appID, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	token, err := a.Login(ctx, "user@example.com", "new-password", appID, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
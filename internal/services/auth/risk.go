@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/clientinfo"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/risk"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+)
+
+// loginHistoryLimit bounds login attempts risk is assessed against
+const loginHistoryLimit = 200
+
+// RiskConfig tunes risk-based login. Password logins scored at least
+// MFAScore require second factor by email code, logins scored at least
+// NotifyScore are reported to user by email. Zero score turns the action
+// off. Login attempts are kept for HistoryRetention
+type RiskConfig struct {
+	risk.Config
+	NotifyScore      int
+	MFAScore         int
+	HistoryRetention time.Duration
+}
+
+// assessLogin scores login of user from client of the call against
+// login history of the user
+func (a *Auth) assessLogin(ctx context.Context, user models.User) (risk.Assessment, error) {
+	client := clientinfo.FromContext(ctx)
+	now := time.Now()
+
+	history, err := a.loginAttempts.LoginAttempts(ctx, user.ID, now.Add(-a.cfg.Risk.HistoryRetention), loginHistoryLimit)
+	if err != nil {
+		return risk.Assessment{}, err
+	}
+
+	return risk.Evaluate(a.cfg.Risk.Config, risk.Attempt{
+		DeviceHash: deviceHash(client.Device),
+		IP:         client.IP,
+		Time:       now,
+	}, history), nil
+}
+
+// stepUpRequired reports whether login with password alone is too risky
+func (a *Auth) stepUpRequired(assessment risk.Assessment) bool {
+	return a.cfg.Risk.MFAScore > 0 && assessment.Score >= a.cfg.Risk.MFAScore
+}
+
+// recordLogin notifies user about risky login and saves it to login
+// history. Failures are logged and don't fail the login
+func (a *Auth) recordLogin(ctx context.Context, log *slog.Logger, user models.User, app models.App) {
+	assessment, err := a.assessLogin(ctx, user)
+	if err != nil {
+		log.Error("failed to assess login risk", sl.Err(err))
+	}
+
+	if a.cfg.Risk.NotifyScore > 0 && assessment.Score >= a.cfg.Risk.NotifyScore {
+		log.Info("notifying user about new sign-in",
+			slog.Int("risk_score", assessment.Score),
+			slog.Any("risk_reasons", assessment.Reasons),
+		)
+
+		if err := a.notifySignIn(ctx, user, app); err != nil {
+			log.Error("failed to notify user about new sign-in", sl.Err(err))
+		}
+	}
+
+	a.saveLoginAttempt(ctx, log, user.ID, app.ID, true)
+}
+
+// saveLoginAttempt saves attempt of user from client of the call.
+// Failure to save doesn't fail the operation
+func (a *Auth) saveLoginAttempt(ctx context.Context, log *slog.Logger, userID int64, appID int, succeeded bool) {
+	client := clientinfo.FromContext(ctx)
+	now := time.Now()
+
+	_, err := a.loginAttempts.SaveLoginAttempt(ctx, models.LoginAttempt{
+		UserID:     userID,
+		AppID:      appID,
+		DeviceHash: deviceHash(client.Device),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		Succeeded:  succeeded,
+		CreatedAt:  now,
+	}, now.Add(-a.cfg.Risk.HistoryRetention))
+	if err != nil {
+		log.Error("failed to save login attempt", sl.Err(err))
+	}
+}
+
+// notifySignIn tells user by email that the account was signed in from
+// a device or network it wasn't used from before
+func (a *Auth) notifySignIn(ctx context.Context, user models.User, app models.App) error {
+	client := clientinfo.FromContext(ctx)
+
+	from := client.IP
+	if from == "" {
+		from = "unknown address"
+	}
+	if client.UserAgent != "" {
+		from += " (" + client.UserAgent + ")"
+	}
+
+	return a.notifiers.Send(ctx, notifier.ChannelEmail, notifier.Message{
+		To:      user.Email,
+		Subject: "New sign-in to " + app.Name,
+		Body: fmt.Sprintf("Your account was signed in to %s at %s from %s. "+
+			"If it wasn't you, change your password.",
+			app.Name, time.Now().Format(time.RFC1123), from),
+	})
+}
+
+// deviceHash returns hash device fingerprint is stored by
+func deviceHash(device string) string {
+	if device == "" {
+		return ""
+	}
+
+	return codes.Hash(device)
+}
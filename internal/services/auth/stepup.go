@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/jwt"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Authentication context classes put into acr claim. Second factor or
+// passkey makes authentication multi-factor
+const (
+	acrSingleFactor = "aal1"
+	acrMultiFactor  = "aal2"
+)
+
+// StepUpConfig tunes step-up authentication. Sensitive operations
+// require token of authentication made within MaxAge, tokens issued by
+// Reauthenticate are valid for TokenTTL
+type StepUpConfig struct {
+	MaxAge   time.Duration
+	TokenTTL time.Duration
+}
+
+// authentication returns how user authenticated by the login method,
+// with amr values of RFC 8176
+func authentication(method string, now time.Time) jwt.Authentication {
+	auth := jwt.Authentication{Time: now, Level: acrSingleFactor}
+
+	switch method {
+	case loginMethodPassword, loginMethodPasswordTrustedDevice:
+		auth.Methods = []string{"pwd"}
+	case loginMethodMagicLink, loginMethodOTP, loginMethodPasswordlessTrustedDevice:
+		auth.Methods = []string{"otp"}
+	case loginMethodPasswordlessOTP:
+		auth.Methods = []string{"otp", "mfa"}
+		auth.Level = acrMultiFactor
+	case loginMethodPasswordlessPasskey:
+		auth.Methods = []string{"otp", "hwk", "mfa"}
+		auth.Level = acrMultiFactor
+	case loginMethodPasswordOTP:
+		auth.Methods = []string{"pwd", "otp", "mfa"}
+		auth.Level = acrMultiFactor
+	case loginMethodPasskey:
+		auth.Methods = []string{"hwk", "user", "mfa"}
+		auth.Level = acrMultiFactor
+	case loginMethodPasswordPasskey:
+		auth.Methods = []string{"pwd", "hwk", "mfa"}
+		auth.Level = acrMultiFactor
+	}
+
+	return auth
+}
+
+// Reauthenticate checks password of logged in user and returns short-lived
+// token sensitive operations accept. Users with second factor get one-time
+// code or passkey assertion and MFARequiredError instead of token, then
+// the token is returned by VerifyLoginCode or FinishPasskeyLogin
+func (a *Auth) Reauthenticate(
+	ctx context.Context,
+	userID int64,
+	password string,
+	appID int,
+) (string, error) {
+	const op = "auth.Reauthenticate"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID), slog.Int("app_id", appID))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+
+			return "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Users of other namespaces don't exist for the app
+	checked, err := a.checkCredentials(ctx, log, app.OrgID, user.Email, password)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if checked.ID != user.ID {
+		log.Warn("user is not registered for the app")
+
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	switch channel := notifier.Channel(user.MFAChannel); channel {
+	case "":
+	case MFAWebAuthn:
+		mfaErr, err := a.beginPasskeyMFA(ctx, user, app, models.WebAuthnPurposeStepUp)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("second factor required", slog.String("channel", user.MFAChannel))
+
+		return "", fmt.Errorf("%s: %w", op, mfaErr)
+	default:
+		challenge, err := a.sendOTP(ctx, log, user, app, models.OTPPurposeStepUp, channel)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("second factor required", slog.String("channel", string(channel)))
+
+		return "", fmt.Errorf("%s: %w", op, &MFARequiredError{Challenge: challenge, Channel: channel})
+	}
+
+	token, err := a.completeStepUp(ctx, log, user, app, loginMethodPassword)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// completeStepUp returns short-lived token of reauthenticated user. It
+// is not a login, so it isn't assessed and recorded in login history
+func (a *Auth) completeStepUp(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	method string,
+) (string, error) {
+	if err := a.checkOrgMember(ctx, log, user, app); err != nil {
+		return "", err
+	}
+
+	token, err := a.issueToken(ctx, log, user, app, authentication(method, time.Now()), a.cfg.StepUp.TokenTTL)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("user reauthenticated")
+
+	a.saveEvent(ctx, log, models.Event{
+		Type:   models.EventUserReauthenticated,
+		UserID: user.ID,
+		OrgID:  app.OrgID,
+		AppID:  app.ID,
+		Data:   map[string]string{"method": method},
+	})
+
+	return token, nil
+}
+
+// AuthorizeStepUp verifies access token and checks that its owner
+// authenticated within StepUp.MaxAge. Users with second factor have to
+// pass it too. Returns ErrStepUpRequired otherwise
+func (a *Auth) AuthorizeStepUp(ctx context.Context, token string) error {
+	const op = "Auth.AuthorizeStepUp"
+
+	log := a.log.With(slog.String("op", op))
+
+	user, claims, err := a.tokenOwner(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	auth := claims.Auth
+	if auth.Time.IsZero() || time.Since(auth.Time) > a.cfg.StepUp.MaxAge {
+		log.Warn("authentication is not recent")
+
+		return fmt.Errorf("%s: %w", op, ErrStepUpRequired)
+	}
+
+	if user.MFAChannel != "" && auth.Level != acrMultiFactor {
+		log.Warn("authentication is not multi-factor", slog.String("acr", auth.Level))
+
+		return fmt.Errorf("%s: %w", op, ErrStepUpRequired)
+	}
+
+	return nil
+}
@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/passkey"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// MFAWebAuthn is the second factor by passkey. Unlike channels of
+// one-time codes it isn't delivered by notifier
+const MFAWebAuthn notifier.Channel = "webauthn"
+
+// BeginPasskeyRegistration starts registration of new passkey of user
+// and returns session id and options for navigator.credentials.create
+func (a *Auth) BeginPasskeyRegistration(ctx context.Context, userID int64) (string, []byte, error) {
+	const op = "auth.BeginPasskeyRegistration"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	user, pkUser, err := a.passkeyUser(ctx, userID)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, data, err := a.relyingParty.BeginRegistration(pkUser)
+	if err != nil {
+		log.Error("failed to begin registration", sl.Err(err))
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID, err := a.saveWebAuthnSession(ctx, user.ID, 0, models.WebAuthnPurposeRegistration, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessionID, options, nil
+}
+
+// FinishPasskeyRegistration verifies response of authenticator and saves
+// new passkey of user. Session is single-use
+func (a *Auth) FinishPasskeyRegistration(
+	ctx context.Context,
+	userID int64,
+	sessionID string,
+	response []byte,
+	name string,
+) (int64, error) {
+	const op = "auth.FinishPasskeyRegistration"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID))
+
+	session, err := a.takeWebAuthnSession(ctx, log, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if session.Purpose != models.WebAuthnPurposeRegistration || session.UserID != userID {
+		log.Warn("session is not registration of the user", slog.Int64("session_id", session.ID))
+
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidPasskeySession)
+	}
+
+	_, pkUser, err := a.passkeyUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cred, err := a.relyingParty.FinishRegistration(pkUser, session.Data, response)
+	if err != nil {
+		if errors.Is(err, passkey.ErrInvalidResponse) {
+			log.Warn("invalid registration response", sl.Err(err))
+
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cred.Name = name
+	cred.CreatedAt = time.Now()
+
+	cred.ID, err = a.webAuthnStorage.SaveWebAuthnCredential(ctx, cred)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnCredentialExists) {
+			log.Warn("passkey is already registered")
+
+			return 0, fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered", slog.Int64("passkey_id", cred.ID))
+
+	return cred.ID, nil
+}
+
+// ListPasskeys returns passkeys of user
+func (a *Auth) ListPasskeys(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "auth.ListPasskeys"
+
+	creds, err := a.webAuthnStorage.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+// DeletePasskey deletes passkey of user. The last passkey of user with
+// passkey second factor can't be deleted
+func (a *Auth) DeletePasskey(ctx context.Context, userID int64, passkeyID int64) error {
+	const op = "auth.DeletePasskey"
+
+	log := a.log.With(slog.String("op", op), slog.Int64("user_id", userID), slog.Int64("passkey_id", passkeyID))
+
+	user, pkUser, err := a.passkeyUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if notifier.Channel(user.MFAChannel) == MFAWebAuthn && len(pkUser.Credentials) == 1 &&
+		pkUser.Credentials[0].ID == passkeyID {
+		log.Warn("last passkey is the second factor")
+
+		return fmt.Errorf("%s: %w", op, ErrLastPasskey)
+	}
+
+	if err := a.webAuthnStorage.DeleteWebAuthnCredential(ctx, userID, passkeyID); err != nil {
+		if errors.Is(err, storage.ErrWebAuthnCredentialNotFound) {
+			log.Warn("passkey not found")
+
+			return fmt.Errorf("%s: %w", op, ErrPasskeyNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey deleted")
+
+	return nil
+}
+
+// BeginPasskeyLogin starts login to the app by passkey alone and returns
+// session id and options for navigator.credentials.get
+func (a *Auth) BeginPasskeyLogin(ctx context.Context, appID int) (string, []byte, error) {
+	const op = "auth.BeginPasskeyLogin"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", nil, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, data, err := a.relyingParty.BeginDiscoverableLogin()
+	if err != nil {
+		log.Error("failed to begin login", sl.Err(err))
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID, err := a.saveWebAuthnSession(ctx, 0, app.ID, models.WebAuthnPurposeLogin, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessionID, options, nil
+}
+
+// FinishPasskeyLogin verifies assertion of passkey and returns access
+// token the same way Login does. Session is either from BeginPasskeyLogin
+// or from MFARequiredError of Login or Reauthenticate with passkey second
+// factor. Device passing second factor of login can be trusted the same
+// way VerifyLoginCode does
+func (a *Auth) FinishPasskeyLogin(
+	ctx context.Context,
+	sessionID string,
+	response []byte,
+	appID int,
+	trustDevice bool,
+) (string, string, error) {
+	const op = "auth.FinishPasskeyLogin"
+
+	log := a.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.takeWebAuthnSession(ctx, log, sessionID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if session.AppID != app.ID || session.Purpose == models.WebAuthnPurposeRegistration {
+		log.Warn("session is not login to the app", slog.Int64("session_id", session.ID))
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidPasskeySession)
+	}
+
+	var (
+		user   models.User
+		cred   models.WebAuthnCredential
+		method = loginMethodPasskey
+	)
+	switch session.Purpose {
+	case models.WebAuthnPurposeMFA, models.WebAuthnPurposePasswordlessMFA, models.WebAuthnPurposeStepUp:
+		method = loginMethodPasswordPasskey
+		if session.Purpose == models.WebAuthnPurposePasswordlessMFA {
+			method = loginMethodPasswordlessPasskey
+		}
+
+		var pkUser passkey.User
+		user, pkUser, err = a.passkeyUser(ctx, session.UserID)
+		if err == nil {
+			cred, err = a.relyingParty.FinishLogin(pkUser, session.Data, response)
+		}
+	default:
+		_, cred, err = a.relyingParty.FinishDiscoverableLogin(session.Data, response,
+			func(userID int64) (passkey.User, error) {
+				found, pkUser, err := a.passkeyUser(ctx, userID)
+				if err != nil {
+					return passkey.User{}, err
+				}
+
+				// Users of other namespaces don't exist for the app
+				if found.OrgID != a.namespace(app.OrgID) {
+					return passkey.User{}, ErrUserNotFound
+				}
+				user = found
+
+				return pkUser, nil
+			})
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, passkey.ErrCloneDetected):
+			log.Warn("passkey counter went back, authenticator may be cloned", slog.Int64("user_id", user.ID))
+		case errors.Is(err, passkey.ErrInvalidResponse), errors.Is(err, ErrUserNotFound):
+			log.Warn("invalid passkey assertion", sl.Err(err))
+		default:
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		if user.ID != 0 {
+			a.saveLoginFailed(ctx, log, app, user.Email, ErrInvalidPasskey)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID), slog.Int64("passkey_id", cred.ID))
+
+	err = a.webAuthnStorage.UpdateWebAuthnCredentialUse(ctx, cred.ID, cred.SignCount, cred.BackupState, time.Now())
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := statusError(user); err != nil {
+		log.Warn("user is not active", slog.String("status", string(user.Status)))
+
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassResetRequired {
+		log.Warn("user has to reset password")
+
+		a.saveLoginFailed(ctx, log, app, user.Email, ErrPassResetRequired)
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrPassResetRequired)
+	}
+
+	if session.Purpose == models.WebAuthnPurposeStepUp {
+		token, err := a.completeStepUp(ctx, log, user, app, method)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return token, "", nil
+	}
+
+	token, err := a.completeLogin(ctx, log, user, app, method)
+	if err != nil {
+		a.saveLoginFailed(ctx, log, app, user.Email, err)
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var deviceToken string
+	if trustDevice && (session.Purpose == models.WebAuthnPurposeMFA || session.Purpose == models.WebAuthnPurposePasswordlessMFA) {
+		deviceToken = a.trustDevice(ctx, log, user)
+	}
+
+	return token, deviceToken, nil
+}
+
+// beginPasskeyMFA starts assertion by passkey of user who passed the
+// first factor of login or reauthenticated with password
+func (a *Auth) beginPasskeyMFA(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	purpose models.WebAuthnPurpose,
+) (*MFARequiredError, error) {
+	_, pkUser, err := a.passkeyUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	options, data, err := a.relyingParty.BeginLogin(pkUser)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := a.saveWebAuthnSession(ctx, user.ID, app.ID, purpose, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFARequiredError{Challenge: sessionID, Channel: MFAWebAuthn, WebAuthnOptions: options}, nil
+}
+
+// passkeyUser returns user with passkeys
+func (a *Auth) passkeyUser(ctx context.Context, userID int64) (models.User, passkey.User, error) {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, passkey.User{}, ErrUserNotFound
+		}
+
+		return models.User{}, passkey.User{}, err
+	}
+
+	creds, err := a.webAuthnStorage.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return models.User{}, passkey.User{}, err
+	}
+
+	return user, passkey.User{ID: user.ID, Name: user.Email, Credentials: creds}, nil
+}
+
+// saveWebAuthnSession saves ceremony state and returns id of the session
+func (a *Auth) saveWebAuthnSession(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	purpose models.WebAuthnPurpose,
+	data []byte,
+) (string, error) {
+	sessionID, sessionHash, err := codes.New()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session := models.WebAuthnSession{
+		UserID:    userID,
+		AppID:     appID,
+		Purpose:   purpose,
+		Data:      data,
+		ExpiresAt: now.Add(a.cfg.WebAuthnTimeout),
+	}
+
+	if _, err := a.webAuthnStorage.SaveWebAuthnSession(ctx, session, sessionHash, now); err != nil {
+		return "", err
+	}
+
+	return sessionID, nil
+}
+
+// takeWebAuthnSession returns session by id, so it can't be used again
+func (a *Auth) takeWebAuthnSession(
+	ctx context.Context,
+	log *slog.Logger,
+	sessionID string,
+) (models.WebAuthnSession, error) {
+	session, err := a.webAuthnStorage.TakeWebAuthnSession(ctx, codes.Hash(sessionID), time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnSessionNotFound) {
+			log.Warn("session is used, expired or unknown")
+
+			return models.WebAuthnSession{}, ErrInvalidPasskeySession
+		}
+
+		return models.WebAuthnSession{}, err
+	}
+
+	return session, nil
+}
@@ -0,0 +1,210 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type Groups struct {
+	log           *slog.Logger
+	groupProvider GroupProvider
+	groupManager  GroupManager
+}
+
+type GroupProvider interface {
+	Groups(ctx context.Context) ([]models.Group, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	GroupMembers(ctx context.Context, groupID int64) ([]int64, error)
+	EffectiveRoles(ctx context.Context, userID int64) ([]string, error)
+}
+
+type GroupManager interface {
+	SaveGroup(ctx context.Context, name string, roles []string) (int64, error)
+	SetGroupRoles(ctx context.Context, groupID int64, roles []string) error
+	DeleteGroup(ctx context.Context, groupID int64) error
+	SaveGroupMember(ctx context.Context, groupID int64, userID int64) error
+	DeleteGroupMember(ctx context.Context, groupID int64, userID int64) error
+}
+
+var (
+	ErrGroupExists         = errors.New("group already exists")
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrGroupMemberNotFound = errors.New("group member not found")
+	ErrUserNotFound        = errors.New("user not found")
+)
+
+// New returns a new instance of Groups service
+func New(
+	log *slog.Logger,
+	groupProvider GroupProvider,
+	groupManager GroupManager,
+) *Groups {
+	return &Groups{
+		log:           log,
+		groupProvider: groupProvider,
+		groupManager:  groupManager,
+	}
+}
+
+// CreateGroup creates group with roles inherited by its members
+func (g *Groups) CreateGroup(ctx context.Context, name string, roles []string) (int64, error) {
+	const op = "Groups.CreateGroup"
+
+	log := g.log.With(slog.String("op", op), slog.String("name", name))
+
+	log.Info("creating group", slog.Any("roles", roles))
+
+	id, err := g.groupManager.SaveGroup(ctx, name, roles)
+	if err != nil {
+		return 0, g.groupError(op, err)
+	}
+
+	log.Info("group created", slog.Int64("group_id", id))
+
+	return id, nil
+}
+
+// ListGroups returns all groups with their roles
+func (g *Groups) ListGroups(ctx context.Context) ([]models.Group, error) {
+	const op = "Groups.ListGroups"
+
+	groups, err := g.groupProvider.Groups(ctx)
+	if err != nil {
+		return nil, g.groupError(op, err)
+	}
+
+	return groups, nil
+}
+
+// SetGroupRoles replaces roles of group
+func (g *Groups) SetGroupRoles(ctx context.Context, groupID int64, roles []string) error {
+	const op = "Groups.SetGroupRoles"
+
+	g.log.Info("setting group roles",
+		slog.String("op", op),
+		slog.Int64("group_id", groupID),
+		slog.Any("roles", roles),
+	)
+
+	if err := g.groupManager.SetGroupRoles(ctx, groupID, roles); err != nil {
+		return g.groupError(op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes group, its members lose inherited roles
+func (g *Groups) DeleteGroup(ctx context.Context, groupID int64) error {
+	const op = "Groups.DeleteGroup"
+
+	g.log.Info("deleting group", slog.String("op", op), slog.Int64("group_id", groupID))
+
+	if err := g.groupManager.DeleteGroup(ctx, groupID); err != nil {
+		return g.groupError(op, err)
+	}
+
+	return nil
+}
+
+// AddMember adds user to group
+func (g *Groups) AddMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "Groups.AddMember"
+
+	g.log.Info("adding group member",
+		slog.String("op", op),
+		slog.Int64("group_id", groupID),
+		slog.Int64("user_id", userID),
+	)
+
+	if err := g.groupManager.SaveGroupMember(ctx, groupID, userID); err != nil {
+		return g.groupError(op, err)
+	}
+
+	return nil
+}
+
+// RemoveMember removes user from group
+func (g *Groups) RemoveMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "Groups.RemoveMember"
+
+	g.log.Info("removing group member",
+		slog.String("op", op),
+		slog.Int64("group_id", groupID),
+		slog.Int64("user_id", userID),
+	)
+
+	if err := g.groupManager.DeleteGroupMember(ctx, groupID, userID); err != nil {
+		return g.groupError(op, err)
+	}
+
+	return nil
+}
+
+// ListMembers returns ids of group members
+func (g *Groups) ListMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	const op = "Groups.ListMembers"
+
+	ids, err := g.groupProvider.GroupMembers(ctx, groupID)
+	if err != nil {
+		return nil, g.groupError(op, err)
+	}
+
+	return ids, nil
+}
+
+// UserGroups returns groups user belongs to
+func (g *Groups) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "Groups.UserGroups"
+
+	groups, err := g.groupProvider.UserGroups(ctx, userID)
+	if err != nil {
+		return nil, g.groupError(op, err)
+	}
+
+	return groups, nil
+}
+
+// EffectiveRoles returns roles of user assigned directly and inherited from groups
+func (g *Groups) EffectiveRoles(ctx context.Context, userID int64) ([]string, error) {
+	const op = "Groups.EffectiveRoles"
+
+	roles, err := g.groupProvider.EffectiveRoles(ctx, userID)
+	if err != nil {
+		return nil, g.groupError(op, err)
+	}
+
+	return roles, nil
+}
+
+func (g *Groups) groupError(op string, err error) error {
+	log := g.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, storage.ErrGroupExists):
+		log.Warn("group already exists", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrGroupExists)
+	case errors.Is(err, storage.ErrGroupNotFound):
+		log.Warn("group not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+	case errors.Is(err, storage.ErrGroupMemberNotFound):
+		log.Warn("group member not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrGroupMemberNotFound)
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	log.Error("storage error", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,169 @@
+package groups
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGroups(t *testing.T) (*Groups, *memory.Storage) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	return New(log, s, s), s
+}
+
+func TestMembers(t *testing.T) {
+	g, s := newTestGroups(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	groupID, err := g.CreateGroup(ctx, "editors", []string{"editor"})
+	require.NoError(t, err)
+	_, err = g.CreateGroup(ctx, "editors", nil)
+	require.ErrorIs(t, err, ErrGroupExists)
+
+	tests := []struct {
+		name    string
+		call    func() error
+		want    []int64
+		wantErr error
+	}{
+		{name: "add", call: func() error { return g.AddMember(ctx, groupID, userID) }, want: []int64{userID}},
+		{name: "add again", call: func() error { return g.AddMember(ctx, groupID, userID) }, want: []int64{userID}},
+		{
+			name:    "add missing user",
+			call:    func() error { return g.AddMember(ctx, groupID, userID+1) },
+			want:    []int64{userID},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "add to missing group",
+			call:    func() error { return g.AddMember(ctx, groupID+1, userID) },
+			want:    []int64{userID},
+			wantErr: ErrGroupNotFound,
+		},
+		{
+			name:    "remove non-member",
+			call:    func() error { return g.RemoveMember(ctx, groupID, userID+1) },
+			want:    []int64{userID},
+			wantErr: ErrGroupMemberNotFound,
+		},
+		{name: "remove", call: func() error { return g.RemoveMember(ctx, groupID, userID) }},
+		{
+			name:    "remove again",
+			call:    func() error { return g.RemoveMember(ctx, groupID, userID) },
+			wantErr: ErrGroupMemberNotFound,
+		},
+	}
+
+	// Cases run in order, each one sees members left by the previous ones
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			members, err := g.ListMembers(ctx, groupID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, members)
+		})
+	}
+}
+
+func TestEffectiveRoles(t *testing.T) {
+	g, s := newTestGroups(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	otherID, err := s.SaveUser(ctx, "other@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserRoles(ctx, userID, []string{"viewer"}))
+
+	editors, err := g.CreateGroup(ctx, "editors", []string{"editor", "viewer"})
+	require.NoError(t, err)
+	billing, err := g.CreateGroup(ctx, "billing", []string{"billing"})
+	require.NoError(t, err)
+	require.NoError(t, g.AddMember(ctx, editors, userID))
+	require.NoError(t, g.AddMember(ctx, billing, userID))
+	require.NoError(t, g.AddMember(ctx, billing, otherID))
+
+	tests := []struct {
+		name    string
+		change  func() error
+		user    []string
+		other   []string
+		wantErr error
+	}{
+		{name: "direct and inherited", user: []string{"billing", "editor", "viewer"}, other: []string{"billing"}},
+		{
+			name:   "group roles replaced",
+			change: func() error { return g.SetGroupRoles(ctx, billing, []string{"invoices"}) },
+			user:   []string{"editor", "invoices", "viewer"},
+			other:  []string{"invoices"},
+		},
+		{
+			name:    "roles of missing group",
+			change:  func() error { return g.SetGroupRoles(ctx, billing+1, []string{"admin"}) },
+			user:    []string{"editor", "invoices", "viewer"},
+			other:   []string{"invoices"},
+			wantErr: ErrGroupNotFound,
+		},
+		{
+			name:   "removed from group",
+			change: func() error { return g.RemoveMember(ctx, billing, userID) },
+			user:   []string{"editor", "viewer"},
+			other:  []string{"invoices"},
+		},
+		{
+			name:   "group deleted",
+			change: func() error { return g.DeleteGroup(ctx, editors) },
+			user:   []string{"viewer"},
+			other:  []string{"invoices"},
+		},
+		{
+			name:    "missing group deleted",
+			change:  func() error { return g.DeleteGroup(ctx, editors) },
+			user:    []string{"viewer"},
+			other:   []string{"invoices"},
+			wantErr: ErrGroupNotFound,
+		},
+	}
+
+	// Cases run in order, each one sees changes made by the previous ones
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				err := tt.change()
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+
+			roles, err := g.EffectiveRoles(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.user, roles)
+
+			roles, err = g.EffectiveRoles(ctx, otherID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.other, roles)
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package invitations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/services/orgs"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Invitations invites users by email to an organization or an app.
+// Organization invitations assign organization role, app invitations
+// assign user role and membership in the app organization if any.
+// User role is global, so only admins can invite with it
+type Invitations struct {
+	log         *slog.Logger
+	invStorage  InvitationStorage
+	appProvider AppProvider
+	grants      GrantManager
+	accounts    Accounts
+	authorizer  Authorizer
+	notifier    notifier.Notifier
+	ttl         time.Duration
+}
+
+type InvitationStorage interface {
+	SaveInvitation(ctx context.Context, inv models.Invitation, codeHash string) (int64, error)
+	Invitation(ctx context.Context, id int64) (models.Invitation, error)
+	InvitationByCode(ctx context.Context, codeHash string) (models.Invitation, error)
+	Invitations(ctx context.Context, orgID int64) ([]models.Invitation, error)
+	AcceptInvitation(ctx context.Context, id int64, userID int64, now time.Time) error
+	RevokeInvitation(ctx context.Context, id int64, now time.Time) error
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type GrantManager interface {
+	OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error)
+	SaveOrgMember(ctx context.Context, member models.OrgMember) error
+	AddUserRole(ctx context.Context, userID int64, role string) error
+}
+
+// Accounts registers new users and checks credentials of existing ones
+type Accounts interface {
+	RegisterNewUser(ctx context.Context, email string, pass string, orgID int64) (int64, error)
+	CheckCredentials(ctx context.Context, orgID int64, email string, password string) (models.User, error)
+}
+
+type Authorizer interface {
+	RequireAdmin(ctx context.Context, actorID int64) error
+	RequireOrgAdmin(ctx context.Context, actorID int64, orgID int64) error
+}
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationInvalid  = errors.New("invitation is invalid or expired")
+	ErrInvalidTarget      = errors.New("invitation must target an organization or an app")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrAppNotFound        = errors.New("app not found")
+	ErrOrgNotFound        = errors.New("organization not found")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// New returns a new instance of Invitations service
+func New(
+	log *slog.Logger,
+	invStorage InvitationStorage,
+	appProvider AppProvider,
+	grants GrantManager,
+	accounts Accounts,
+	authorizer Authorizer,
+	notifier notifier.Notifier,
+	ttl time.Duration,
+) *Invitations {
+	return &Invitations{
+		log:         log,
+		invStorage:  invStorage,
+		appProvider: appProvider,
+		grants:      grants,
+		accounts:    accounts,
+		authorizer:  authorizer,
+		notifier:    notifier,
+		ttl:         ttl,
+	}
+}
+
+// CreateInvitation creates invitation and sends its code to invited email.
+// Returns the invitation and its code
+func (i *Invitations) CreateInvitation(
+	ctx context.Context,
+	actorID int64,
+	inv models.Invitation,
+) (models.Invitation, string, error) {
+	const op = "Invitations.CreateInvitation"
+
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.String("email", inv.Email),
+	)
+
+	if inv.OrgID == 0 && inv.AppID == 0 {
+		return models.Invitation{}, "", fmt.Errorf("%s: %w", op, ErrInvalidTarget)
+	}
+
+	if inv.AppID != 0 {
+		app, err := i.appProvider.App(ctx, inv.AppID)
+		if err != nil {
+			return models.Invitation{}, "", i.invitationError(op, err)
+		}
+		if inv.OrgID != 0 && inv.OrgID != app.OrgID {
+			return models.Invitation{}, "", fmt.Errorf("%s: %w", op, ErrInvalidTarget)
+		}
+		inv.OrgID = app.OrgID
+
+		// Admin rights are granted by SetAdmin only
+		if inv.Role == models.RoleAdmin {
+			return models.Invitation{}, "", fmt.Errorf("%s: %w", op, ErrInvalidRole)
+		}
+	} else {
+		if inv.Role == "" {
+			inv.Role = models.OrgRoleMember
+		}
+		if inv.Role != models.OrgRoleAdmin && inv.Role != models.OrgRoleMember {
+			return models.Invitation{}, "", fmt.Errorf("%s: %w", op, ErrInvalidRole)
+		}
+	}
+
+	// User roles are global, so only admins can pre-assign them
+	orgID := inv.OrgID
+	if inv.AppID != 0 && inv.Role != "" {
+		orgID = 0
+	}
+	if err := i.authorize(ctx, actorID, orgID); err != nil {
+		return models.Invitation{}, "", i.invitationError(op, err)
+	}
+
+	code, hash, err := codes.New()
+	if err != nil {
+		log.Error("failed to generate invitation code", sl.Err(err))
+
+		return models.Invitation{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	inv.CreatedBy = actorID
+	inv.CreatedAt = now
+	inv.ExpiresAt = now.Add(i.ttl)
+
+	inv.ID, err = i.invStorage.SaveInvitation(ctx, inv, hash)
+	if err != nil {
+		return models.Invitation{}, "", i.invitationError(op, err)
+	}
+
+	err = i.notifier.Send(ctx, notifier.Message{
+		To:      inv.Email,
+		Subject: "You are invited",
+		Body: fmt.Sprintf("Use invitation code %s to accept the invitation. It expires at %s.",
+			code, inv.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		// Invitation is still usable, creator gets the code in response
+		log.Error("failed to send invitation", sl.Err(err))
+	}
+
+	log.Info("invitation created", slog.Int64("invitation_id", inv.ID))
+
+	return inv, code, nil
+}
+
+// ListInvitations returns invitations to organization. Zero orgID
+// returns all invitations and is allowed to admins only
+func (i *Invitations) ListInvitations(ctx context.Context, actorID int64, orgID int64) ([]models.Invitation, error) {
+	const op = "Invitations.ListInvitations"
+
+	if err := i.authorize(ctx, actorID, orgID); err != nil {
+		return nil, i.invitationError(op, err)
+	}
+
+	invs, err := i.invStorage.Invitations(ctx, orgID)
+	if err != nil {
+		return nil, i.invitationError(op, err)
+	}
+
+	return invs, nil
+}
+
+// RevokeInvitation revokes pending invitation
+func (i *Invitations) RevokeInvitation(ctx context.Context, actorID int64, id int64) error {
+	const op = "Invitations.RevokeInvitation"
+
+	inv, err := i.invStorage.Invitation(ctx, id)
+	if err != nil {
+		return i.invitationError(op, err)
+	}
+
+	if err := i.authorize(ctx, actorID, inv.OrgID); err != nil {
+		return i.invitationError(op, err)
+	}
+
+	i.log.Info("revoking invitation",
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("invitation_id", id),
+	)
+
+	if err := i.invStorage.RevokeInvitation(ctx, id, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvitationInvalid)
+		}
+
+		return i.invitationError(op, err)
+	}
+
+	return nil
+}
+
+// AcceptInvitation accepts invitation by code. New user is registered with
+// given password, existing user has to confirm his password to link the
+// invitation to his account. Returns id of the user
+func (i *Invitations) AcceptInvitation(ctx context.Context, code string, password string) (int64, error) {
+	const op = "Invitations.AcceptInvitation"
+
+	log := i.log.With(slog.String("op", op))
+
+	inv, err := i.invStorage.InvitationByCode(ctx, codes.Hash(code))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("invitation not found")
+
+			return 0, fmt.Errorf("%s: %w", op, ErrInvitationInvalid)
+		}
+
+		return 0, i.invitationError(op, err)
+	}
+
+	now := time.Now()
+	if !inv.Pending(now) {
+		log.Warn("invitation is not pending", slog.Int64("invitation_id", inv.ID))
+
+		return 0, fmt.Errorf("%s: %w", op, ErrInvitationInvalid)
+	}
+	if inv.AppID != 0 && inv.Role == models.RoleAdmin {
+		log.Warn("invitation grants admin rights", slog.Int64("invitation_id", inv.ID))
+
+		return 0, fmt.Errorf("%s: %w", op, ErrInvitationInvalid)
+	}
+
+	log = log.With(slog.Int64("invitation_id", inv.ID), slog.String("email", inv.Email))
+
+	userID, err := i.accounts.RegisterNewUser(ctx, inv.Email, password, inv.OrgID)
+	if errors.Is(err, auth.ErrUserExists) {
+		var user models.User
+		user, err = i.accounts.CheckCredentials(ctx, inv.OrgID, inv.Email, password)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Warn("invalid credentials of existing user")
+
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		if errors.Is(err, auth.ErrUserInactive) || errors.Is(err, auth.ErrPassResetRequired) {
+			log.Warn("existing user can't accept invitation", sl.Err(err))
+
+			return 0, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+		}
+		userID = user.ID
+
+		log.Info("linking invitation to existing user", slog.Int64("user_id", userID))
+	}
+	if err != nil {
+		log.Error("failed to get invited user", sl.Err(err))
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := i.invStorage.AcceptInvitation(ctx, inv.ID, userID, now); err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("invitation was accepted or revoked concurrently")
+
+			return 0, fmt.Errorf("%s: %w", op, ErrInvitationInvalid)
+		}
+
+		return 0, i.invitationError(op, err)
+	}
+
+	if err := i.grant(ctx, inv, userID); err != nil {
+		return 0, i.invitationError(op, err)
+	}
+
+	log.Info("invitation accepted", slog.Int64("user_id", userID))
+
+	return userID, nil
+}
+
+// grant assigns user roles and memberships the invitation offers
+func (i *Invitations) grant(ctx context.Context, inv models.Invitation, userID int64) error {
+	if inv.AppID == 0 {
+		return i.grants.SaveOrgMember(ctx, models.OrgMember{
+			OrgID:  inv.OrgID,
+			UserID: userID,
+			Role:   inv.Role,
+		})
+	}
+
+	if inv.OrgID != 0 {
+		_, err := i.grants.OrgMember(ctx, inv.OrgID, userID)
+		if errors.Is(err, storage.ErrOrgMemberNotFound) {
+			err = i.grants.SaveOrgMember(ctx, models.OrgMember{
+				OrgID:  inv.OrgID,
+				UserID: userID,
+				Role:   models.OrgRoleMember,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if inv.Role != "" {
+		return i.grants.AddUserRole(ctx, userID, inv.Role)
+	}
+
+	return nil
+}
+
+// authorize checks that actor can manage invitations to organization
+func (i *Invitations) authorize(ctx context.Context, actorID int64, orgID int64) error {
+	if orgID == 0 {
+		return i.authorizer.RequireAdmin(ctx, actorID)
+	}
+
+	return i.authorizer.RequireOrgAdmin(ctx, actorID, orgID)
+}
+
+func (i *Invitations) invitationError(op string, err error) error {
+	log := i.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, orgs.ErrPermissionDenied):
+		log.Warn("permission denied")
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	case errors.Is(err, orgs.ErrOrgNotFound), errors.Is(err, storage.ErrOrgNotFound):
+		log.Warn("organization not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+	case errors.Is(err, storage.ErrAppNotFound):
+		log.Warn("app not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	case errors.Is(err, storage.ErrInvitationNotFound):
+		log.Warn("invitation not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+	}
+
+	log.Error("failed to process invitation", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
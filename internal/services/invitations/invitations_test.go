@@ -0,0 +1,227 @@
+package invitations
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/codes"
+	"github.com/m1al04949/sso-gRPC/internal/lib/notifier"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/services/orgs"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testInvitations struct {
+	*Invitations
+	storage *memory.Storage
+	auth    *auth.Auth
+	mail    *notifier.File
+}
+
+func newTestInvitations(t *testing.T) testInvitations {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	mail := notifier.NewFile(filepath.Join(t.TempDir(), "mail.jsonl"))
+	a := auth.New(log, s, nil, nil, auth.Config{TokenTTL: time.Hour})
+	o := orgs.New(log, s, s, s, s)
+
+	return testInvitations{
+		Invitations: New(log, s, s, s, a, o, mail, time.Hour),
+		storage:     s,
+		auth:        a,
+		mail:        mail,
+	}
+}
+
+// orgAdmin registers admin of new organization with an app
+func (i testInvitations) orgAdmin(t *testing.T, email string) (int64, int64, int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	userID, err := i.auth.RegisterNewUser(ctx, email, "password", 0)
+	require.NoError(t, err)
+	orgID, err := i.storage.SaveOrg(ctx, email)
+	require.NoError(t, err)
+	require.NoError(t, i.storage.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: userID, Role: models.OrgRoleAdmin}))
+	appID, err := i.storage.SaveApp(ctx, email, email+"-secret", orgID)
+	require.NoError(t, err)
+
+	return userID, orgID, appID
+}
+
+var codeRe = regexp.MustCompile(`code (\S+) to`)
+
+func (i testInvitations) lastCode(t *testing.T) string {
+	t.Helper()
+
+	messages, err := i.mail.Messages()
+	require.NoError(t, err)
+	require.NotEmpty(t, messages)
+
+	m := codeRe.FindStringSubmatch(messages[len(messages)-1].Body)
+	require.NotNil(t, m)
+
+	return m[1]
+}
+
+func TestCreateInvitation(t *testing.T) {
+	i := newTestInvitations(t)
+	ctx := context.Background()
+
+	adminID, err := i.auth.RegisterNewUser(ctx, "admin@example.com", "password", 0)
+	require.NoError(t, err)
+	require.NoError(t, i.storage.SetAdmin(ctx, adminID, true))
+	orgAdminID, orgID, appID := i.orgAdmin(t, "org@example.com")
+	_, otherOrgID, otherAppID := i.orgAdmin(t, "other@example.com")
+
+	tests := []struct {
+		name    string
+		actorID int64
+		inv     models.Invitation
+		wantErr error
+	}{
+		{"org member", orgAdminID, models.Invitation{OrgID: orgID}, nil},
+		{"org admin", orgAdminID, models.Invitation{OrgID: orgID, Role: models.OrgRoleAdmin}, nil},
+		{"unknown org role", orgAdminID, models.Invitation{OrgID: orgID, Role: "owner"}, ErrInvalidRole},
+		{"other org", orgAdminID, models.Invitation{OrgID: otherOrgID}, ErrPermissionDenied},
+		{"app without role", orgAdminID, models.Invitation{AppID: appID}, nil},
+		{"app of other org", orgAdminID, models.Invitation{AppID: otherAppID}, ErrPermissionDenied},
+		{"app with mismatched org", orgAdminID, models.Invitation{AppID: appID, OrgID: otherOrgID}, ErrInvalidTarget},
+		{"app role by org admin", orgAdminID, models.Invitation{AppID: appID, Role: "viewer"}, ErrPermissionDenied},
+		{"app role by admin", adminID, models.Invitation{AppID: appID, Role: "viewer"}, nil},
+		{"app admin role by org admin", orgAdminID, models.Invitation{AppID: appID, Role: models.RoleAdmin}, ErrInvalidRole},
+		{"app admin role by admin", adminID, models.Invitation{AppID: appID, Role: models.RoleAdmin}, ErrInvalidRole},
+		{"no target", adminID, models.Invitation{}, ErrInvalidTarget},
+		{"missing app", adminID, models.Invitation{AppID: 1000}, ErrAppNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.inv.Email = "invited@example.com"
+
+			inv, code, err := i.CreateInvitation(ctx, tt.actorID, tt.inv)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, code)
+			assert.Equal(t, code, i.lastCode(t))
+			assert.Equal(t, tt.actorID, inv.CreatedBy)
+		})
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	i := newTestInvitations(t)
+	ctx := context.Background()
+
+	adminID, err := i.auth.RegisterNewUser(ctx, "admin@example.com", "password", 0)
+	require.NoError(t, err)
+	require.NoError(t, i.storage.SetAdmin(ctx, adminID, true))
+	_, orgID, appID := i.orgAdmin(t, "org@example.com")
+
+	_, code, err := i.CreateInvitation(ctx, adminID, models.Invitation{
+		Email: "new@example.com",
+		AppID: appID,
+		Role:  "viewer",
+	})
+	require.NoError(t, err)
+
+	// New user is registered with the invited email
+	userID, err := i.AcceptInvitation(ctx, code, "password")
+	require.NoError(t, err)
+
+	user, err := i.storage.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email)
+	roles, err := i.storage.UserRoles(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, roles)
+	member, err := i.storage.OrgMember(ctx, orgID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrgRoleMember, member.Role)
+
+	_, err = i.AcceptInvitation(ctx, code, "password")
+	assert.ErrorIs(t, err, ErrInvitationInvalid)
+	_, err = i.AcceptInvitation(ctx, "missing", "password")
+	assert.ErrorIs(t, err, ErrInvitationInvalid)
+}
+
+func TestLinkInvitation(t *testing.T) {
+	i := newTestInvitations(t)
+	ctx := context.Background()
+
+	orgAdminID, orgID, _ := i.orgAdmin(t, "org@example.com")
+	existingID, err := i.auth.RegisterNewUser(ctx, "existing@example.com", "password", orgID)
+	require.NoError(t, err)
+
+	inv, code, err := i.CreateInvitation(ctx, orgAdminID, models.Invitation{
+		Email: "existing@example.com",
+		OrgID: orgID,
+		Role:  models.OrgRoleAdmin,
+	})
+	require.NoError(t, err)
+
+	// Existing user confirms the password to link invitation
+	_, err = i.AcceptInvitation(ctx, code, "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	userID, err := i.AcceptInvitation(ctx, code, "password")
+	require.NoError(t, err)
+	assert.Equal(t, existingID, userID)
+
+	member, err := i.storage.OrgMember(ctx, orgID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrgRoleAdmin, member.Role)
+
+	accepted, err := i.storage.Invitation(ctx, inv.ID)
+	require.NoError(t, err)
+	assert.Equal(t, userID, accepted.AcceptedBy)
+}
+
+func TestAppInvitationDoesNotGrantAdmin(t *testing.T) {
+	i := newTestInvitations(t)
+	ctx := context.Background()
+
+	orgAdminID, _, appID := i.orgAdmin(t, "org@example.com")
+
+	// Org admin invites own account as admin of the org app
+	_, _, err := i.CreateInvitation(ctx, orgAdminID, models.Invitation{
+		Email: "org@example.com",
+		AppID: appID,
+		Role:  models.RoleAdmin,
+	})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	// Invitation saved before the check is refused on accept
+	_, err = i.storage.SaveInvitation(ctx, models.Invitation{
+		Email:     "org@example.com",
+		AppID:     appID,
+		Role:      models.RoleAdmin,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, codes.Hash("stored"))
+	require.NoError(t, err)
+	_, err = i.AcceptInvitation(ctx, "stored", "password")
+	assert.ErrorIs(t, err, ErrInvitationInvalid)
+
+	isAdmin, err := i.storage.IsAdmin(ctx, orgAdminID)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+}
@@ -0,0 +1,320 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Orgs manages organizations. Organizations are created and deleted by
+// admins, members are managed by admins and organization admins
+type Orgs struct {
+	log          *slog.Logger
+	orgProvider  OrgProvider
+	orgManager   OrgManager
+	userProvider UserProvider
+	appProvider  AppProvider
+}
+
+type OrgProvider interface {
+	Org(ctx context.Context, orgID int64) (models.Org, error)
+	Orgs(ctx context.Context, userID int64) ([]models.Org, error)
+	OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error)
+	OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error)
+}
+
+type OrgManager interface {
+	SaveOrg(ctx context.Context, name string) (int64, error)
+	DeleteOrg(ctx context.Context, orgID int64) error
+	SaveOrgMember(ctx context.Context, member models.OrgMember) error
+	DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error
+}
+
+type UserProvider interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+type AppProvider interface {
+	Apps(ctx context.Context, orgID int64) ([]models.App, error)
+}
+
+var (
+	ErrOrgNotFound       = errors.New("organization not found")
+	ErrOrgExists         = errors.New("organization already exists")
+	ErrOrgHasApps        = errors.New("organization has apps")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrOrgMemberNotFound = errors.New("organization member not found")
+	ErrInvalidRole       = errors.New("invalid organization role")
+	ErrPermissionDenied  = errors.New("permission denied")
+)
+
+// New returns a new instance of Orgs service
+func New(
+	log *slog.Logger,
+	orgProvider OrgProvider,
+	orgManager OrgManager,
+	userProvider UserProvider,
+	appProvider AppProvider,
+) *Orgs {
+	return &Orgs{
+		log:          log,
+		orgProvider:  orgProvider,
+		orgManager:   orgManager,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+	}
+}
+
+// CreateOrg creates organization. Only admins can create organizations
+func (o *Orgs) CreateOrg(ctx context.Context, actorID int64, name string) (int64, error) {
+	const op = "Orgs.CreateOrg"
+
+	log := o.log.With(slog.String("op", op), slog.Int64("actor_id", actorID), slog.String("name", name))
+
+	if err := o.requireAdmin(ctx, actorID); err != nil {
+		return 0, o.orgError(op, err)
+	}
+
+	log.Info("creating organization")
+
+	id, err := o.orgManager.SaveOrg(ctx, name)
+	if err != nil {
+		return 0, o.orgError(op, err)
+	}
+
+	log.Info("organization created", slog.Int64("org_id", id))
+
+	return id, nil
+}
+
+// ListOrgs returns all organizations to admins and organizations
+// the actor is a member of to other users
+func (o *Orgs) ListOrgs(ctx context.Context, actorID int64) ([]models.Org, error) {
+	const op = "Orgs.ListOrgs"
+
+	isAdmin, err := o.isAdmin(ctx, actorID)
+	if err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	memberID := actorID
+	if isAdmin {
+		memberID = 0
+	}
+
+	orgs, err := o.orgProvider.Orgs(ctx, memberID)
+	if err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrg deletes organization without apps. Only admins can delete organizations
+func (o *Orgs) DeleteOrg(ctx context.Context, actorID int64, orgID int64) error {
+	const op = "Orgs.DeleteOrg"
+
+	if err := o.requireAdmin(ctx, actorID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	o.log.Info("deleting organization",
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("org_id", orgID),
+	)
+
+	if err := o.orgManager.DeleteOrg(ctx, orgID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	return nil
+}
+
+// SetMember adds user to organization or changes his role
+func (o *Orgs) SetMember(ctx context.Context, actorID int64, member models.OrgMember) error {
+	const op = "Orgs.SetMember"
+
+	if member.Role != models.OrgRoleAdmin && member.Role != models.OrgRoleMember {
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	if err := o.requireOrgAdmin(ctx, actorID, member.OrgID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	o.log.Info("setting organization member",
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("org_id", member.OrgID),
+		slog.Int64("user_id", member.UserID),
+		slog.String("role", member.Role),
+	)
+
+	if err := o.orgManager.SaveOrgMember(ctx, member); err != nil {
+		return o.orgError(op, err)
+	}
+
+	return nil
+}
+
+// RemoveMember removes user from organization
+func (o *Orgs) RemoveMember(ctx context.Context, actorID int64, orgID int64, userID int64) error {
+	const op = "Orgs.RemoveMember"
+
+	if err := o.requireOrgAdmin(ctx, actorID, orgID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	o.log.Info("removing organization member",
+		slog.String("op", op),
+		slog.Int64("actor_id", actorID),
+		slog.Int64("org_id", orgID),
+		slog.Int64("user_id", userID),
+	)
+
+	if err := o.orgManager.DeleteOrgMember(ctx, orgID, userID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	return nil
+}
+
+// ListMembers returns members of organization
+func (o *Orgs) ListMembers(ctx context.Context, actorID int64, orgID int64) ([]models.OrgMember, error) {
+	const op = "Orgs.ListMembers"
+
+	if err := o.requireOrgAdmin(ctx, actorID, orgID); err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	members, err := o.orgProvider.OrgMembers(ctx, orgID)
+	if err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	return members, nil
+}
+
+// ListApps returns apps of organization
+func (o *Orgs) ListApps(ctx context.Context, actorID int64, orgID int64) ([]models.App, error) {
+	const op = "Orgs.ListApps"
+
+	if err := o.requireOrgAdmin(ctx, actorID, orgID); err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	apps, err := o.appProvider.Apps(ctx, orgID)
+	if err != nil {
+		return nil, o.orgError(op, err)
+	}
+
+	return apps, nil
+}
+
+// RequireOrgAdmin checks that actor is an admin or an admin of organization
+func (o *Orgs) RequireOrgAdmin(ctx context.Context, actorID int64, orgID int64) error {
+	const op = "Orgs.RequireOrgAdmin"
+
+	if err := o.requireOrgAdmin(ctx, actorID, orgID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	return nil
+}
+
+// RequireAdmin checks that actor is an admin
+func (o *Orgs) RequireAdmin(ctx context.Context, actorID int64) error {
+	const op = "Orgs.RequireAdmin"
+
+	if err := o.requireAdmin(ctx, actorID); err != nil {
+		return o.orgError(op, err)
+	}
+
+	return nil
+}
+
+// requireOrgAdmin checks that actor is an admin or an admin of existing organization
+func (o *Orgs) requireOrgAdmin(ctx context.Context, actorID int64, orgID int64) error {
+	if _, err := o.orgProvider.Org(ctx, orgID); err != nil {
+		return err
+	}
+
+	isAdmin, err := o.isAdmin(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return nil
+	}
+
+	member, err := o.orgProvider.OrgMember(ctx, orgID, actorID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgMemberNotFound) {
+			return ErrPermissionDenied
+		}
+
+		return err
+	}
+	if member.Role != models.OrgRoleAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+func (o *Orgs) requireAdmin(ctx context.Context, actorID int64) error {
+	isAdmin, err := o.isAdmin(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+func (o *Orgs) isAdmin(ctx context.Context, userID int64) (bool, error) {
+	return o.userProvider.IsAdmin(ctx, userID)
+}
+
+func (o *Orgs) orgError(op string, err error) error {
+	log := o.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		log.Warn("permission denied")
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	case errors.Is(err, storage.ErrOrgNotFound):
+		log.Warn("organization not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+	case errors.Is(err, storage.ErrOrgExists):
+		log.Warn("organization already exists", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgExists)
+	case errors.Is(err, storage.ErrOrgHasApps):
+		log.Warn("organization has apps", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgHasApps)
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	case errors.Is(err, storage.ErrOrgMemberNotFound):
+		log.Warn("organization member not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrOrgMemberNotFound)
+	}
+
+	log.Error("storage error", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,234 @@
+package orgs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenants are two organizations with an admin, a member and an app each,
+// a global admin and a user outside of both organizations
+type tenants struct {
+	storage *memory.Storage
+
+	admin, stranger       int64
+	orgA, adminA, memberA int64
+	orgB, adminB, memberB int64
+	appA, appB            int
+}
+
+func newTestOrgs(t *testing.T) (*Orgs, tenants) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	user := func(email string) int64 {
+		id, err := s.SaveUser(ctx, email, []byte("hash"))
+		require.NoError(t, err)
+		return id
+	}
+	org := func(name string, adminID, memberID int64) (int64, int) {
+		orgID, err := s.SaveOrg(ctx, name)
+		require.NoError(t, err)
+		require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: adminID, Role: models.OrgRoleAdmin}))
+		require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: memberID, Role: models.OrgRoleMember}))
+		appID, err := s.SaveApp(ctx, name+"-app", name+"-secret", orgID)
+		require.NoError(t, err)
+		return orgID, appID
+	}
+
+	tt := tenants{
+		storage:  s,
+		admin:    user("admin@example.com"),
+		stranger: user("stranger@example.com"),
+		adminA:   user("admin@a.example.com"),
+		memberA:  user("member@a.example.com"),
+		adminB:   user("admin@b.example.com"),
+		memberB:  user("member@b.example.com"),
+	}
+	require.NoError(t, s.SetAdmin(ctx, tt.admin, true))
+	tt.orgA, tt.appA = org("a", tt.adminA, tt.memberA)
+	tt.orgB, tt.appB = org("b", tt.adminB, tt.memberB)
+
+	return New(log, s, s, s, s), tt
+}
+
+func TestRequireOrgAdmin(t *testing.T) {
+	o, tt := newTestOrgs(t)
+
+	tests := []struct {
+		name    string
+		actorID int64
+		orgID   int64
+		wantErr error
+	}{
+		{name: "admin", actorID: tt.admin, orgID: tt.orgA},
+		{name: "org admin", actorID: tt.adminA, orgID: tt.orgA},
+		{name: "org member", actorID: tt.memberA, orgID: tt.orgA, wantErr: ErrPermissionDenied},
+		{name: "admin of other org", actorID: tt.adminB, orgID: tt.orgA, wantErr: ErrPermissionDenied},
+		{name: "not a member", actorID: tt.stranger, orgID: tt.orgA, wantErr: ErrPermissionDenied},
+		{name: "missing org", actorID: tt.admin, orgID: tt.orgB + 1, wantErr: ErrOrgNotFound},
+		{name: "org admin of missing org", actorID: tt.adminA, orgID: tt.orgB + 1, wantErr: ErrOrgNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := o.RequireOrgAdmin(context.Background(), tc.actorID, tc.orgID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSetMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		actorID func(tenants) int64
+		member  func(tenants) models.OrgMember
+		wantErr error
+	}{
+		{
+			name:    "org admin adds user",
+			actorID: func(tt tenants) int64 { return tt.adminA },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.stranger, Role: models.OrgRoleMember}
+			},
+		},
+		{
+			name:    "org admin promotes member",
+			actorID: func(tt tenants) int64 { return tt.adminA },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.memberA, Role: models.OrgRoleAdmin}
+			},
+		},
+		{
+			name:    "admin adds user",
+			actorID: func(tt tenants) int64 { return tt.admin },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgB, UserID: tt.stranger, Role: models.OrgRoleAdmin}
+			},
+		},
+		{
+			name:    "member promotes self",
+			actorID: func(tt tenants) int64 { return tt.memberA },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.memberA, Role: models.OrgRoleAdmin}
+			},
+			wantErr: ErrPermissionDenied,
+		},
+		{
+			name:    "admin of other org adds self",
+			actorID: func(tt tenants) int64 { return tt.adminB },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.adminB, Role: models.OrgRoleAdmin}
+			},
+			wantErr: ErrPermissionDenied,
+		},
+		{
+			name:    "stranger adds self",
+			actorID: func(tt tenants) int64 { return tt.stranger },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.stranger, Role: models.OrgRoleMember}
+			},
+			wantErr: ErrPermissionDenied,
+		},
+		{
+			name:    "invalid role",
+			actorID: func(tt tenants) int64 { return tt.adminA },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.stranger, Role: "owner"}
+			},
+			wantErr: ErrInvalidRole,
+		},
+		{
+			name:    "missing user",
+			actorID: func(tt tenants) int64 { return tt.adminA },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgA, UserID: tt.memberB + 1, Role: models.OrgRoleMember}
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "missing org",
+			actorID: func(tt tenants) int64 { return tt.admin },
+			member: func(tt tenants) models.OrgMember {
+				return models.OrgMember{OrgID: tt.orgB + 1, UserID: tt.stranger, Role: models.OrgRoleMember}
+			},
+			wantErr: ErrOrgNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, tt := newTestOrgs(t)
+			ctx := context.Background()
+			member := tc.member(tt)
+
+			err := o.SetMember(ctx, tc.actorID(tt), member)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+
+				// Denied change leaves membership as it was
+				if got, err := tt.storage.OrgMember(ctx, member.OrgID, member.UserID); err == nil {
+					assert.NotEqual(t, member, got)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := tt.storage.OrgMember(ctx, member.OrgID, member.UserID)
+			require.NoError(t, err)
+			assert.Equal(t, member.Role, got.Role)
+		})
+	}
+}
+
+func TestListApps(t *testing.T) {
+	o, tt := newTestOrgs(t)
+
+	tests := []struct {
+		name    string
+		actorID int64
+		orgID   int64
+		want    []int
+		wantErr error
+	}{
+		{name: "org admin", actorID: tt.adminA, orgID: tt.orgA, want: []int{tt.appA}},
+		{name: "admin", actorID: tt.admin, orgID: tt.orgB, want: []int{tt.appB}},
+		{name: "org member", actorID: tt.memberA, orgID: tt.orgA, wantErr: ErrPermissionDenied},
+		{name: "admin of other org", actorID: tt.adminB, orgID: tt.orgA, wantErr: ErrPermissionDenied},
+		{name: "missing org", actorID: tt.admin, orgID: tt.orgB + 1, wantErr: ErrOrgNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			apps, err := o.ListApps(context.Background(), tc.actorID, tc.orgID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Empty(t, apps)
+				return
+			}
+			require.NoError(t, err)
+
+			var ids []int
+			for _, app := range apps {
+				ids = append(ids, app.ID)
+			}
+			assert.Equal(t, tc.want, ids)
+		})
+	}
+}
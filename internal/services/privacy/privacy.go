@@ -0,0 +1,274 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/services/auth"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Privacy handles data subject requests: export of all data held
+// about user and its erasure. Users make requests about themselves,
+// admins about anyone
+type Privacy struct {
+	log          *slog.Logger
+	dataProvider DataProvider
+	eraser       Eraser
+	credentials  CredentialsChecker
+}
+
+// DataProvider provides everything held about user
+type DataProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	UserRoles(ctx context.Context, userID int64) ([]string, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	UserOrgMembers(ctx context.Context, userID int64) ([]models.OrgMember, error)
+	UserProfile(ctx context.Context, userID int64) (models.Profile, error)
+	UserEmailChanges(ctx context.Context, userID int64) ([]models.EmailChange, error)
+	UserInvitations(ctx context.Context, userID int64, email string) ([]models.Invitation, error)
+	UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error)
+	UserLoginEvents(ctx context.Context, userID int64) ([]models.Event, error)
+	UserMagicLinks(ctx context.Context, userID int64) ([]models.MagicLink, error)
+	UserOTPChallenges(ctx context.Context, userID int64) ([]models.OTPChallenge, error)
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UserLoginAttempts(ctx context.Context, userID int64) ([]models.LoginAttempt, error)
+	TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error)
+}
+
+type Eraser interface {
+	EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error
+}
+
+type CredentialsChecker interface {
+	CheckCredentials(ctx context.Context, orgID int64, email string, password string) (models.User, error)
+}
+
+// Section is a named part of user data export encoded as JSON
+type Section struct {
+	Name string
+	Data []byte
+}
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// New returns a new instance of Privacy service
+func New(
+	log *slog.Logger,
+	dataProvider DataProvider,
+	eraser Eraser,
+	credentials CredentialsChecker,
+) *Privacy {
+	return &Privacy{
+		log:          log,
+		dataProvider: dataProvider,
+		eraser:       eraser,
+		credentials:  credentials,
+	}
+}
+
+// ExportUserData passes data held about user to send section by section.
+// Zero userID means the actor
+func (p *Privacy) ExportUserData(
+	ctx context.Context,
+	actorID int64,
+	userID int64,
+	send func(Section) error,
+) error {
+	const op = "Privacy.ExportUserData"
+
+	if userID == 0 {
+		userID = actorID
+	}
+
+	log := p.log.With(slog.String("op", op), slog.Int64("actor_id", actorID), slog.Int64("user_id", userID))
+
+	if err := p.authorize(ctx, actorID, userID); err != nil {
+		return p.privacyError(op, err)
+	}
+
+	user, err := p.dataProvider.UserByID(ctx, userID)
+	if err != nil {
+		return p.privacyError(op, err)
+	}
+
+	sections := []struct {
+		name string
+		data func() (any, error)
+	}{
+		{"user", func() (any, error) { return exportUser(user), nil }},
+		{"profile", func() (any, error) { return p.dataProvider.UserProfile(ctx, userID) }},
+		{"roles", func() (any, error) { return p.dataProvider.UserRoles(ctx, userID) }},
+		{"groups", func() (any, error) { return p.dataProvider.UserGroups(ctx, userID) }},
+		{"organizations", func() (any, error) { return p.dataProvider.UserOrgMembers(ctx, userID) }},
+		{"email_changes", func() (any, error) { return p.dataProvider.UserEmailChanges(ctx, userID) }},
+		{"invitations", func() (any, error) { return p.dataProvider.UserInvitations(ctx, userID, user.Email) }},
+		{"sessions", func() (any, error) { return p.dataProvider.UserLoginEvents(ctx, userID) }},
+		{"magic_links", func() (any, error) { return p.dataProvider.UserMagicLinks(ctx, userID) }},
+		{"otp_challenges", func() (any, error) { return p.userOTPChallenges(ctx, userID) }},
+		{"webauthn_credentials", func() (any, error) { return p.dataProvider.WebAuthnCredentials(ctx, userID) }},
+		{"login_attempts", func() (any, error) { return p.dataProvider.UserLoginAttempts(ctx, userID) }},
+		// Zero time includes expired devices
+		{"trusted_devices", func() (any, error) { return p.dataProvider.TrustedDevices(ctx, userID, time.Time{}) }},
+		{"audit_events", func() (any, error) { return p.dataProvider.UserAuditEvents(ctx, userID) }},
+	}
+
+	for _, section := range sections {
+		data, err := section.data()
+		if err != nil {
+			return p.privacyError(op, fmt.Errorf("section %s: %w", section.name, err))
+		}
+
+		b, err := json.Marshal(data)
+		if err != nil {
+			return p.privacyError(op, fmt.Errorf("section %s: %w", section.name, err))
+		}
+
+		if err := send(Section{Name: section.name, Data: b}); err != nil {
+			log.Warn("failed to send section", slog.String("section", section.name), sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("user data exported")
+
+	return nil
+}
+
+// EraseUser irreversibly erases personal data of user. Users erasing
+// themselves confirm it with password. Zero userID means the actor
+func (p *Privacy) EraseUser(ctx context.Context, actorID int64, userID int64, password string) error {
+	const op = "Privacy.EraseUser"
+
+	if userID == 0 {
+		userID = actorID
+	}
+
+	log := p.log.With(slog.String("op", op), slog.Int64("actor_id", actorID), slog.Int64("user_id", userID))
+
+	if err := p.authorize(ctx, actorID, userID); err != nil {
+		return p.privacyError(op, err)
+	}
+
+	if actorID == userID {
+		user, err := p.dataProvider.UserByID(ctx, userID)
+		if err != nil {
+			return p.privacyError(op, err)
+		}
+
+		if _, err := p.credentials.CheckCredentials(ctx, user.OrgID, user.Email, password); err != nil {
+			return p.privacyError(op, err)
+		}
+	}
+
+	if err := p.eraser.EraseUser(ctx, userID, actorID, time.Now()); err != nil {
+		return p.privacyError(op, err)
+	}
+
+	log.Info("user erased")
+
+	return nil
+}
+
+// userOTPChallenges returns challenges of user without hashes of codes
+func (p *Privacy) userOTPChallenges(ctx context.Context, userID int64) ([]models.OTPChallenge, error) {
+	challenges, err := p.dataProvider.UserOTPChallenges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range challenges {
+		challenges[i].CodeHash = ""
+	}
+
+	return challenges, nil
+}
+
+// exportedUser is user without password hash
+type exportedUser struct {
+	ID                int64
+	OrgID             int64
+	Email             string
+	Username          string
+	Phone             string
+	IsAdmin           bool
+	Disabled          bool
+	PassResetRequired bool
+	Status            models.AccountStatus
+	StatusReason      string
+	StatusChangedAt   time.Time
+	MFAChannel        string
+}
+
+func exportUser(user models.User) exportedUser {
+	return exportedUser{
+		ID:                user.ID,
+		OrgID:             user.OrgID,
+		Email:             user.Email,
+		Username:          user.Username,
+		Phone:             user.Phone,
+		IsAdmin:           user.IsAdmin,
+		Disabled:          user.Disabled,
+		PassResetRequired: user.PassResetRequired,
+		Status:            user.Status,
+		StatusReason:      user.StatusReason,
+		StatusChangedAt:   user.StatusChangedAt,
+		MFAChannel:        user.MFAChannel,
+	}
+}
+
+// authorize checks that actor is the user or an admin
+func (p *Privacy) authorize(ctx context.Context, actorID int64, userID int64) error {
+	if actorID == userID {
+		return nil
+	}
+
+	isAdmin, err := p.dataProvider.IsAdmin(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+func (p *Privacy) privacyError(op string, err error) error {
+	log := p.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		log.Warn("permission denied")
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		log.Warn("invalid credentials", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	case errors.Is(err, auth.ErrUserInactive), errors.Is(err, auth.ErrPassResetRequired):
+		log.Warn("user can't manage account", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	log.Error("privacy operation failed", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
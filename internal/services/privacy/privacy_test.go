@@ -0,0 +1,150 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// populate saves user with a row in every table export reads
+func populate(t *testing.T, s *memory.Storage) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	id, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserIdentifiers(ctx, id, "user", "+15550001"))
+	require.NoError(t, s.SetUserMFAChannel(ctx, id, "email"))
+	require.NoError(t, s.SetUserStatus(ctx, id, models.StatusActive, models.StatusLocked, "too many attempts", now))
+
+	require.NoError(t, s.SaveUserProfile(ctx, models.Profile{UserID: id, DisplayName: "User"}))
+	require.NoError(t, s.AddUserRole(ctx, id, "viewer"))
+
+	groupID, err := s.SaveGroup(ctx, "group", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveGroupMember(ctx, groupID, id))
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: id, Role: models.OrgRoleMember}))
+
+	_, err = s.SaveEmailChange(ctx, models.EmailChange{
+		UserID:    id,
+		OldEmail:  "user@example.com",
+		NewEmail:  "new@example.com",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "code", "cancel")
+	require.NoError(t, err)
+
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		Email:     "user@example.com",
+		OrgID:     orgID,
+		Role:      models.OrgRoleMember,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "invitation")
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveEvent(ctx, models.Event{Type: models.EventUserLoggedIn, UserID: id, AppID: 1}))
+	_, err = s.SaveOTPChallenge(ctx, models.OTPChallenge{
+		UserID:     id,
+		AppID:      1,
+		Purpose:    models.OTPPurposeMFA,
+		Channel:    "email",
+		CodeHash:   "code",
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour),
+		LastSentAt: now,
+	}, "challenge")
+	require.NoError(t, err)
+	_, err = s.SaveWebAuthnCredential(ctx, models.WebAuthnCredential{
+		UserID:       id,
+		Name:         "laptop",
+		CredentialID: []byte("credential"),
+		PublicKey:    []byte("key"),
+		CreatedAt:    now,
+	})
+	require.NoError(t, err)
+	_, err = s.SaveLoginAttempt(ctx, models.LoginAttempt{
+		UserID:    id,
+		AppID:     1,
+		IP:        "10.0.0.1",
+		Succeeded: true,
+		CreatedAt: now,
+	}, now.Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = s.SaveTrustedDevice(ctx, models.TrustedDevice{
+		UserID:    id,
+		UserAgent: "agent",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "device")
+	require.NoError(t, err)
+	_, err = s.SaveMagicLink(ctx, models.MagicLink{UserID: id, AppID: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, "link")
+	require.NoError(t, err)
+	_, err = s.SaveAuditEvent(ctx, models.AuditEvent{Time: now, ActorID: id, Action: "login", Result: "OK"}, false)
+	require.NoError(t, err)
+
+	return id
+}
+
+func TestExportUserData(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := memory.New(log, config.DBConfig{})
+	require.NoError(t, err)
+	id := populate(t, s)
+
+	p := New(log, s, s, nil)
+
+	sections := make(map[string]json.RawMessage)
+	var names []string
+	err = p.ExportUserData(context.Background(), id, 0, func(section Section) error {
+		names = append(names, section.Name)
+		sections[section.Name] = section.Data
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"user",
+		"profile",
+		"roles",
+		"groups",
+		"organizations",
+		"email_changes",
+		"invitations",
+		"sessions",
+		"magic_links",
+		"otp_challenges",
+		"webauthn_credentials",
+		"login_attempts",
+		"trusted_devices",
+		"audit_events",
+	}, names)
+	for name, data := range sections {
+		assert.NotContains(t, []string{"null", "[]", "{}"}, string(data), name)
+	}
+
+	var user map[string]any
+	require.NoError(t, json.Unmarshal(sections["user"], &user))
+	for _, field := range []string{"Username", "Phone", "Status", "StatusReason", "MFAChannel"} {
+		assert.NotEmpty(t, user[field], field)
+	}
+	assert.NotContains(t, user, "PassHash")
+
+	assert.NotContains(t, string(sections["otp_challenges"]), `"code"`)
+}
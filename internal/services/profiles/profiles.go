@@ -0,0 +1,325 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const (
+	maxDisplayNameLen = 100
+	maxAvatarURLLen   = 2048
+	maxMetadataSize   = 4096
+)
+
+var localeRe = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`)
+
+// Profiles manages user profiles and login identifiers. Users read and
+// edit their own profile, admins read and edit any profile including
+// admin metadata
+type Profiles struct {
+	log             *slog.Logger
+	profileProvider ProfileProvider
+	profileSaver    ProfileSaver
+	userProvider    UserProvider
+	identifierSaver IdentifierSaver
+}
+
+type ProfileProvider interface {
+	UserProfile(ctx context.Context, userID int64) (models.Profile, error)
+}
+
+type ProfileSaver interface {
+	SaveUserProfile(ctx context.Context, profile models.Profile) error
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+type IdentifierSaver interface {
+	SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error
+}
+
+// Update holds profile changes. Nil fields are left unchanged,
+// metadata is replaced as a whole
+type Update struct {
+	DisplayName   *string
+	Locale        *string
+	TimeZone      *string
+	AvatarURL     *string
+	Metadata      map[string]any
+	AdminMetadata map[string]any
+}
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidProfile   = errors.New("invalid profile")
+	ErrInvalidUsername  = errors.New("invalid username")
+	ErrInvalidPhone     = errors.New("invalid phone")
+	ErrUsernameExists   = errors.New("username already taken")
+	ErrPhoneExists      = errors.New("phone already taken")
+)
+
+// New returns a new instance of Profiles service
+func New(
+	log *slog.Logger,
+	profileProvider ProfileProvider,
+	profileSaver ProfileSaver,
+	userProvider UserProvider,
+	identifierSaver IdentifierSaver,
+) *Profiles {
+	return &Profiles{
+		log:             log,
+		profileProvider: profileProvider,
+		profileSaver:    profileSaver,
+		userProvider:    userProvider,
+		identifierSaver: identifierSaver,
+	}
+}
+
+// GetProfile returns profile of user. Zero userID means the actor
+func (p *Profiles) GetProfile(ctx context.Context, actorID int64, userID int64) (models.Profile, error) {
+	const op = "Profiles.GetProfile"
+
+	if userID == 0 {
+		userID = actorID
+	}
+
+	if err := p.authorize(ctx, actorID, userID); err != nil {
+		return models.Profile{}, p.profileError(op, err)
+	}
+
+	profile, err := p.profileProvider.UserProfile(ctx, userID)
+	if err != nil {
+		return models.Profile{}, p.profileError(op, err)
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile applies update to profile of user and returns the result.
+// Zero userID means the actor. Only admins can change admin metadata
+func (p *Profiles) UpdateProfile(
+	ctx context.Context,
+	actorID int64,
+	userID int64,
+	update Update,
+) (models.Profile, error) {
+	const op = "Profiles.UpdateProfile"
+
+	if userID == 0 {
+		userID = actorID
+	}
+
+	log := p.log.With(slog.String("op", op), slog.Int64("actor_id", actorID), slog.Int64("user_id", userID))
+
+	if err := p.authorize(ctx, actorID, userID); err != nil {
+		return models.Profile{}, p.profileError(op, err)
+	}
+
+	if update.AdminMetadata != nil {
+		isAdmin, err := p.userProvider.IsAdmin(ctx, actorID)
+		if err != nil {
+			return models.Profile{}, p.profileError(op, err)
+		}
+		if !isAdmin {
+			return models.Profile{}, p.profileError(op, ErrPermissionDenied)
+		}
+	}
+
+	profile, err := p.profileProvider.UserProfile(ctx, userID)
+	if err != nil {
+		return models.Profile{}, p.profileError(op, err)
+	}
+
+	apply(&profile, update)
+
+	if err := validate(profile); err != nil {
+		log.Warn("invalid profile", sl.Err(err))
+
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.profileSaver.SaveUserProfile(ctx, profile); err != nil {
+		return models.Profile{}, p.profileError(op, err)
+	}
+
+	log.Info("profile updated")
+
+	return profile, nil
+}
+
+// SetIdentifiers sets username and phone number user can log in with
+// and returns the user. Nil values are left unchanged, empty values
+// remove identifiers. Zero userID means the actor
+func (p *Profiles) SetIdentifiers(
+	ctx context.Context,
+	actorID int64,
+	userID int64,
+	username *string,
+	phone *string,
+) (models.User, error) {
+	const op = "Profiles.SetIdentifiers"
+
+	if userID == 0 {
+		userID = actorID
+	}
+
+	log := p.log.With(slog.String("op", op), slog.Int64("actor_id", actorID), slog.Int64("user_id", userID))
+
+	if err := p.authorize(ctx, actorID, userID); err != nil {
+		return models.User{}, p.profileError(op, err)
+	}
+
+	user, err := p.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, p.profileError(op, err)
+	}
+
+	if username != nil {
+		user.Username = ""
+		if *username != "" {
+			if user.Username, err = identifiers.CanonicalUsername(*username); err != nil {
+				log.Warn("invalid username", sl.Err(err))
+
+				return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidUsername)
+			}
+		}
+	}
+	if phone != nil {
+		user.Phone = ""
+		if *phone != "" {
+			if user.Phone, err = identifiers.CanonicalPhone(*phone); err != nil {
+				log.Warn("invalid phone", sl.Err(err))
+
+				return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidPhone)
+			}
+		}
+	}
+
+	if err := p.identifierSaver.SetUserIdentifiers(ctx, userID, user.Username, user.Phone); err != nil {
+		return models.User{}, p.profileError(op, err)
+	}
+
+	log.Info("login identifiers updated")
+
+	return user, nil
+}
+
+func apply(profile *models.Profile, update Update) {
+	if update.DisplayName != nil {
+		profile.DisplayName = *update.DisplayName
+	}
+	if update.Locale != nil {
+		profile.Locale = *update.Locale
+	}
+	if update.TimeZone != nil {
+		profile.TimeZone = *update.TimeZone
+	}
+	if update.AvatarURL != nil {
+		profile.AvatarURL = *update.AvatarURL
+	}
+	if update.Metadata != nil {
+		profile.Metadata = update.Metadata
+	}
+	if update.AdminMetadata != nil {
+		profile.AdminMetadata = update.AdminMetadata
+	}
+}
+
+func validate(profile models.Profile) error {
+	if utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLen {
+		return fmt.Errorf("%w: display name is too long", ErrInvalidProfile)
+	}
+
+	if profile.Locale != "" && !localeRe.MatchString(profile.Locale) {
+		return fmt.Errorf("%w: invalid locale", ErrInvalidProfile)
+	}
+
+	if profile.TimeZone != "" {
+		if _, err := time.LoadLocation(profile.TimeZone); err != nil {
+			return fmt.Errorf("%w: invalid time zone", ErrInvalidProfile)
+		}
+	}
+
+	if profile.AvatarURL != "" {
+		u, err := url.Parse(profile.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			len(profile.AvatarURL) > maxAvatarURLLen {
+			return fmt.Errorf("%w: invalid avatar url", ErrInvalidProfile)
+		}
+	}
+
+	for name, metadata := range map[string]map[string]any{
+		"metadata":       profile.Metadata,
+		"admin metadata": profile.AdminMetadata,
+	} {
+		b, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("%w: invalid %s", ErrInvalidProfile, name)
+		}
+		if len(b) > maxMetadataSize {
+			return fmt.Errorf("%w: %s is too large", ErrInvalidProfile, name)
+		}
+	}
+
+	return nil
+}
+
+// authorize checks that actor is the user or an admin
+func (p *Profiles) authorize(ctx context.Context, actorID int64, userID int64) error {
+	if actorID == userID {
+		return nil
+	}
+
+	isAdmin, err := p.userProvider.IsAdmin(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+func (p *Profiles) profileError(op string, err error) error {
+	log := p.log.With(slog.String("op", op))
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		log.Warn("permission denied")
+
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	case errors.Is(err, storage.ErrUsernameExists):
+		log.Warn("username already taken", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrUsernameExists)
+	case errors.Is(err, storage.ErrPhoneExists):
+		log.Warn("phone already taken", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrPhoneExists)
+	}
+
+	log.Error("profile operation failed", sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
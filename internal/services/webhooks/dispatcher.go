@@ -0,0 +1,322 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+)
+
+const (
+	// batchSize is how many events or deliveries are processed at once
+	batchSize = 100
+
+	EventIDHeader   = "X-Webhook-Event-Id"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Dispatcher relays outbox events to the event bus and webhooks and
+// delivers them with retries
+type Dispatcher struct {
+	log        *slog.Logger
+	outbox     Outbox
+	deliveries DeliveryStorage
+	publisher  EventPublisher
+	client     *http.Client
+	cfg        DispatcherConfig
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// DispatcherConfig tunes delivery of webhooks. Delay before retry
+// doubles after each failed attempt up to RetryMaxDelay. Deliveries
+// failed MaxAttempts times are dead
+type DispatcherConfig struct {
+	PollInterval   time.Duration
+	Timeout        time.Duration
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+type Outbox interface {
+	UnrelayedEvents(ctx context.Context, limit int) ([]models.Event, error)
+	RelayEvent(ctx context.Context, eventID int64, webhookIDs []int64, now time.Time) error
+}
+
+type DeliveryStorage interface {
+	Webhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// EventPublisher delivers relayed events to event stream watchers
+type EventPublisher interface {
+	Publish(event models.Event)
+}
+
+// payload is JSON body of webhook request
+type payload struct {
+	ID     int64             `json:"id"`
+	Type   string            `json:"type"`
+	Time   int64             `json:"time"`
+	UserID int64             `json:"user_id,omitempty"`
+	OrgID  int64             `json:"org_id,omitempty"`
+	AppID  int               `json:"app_id,omitempty"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+// NewDispatcher returns a new instance of Dispatcher
+func NewDispatcher(
+	log *slog.Logger,
+	outbox Outbox,
+	deliveries DeliveryStorage,
+	publisher EventPublisher,
+	cfg DispatcherConfig,
+) *Dispatcher {
+	return &Dispatcher{
+		log:        log,
+		outbox:     outbox,
+		deliveries: deliveries,
+		publisher:  publisher,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		cfg:        cfg,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Sign returns signature of webhook request body sent at timestamp.
+// It is HMAC-SHA256 of "timestamp.body" keyed with webhook secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run dispatches events every poll interval until Stop is called
+func (d *Dispatcher) Run() {
+	const op = "Dispatcher.Run"
+
+	defer close(d.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-d.stop
+		cancel()
+	}()
+
+	d.log.Info("webhook dispatcher is running", slog.String("op", op))
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops dispatcher and waits for deliveries in flight
+func (d *Dispatcher) Stop() {
+	const op = "Dispatcher.Stop"
+
+	d.log.Info("stopping webhook dispatcher", slog.String("op", op))
+
+	close(d.stop)
+	<-d.done
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	const op = "Dispatcher.dispatch"
+
+	log := d.log.With(slog.String("op", op))
+
+	// Errors caused by stopping are not logged
+	if err := d.relay(ctx); err != nil && ctx.Err() == nil {
+		log.Error("failed to relay events", sl.Err(err))
+	}
+	if err := d.deliver(ctx); err != nil && ctx.Err() == nil {
+		log.Error("failed to deliver webhooks", sl.Err(err))
+	}
+}
+
+// relay schedules deliveries of outbox events to webhooks and publishes
+// them to the event bus
+func (d *Dispatcher) relay(ctx context.Context) error {
+	events, err := d.outbox.UnrelayedEvents(ctx, batchSize)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	webhooks, err := d.deliveries.Webhooks(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		var webhookIDs []int64
+		for _, webhook := range webhooks {
+			if webhook.Accepts(event) {
+				webhookIDs = append(webhookIDs, webhook.ID)
+			}
+		}
+
+		if err := d.outbox.RelayEvent(ctx, event.ID, webhookIDs, time.Now()); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+
+		d.publisher.Publish(event)
+	}
+
+	return nil
+}
+
+// deliver sends due deliveries concurrently and saves results
+func (d *Dispatcher) deliver(ctx context.Context) error {
+	deliveries, err := d.deliveries.DueWebhookDeliveries(ctx, time.Now(), batchSize)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+
+	webhooks, err := d.deliveries.Webhooks(ctx, 0)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]models.Webhook, len(webhooks))
+	for _, webhook := range webhooks {
+		byID[webhook.ID] = webhook
+	}
+
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		webhook, ok := byID[delivery.WebhookID]
+		if !ok {
+			// Webhook was deleted along with its deliveries
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliveries[i] = d.attempt(ctx, webhook, delivery)
+		}()
+	}
+	wg.Wait()
+
+	// Results are saved even if dispatcher is stopping
+	ctx = context.WithoutCancel(ctx)
+	for _, delivery := range deliveries {
+		if delivery.Attempts == 0 {
+			continue
+		}
+		if err := d.deliveries.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("delivery %d: %w", delivery.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// attempt sends delivery once and returns it with the result
+func (d *Dispatcher) attempt(
+	ctx context.Context,
+	webhook models.Webhook,
+	delivery models.WebhookDelivery,
+) models.WebhookDelivery {
+	log := d.log.With(
+		slog.String("op", "Dispatcher.attempt"),
+		slog.Int64("webhook_id", webhook.ID),
+		slog.Int64("delivery_id", delivery.ID),
+	)
+
+	now := time.Now()
+	delivery.Attempts++
+
+	if err := d.send(ctx, webhook, delivery.Event, now); err != nil {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.cfg.MaxAttempts {
+			delivery.Status = models.DeliveryDead
+			log.Warn("webhook delivery is dead", slog.Int("attempts", delivery.Attempts), sl.Err(err))
+		} else {
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+			log.Info("webhook delivery failed", slog.Int("attempts", delivery.Attempts), sl.Err(err))
+		}
+	} else {
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = now
+	}
+
+	return delivery
+}
+
+func (d *Dispatcher) send(ctx context.Context, webhook models.Webhook, event models.Event, now time.Time) error {
+	body, err := json.Marshal(payload{
+		ID:     event.ID,
+		Type:   event.Type,
+		Time:   event.Time.Unix(),
+		UserID: event.UserID,
+		OrgID:  event.OrgID,
+		AppID:  event.AppID,
+		Data:   event.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, strconv.FormatInt(event.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, now.Unix(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// backoff returns delay before the next attempt after given number of attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBaseDelay
+	for i := 1; i < attempts && delay < d.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.cfg.RetryMaxDelay)
+}
@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage keeps outbox and deliveries in memory
+type fakeStorage struct {
+	mu         sync.Mutex
+	events     []models.Event
+	relayed    map[int64]bool
+	webhooks   []models.Webhook
+	deliveries []models.WebhookDelivery
+}
+
+func (s *fakeStorage) UnrelayedEvents(_ context.Context, limit int) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.Event
+	for _, event := range s.events {
+		if !s.relayed[event.ID] && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeStorage) RelayEvent(_ context.Context, eventID int64, webhookIDs []int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhookID := range webhookIDs {
+		s.deliveries = append(s.deliveries, models.WebhookDelivery{
+			ID:            int64(len(s.deliveries) + 1),
+			WebhookID:     webhookID,
+			Event:         s.events[eventID-1],
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	s.relayed[eventID] = true
+	return nil
+}
+
+func (s *fakeStorage) Webhooks(context.Context, int) ([]models.Webhook, error) {
+	return s.webhooks, nil
+}
+
+func (s *fakeStorage) DueWebhookDeliveries(_ context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStorage) UpdateWebhookDelivery(_ context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries[delivery.ID-1] = delivery
+	return nil
+}
+
+type fakePublisher struct {
+	events []models.Event
+}
+
+func (p *fakePublisher) Publish(event models.Event) {
+	p.events = append(p.events, event)
+}
+
+func newDispatcher(url string, maxAttempts int) (*Dispatcher, *fakeStorage, *fakePublisher) {
+	st := &fakeStorage{
+		relayed: make(map[int64]bool),
+		webhooks: []models.Webhook{
+			{ID: 1, AppID: 1, URL: url, Secret: "secret"},
+			{ID: 2, AppID: 2, URL: url, Secret: "other"},
+		},
+		events: []models.Event{
+			{ID: 1, Type: models.EventUserLoggedIn, Time: time.Unix(1700000000, 0), UserID: 5, AppID: 1},
+		},
+	}
+	pub := &fakePublisher{}
+
+	d := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), st, st, pub, DispatcherConfig{
+		Timeout:        time.Second,
+		MaxAttempts:    maxAttempts,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  4 * time.Millisecond,
+	})
+
+	return d, st, pub
+}
+
+func TestDispatcher_DeliversSignedEvents(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var requests []received
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, received{header: r.Header, body: body})
+	}))
+	defer srv.Close()
+
+	d, st, pub := newDispatcher(srv.URL, 3)
+	d.dispatch(context.Background())
+
+	// Event of app 1 is delivered to its webhook only
+	require.Len(t, requests, 1)
+	require.Len(t, pub.events, 1)
+
+	req := requests[0]
+	timestamp, err := strconv.ParseInt(req.header.Get(TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, Sign("secret", timestamp, req.body), req.header.Get(SignatureHeader))
+	assert.Equal(t, "1", req.header.Get(EventIDHeader))
+
+	var p payload
+	require.NoError(t, json.Unmarshal(req.body, &p))
+	assert.Equal(t, models.EventUserLoggedIn, p.Type)
+	assert.Equal(t, int64(5), p.UserID)
+
+	assert.Equal(t, models.DeliveryDelivered, st.deliveries[0].Status)
+	assert.Equal(t, 1, st.deliveries[0].Attempts)
+
+	// Delivered events are not sent again
+	d.dispatch(context.Background())
+	assert.Len(t, requests, 1)
+}
+
+func TestDispatcher_KeepsOrgEventsInOrg(t *testing.T) {
+	d, st, _ := newDispatcher("http://localhost", 3)
+	st.webhooks = []models.Webhook{
+		{ID: 1, AppID: 1, OrgID: 10, URL: "http://org-a"},
+		{ID: 2, AppID: 2, OrgID: 20, URL: "http://org-b"},
+		{ID: 3, AppID: 3, URL: "http://no-org"},
+	}
+	st.events = []models.Event{
+		// Users registered in org B and events of no organization
+		{ID: 1, Type: models.EventUserRegistered, UserID: 5, OrgID: 20},
+		{ID: 2, Type: models.EventUserStatusChanged, UserID: 5, OrgID: 20},
+		{ID: 3, Type: models.EventUserRegistered, UserID: 6},
+	}
+
+	require.NoError(t, d.relay(context.Background()))
+
+	got := make(map[int64][]int64)
+	for _, delivery := range st.deliveries {
+		got[delivery.Event.ID] = append(got[delivery.Event.ID], delivery.WebhookID)
+	}
+	assert.Equal(t, map[int64][]int64{
+		1: {2},
+		2: {2},
+		3: {1, 2, 3},
+	}, got)
+}
+
+func TestDispatcher_RetriesUntilDead(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	d, st, _ := newDispatcher(srv.URL, 3)
+
+	d.dispatch(context.Background())
+	delivery := st.deliveries[0]
+	assert.Equal(t, models.DeliveryPending, delivery.Status)
+	assert.Contains(t, delivery.LastError, "503")
+
+	for i := 0; i < 10 && st.deliveries[0].Status == models.DeliveryPending; i++ {
+		time.Sleep(5 * time.Millisecond)
+		d.dispatch(context.Background())
+	}
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, models.DeliveryDead, st.deliveries[0].Status)
+	assert.Equal(t, 3, st.deliveries[0].Attempts)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := &Dispatcher{cfg: DispatcherConfig{RetryBaseDelay: time.Second, RetryMaxDelay: 5 * time.Second}}
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(100))
+}
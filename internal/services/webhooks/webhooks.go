@@ -0,0 +1,207 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/sl"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const (
+	secretLen = 32
+	// deliveriesLimit is how many latest deliveries are listed
+	deliveriesLimit = 100
+)
+
+// Webhooks manages webhooks apps receive auth events with
+type Webhooks struct {
+	log            *slog.Logger
+	webhookStorage WebhookStorage
+}
+
+type WebhookStorage interface {
+	SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error)
+	Webhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int64) error
+	WebhookDeliveries(
+		ctx context.Context,
+		webhookID int64,
+		status models.DeliveryStatus,
+		limit int,
+	) ([]models.WebhookDelivery, error)
+	ReplayWebhookDeliveries(ctx context.Context, webhookID int64, deliveryID int64, now time.Time) (int64, error)
+}
+
+var (
+	ErrAppNotFound           = errors.New("app not found")
+	ErrWebhookNotFound       = errors.New("webhook not found")
+	ErrDeliveryNotFound      = errors.New("webhook delivery not found")
+	ErrInvalidURL            = errors.New("invalid webhook url")
+	ErrInvalidEventType      = errors.New("invalid event type")
+	ErrInvalidDeliveryStatus = errors.New("invalid delivery status")
+)
+
+// New returns a new instance of Webhooks service
+func New(log *slog.Logger, webhookStorage WebhookStorage) *Webhooks {
+	return &Webhooks{
+		log:            log,
+		webhookStorage: webhookStorage,
+	}
+}
+
+// CreateWebhook registers webhook of app with generated signing secret.
+// Empty eventTypes subscribe to all events
+func (w *Webhooks) CreateWebhook(
+	ctx context.Context,
+	appID int,
+	rawURL string,
+	eventTypes []string,
+) (models.Webhook, error) {
+	const op = "Webhooks.CreateWebhook"
+
+	log := w.log.With(slog.String("op", op), slog.Int("app_id", appID))
+
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Warn("invalid webhook url")
+
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+	for _, t := range eventTypes {
+		if !models.ValidEventType(t) {
+			log.Warn("invalid event type", slog.String("type", t))
+
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrInvalidEventType)
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	webhook := models.Webhook{
+		AppID:      appID,
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now(),
+	}
+
+	webhook.ID, err = w.webhookStorage.SaveWebhook(ctx, webhook)
+	if err != nil {
+		return models.Webhook{}, w.webhookError(op, err)
+	}
+
+	log.Info("webhook created", slog.Int64("webhook_id", webhook.ID))
+
+	return webhook, nil
+}
+
+// ListWebhooks returns webhooks of app. Zero appID returns webhooks of all apps
+func (w *Webhooks) ListWebhooks(ctx context.Context, appID int) ([]models.Webhook, error) {
+	const op = "Webhooks.ListWebhooks"
+
+	webhooks, err := w.webhookStorage.Webhooks(ctx, appID)
+	if err != nil {
+		return nil, w.webhookError(op, err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook with its pending deliveries
+func (w *Webhooks) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	const op = "Webhooks.DeleteWebhook"
+
+	if err := w.webhookStorage.DeleteWebhook(ctx, webhookID); err != nil {
+		return w.webhookError(op, err)
+	}
+
+	w.log.Info("webhook deleted", slog.String("op", op), slog.Int64("webhook_id", webhookID))
+
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of webhook, newest
+// first. Empty status returns deliveries in any status
+func (w *Webhooks) ListDeliveries(
+	ctx context.Context,
+	webhookID int64,
+	status models.DeliveryStatus,
+) ([]models.WebhookDelivery, error) {
+	const op = "Webhooks.ListDeliveries"
+
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidDeliveryStatus)
+	}
+
+	deliveries, err := w.webhookStorage.WebhookDeliveries(ctx, webhookID, status, deliveriesLimit)
+	if err != nil {
+		return nil, w.webhookError(op, err)
+	}
+
+	return deliveries, nil
+}
+
+// ReplayDeliveries retries deliveries of webhook from scratch. Zero
+// deliveryID replays all dead deliveries, otherwise only the given one.
+// Returns number of replayed deliveries
+func (w *Webhooks) ReplayDeliveries(ctx context.Context, webhookID int64, deliveryID int64) (int64, error) {
+	const op = "Webhooks.ReplayDeliveries"
+
+	n, err := w.webhookStorage.ReplayWebhookDeliveries(ctx, webhookID, deliveryID, time.Now())
+	if err != nil {
+		return 0, w.webhookError(op, err)
+	}
+
+	w.log.Info("webhook deliveries replayed",
+		slog.String("op", op),
+		slog.Int64("webhook_id", webhookID),
+		slog.Int64("delivery_id", deliveryID),
+		slog.Int64("count", n),
+	)
+
+	return n, nil
+}
+
+func (w *Webhooks) webhookError(op string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrAppNotFound):
+		w.log.Warn("app not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	case errors.Is(err, storage.ErrWebhookNotFound):
+		w.log.Warn("webhook not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrWebhookNotFound)
+	case errors.Is(err, storage.ErrWebhookDeliveryNotFound):
+		w.log.Warn("webhook delivery not found", slog.String("op", op), sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+	}
+
+	w.log.Error("storage error", slog.String("op", op), sl.Err(err))
+
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, secretLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
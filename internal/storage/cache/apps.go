@@ -0,0 +1,76 @@
+// Package cache serves hot storage reads from memory. Decorators
+// invalidate cached values on writes made through them; writes made by
+// other instances are seen once the values expire
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/lru"
+)
+
+// AppStorage is storage of apps Apps decorates
+type AppStorage interface {
+	App(ctx context.Context, appID int) (models.App, error)
+	UpdateApp(ctx context.Context, appID int, name string) error
+	RotateAppSecret(ctx context.Context, appID int, secret string, prevExpiresAt time.Time) error
+	DeleteApp(ctx context.Context, appID int) error
+}
+
+// Apps caches apps by id
+type Apps struct {
+	storage AppStorage
+	cache   *lru.Cache[int, models.App]
+}
+
+// NewApps returns decorator keeping up to size apps for ttl
+func NewApps(storage AppStorage, size int, ttl time.Duration) *Apps {
+	return &Apps{
+		storage: storage,
+		cache:   lru.New[int, models.App](size, ttl),
+	}
+}
+
+// App returns app from cache or storage
+func (a *Apps) App(ctx context.Context, appID int) (models.App, error) {
+	if app, ok := a.cache.Get(appID); ok {
+		return app, nil
+	}
+
+	version := a.cache.Version()
+	app, err := a.storage.App(ctx, appID)
+	if err != nil {
+		return models.App{}, err
+	}
+	a.cache.Add(appID, app, version)
+
+	return app, nil
+}
+
+// UpdateApp renames app and invalidates it
+func (a *Apps) UpdateApp(ctx context.Context, appID int, name string) error {
+	defer a.cache.Remove(appID)
+
+	return a.storage.UpdateApp(ctx, appID, name)
+}
+
+// RotateAppSecret replaces secret of app and invalidates it
+func (a *Apps) RotateAppSecret(ctx context.Context, appID int, secret string, prevExpiresAt time.Time) error {
+	defer a.cache.Remove(appID)
+
+	return a.storage.RotateAppSecret(ctx, appID, secret, prevExpiresAt)
+}
+
+// DeleteApp deletes app and invalidates it
+func (a *Apps) DeleteApp(ctx context.Context, appID int) error {
+	defer a.cache.Remove(appID)
+
+	return a.storage.DeleteApp(ctx, appID)
+}
+
+// Stats returns hit and miss counters of the cache
+func (a *Apps) Stats() lru.Stats {
+	return a.cache.Stats()
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/lru"
+	"github.com/m1al04949/sso-gRPC/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStorage(t *testing.T) *memory.Storage {
+	s, err := memory.New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.DBConfig{})
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestApps(t *testing.T) {
+	s := newStorage(t)
+	apps := NewApps(s, 10, time.Minute)
+	ctx := context.Background()
+
+	appID, err := s.SaveApp(ctx, "app", "secret", 0)
+	require.NoError(t, err)
+
+	_, err = apps.App(ctx, appID)
+	require.NoError(t, err)
+	app, err := apps.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", app.Secret)
+
+	require.NoError(t, apps.RotateAppSecret(ctx, appID, "rotated", time.Now().Add(time.Hour)))
+	app, err = apps.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", app.Secret)
+
+	require.NoError(t, apps.UpdateApp(ctx, appID, "renamed"))
+	app, err = apps.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", app.Name)
+
+	assert.Equal(t, lru.Stats{Hits: 1, Misses: 3}, apps.Stats())
+}
+
+func TestUsers(t *testing.T) {
+	s := newStorage(t)
+	users := NewUsers(s, 10, time.Minute)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	user, err := users.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Nil(t, user.PassHash)
+
+	err = users.SetUserStatus(ctx, userID, models.StatusActive, models.StatusSuspended, "abuse", time.Now())
+	require.NoError(t, err)
+	user, err = users.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, user.Disabled)
+	assert.Nil(t, user.PassHash)
+
+	user, err = users.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuspended, user.Status)
+
+	assert.Equal(t, lru.Stats{Hits: 1, Misses: 2}, users.Stats())
+}
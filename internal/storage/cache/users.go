@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/lru"
+)
+
+// UserStorage is storage of users Users decorates. Every write changing
+// a user returned by UserByID is listed here to invalidate it
+type UserStorage interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error
+	SetUserStatus(
+		ctx context.Context,
+		userID int64,
+		from models.AccountStatus,
+		to models.AccountStatus,
+		reason string,
+		now time.Time,
+	) error
+	SetPassResetRequired(ctx context.Context, userID int64, required bool) error
+	SetPassword(ctx context.Context, userID int64, passHash []byte) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	SetUserMFAChannel(ctx context.Context, userID int64, channel string) error
+	EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error
+	ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// Users caches users by id. Users are returned without password hash
+// whether they come from cache or storage. Passwords are checked with
+// users looked up by login, which always read storage, so a password
+// change is effective at once on every instance
+type Users struct {
+	storage UserStorage
+	cache   *lru.Cache[int64, models.User]
+}
+
+// NewUsers returns decorator keeping up to size users for ttl
+func NewUsers(storage UserStorage, size int, ttl time.Duration) *Users {
+	return &Users{
+		storage: storage,
+		cache:   lru.New[int64, models.User](size, ttl),
+	}
+}
+
+// UserByID returns user without password hash from cache or storage
+func (u *Users) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	if user, ok := u.cache.Get(userID); ok {
+		return user, nil
+	}
+
+	version := u.cache.Version()
+	user, err := u.storage.UserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.PassHash = nil
+	u.cache.Add(userID, user, version)
+
+	return user, nil
+}
+
+// SetUserIdentifiers sets username and phone of user and invalidates it
+func (u *Users) SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetUserIdentifiers(ctx, userID, username, phone)
+}
+
+// SetUserStatus changes status of user and invalidates it
+func (u *Users) SetUserStatus(
+	ctx context.Context,
+	userID int64,
+	from models.AccountStatus,
+	to models.AccountStatus,
+	reason string,
+	now time.Time,
+) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetUserStatus(ctx, userID, from, to, reason, now)
+}
+
+// SetPassResetRequired sets password reset flag of user and invalidates it
+func (u *Users) SetPassResetRequired(ctx context.Context, userID int64, required bool) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetPassResetRequired(ctx, userID, required)
+}
+
+// SetPassword sets password of user and invalidates it, as it clears
+// the password reset flag
+func (u *Users) SetPassword(ctx context.Context, userID int64, passHash []byte) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetPassword(ctx, userID, passHash)
+}
+
+// SetAdmin sets admin flag of user and invalidates it
+func (u *Users) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetAdmin(ctx, userID, isAdmin)
+}
+
+// SetUserMFAChannel sets second factor channel of user and invalidates it
+func (u *Users) SetUserMFAChannel(ctx context.Context, userID int64, channel string) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.SetUserMFAChannel(ctx, userID, channel)
+}
+
+// EraseUser erases personal data of user and invalidates it
+func (u *Users) EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error {
+	defer u.cache.Remove(userID)
+
+	return u.storage.EraseUser(ctx, userID, erasedBy, now)
+}
+
+// ConfirmEmailChange sets the new email of user. The change doesn't
+// name its user, so all users are invalidated
+func (u *Users) ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error {
+	defer u.cache.Purge()
+
+	return u.storage.ConfirmEmailChange(ctx, id, now)
+}
+
+// PurgeDeletedUsers deletes users deleted before given time and
+// invalidates all users
+func (u *Users) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer u.cache.Purge()
+
+	return u.storage.PurgeDeletedUsers(ctx, deletedBefore)
+}
+
+// Stats returns hit and miss counters of the cache
+func (u *Users) Stats() lru.Stats {
+	return u.cache.Stats()
+}
@@ -0,0 +1,11 @@
+package storage
+
+import "strings"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike escapes wildcards of s to match it literally in LIKE
+// pattern with ESCAPE '\'
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveApp saving new app. Zero orgID means app doesn't belong to any organization
+func (s *Storage) SaveApp(ctx context.Context, name string, secret string, orgID int64) (int, error) {
+	const op = "storage.memory.SaveApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if orgID != 0 {
+		if _, ok := s.orgs[orgID]; !ok {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+	}
+
+	id := int(s.nextID("apps"))
+	if err := s.insertApp(models.App{ID: id, Name: name, Secret: secret, OrgID: orgID}); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// insertApp stores app checking name and secret are unique. Called with s.mu held
+func (s *Storage) insertApp(app models.App) error {
+	for _, other := range s.apps {
+		if other.ID != app.ID && (other.Name == app.Name || other.Secret == app.Secret) {
+			return storage.ErrAppExists
+		}
+	}
+
+	s.apps[app.ID] = app
+
+	return nil
+}
+
+// Apps returns apps ordered by id. Non-zero orgID returns only apps of the organization
+func (s *Storage) Apps(ctx context.Context, orgID int64) ([]models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []models.App
+	for _, id := range slices.Sorted(maps.Keys(s.apps)) {
+		if orgID == 0 || s.apps[id].OrgID == orgID {
+			apps = append(apps, s.apps[id])
+		}
+	}
+
+	return apps, nil
+}
+
+// UpdateApp renames app
+func (s *Storage) UpdateApp(ctx context.Context, appID int, name string) error {
+	const op = "storage.memory.UpdateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.Name = name
+	if err := s.insertApp(app); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RotateAppSecret replaces app secret with a new one. Current secret
+// is kept as previous until prevExpiresAt
+func (s *Storage) RotateAppSecret(
+	ctx context.Context,
+	appID int,
+	secret string,
+	prevExpiresAt time.Time,
+) error {
+	const op = "storage.memory.RotateAppSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.PrevSecret = app.Secret
+	app.PrevSecretExpiresAt = unix(prevExpiresAt)
+	app.Secret = secret
+	if err := s.insertApp(app); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteApp deletes app with its webhooks, magic links and one-time codes
+func (s *Storage) DeleteApp(ctx context.Context, appID int) error {
+	const op = "storage.memory.DeleteApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[appID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	delete(s.apps, appID)
+
+	for id, webhook := range s.webhooks {
+		if webhook.AppID == appID {
+			s.deleteWebhook(id)
+		}
+	}
+	for id, link := range s.magicLinks {
+		if link.AppID == appID {
+			delete(s.magicLinks, id)
+		}
+	}
+	for id, ch := range s.otpChallenges {
+		if ch.AppID == appID {
+			delete(s.otpChallenges, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// SaveAuditEvent appends event to the audit log. Chained event is linked
+// to the last chained one by hash
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent, chained bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Time = unix(event.Time)
+	event.PrevHash, event.Hash, event.Redacted = "", "", false
+	if chained {
+		for i := len(s.auditEvents) - 1; i >= 0; i-- {
+			if s.auditEvents[i].Hash != "" {
+				event.PrevHash = s.auditEvents[i].Hash
+				break
+			}
+		}
+		event.Hash = event.ChainHash(event.PrevHash)
+	}
+
+	event.ID = int64(len(s.auditEvents)) + 1
+	s.auditEvents = append(s.auditEvents, event)
+
+	return event.ID, nil
+}
+
+// AuditEvents returns audit events matching filter, newest first.
+// Zero beforeID starts from the newest event
+func (s *Storage) AuditEvents(
+	ctx context.Context,
+	filter models.AuditFilter,
+	beforeID int64,
+	limit int,
+) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.AuditEvent
+	for i := len(s.auditEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		event := s.auditEvents[i]
+		if beforeID != 0 && event.ID >= beforeID {
+			continue
+		}
+		if matchAuditEvent(event, filter) {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func matchAuditEvent(event models.AuditEvent, filter models.AuditFilter) bool {
+	switch {
+	case filter.ActorID != 0 && event.ActorID != filter.ActorID,
+		filter.Action != "" && event.Action != filter.Action,
+		filter.Target != "" && event.Target != filter.Target,
+		filter.AppID != 0 && event.AppID != filter.AppID,
+		filter.Result != "" && event.Result != filter.Result,
+		!filter.From.IsZero() && event.Time.Unix() < filter.From.Unix(),
+		!filter.To.IsZero() && event.Time.Unix() >= filter.To.Unix():
+		return false
+	}
+
+	return true
+}
+
+// ChainedAuditEvents returns hash chained audit events in append order
+func (s *Storage) ChainedAuditEvents(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, event := range s.auditEvents {
+		if len(events) == limit {
+			break
+		}
+		if event.ID > afterID && event.Hash != "" {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// UserAuditEvents returns audit events user is the actor or the target of
+func (s *Storage) UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := models.AuditTarget(models.AuditTargetUser, userID)
+
+	var events []models.AuditEvent
+	for _, event := range s.auditEvents {
+		if event.ActorID == userID || event.Target == target {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
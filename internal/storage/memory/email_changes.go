@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type emailChange struct {
+	models.EmailChange
+	codeHash, cancelHash string
+}
+
+// SaveEmailChange saving new email change identified by hashes of its
+// confirmation and cancellation codes. Pending changes of the user
+// are cancelled
+func (s *Storage) SaveEmailChange(
+	ctx context.Context,
+	change models.EmailChange,
+	codeHash string,
+	cancelHash string,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, pending := range s.emailChanges {
+		if pending.UserID == change.UserID && pending.ConfirmedAt.IsZero() && pending.CancelledAt.IsZero() {
+			pending.CancelledAt = unix(change.CreatedAt)
+			s.emailChanges[id] = pending
+		}
+	}
+
+	change.ID = s.nextID("email_changes")
+	change.CreatedAt = unix(change.CreatedAt)
+	change.ExpiresAt = unix(change.ExpiresAt)
+	change.ConfirmedAt, change.CancelledAt = time.Time{}, time.Time{}
+	s.emailChanges[change.ID] = emailChange{EmailChange: change, codeHash: codeHash, cancelHash: cancelHash}
+
+	return change.ID, nil
+}
+
+// EmailChangeByCode returns email change by hash of its confirmation code
+func (s *Storage) EmailChangeByCode(ctx context.Context, codeHash string) (models.EmailChange, error) {
+	const op = "storage.memory.EmailChangeByCode"
+
+	change, err := s.emailChangeBy(func(change emailChange) bool { return change.codeHash == codeHash })
+	if err != nil {
+		return models.EmailChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return change, nil
+}
+
+// EmailChangeByCancelCode returns email change by hash of its cancellation code
+func (s *Storage) EmailChangeByCancelCode(ctx context.Context, cancelHash string) (models.EmailChange, error) {
+	const op = "storage.memory.EmailChangeByCancelCode"
+
+	change, err := s.emailChangeBy(func(change emailChange) bool { return change.cancelHash == cancelHash })
+	if err != nil {
+		return models.EmailChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return change, nil
+}
+
+func (s *Storage) emailChangeBy(match func(emailChange) bool) (models.EmailChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, change := range s.emailChanges {
+		if match(change) {
+			return change.EmailChange, nil
+		}
+	}
+
+	return models.EmailChange{}, storage.ErrEmailChangeNotFound
+}
+
+// UserEmailChanges returns email changes of user ordered by id
+func (s *Storage) UserEmailChanges(ctx context.Context, userID int64) ([]models.EmailChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []models.EmailChange
+	for _, id := range slices.Sorted(maps.Keys(s.emailChanges)) {
+		if change := s.emailChanges[id]; change.UserID == userID {
+			changes = append(changes, change.EmailChange)
+		}
+	}
+
+	return changes, nil
+}
+
+// ConfirmEmailChange marks pending email change as confirmed and sets
+// the new email of user. Returns storage.ErrUserExists if the email
+// is already taken in the user namespace
+func (s *Storage) ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.memory.ConfirmEmailChange"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, ok := s.emailChanges[id]
+	if !ok || !change.ConfirmedAt.IsZero() || !change.CancelledAt.IsZero() ||
+		change.ExpiresAt.Unix() <= now.Unix() {
+		return fmt.Errorf("%s: %w", op, storage.ErrEmailChangeNotFound)
+	}
+
+	user, ok := s.users[change.UserID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if s.emailTaken(user.OrgID, change.NewEmail, user.ID) {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	user.Email = change.NewEmail
+	s.users[user.ID] = user
+
+	change.ConfirmedAt = unix(now)
+	s.emailChanges[id] = change
+
+	return nil
+}
+
+// CancelEmailChange marks pending email change as cancelled
+func (s *Storage) CancelEmailChange(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.memory.CancelEmailChange"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, ok := s.emailChanges[id]
+	if !ok || !change.ConfirmedAt.IsZero() || !change.CancelledAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrEmailChangeNotFound)
+	}
+
+	change.CancelledAt = unix(now)
+	s.emailChanges[id] = change
+
+	return nil
+}
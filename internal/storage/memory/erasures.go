@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// erasedEmail returns email erased user is pseudonymized with
+func erasedEmail(userID int64) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", userID)
+}
+
+// EraseUser irreversibly pseudonymizes user: personal data, roles and
+// memberships are deleted, email is replaced with a pseudonym, password
+// hash is cleared and the user is deleted. The erasure is recorded.
+// Audit events and failed logins keep the fact of action but lose
+// identifiers, ip and user agent of user
+func (s *Storage) EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error {
+	const op = "storage.memory.EraseUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	pseudonym := erasedEmail(userID)
+
+	s.deleteUserData(userID)
+	s.redactUserEvents(userID, pseudonym, user.Email, user.Username, user.Phone)
+
+	for id, inv := range s.invitations {
+		if strings.EqualFold(inv.Email, user.Email) || inv.AcceptedBy == userID {
+			inv.Email = pseudonym
+			s.invitations[id] = inv
+		}
+	}
+
+	user.Email = pseudonym
+	user.Username, user.Phone = "", ""
+	user.PassHash = []byte{}
+	user.IsAdmin, user.Disabled, user.PassResetRequired = false, true, false
+	user.Status = models.StatusDeleted
+	user.StatusReason = models.StatusReasonErased
+	user.StatusChangedAt = unix(now)
+	s.users[userID] = user
+
+	s.erasures[userID] = struct{}{}
+
+	return nil
+}
+
+// redactUserEvents removes personal data of user from the audit log and
+// the outbox. Called with s.mu held
+func (s *Storage) redactUserEvents(userID int64, pseudonym string, identifiers ...string) {
+	target := models.AuditTarget(models.AuditTargetUser, userID)
+
+	isIdentifier := func(value string) bool {
+		for _, identifier := range identifiers {
+			if identifier != "" && strings.EqualFold(value, identifier) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, event := range s.auditEvents {
+		kind, value, _ := strings.Cut(event.Target, ":")
+		byIdentifier := kind == models.AuditTargetIdentifier && isIdentifier(value)
+		if !byIdentifier && event.ActorID != userID {
+			continue
+		}
+		if byIdentifier {
+			event.Target = target
+		}
+		event.IP, event.UserAgent, event.Redacted = "", "", true
+		s.auditEvents[i] = event
+	}
+
+	for i, event := range s.outbox {
+		if event.Type == models.EventUserLoginFailed && isIdentifier(event.Data["login"]) {
+			event.Data = maps.Clone(event.Data)
+			event.Data["login"] = pseudonym
+			s.outbox[i] = event
+		}
+	}
+}
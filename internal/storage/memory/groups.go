@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveGroup saving new group with roles
+func (s *Storage) SaveGroup(ctx context.Context, name string, roles []string) (int64, error) {
+	const op = "storage.memory.SaveGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, group := range s.groups {
+		if group.Name == name {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+	}
+
+	id := s.nextID("groups")
+	s.groups[id] = models.Group{ID: id, Name: name, Roles: sortedRoles(roleSet(roles))}
+
+	return id, nil
+}
+
+// Groups returns all groups with their roles ordered by id
+func (s *Storage) Groups(ctx context.Context) ([]models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var groups []models.Group
+	for _, id := range slices.Sorted(maps.Keys(s.groups)) {
+		groups = append(groups, copyGroup(s.groups[id]))
+	}
+
+	return groups, nil
+}
+
+// UserGroups returns groups user belongs to ordered by name
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := s.userGroups(userID)
+	slices.SortFunc(groups, func(a, b models.Group) int { return strings.Compare(a.Name, b.Name) })
+
+	return groups, nil
+}
+
+// userGroups returns groups user belongs to. Called with s.mu held
+func (s *Storage) userGroups(userID int64) []models.Group {
+	var groups []models.Group
+	for id, group := range s.groups {
+		if _, ok := s.groupMembers[membership{ID: id, UserID: userID}]; ok {
+			groups = append(groups, copyGroup(group))
+		}
+	}
+
+	return groups
+}
+
+// SetGroupRoles replaces all roles of group with given ones
+func (s *Storage) SetGroupRoles(ctx context.Context, groupID int64, roles []string) error {
+	const op = "storage.memory.SetGroupRoles"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[groupID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	group.Roles = sortedRoles(roleSet(roles))
+	s.groups[groupID] = group
+
+	return nil
+}
+
+// DeleteGroup deletes group with its roles and memberships
+func (s *Storage) DeleteGroup(ctx context.Context, groupID int64) error {
+	const op = "storage.memory.DeleteGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.groupMembers {
+		if key.ID == groupID {
+			delete(s.groupMembers, key)
+		}
+	}
+
+	if _, ok := s.groups[groupID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	delete(s.groups, groupID)
+
+	return nil
+}
+
+// SaveGroupMember adds user to group
+func (s *Storage) SaveGroupMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "storage.memory.SaveGroupMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[groupID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	s.groupMembers[membership{ID: groupID, UserID: userID}] = struct{}{}
+
+	return nil
+}
+
+// DeleteGroupMember removes user from group
+func (s *Storage) DeleteGroupMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "storage.memory.DeleteGroupMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := membership{ID: groupID, UserID: userID}
+	if _, ok := s.groupMembers[key]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupMemberNotFound)
+	}
+	delete(s.groupMembers, key)
+
+	return nil
+}
+
+// GroupMembers returns ids of group members
+func (s *Storage) GroupMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	for key := range s.groupMembers {
+		if key.ID == groupID {
+			ids = append(ids, key.UserID)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids, nil
+}
+
+// EffectiveRoles returns roles assigned to user directly and through groups
+func (s *Storage) EffectiveRoles(ctx context.Context, userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.effectiveRoles(userID), nil
+}
+
+// effectiveRoles is EffectiveRoles called with s.mu held
+func (s *Storage) effectiveRoles(userID int64) []string {
+	roles := maps.Clone(s.userRoles[userID])
+	if roles == nil {
+		roles = make(map[string]struct{})
+	}
+	for _, group := range s.userGroups(userID) {
+		for _, role := range group.Roles {
+			roles[role] = struct{}{}
+		}
+	}
+
+	return sortedRoles(roles)
+}
+
+func copyGroup(group models.Group) models.Group {
+	group.Roles = slices.Clone(group.Roles)
+
+	return group
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type invitation struct {
+	models.Invitation
+	codeHash string
+}
+
+// SaveInvitation saving new invitation identified by hash of its code
+func (s *Storage) SaveInvitation(ctx context.Context, inv models.Invitation, codeHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv.ID = s.nextID("invitations")
+	inv.CreatedAt = unix(inv.CreatedAt)
+	inv.ExpiresAt = unix(inv.ExpiresAt)
+	inv.AcceptedAt, inv.AcceptedBy, inv.RevokedAt = time.Time{}, 0, time.Time{}
+	s.invitations[inv.ID] = invitation{Invitation: inv, codeHash: codeHash}
+
+	return inv.ID, nil
+}
+
+// Invitation returns invitation by id
+func (s *Storage) Invitation(ctx context.Context, id int64) (models.Invitation, error) {
+	const op = "storage.memory.Invitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitations[id]
+	if !ok {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	return inv.Invitation, nil
+}
+
+// InvitationByCode returns invitation by hash of its code
+func (s *Storage) InvitationByCode(ctx context.Context, codeHash string) (models.Invitation, error) {
+	const op = "storage.memory.InvitationByCode"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, inv := range s.invitations {
+		if inv.codeHash == codeHash {
+			return inv.Invitation, nil
+		}
+	}
+
+	return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+}
+
+// Invitations returns invitations ordered by id. Non-zero orgID returns
+// only invitations to the organization
+func (s *Storage) Invitations(ctx context.Context, orgID int64) ([]models.Invitation, error) {
+	return s.invitationsBy(func(inv models.Invitation) bool {
+		return orgID == 0 || inv.OrgID == orgID
+	}), nil
+}
+
+// UserInvitations returns invitations sent to email or accepted by user
+func (s *Storage) UserInvitations(ctx context.Context, userID int64, email string) ([]models.Invitation, error) {
+	return s.invitationsBy(func(inv models.Invitation) bool {
+		return strings.EqualFold(inv.Email, email) || inv.AcceptedBy == userID
+	}), nil
+}
+
+func (s *Storage) invitationsBy(match func(models.Invitation) bool) []models.Invitation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var invs []models.Invitation
+	for _, id := range slices.Sorted(maps.Keys(s.invitations)) {
+		if inv := s.invitations[id].Invitation; match(inv) {
+			invs = append(invs, inv)
+		}
+	}
+
+	return invs
+}
+
+// AcceptInvitation marks pending invitation as accepted by user.
+// Returns ErrInvitationNotFound if invitation is not pending anymore
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, userID int64, now time.Time) error {
+	const op = "storage.memory.AcceptInvitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitations[id]
+	if !ok || !inv.AcceptedAt.IsZero() || !inv.RevokedAt.IsZero() || inv.ExpiresAt.Unix() <= now.Unix() {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	inv.AcceptedAt = unix(now)
+	inv.AcceptedBy = userID
+	s.invitations[id] = inv
+
+	return nil
+}
+
+// RevokeInvitation marks pending invitation as revoked
+func (s *Storage) RevokeInvitation(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.memory.RevokeInvitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitations[id]
+	if !ok || !inv.AcceptedAt.IsZero() || !inv.RevokedAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	inv.RevokedAt = unix(now)
+	s.invitations[id] = inv
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// SaveLoginAttempt saving login attempt of user. Attempts of the user
+// made before keepAfter are deleted along the way
+func (s *Storage) SaveLoginAttempt(ctx context.Context, attempt models.LoginAttempt, keepAfter time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, other := range s.loginAttempts {
+		if other.UserID == attempt.UserID && other.CreatedAt.Unix() < keepAfter.Unix() {
+			delete(s.loginAttempts, id)
+		}
+	}
+
+	attempt.ID = s.nextID("login_attempts")
+	attempt.CreatedAt = unix(attempt.CreatedAt)
+	s.loginAttempts[attempt.ID] = attempt
+
+	return attempt.ID, nil
+}
+
+// LoginAttempts returns at most limit attempts of user made since the
+// given time, newest first
+func (s *Storage) LoginAttempts(
+	ctx context.Context,
+	userID int64,
+	since time.Time,
+	limit int,
+) ([]models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []models.LoginAttempt
+	for _, attempt := range s.loginAttempts {
+		if attempt.UserID == userID && attempt.CreatedAt.Unix() >= since.Unix() {
+			attempts = append(attempts, attempt)
+		}
+	}
+	slices.SortFunc(attempts, func(a, b models.LoginAttempt) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	if len(attempts) > limit {
+		attempts = attempts[:limit]
+	}
+
+	return attempts, nil
+}
+
+// UserLoginAttempts returns all kept attempts of user in order they
+// were made
+func (s *Storage) UserLoginAttempts(ctx context.Context, userID int64) ([]models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []models.LoginAttempt
+	for _, id := range slices.Sorted(maps.Keys(s.loginAttempts)) {
+		if attempt := s.loginAttempts[id]; attempt.UserID == userID {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts, nil
+}
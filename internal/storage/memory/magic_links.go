@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type magicLink struct {
+	models.MagicLink
+	codeHash string
+}
+
+// SaveMagicLink saving new magic link identified by hash of its code.
+// Unused links of the user for the app are deleted, so only the latest
+// link works
+func (s *Storage) SaveMagicLink(ctx context.Context, link models.MagicLink, codeHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, other := range s.magicLinks {
+		if other.UserID == link.UserID && other.AppID == link.AppID && other.ConsumedAt.IsZero() {
+			delete(s.magicLinks, id)
+		}
+	}
+
+	link.ID = s.nextID("magic_links")
+	link.CreatedAt = unix(link.CreatedAt)
+	link.ExpiresAt = unix(link.ExpiresAt)
+	link.ConsumedAt = time.Time{}
+	s.magicLinks[link.ID] = magicLink{MagicLink: link, codeHash: codeHash}
+
+	return link.ID, nil
+}
+
+// ConsumeMagicLink marks unused and unexpired magic link of the app as
+// consumed and returns it. Link can be consumed only once, otherwise
+// storage.ErrMagicLinkNotFound is returned
+func (s *Storage) ConsumeMagicLink(
+	ctx context.Context,
+	codeHash string,
+	appID int,
+	now time.Time,
+) (models.MagicLink, error) {
+	const op = "storage.memory.ConsumeMagicLink"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, link := range s.magicLinks {
+		if link.codeHash != codeHash || link.AppID != appID {
+			continue
+		}
+		if !link.ConsumedAt.IsZero() || link.ExpiresAt.Unix() <= now.Unix() {
+			break
+		}
+
+		link.ConsumedAt = unix(now)
+		s.magicLinks[id] = link
+
+		return link.MagicLink, nil
+	}
+
+	return models.MagicLink{}, fmt.Errorf("%s: %w", op, storage.ErrMagicLinkNotFound)
+}
+
+// UserMagicLinks returns magic links of user in order they were sent
+func (s *Storage) UserMagicLinks(ctx context.Context, userID int64) ([]models.MagicLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var links []models.MagicLink
+	for _, id := range slices.Sorted(maps.Keys(s.magicLinks)) {
+		if link := s.magicLinks[id]; link.UserID == userID {
+			links = append(links, link.MagicLink)
+		}
+	}
+
+	return links, nil
+}
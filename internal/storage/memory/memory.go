@@ -0,0 +1,262 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// Storage keeps data in process memory, it is lost when the process
+// stops. Meant for unit tests and ephemeral dev runs. Errors and
+// ordering follow the SQL storages
+type Storage struct {
+	log *slog.Logger
+	// mu guards all data, so every method is atomic like a transaction
+	mu sync.Mutex
+
+	users               map[int64]models.User
+	userRoles           map[int64]map[string]struct{}
+	apps                map[int]models.App
+	orgs                map[int64]models.Org
+	orgMembers          map[membership]string
+	groups              map[int64]models.Group
+	groupMembers        map[membership]struct{}
+	invitations         map[int64]invitation
+	profiles            map[int64]profile
+	emailChanges        map[int64]emailChange
+	erasures            map[int64]struct{}
+	auditEvents         []models.AuditEvent
+	outbox              []outboxEvent
+	webhooks            map[int64]models.Webhook
+	deliveries          map[int64]delivery
+	magicLinks          map[int64]magicLink
+	otpChallenges       map[int64]otpChallenge
+	webAuthnCredentials map[int64]models.WebAuthnCredential
+	webAuthnSessions    map[int64]webAuthnSession
+	loginAttempts       map[int64]models.LoginAttempt
+	trustedDevices      map[int64]trustedDevice
+
+	// lastID holds the last id issued per table
+	lastID map[string]int64
+}
+
+// membership is a key of organization and group members
+type membership struct {
+	ID     int64
+	UserID int64
+}
+
+// New instance of storage. Apps from dbCfg.SeedPath are loaded if it is set
+func New(log *slog.Logger, dbCfg config.DBConfig) (*Storage, error) {
+	const op = "storage.memory.New"
+
+	s := &Storage{
+		log:                 log,
+		users:               make(map[int64]models.User),
+		userRoles:           make(map[int64]map[string]struct{}),
+		apps:                make(map[int]models.App),
+		orgs:                make(map[int64]models.Org),
+		orgMembers:          make(map[membership]string),
+		groups:              make(map[int64]models.Group),
+		groupMembers:        make(map[membership]struct{}),
+		invitations:         make(map[int64]invitation),
+		profiles:            make(map[int64]profile),
+		emailChanges:        make(map[int64]emailChange),
+		erasures:            make(map[int64]struct{}),
+		webhooks:            make(map[int64]models.Webhook),
+		deliveries:          make(map[int64]delivery),
+		magicLinks:          make(map[int64]magicLink),
+		otpChallenges:       make(map[int64]otpChallenge),
+		webAuthnCredentials: make(map[int64]models.WebAuthnCredential),
+		webAuthnSessions:    make(map[int64]webAuthnSession),
+		loginAttempts:       make(map[int64]models.LoginAttempt),
+		trustedDevices:      make(map[int64]trustedDevice),
+		lastID:              make(map[string]int64),
+	}
+
+	if dbCfg.SeedPath != "" {
+		if err := s.loadSeed(dbCfg.SeedPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return s, nil
+}
+
+// nextID issues id of a new row of table
+func (s *Storage) nextID(table string) int64 {
+	s.lastID[table]++
+
+	return s.lastID[table]
+}
+
+// SaveUser saving new user
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.insertUser(0, email, passHash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertOutboxEvent(models.Event{Type: models.EventUserRegistered, UserID: id})
+
+	return id, nil
+}
+
+// SaveOrgUser saving new user with email unique in namespace
+// and makes him a member of organization
+func (s *Storage) SaveOrgUser(
+	ctx context.Context,
+	namespace int64,
+	orgID int64,
+	email string,
+	passHash []byte,
+) (int64, error) {
+	const op = "storage.memory.SaveOrgUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[orgID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+
+	id, err := s.insertUser(namespace, email, passHash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.orgMembers[membership{ID: orgID, UserID: id}] = models.OrgRoleMember
+	s.insertOutboxEvent(models.Event{Type: models.EventUserRegistered, UserID: id, OrgID: orgID})
+
+	return id, nil
+}
+
+func (s *Storage) insertUser(namespace int64, email string, passHash []byte) (int64, error) {
+	if s.emailTaken(namespace, email, 0) {
+		return 0, storage.ErrUserExists
+	}
+
+	id := s.nextID("users")
+	s.users[id] = models.User{
+		ID:       id,
+		OrgID:    namespace,
+		Email:    email,
+		PassHash: slices.Clone(passHash),
+		Status:   models.StatusActive,
+	}
+
+	return id, nil
+}
+
+// emailTaken reports whether other user than exceptID has email in namespace
+func (s *Storage) emailTaken(namespace int64, email string, exceptID int64) bool {
+	canonical := identifiers.CanonicalEmail(email)
+	for _, user := range s.users {
+		if user.ID != exceptID && user.OrgID == namespace && identifiers.CanonicalEmail(user.Email) == canonical {
+			return true
+		}
+	}
+
+	return false
+}
+
+// User returns user by case-insensitive email from the shared namespace
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	user, err := s.namespaceUser(0, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// OrgUser returns user by case-insensitive email from the namespace
+func (s *Storage) OrgUser(ctx context.Context, namespace int64, email string) (models.User, error) {
+	const op = "storage.memory.OrgUser"
+
+	user, err := s.namespaceUser(namespace, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) namespaceUser(namespace int64, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	canonical := identifiers.CanonicalEmail(email)
+	for _, user := range s.users {
+		if user.OrgID == namespace && identifiers.CanonicalEmail(user.Email) == canonical {
+			return copyUser(user), nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+// IsAdmin check user is admin. User is admin if he has admin flag
+// or admin role assigned directly or through a group
+func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if user.IsAdmin {
+		return true, nil
+	}
+
+	for _, role := range s.effectiveRoles(userID) {
+		if role == models.RoleAdmin {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// App returns some info about current app
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "storage.memory.App"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return app, nil
+}
+
+// Close closing storage
+func (s *Storage) Close() {
+	s.log.Info("storage stopped successfully")
+}
+
+// unix drops precision SQL storages don't keep
+func unix(t time.Time) time.Time {
+	return time.Unix(t.Unix(), 0)
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/m1al04949/sso-gRPC/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+apps:
+  - id: 5
+    name: "seeded"
+    secret: "seeded-secret"
+`), 0o600))
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := New(log, config.DBConfig{SeedPath: path})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	app, err := s.App(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "seeded", app.Name)
+	assert.Equal(t, "seeded-secret", app.Secret)
+
+	id, err := s.SaveApp(ctx, "next", "next-secret", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 6, id)
+
+	_, err = s.SaveApp(ctx, "seeded", "other", 0)
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+}
+
+func TestConformance(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		s, err := New(log, config.DBConfig{})
+		require.NoError(t, err)
+
+		return s
+	})
+}
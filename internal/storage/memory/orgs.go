@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveOrg saving new organization
+func (s *Storage) SaveOrg(ctx context.Context, name string) (int64, error) {
+	const op = "storage.memory.SaveOrg"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, org := range s.orgs {
+		if org.Name == name {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+	}
+
+	id := s.nextID("organizations")
+	s.orgs[id] = models.Org{ID: id, Name: name}
+
+	return id, nil
+}
+
+// Org returns organization by id
+func (s *Storage) Org(ctx context.Context, orgID int64) (models.Org, error) {
+	const op = "storage.memory.Org"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, ok := s.orgs[orgID]
+	if !ok {
+		return models.Org{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+
+	return org, nil
+}
+
+// Orgs returns organizations ordered by id. Non-zero userID returns
+// only organizations the user is a member of
+func (s *Storage) Orgs(ctx context.Context, userID int64) ([]models.Org, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orgs []models.Org
+	for _, id := range slices.Sorted(maps.Keys(s.orgs)) {
+		if userID != 0 {
+			if _, ok := s.orgMembers[membership{ID: id, UserID: userID}]; !ok {
+				continue
+			}
+		}
+		orgs = append(orgs, s.orgs[id])
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrg deletes organization with its memberships.
+// Organization that still has apps can't be deleted
+func (s *Storage) DeleteOrg(ctx context.Context, orgID int64) error {
+	const op = "storage.memory.DeleteOrg"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, app := range s.apps {
+		if app.OrgID == orgID {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrgHasApps)
+		}
+	}
+
+	if _, ok := s.orgs[orgID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	delete(s.orgs, orgID)
+
+	for key := range s.orgMembers {
+		if key.ID == orgID {
+			delete(s.orgMembers, key)
+		}
+	}
+
+	return nil
+}
+
+// SaveOrgMember adds user to organization or changes his role
+func (s *Storage) SaveOrgMember(ctx context.Context, member models.OrgMember) error {
+	const op = "storage.memory.SaveOrgMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[member.OrgID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	if _, ok := s.users[member.UserID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	s.orgMembers[membership{ID: member.OrgID, UserID: member.UserID}] = member.Role
+
+	return nil
+}
+
+// OrgMember returns membership of user in organization
+func (s *Storage) OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error) {
+	const op = "storage.memory.OrgMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.orgMembers[membership{ID: orgID, UserID: userID}]
+	if !ok {
+		return models.OrgMember{}, fmt.Errorf("%s: %w", op, storage.ErrOrgMemberNotFound)
+	}
+
+	return models.OrgMember{OrgID: orgID, UserID: userID, Role: role}, nil
+}
+
+// OrgMembers returns members of organization ordered by user id
+func (s *Storage) OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error) {
+	return s.orgMembersBy(func(key membership) bool { return key.ID == orgID }, func(a, b membership) int {
+		return cmp.Compare(a.UserID, b.UserID)
+	}), nil
+}
+
+// UserOrgMembers returns memberships of user in organizations
+func (s *Storage) UserOrgMembers(ctx context.Context, userID int64) ([]models.OrgMember, error) {
+	return s.orgMembersBy(func(key membership) bool { return key.UserID == userID }, func(a, b membership) int {
+		return cmp.Compare(a.ID, b.ID)
+	}), nil
+}
+
+func (s *Storage) orgMembersBy(match func(membership) bool, compare func(a, b membership) int) []models.OrgMember {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []membership
+	for key := range s.orgMembers {
+		if match(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, compare)
+
+	var members []models.OrgMember
+	for _, key := range keys {
+		members = append(members, models.OrgMember{OrgID: key.ID, UserID: key.UserID, Role: s.orgMembers[key]})
+	}
+
+	return members
+}
+
+// DeleteOrgMember removes user from organization
+func (s *Storage) DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.memory.DeleteOrgMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := membership{ID: orgID, UserID: userID}
+	if _, ok := s.orgMembers[key]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgMemberNotFound)
+	}
+	delete(s.orgMembers, key)
+
+	return nil
+}
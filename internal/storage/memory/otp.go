@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type otpChallenge struct {
+	models.OTPChallenge
+	challengeHash string
+}
+
+// SaveOTPChallenge saving new one-time code challenge identified by hash
+// of its id. Unused challenges of the user for the app are deleted, so
+// only the latest code works
+func (s *Storage) SaveOTPChallenge(ctx context.Context, ch models.OTPChallenge, challengeHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, other := range s.otpChallenges {
+		if other.UserID == ch.UserID && other.AppID == ch.AppID && other.ConsumedAt.IsZero() {
+			delete(s.otpChallenges, id)
+		}
+	}
+
+	ch.ID = s.nextID("otp_challenges")
+	ch.Attempts, ch.Sends = 0, 1
+	ch.CreatedAt = unix(ch.CreatedAt)
+	ch.ExpiresAt = unix(ch.ExpiresAt)
+	ch.LastSentAt = unix(ch.LastSentAt)
+	ch.ConsumedAt = time.Time{}
+	s.otpChallenges[ch.ID] = otpChallenge{OTPChallenge: ch, challengeHash: challengeHash}
+
+	return ch.ID, nil
+}
+
+// OTPChallenge returns challenge by hash of its id
+func (s *Storage) OTPChallenge(ctx context.Context, challengeHash string) (models.OTPChallenge, error) {
+	const op = "storage.memory.OTPChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.otpChallenges {
+		if ch.challengeHash == challengeHash {
+			return ch.OTPChallenge, nil
+		}
+	}
+
+	return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+}
+
+// LatestOTPChallenge returns the last challenge sent to user for the app
+func (s *Storage) LatestOTPChallenge(ctx context.Context, userID int64, appID int) (models.OTPChallenge, error) {
+	const op = "storage.memory.LatestOTPChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		latest models.OTPChallenge
+		found  bool
+	)
+	for _, ch := range s.otpChallenges {
+		if ch.UserID != userID || ch.AppID != appID {
+			continue
+		}
+		if !found || ch.LastSentAt.After(latest.LastSentAt) ||
+			(ch.LastSentAt.Equal(latest.LastSentAt) && ch.ID > latest.ID) {
+			latest, found = ch.OTPChallenge, true
+		}
+	}
+	if !found {
+		return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+	}
+
+	return latest, nil
+}
+
+// AddOTPAttempt counts attempt to enter code of unused challenge. Returns
+// storage.ErrOTPChallengeNotFound once maxAttempts are used up
+func (s *Storage) AddOTPAttempt(ctx context.Context, id int64, maxAttempts int) error {
+	const op = "storage.memory.AddOTPAttempt"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.otpChallenges[id]
+	if !ok || !ch.ConsumedAt.IsZero() || ch.Attempts >= maxAttempts {
+		return fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+	}
+
+	ch.Attempts++
+	s.otpChallenges[id] = ch
+
+	return nil
+}
+
+// ResendOTPChallenge replaces code of unused challenge and counts
+// the send
+func (s *Storage) ResendOTPChallenge(ctx context.Context, id int64, codeHash string, now time.Time) error {
+	const op = "storage.memory.ResendOTPChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.otpChallenges[id]
+	if !ok || !ch.ConsumedAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+	}
+
+	ch.CodeHash = codeHash
+	ch.Sends++
+	ch.LastSentAt = unix(now)
+	s.otpChallenges[id] = ch
+
+	return nil
+}
+
+// ConsumeOTPChallenge marks unused and unexpired challenge as consumed.
+// Challenge can be consumed only once, otherwise
+// storage.ErrOTPChallengeNotFound is returned
+func (s *Storage) ConsumeOTPChallenge(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.memory.ConsumeOTPChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.otpChallenges[id]
+	if !ok || !ch.ConsumedAt.IsZero() || ch.ExpiresAt.Unix() <= now.Unix() {
+		return fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+	}
+
+	ch.ConsumedAt = unix(now)
+	s.otpChallenges[id] = ch
+
+	return nil
+}
+
+// UserOTPChallenges returns one-time code challenges of user in order
+// they were created
+func (s *Storage) UserOTPChallenges(ctx context.Context, userID int64) ([]models.OTPChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var challenges []models.OTPChallenge
+	for _, id := range slices.Sorted(maps.Keys(s.otpChallenges)) {
+		if ch := s.otpChallenges[id]; ch.UserID == userID {
+			challenges = append(challenges, ch.OTPChallenge)
+		}
+	}
+
+	return challenges, nil
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+type outboxEvent struct {
+	models.Event
+	relayed bool
+}
+
+// insertOutboxEvent writes event to the outbox. Called with s.mu held
+// by the state change the event is about
+func (s *Storage) insertOutboxEvent(event models.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = unix(event.Time)
+	event.Data = maps.Clone(event.Data)
+	event.ID = int64(len(s.outbox)) + 1
+
+	s.outbox = append(s.outbox, outboxEvent{Event: event})
+}
+
+// SaveEvent writes event which is not caused by a state change to the outbox
+func (s *Storage) SaveEvent(ctx context.Context, event models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertOutboxEvent(event)
+
+	return nil
+}
+
+// UnrelayedEvents returns outbox events not relayed yet in order they were written
+func (s *Storage) UnrelayedEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.Event
+	for _, event := range s.outbox {
+		if len(events) == limit {
+			break
+		}
+		if !event.relayed {
+			events = append(events, copyEvent(event.Event))
+		}
+	}
+
+	return events, nil
+}
+
+// UserLoginEvents returns events of logins and reauthentications of
+// user in order they were written. Each of them started a session
+func (s *Storage) UserLoginEvents(ctx context.Context, userID int64) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.Event
+	for _, event := range s.outbox {
+		if event.UserID != userID {
+			continue
+		}
+		if event.Type == models.EventUserLoggedIn || event.Type == models.EventUserReauthenticated {
+			events = append(events, copyEvent(event.Event))
+		}
+	}
+
+	return events, nil
+}
+
+// RelayEvent marks outbox event relayed and schedules its delivery to webhooks
+func (s *Storage) RelayEvent(ctx context.Context, eventID int64, webhookIDs []int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhookID := range webhookIDs {
+		if s.deliveryExists(webhookID, eventID) {
+			continue
+		}
+
+		id := s.nextID("webhook_deliveries")
+		s.deliveries[id] = delivery{
+			ID:            id,
+			WebhookID:     webhookID,
+			EventID:       eventID,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: unix(now),
+		}
+	}
+
+	if eventID >= 1 && eventID <= int64(len(s.outbox)) {
+		s.outbox[eventID-1].relayed = true
+	}
+
+	return nil
+}
+
+func copyEvent(event models.Event) models.Event {
+	event.Data = maps.Clone(event.Data)
+
+	return event
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// profile keeps metadata encoded as JSON, so values read back have
+// the same types as from SQL storages
+type profile struct {
+	models.Profile
+	metadata, adminMetadata []byte
+}
+
+// UserProfile returns profile of user. User without saved profile
+// has empty one
+func (s *Storage) UserProfile(ctx context.Context, userID int64) (models.Profile, error) {
+	const op = "storage.memory.UserProfile"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return models.Profile{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	stored, ok := s.profiles[userID]
+	if !ok {
+		stored = profile{
+			Profile:       models.Profile{UserID: userID},
+			metadata:      []byte("{}"),
+			adminMetadata: []byte("{}"),
+		}
+	}
+
+	p := stored.Profile
+	if err := json.Unmarshal(stored.metadata, &p.Metadata); err != nil {
+		return models.Profile{}, fmt.Errorf("%s: metadata: %w", op, err)
+	}
+	if err := json.Unmarshal(stored.adminMetadata, &p.AdminMetadata); err != nil {
+		return models.Profile{}, fmt.Errorf("%s: admin metadata: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// SaveUserProfile creates or replaces profile of user
+func (s *Storage) SaveUserProfile(ctx context.Context, p models.Profile) error {
+	const op = "storage.memory.SaveUserProfile"
+
+	metadata, err := marshalMetadata(p.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s: metadata: %w", op, err)
+	}
+	adminMetadata, err := marshalMetadata(p.AdminMetadata)
+	if err != nil {
+		return fmt.Errorf("%s: admin metadata: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[p.UserID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	p.Metadata, p.AdminMetadata = nil, nil
+	s.profiles[p.UserID] = profile{Profile: p, metadata: metadata, adminMetadata: adminMetadata}
+
+	return nil
+}
+
+func marshalMetadata(metadata map[string]any) ([]byte, error) {
+	if metadata == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(metadata)
+}
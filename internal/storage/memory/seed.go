@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// seed is the YAML file storage is filled from at startup
+type seed struct {
+	Apps []seedApp `yaml:"apps"`
+}
+
+// seedApp is an app of seed file. Zero ID takes the next free one
+type seedApp struct {
+	ID     int    `yaml:"id"`
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
+// loadSeed saves apps from seed file at path
+func (s *Storage) loadSeed(path string) error {
+	var file seed
+	if err := cleanenv.ReadConfig(path, &file); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, app := range file.Apps {
+		if app.ID == 0 {
+			app.ID = int(s.nextID("apps"))
+		}
+		if _, ok := s.apps[app.ID]; ok {
+			return storage.ErrAppExists
+		}
+		if int64(app.ID) > s.lastID["apps"] {
+			s.lastID["apps"] = int64(app.ID)
+		}
+
+		if err := s.insertApp(models.App{ID: app.ID, Name: app.Name, Secret: app.Secret}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
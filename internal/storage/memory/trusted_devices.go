@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type trustedDevice struct {
+	models.TrustedDevice
+	tokenHash string
+}
+
+// SaveTrustedDevice saving device of user identified by hash of its
+// token. Expired devices of the user are deleted along the way
+func (s *Storage) SaveTrustedDevice(
+	ctx context.Context,
+	device models.TrustedDevice,
+	tokenHash string,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, other := range s.trustedDevices {
+		if other.UserID == device.UserID && other.ExpiresAt.Unix() <= device.CreatedAt.Unix() {
+			delete(s.trustedDevices, id)
+		}
+	}
+
+	device.ID = s.nextID("trusted_devices")
+	device.CreatedAt = unix(device.CreatedAt)
+	device.ExpiresAt = unix(device.ExpiresAt)
+	device.LastUsedAt = time.Time{}
+	s.trustedDevices[device.ID] = trustedDevice{TrustedDevice: device, tokenHash: tokenHash}
+
+	return device.ID, nil
+}
+
+// UseTrustedDevice marks unexpired device of user with the token hash as
+// used. Returns storage.ErrTrustedDeviceNotFound if there is no such device
+func (s *Storage) UseTrustedDevice(ctx context.Context, userID int64, tokenHash string, now time.Time) error {
+	const op = "storage.memory.UseTrustedDevice"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, device := range s.trustedDevices {
+		if device.UserID == userID && device.tokenHash == tokenHash && device.ExpiresAt.Unix() > now.Unix() {
+			device.LastUsedAt = unix(now)
+			s.trustedDevices[id] = device
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+}
+
+// TrustedDevices returns unexpired devices of user, newest first.
+// Zero now returns expired devices kept so far too
+func (s *Storage) TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var devices []models.TrustedDevice
+	for _, device := range s.trustedDevices {
+		if device.UserID == userID && device.ExpiresAt.Unix() > now.Unix() {
+			devices = append(devices, device.TrustedDevice)
+		}
+	}
+	slices.SortFunc(devices, func(a, b models.TrustedDevice) int { return cmp.Compare(b.ID, a.ID) })
+
+	return devices, nil
+}
+
+// DeleteTrustedDevice deletes device of user
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.memory.DeleteTrustedDevice"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.trustedDevices[id]
+	if !ok || device.UserID != userID {
+		return fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+	}
+	delete(s.trustedDevices, id)
+
+	return nil
+}
+
+// DeleteTrustedDevices deletes all devices of user and returns how many
+// were deleted
+func (s *Storage) DeleteTrustedDevices(ctx context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, device := range s.trustedDevices {
+		if device.UserID == userID {
+			delete(s.trustedDevices, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
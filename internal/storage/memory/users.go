@@ -0,0 +1,425 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// copyUser returns user that doesn't share memory with the stored one
+func copyUser(user models.User) models.User {
+	user.PassHash = slices.Clone(user.PassHash)
+
+	return user
+}
+
+// UserByID returns user by id
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.memory.UserByID"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return copyUser(user), nil
+}
+
+// UserByUsername returns user by canonical username from the namespace
+func (s *Storage) UserByUsername(ctx context.Context, namespace int64, username string) (models.User, error) {
+	const op = "storage.memory.UserByUsername"
+
+	user, err := s.userBy(func(user models.User) bool {
+		return user.OrgID == namespace && user.Username != "" && user.Username == username
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByPhone returns user by canonical phone number from the namespace
+func (s *Storage) UserByPhone(ctx context.Context, namespace int64, phone string) (models.User, error) {
+	const op = "storage.memory.UserByPhone"
+
+	user, err := s.userBy(func(user models.User) bool {
+		return user.OrgID == namespace && user.Phone != "" && user.Phone == phone
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) userBy(match func(models.User) bool) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if match(user) {
+			return copyUser(user), nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+// SetUserIdentifiers sets canonical username and phone number of user.
+// Empty values remove identifiers
+func (s *Storage) SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error {
+	const op = "storage.memory.SetUserIdentifiers"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, other := range s.users {
+		if other.ID == userID || other.OrgID != user.OrgID {
+			continue
+		}
+		if username != "" && other.Username == username {
+			return fmt.Errorf("%s: %w", op, storage.ErrUsernameExists)
+		}
+		if phone != "" && other.Phone == phone {
+			return fmt.Errorf("%s: %w", op, storage.ErrPhoneExists)
+		}
+	}
+
+	user.Username = username
+	user.Phone = phone
+	s.users[userID] = user
+
+	return nil
+}
+
+// Users returns up to limit users with id greater than afterID, ordered by id
+func (s *Storage) Users(
+	ctx context.Context,
+	filter models.UserFilter,
+	afterID int64,
+	limit int,
+) ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []models.User
+	for _, id := range slices.Sorted(maps.Keys(s.users)) {
+		if len(users) == limit {
+			break
+		}
+
+		user := s.users[id]
+		if id <= afterID || !s.matchUser(user, filter) {
+			continue
+		}
+		users = append(users, copyUser(user))
+	}
+
+	return users, nil
+}
+
+func (s *Storage) matchUser(user models.User, filter models.UserFilter) bool {
+	if filter.Email != "" && !strings.Contains(strings.ToLower(user.Email), strings.ToLower(filter.Email)) {
+		return false
+	}
+	if filter.IsAdmin != nil && user.IsAdmin != *filter.IsAdmin {
+		return false
+	}
+	if filter.Disabled != nil && user.Disabled != *filter.Disabled {
+		return false
+	}
+	if filter.Status != "" && user.Status != filter.Status {
+		return false
+	}
+	if filter.OrgID != 0 {
+		if _, ok := s.orgMembers[membership{ID: filter.OrgID, UserID: user.ID}]; !ok {
+			return false
+		}
+	}
+	if filter.Role != "" {
+		if _, ok := s.userRoles[user.ID][filter.Role]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UserRoles returns roles assigned to user
+func (s *Storage) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedRoles(s.userRoles[userID]), nil
+}
+
+// SetUserStatus moves user from status from to status to. Returns
+// storage.ErrStatusConflict if user status is not from anymore.
+// Users are disabled unless they are active
+func (s *Storage) SetUserStatus(
+	ctx context.Context,
+	userID int64,
+	from models.AccountStatus,
+	to models.AccountStatus,
+	reason string,
+	now time.Time,
+) error {
+	const op = "storage.memory.SetUserStatus"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if user.Status != from {
+		return fmt.Errorf("%s: %w", op, storage.ErrStatusConflict)
+	}
+
+	user.Status = to
+	user.StatusReason = reason
+	user.StatusChangedAt = unix(now)
+	user.Disabled = to != models.StatusActive
+	s.users[userID] = user
+
+	s.insertOutboxEvent(models.Event{
+		Type:   models.EventUserStatusChanged,
+		Time:   now,
+		UserID: userID,
+		OrgID:  user.OrgID,
+		Data:   map[string]string{"from": string(from), "to": string(to), "reason": reason},
+	})
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users deleted before given time.
+// Erased users are kept. Returns number of deleted users
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, user := range s.users {
+		if user.Status != models.StatusDeleted || user.StatusChangedAt.IsZero() ||
+			user.StatusChangedAt.Unix() >= deletedBefore.Unix() {
+			continue
+		}
+		if _, erased := s.erasures[id]; erased {
+			continue
+		}
+
+		s.deleteUserData(id)
+		delete(s.users, id)
+		n++
+	}
+
+	return n, nil
+}
+
+// SetPassResetRequired marks that user has to reset password before next login
+func (s *Storage) SetPassResetRequired(ctx context.Context, userID int64, required bool) error {
+	const op = "storage.memory.SetPassResetRequired"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.updateUser(userID, func(user *models.User) { user.PassResetRequired = required })
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if required {
+		s.insertOutboxEvent(models.Event{
+			Type:   models.EventUserPassResetRequired,
+			UserID: userID,
+			OrgID:  s.users[userID].OrgID,
+		})
+	}
+
+	return nil
+}
+
+// SetPassword replaces password hash of user and clears the password
+// reset flag
+func (s *Storage) SetPassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.memory.SetPassword"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.updateUser(userID, func(user *models.User) {
+		user.PassHash = slices.Clone(passHash)
+		user.PassResetRequired = false
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertOutboxEvent(models.Event{Type: models.EventUserPasswordChanged, UserID: userID, OrgID: s.users[userID].OrgID})
+
+	return nil
+}
+
+// SetAdmin grants or revokes admin flag
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.memory.SetAdmin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.updateUser(userID, func(user *models.User) { user.IsAdmin = isAdmin }); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetUserMFAChannel sets channel of second factor codes, empty channel
+// turns second factor off
+func (s *Storage) SetUserMFAChannel(ctx context.Context, userID int64, channel string) error {
+	const op = "storage.memory.SetUserMFAChannel"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.updateUser(userID, func(user *models.User) { user.MFAChannel = channel }); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetUserRoles replaces all roles of user with given ones
+func (s *Storage) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	const op = "storage.memory.SetUserRoles"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	s.userRoles[userID] = roleSet(roles)
+
+	return nil
+}
+
+// AddUserRole assigns role to user keeping his other roles
+func (s *Storage) AddUserRole(ctx context.Context, userID int64, role string) error {
+	const op = "storage.memory.AddUserRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if s.userRoles[userID] == nil {
+		s.userRoles[userID] = make(map[string]struct{})
+	}
+	s.userRoles[userID][role] = struct{}{}
+
+	return nil
+}
+
+// deleteUserData deletes data referencing user
+func (s *Storage) deleteUserData(userID int64) {
+	delete(s.userRoles, userID)
+	delete(s.profiles, userID)
+
+	for key := range s.orgMembers {
+		if key.UserID == userID {
+			delete(s.orgMembers, key)
+		}
+	}
+	for key := range s.groupMembers {
+		if key.UserID == userID {
+			delete(s.groupMembers, key)
+		}
+	}
+	for id, change := range s.emailChanges {
+		if change.UserID == userID {
+			delete(s.emailChanges, id)
+		}
+	}
+	for id, link := range s.magicLinks {
+		if link.UserID == userID {
+			delete(s.magicLinks, id)
+		}
+	}
+	for id, ch := range s.otpChallenges {
+		if ch.UserID == userID {
+			delete(s.otpChallenges, id)
+		}
+	}
+	for id, cred := range s.webAuthnCredentials {
+		if cred.UserID == userID {
+			delete(s.webAuthnCredentials, id)
+		}
+	}
+	for id, session := range s.webAuthnSessions {
+		if session.UserID == userID {
+			delete(s.webAuthnSessions, id)
+		}
+	}
+	for id, attempt := range s.loginAttempts {
+		if attempt.UserID == userID {
+			delete(s.loginAttempts, id)
+		}
+	}
+	for id, device := range s.trustedDevices {
+		if device.UserID == userID {
+			delete(s.trustedDevices, id)
+		}
+	}
+}
+
+// updateUser applies change to user. Called with s.mu held
+func (s *Storage) updateUser(userID int64, change func(*models.User)) error {
+	user, ok := s.users[userID]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+
+	change(&user)
+	s.users[userID] = user
+
+	return nil
+}
+
+func roleSet(roles []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(roles))
+	for _, role := range roles {
+		set[role] = struct{}{}
+	}
+
+	return set
+}
+
+// sortedRoles returns roles of set ordered by name, nil for empty set
+func sortedRoles(set map[string]struct{}) []string {
+	if len(set) == 0 {
+		return nil
+	}
+
+	return slices.Sorted(maps.Keys(set))
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+type webAuthnSession struct {
+	models.WebAuthnSession
+	sessionHash string
+}
+
+// SaveWebAuthnCredential saving new passkey of user. Returns
+// storage.ErrWebAuthnCredentialExists if credential id is registered
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (int64, error) {
+	const op = "storage.memory.SaveWebAuthnCredential"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.webAuthnCredentials {
+		if bytes.Equal(other.CredentialID, cred.CredentialID) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialExists)
+		}
+	}
+
+	cred.ID = s.nextID("webauthn_credentials")
+	cred.CreatedAt = unix(cred.CreatedAt)
+	cred.LastUsedAt = time.Time{}
+	s.webAuthnCredentials[cred.ID] = copyWebAuthnCredential(cred)
+
+	return cred.ID, nil
+}
+
+// WebAuthnCredentials returns passkeys of user ordered by id
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var creds []models.WebAuthnCredential
+	for _, id := range slices.Sorted(maps.Keys(s.webAuthnCredentials)) {
+		if cred := s.webAuthnCredentials[id]; cred.UserID == userID {
+			creds = append(creds, copyWebAuthnCredential(cred))
+		}
+	}
+
+	return creds, nil
+}
+
+// UpdateWebAuthnCredentialUse saves signature counter and backup state
+// reported by the last assertion of passkey. Counter only grows, so
+// concurrent assertions by a cloned authenticator don't roll it back
+func (s *Storage) UpdateWebAuthnCredentialUse(
+	ctx context.Context,
+	id int64,
+	signCount uint32,
+	backupState bool,
+	now time.Time,
+) error {
+	const op = "storage.memory.UpdateWebAuthnCredentialUse"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.webAuthnCredentials[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialNotFound)
+	}
+
+	cred.SignCount = max(cred.SignCount, signCount)
+	cred.BackupState = backupState
+	cred.LastUsedAt = unix(now)
+	s.webAuthnCredentials[id] = cred
+
+	return nil
+}
+
+// DeleteWebAuthnCredential deletes passkey of user
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.memory.DeleteWebAuthnCredential"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.webAuthnCredentials[id]
+	if !ok || cred.UserID != userID {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialNotFound)
+	}
+	delete(s.webAuthnCredentials, id)
+
+	return nil
+}
+
+// SaveWebAuthnSession saving state of started ceremony identified by
+// hash of its id. Expired sessions are deleted along the way
+func (s *Storage) SaveWebAuthnSession(
+	ctx context.Context,
+	session models.WebAuthnSession,
+	sessionHash string,
+	now time.Time,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, other := range s.webAuthnSessions {
+		if other.ExpiresAt.Unix() <= now.Unix() {
+			delete(s.webAuthnSessions, id)
+		}
+	}
+
+	session.ID = s.nextID("webauthn_sessions")
+	session.Data = slices.Clone(session.Data)
+	session.ExpiresAt = unix(session.ExpiresAt)
+	s.webAuthnSessions[session.ID] = webAuthnSession{WebAuthnSession: session, sessionHash: sessionHash}
+
+	return session.ID, nil
+}
+
+// TakeWebAuthnSession deletes unexpired session by hash of its id and
+// returns it. Session can be taken only once, otherwise
+// storage.ErrWebAuthnSessionNotFound is returned
+func (s *Storage) TakeWebAuthnSession(
+	ctx context.Context,
+	sessionHash string,
+	now time.Time,
+) (models.WebAuthnSession, error) {
+	const op = "storage.memory.TakeWebAuthnSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.webAuthnSessions {
+		if session.sessionHash != sessionHash {
+			continue
+		}
+		delete(s.webAuthnSessions, id)
+
+		if !now.Before(session.ExpiresAt) {
+			break
+		}
+
+		return session.WebAuthnSession, nil
+	}
+
+	return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+}
+
+func copyWebAuthnCredential(cred models.WebAuthnCredential) models.WebAuthnCredential {
+	cred.CredentialID = slices.Clone(cred.CredentialID)
+	cred.PublicKey = slices.Clone(cred.PublicKey)
+	cred.AAGUID = slices.Clone(cred.AAGUID)
+	cred.Transports = slices.Clone(cred.Transports)
+
+	return cred
+}
@@ -0,0 +1,241 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// delivery is a webhook delivery referencing its outbox event by id
+type delivery struct {
+	ID            int64
+	WebhookID     int64
+	EventID       int64
+	Status        models.DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	DeliveredAt   time.Time
+}
+
+// SaveWebhook saves webhook of app
+func (s *Storage) SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error) {
+	const op = "storage.memory.SaveWebhook"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[webhook.AppID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	webhook.ID = s.nextID("webhooks")
+	webhook.EventTypes = slices.Clone(webhook.EventTypes)
+	if len(webhook.EventTypes) == 0 {
+		webhook.EventTypes = nil
+	}
+	webhook.CreatedAt = unix(webhook.CreatedAt)
+	s.webhooks[webhook.ID] = webhook
+
+	return webhook.ID, nil
+}
+
+// Webhooks returns webhooks ordered by id. Zero appID returns webhooks of all apps
+func (s *Storage) Webhooks(ctx context.Context, appID int) ([]models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var webhooks []models.Webhook
+	for _, id := range slices.Sorted(maps.Keys(s.webhooks)) {
+		webhook := s.webhooks[id]
+		if appID == 0 || webhook.AppID == appID {
+			webhook.OrgID = s.apps[webhook.AppID].OrgID
+			webhook.EventTypes = slices.Clone(webhook.EventTypes)
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook with its deliveries
+func (s *Storage) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	const op = "storage.memory.DeleteWebhook"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[webhookID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+	s.deleteWebhook(webhookID)
+
+	return nil
+}
+
+// deleteWebhook deletes webhook with its deliveries. Called with s.mu held
+func (s *Storage) deleteWebhook(webhookID int64) {
+	delete(s.webhooks, webhookID)
+
+	for id, d := range s.deliveries {
+		if d.WebhookID == webhookID {
+			delete(s.deliveries, id)
+		}
+	}
+}
+
+// DueWebhookDeliveries returns pending deliveries which next attempt is due
+func (s *Storage) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []delivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && d.NextAttemptAt.Unix() <= now.Unix() {
+			due = append(due, d)
+		}
+	}
+	slices.SortFunc(due, func(a, b delivery) int {
+		if c := a.NextAttemptAt.Compare(b.NextAttemptAt); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return s.withEvents(due, limit), nil
+}
+
+// WebhookDeliveries returns the latest deliveries of webhook, newest
+// first. Empty status returns deliveries in any status
+func (s *Storage) WebhookDeliveries(
+	ctx context.Context,
+	webhookID int64,
+	status models.DeliveryStatus,
+	limit int,
+) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []delivery
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			found = append(found, d)
+		}
+	}
+	slices.SortFunc(found, func(a, b delivery) int { return cmp.Compare(b.ID, a.ID) })
+
+	return s.withEvents(found, limit), nil
+}
+
+// withEvents returns up to limit deliveries joined with their events.
+// Deliveries of missing events are skipped. Called with s.mu held
+func (s *Storage) withEvents(found []delivery, limit int) []models.WebhookDelivery {
+	var deliveries []models.WebhookDelivery
+	for _, d := range found {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.EventID < 1 || d.EventID > int64(len(s.outbox)) {
+			continue
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            d.ID,
+			WebhookID:     d.WebhookID,
+			Event:         copyEvent(s.outbox[d.EventID-1].Event),
+			Status:        d.Status,
+			Attempts:      d.Attempts,
+			NextAttemptAt: d.NextAttemptAt,
+			LastError:     d.LastError,
+			DeliveredAt:   d.DeliveredAt,
+		})
+	}
+
+	return deliveries
+}
+
+// deliveryExists reports whether event is scheduled for webhook. Called with s.mu held
+func (s *Storage) deliveryExists(webhookID int64, eventID int64) bool {
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && d.EventID == eventID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateWebhookDelivery saves result of delivery attempt
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, update models.WebhookDelivery) error {
+	const op = "storage.memory.UpdateWebhookDelivery"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[update.ID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+
+	d.Status = update.Status
+	d.Attempts = update.Attempts
+	d.NextAttemptAt = unix(update.NextAttemptAt)
+	d.LastError = update.LastError
+	d.DeliveredAt = time.Time{}
+	if !update.DeliveredAt.IsZero() {
+		d.DeliveredAt = unix(update.DeliveredAt)
+	}
+	s.deliveries[d.ID] = d
+
+	return nil
+}
+
+// ReplayWebhookDeliveries schedules deliveries of webhook to be retried
+// from scratch. Zero deliveryID replays all dead deliveries, otherwise
+// only the given delivery in any status. Returns number of replayed deliveries
+func (s *Storage) ReplayWebhookDeliveries(
+	ctx context.Context,
+	webhookID int64,
+	deliveryID int64,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.memory.ReplayWebhookDeliveries"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[webhookID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	var n int64
+	for id, d := range s.deliveries {
+		if d.WebhookID != webhookID {
+			continue
+		}
+		if (deliveryID != 0 || d.Status != models.DeliveryDead) && id != deliveryID {
+			continue
+		}
+
+		d.Status = models.DeliveryPending
+		d.Attempts = 0
+		d.NextAttemptAt = unix(now)
+		d.LastError = ""
+		d.DeliveredAt = time.Time{}
+		s.deliveries[id] = d
+		n++
+	}
+
+	if deliveryID != 0 && n == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+const auditColumns = "id, time, actor_id, action, target, app_id, ip, user_agent, result, prev_hash, hash, redacted"
+
+// auditChainLock is key of advisory lock held while appending to the chain
+const auditChainLock = 0x61756474
+
+// SaveAuditEvent appends event to the audit log. Chained event is linked
+// to the last chained one by hash
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent, chained bool) (int64, error) {
+	const op = "storage.postgres.SaveAuditEvent"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if chained {
+		// Advisory lock serializes appends to the chain across instances
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err := tx.QueryRowContext(ctx,
+			"SELECT hash FROM audit_events WHERE hash != '' ORDER BY id DESC LIMIT 1").Scan(&event.PrevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		event.Hash = event.ChainHash(event.PrevHash)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_events(time, actor_id, action, target, app_id, ip, user_agent, result, prev_hash, hash)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		event.Time.Unix(), event.ActorID, event.Action, event.Target, event.AppID,
+		event.IP, event.UserAgent, event.Result, event.PrevHash, event.Hash).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// AuditEvents returns audit events matching filter, newest first.
+// Zero beforeID starts from the newest event
+func (s *Storage) AuditEvents(
+	ctx context.Context,
+	filter models.AuditFilter,
+	beforeID int64,
+	limit int,
+) ([]models.AuditEvent, error) {
+	const op = "storage.postgres.AuditEvents"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	if beforeID != 0 {
+		where = append(where, "id < "+arg(&args, beforeID))
+	}
+	if filter.ActorID != 0 {
+		where = append(where, "actor_id = "+arg(&args, filter.ActorID))
+	}
+	if filter.Action != "" {
+		where = append(where, "action = "+arg(&args, filter.Action))
+	}
+	if filter.Target != "" {
+		where = append(where, "target = "+arg(&args, filter.Target))
+	}
+	if filter.AppID != 0 {
+		where = append(where, "app_id = "+arg(&args, filter.AppID))
+	}
+	if filter.Result != "" {
+		where = append(where, "result = "+arg(&args, filter.Result))
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "time >= "+arg(&args, filter.From.Unix()))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "time < "+arg(&args, filter.To.Unix()))
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(&args, limit)
+
+	events, err := s.queryAuditEvents(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// ChainedAuditEvents returns hash chained audit events in append order
+func (s *Storage) ChainedAuditEvents(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	const op = "storage.postgres.ChainedAuditEvents"
+
+	events, err := s.queryAuditEvents(ctx,
+		"SELECT "+auditColumns+" FROM audit_events WHERE id > $1 AND hash != '' ORDER BY id LIMIT $2",
+		afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// UserAuditEvents returns audit events user is the actor or the target of
+func (s *Storage) UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error) {
+	const op = "storage.postgres.UserAuditEvents"
+
+	events, err := s.queryAuditEvents(ctx,
+		"SELECT "+auditColumns+" FROM audit_events WHERE actor_id = $1 OR target = $2 ORDER BY id",
+		userID, models.AuditTarget(models.AuditTargetUser, userID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) queryAuditEvents(ctx context.Context, query string, args ...any) ([]models.AuditEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var (
+			event models.AuditEvent
+			ts    int64
+		)
+		err := rows.Scan(&event.ID, &ts, &event.ActorID, &event.Action, &event.Target, &event.AppID,
+			&event.IP, &event.UserAgent, &event.Result, &event.PrevHash, &event.Hash, &event.Redacted)
+		if err != nil {
+			return nil, err
+		}
+		event.Time = time.Unix(ts, 0)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const emailChangeColumns = `id, user_id, old_email, new_email, created_at, expires_at, confirmed_at, cancelled_at`
+
+func scanEmailChange(row scanner) (models.EmailChange, error) {
+	var (
+		change                   models.EmailChange
+		createdAt, expiresAt     int64
+		confirmedAt, cancelledAt sql.NullInt64
+	)
+
+	err := row.Scan(&change.ID, &change.UserID, &change.OldEmail, &change.NewEmail,
+		&createdAt, &expiresAt, &confirmedAt, &cancelledAt)
+	if err != nil {
+		return models.EmailChange{}, err
+	}
+
+	change.CreatedAt = time.Unix(createdAt, 0)
+	change.ExpiresAt = time.Unix(expiresAt, 0)
+	change.ConfirmedAt = unixOrZero(confirmedAt)
+	change.CancelledAt = unixOrZero(cancelledAt)
+
+	return change, nil
+}
+
+// SaveEmailChange saving new email change identified by hashes of its
+// confirmation and cancellation codes. Pending changes of the user
+// are cancelled
+func (s *Storage) SaveEmailChange(
+	ctx context.Context,
+	change models.EmailChange,
+	codeHash string,
+	cancelHash string,
+) (int64, error) {
+	const op = "storage.postgres.SaveEmailChange"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE email_changes SET cancelled_at = $1
+		WHERE user_id = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL`,
+		change.CreatedAt.Unix(), change.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO email_changes(user_id, old_email, new_email, code_hash, cancel_hash, created_at, expires_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		change.UserID, change.OldEmail, change.NewEmail, codeHash, cancelHash,
+		change.CreatedAt.Unix(), change.ExpiresAt.Unix()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// EmailChangeByCode returns email change by hash of its confirmation code
+func (s *Storage) EmailChangeByCode(ctx context.Context, codeHash string) (models.EmailChange, error) {
+	const op = "storage.postgres.EmailChangeByCode"
+
+	change, err := s.emailChangeBy(ctx, "code_hash", codeHash)
+	if err != nil {
+		return models.EmailChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return change, nil
+}
+
+// EmailChangeByCancelCode returns email change by hash of its cancellation code
+func (s *Storage) EmailChangeByCancelCode(ctx context.Context, cancelHash string) (models.EmailChange, error) {
+	const op = "storage.postgres.EmailChangeByCancelCode"
+
+	change, err := s.emailChangeBy(ctx, "cancel_hash", cancelHash)
+	if err != nil {
+		return models.EmailChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return change, nil
+}
+
+func (s *Storage) emailChangeBy(ctx context.Context, column string, hash string) (models.EmailChange, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+emailChangeColumns+" FROM email_changes WHERE "+column+" = $1", hash)
+
+	change, err := scanEmailChange(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.EmailChange{}, storage.ErrEmailChangeNotFound
+		}
+
+		return models.EmailChange{}, err
+	}
+
+	return change, nil
+}
+
+// UserEmailChanges returns email changes of user ordered by id
+func (s *Storage) UserEmailChanges(ctx context.Context, userID int64) ([]models.EmailChange, error) {
+	const op = "storage.postgres.UserEmailChanges"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+emailChangeColumns+" FROM email_changes WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var changes []models.EmailChange
+	for rows.Next() {
+		change, err := scanEmailChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changes, nil
+}
+
+// ConfirmEmailChange marks pending email change as confirmed and sets
+// the new email of user. Returns storage.ErrUserExists if the email
+// is already taken in the user namespace
+func (s *Storage) ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.postgres.ConfirmEmailChange"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE email_changes SET confirmed_at = $1
+		WHERE id = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > $3`,
+		now.Unix(), id, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrEmailChangeNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		userID   int64
+		newEmail string
+	)
+	err = tx.QueryRowContext(ctx,
+		"SELECT user_id, new_email FROM email_changes WHERE id = $1", id).Scan(&userID, &newEmail)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err = tx.ExecContext(ctx,
+		"UPDATE users SET email = $1, email_canonical = $2 WHERE id = $3",
+		newEmail, identifiers.CanonicalEmail(newEmail), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
+	if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelEmailChange marks pending email change as cancelled
+func (s *Storage) CancelEmailChange(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.postgres.CancelEmailChange"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE email_changes SET cancelled_at = $1
+		WHERE id = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL`, now.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrEmailChangeNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// erasedEmail returns email erased user is pseudonymized with
+func erasedEmail(userID int64) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", userID)
+}
+
+// EraseUser irreversibly pseudonymizes user: personal data, roles and
+// memberships are deleted, email is replaced with a pseudonym, password
+// hash is cleared and the user is deleted. The erasure is recorded.
+// Audit events and failed logins keep the fact of action but lose
+// identifiers, ip and user agent of user
+func (s *Storage) EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error {
+	const op = "storage.postgres.EraseUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		email           string
+		username, phone sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, "SELECT email, username, phone FROM users WHERE id = $1", userID).Scan(&email, &username, &phone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	pseudonym := erasedEmail(userID)
+
+	if err := deleteUserData(ctx, tx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	identifiers := []string{email}
+	for _, identifier := range []sql.NullString{username, phone} {
+		if identifier.String != "" {
+			identifiers = append(identifiers, identifier.String)
+		}
+	}
+	if err := redactUserEvents(ctx, tx, userID, pseudonym, identifiers); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE invitations SET email = $1 WHERE lower(email) = lower($2) OR accepted_by = $3",
+		pseudonym, email, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET email = $1, email_canonical = $2, username = NULL, phone = NULL, pass_hash = ''::bytea,
+			is_admin = FALSE, disabled = TRUE, pass_reset_required = FALSE,
+			status = $3, status_reason = $4, status_changed_at = $5
+		WHERE id = $6`,
+		pseudonym, pseudonym, models.StatusDeleted, models.StatusReasonErased, now.Unix(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO erasures(user_id, erased_by, erased_at) VALUES($1, $2, $3) ON CONFLICT(user_id) DO NOTHING",
+		userID, erasedBy, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// redactUserEvents removes personal data of user from the audit log and
+// the outbox. Audit targets naming user by identifier are replaced with
+// user id, ip and user agent of events user acted in are cleared. Login
+// of failed logins is replaced with pseudonym
+func redactUserEvents(ctx context.Context, tx *sql.Tx, userID int64, pseudonym string, identifiers []string) error {
+	target := models.AuditTarget(models.AuditTargetUser, userID)
+
+	for _, identifier := range identifiers {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE audit_events SET target = $1, ip = '', user_agent = '', redacted = TRUE
+			WHERE lower(target) = lower($2)`,
+			target, models.AuditTarget(models.AuditTargetIdentifier, identifier))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE outbox_events SET data = jsonb_set(data::jsonb, '{login}', to_jsonb($1::text))::text
+			WHERE type = $2 AND lower(data::jsonb->>'login') = lower($3)`,
+			pseudonym, models.EventUserLoginFailed, identifier)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"UPDATE audit_events SET ip = '', user_agent = '', redacted = TRUE WHERE actor_id = $1", userID)
+
+	return err
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// SaveLoginAttempt saving login attempt of user. Attempts of the user
+// made before keepAfter are deleted along the way
+func (s *Storage) SaveLoginAttempt(ctx context.Context, attempt models.LoginAttempt, keepAfter time.Time) (int64, error) {
+	const op = "storage.postgres.SaveLoginAttempt"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE user_id = $1 AND created_at < $2", attempt.UserID, keepAfter.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO login_attempts(user_id, app_id, device_hash, ip, user_agent, succeeded, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		attempt.UserID, attempt.AppID, attempt.DeviceHash, attempt.IP, attempt.UserAgent,
+		attempt.Succeeded, attempt.CreatedAt.Unix()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const loginAttemptColumns = "id, user_id, app_id, device_hash, ip, user_agent, succeeded, created_at"
+
+// LoginAttempts returns at most limit attempts of user made since the
+// given time, newest first
+func (s *Storage) LoginAttempts(
+	ctx context.Context,
+	userID int64,
+	since time.Time,
+	limit int,
+) ([]models.LoginAttempt, error) {
+	const op = "storage.postgres.LoginAttempts"
+
+	attempts, err := s.queryLoginAttempts(ctx, `
+		SELECT `+loginAttemptColumns+` FROM login_attempts WHERE user_id = $1 AND created_at >= $2
+		ORDER BY created_at DESC, id DESC LIMIT $3`, userID, since.Unix(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+// UserLoginAttempts returns all kept attempts of user in order they
+// were made
+func (s *Storage) UserLoginAttempts(ctx context.Context, userID int64) ([]models.LoginAttempt, error) {
+	const op = "storage.postgres.UserLoginAttempts"
+
+	attempts, err := s.queryLoginAttempts(ctx,
+		"SELECT "+loginAttemptColumns+" FROM login_attempts WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) queryLoginAttempts(ctx context.Context, query string, args ...any) ([]models.LoginAttempt, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var (
+			attempt   models.LoginAttempt
+			createdAt int64
+		)
+		err := rows.Scan(&attempt.ID, &attempt.UserID, &attempt.AppID, &attempt.DeviceHash,
+			&attempt.IP, &attempt.UserAgent, &attempt.Succeeded, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		attempt.CreatedAt = time.Unix(createdAt, 0)
+
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
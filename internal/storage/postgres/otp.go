@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const otpChallengeColumns = `id, user_id, app_id, purpose, channel, code_hash, attempts, sends,
+	created_at, expires_at, last_sent_at, consumed_at`
+
+func scanOTPChallenge(row scanner) (models.OTPChallenge, error) {
+	var (
+		ch                               models.OTPChallenge
+		createdAt, expiresAt, lastSentAt int64
+		consumedAt                       sql.NullInt64
+	)
+
+	err := row.Scan(&ch.ID, &ch.UserID, &ch.AppID, &ch.Purpose, &ch.Channel, &ch.CodeHash,
+		&ch.Attempts, &ch.Sends, &createdAt, &expiresAt, &lastSentAt, &consumedAt)
+	if err != nil {
+		return models.OTPChallenge{}, err
+	}
+
+	ch.CreatedAt = time.Unix(createdAt, 0)
+	ch.ExpiresAt = time.Unix(expiresAt, 0)
+	ch.LastSentAt = time.Unix(lastSentAt, 0)
+	ch.ConsumedAt = unixOrZero(consumedAt)
+
+	return ch, nil
+}
+
+// SaveOTPChallenge saving new one-time code challenge identified by hash
+// of its id. Unused challenges of the user for the app are deleted, so
+// only the latest code works
+func (s *Storage) SaveOTPChallenge(ctx context.Context, ch models.OTPChallenge, challengeHash string) (int64, error) {
+	const op = "storage.postgres.SaveOTPChallenge"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM otp_challenges WHERE user_id = $1 AND app_id = $2 AND consumed_at IS NULL",
+		ch.UserID, ch.AppID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO otp_challenges(challenge_hash, user_id, app_id, purpose, channel, code_hash,
+			created_at, expires_at, last_sent_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		challengeHash, ch.UserID, ch.AppID, ch.Purpose, ch.Channel, ch.CodeHash,
+		ch.CreatedAt.Unix(), ch.ExpiresAt.Unix(), ch.LastSentAt.Unix()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// OTPChallenge returns challenge by hash of its id
+func (s *Storage) OTPChallenge(ctx context.Context, challengeHash string) (models.OTPChallenge, error) {
+	const op = "storage.postgres.OTPChallenge"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+otpChallengeColumns+" FROM otp_challenges WHERE challenge_hash = $1", challengeHash)
+
+	ch, err := scanOTPChallenge(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+		}
+
+		return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ch, nil
+}
+
+// LatestOTPChallenge returns the last challenge sent to user for the app
+func (s *Storage) LatestOTPChallenge(ctx context.Context, userID int64, appID int) (models.OTPChallenge, error) {
+	const op = "storage.postgres.LatestOTPChallenge"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+otpChallengeColumns+` FROM otp_challenges
+		WHERE user_id = $1 AND app_id = $2 ORDER BY last_sent_at DESC, id DESC LIMIT 1`, userID, appID)
+
+	ch, err := scanOTPChallenge(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrOTPChallengeNotFound)
+		}
+
+		return models.OTPChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ch, nil
+}
+
+// AddOTPAttempt counts attempt to enter code of unused challenge. Returns
+// storage.ErrOTPChallengeNotFound once maxAttempts are used up, so
+// concurrent attempts can't exceed the limit
+func (s *Storage) AddOTPAttempt(ctx context.Context, id int64, maxAttempts int) error {
+	const op = "storage.postgres.AddOTPAttempt"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE otp_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND consumed_at IS NULL AND attempts < $2`, id, maxAttempts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrOTPChallengeNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResendOTPChallenge replaces code of unused challenge and counts
+// the send
+func (s *Storage) ResendOTPChallenge(ctx context.Context, id int64, codeHash string, now time.Time) error {
+	const op = "storage.postgres.ResendOTPChallenge"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE otp_challenges SET code_hash = $1, sends = sends + 1, last_sent_at = $2
+		WHERE id = $3 AND consumed_at IS NULL`, codeHash, now.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrOTPChallengeNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeOTPChallenge marks unused and unexpired challenge as consumed.
+// Challenge can be consumed only once, otherwise
+// storage.ErrOTPChallengeNotFound is returned
+func (s *Storage) ConsumeOTPChallenge(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.postgres.ConsumeOTPChallenge"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE otp_challenges SET consumed_at = $1
+		WHERE id = $2 AND consumed_at IS NULL AND expires_at > $3`, now.Unix(), id, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrOTPChallengeNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserOTPChallenges returns one-time code challenges of user in order
+// they were created
+func (s *Storage) UserOTPChallenges(ctx context.Context, userID int64) ([]models.OTPChallenge, error) {
+	const op = "storage.postgres.UserOTPChallenges"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+otpChallengeColumns+" FROM otp_challenges WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var challenges []models.OTPChallenge
+	for rows.Next() {
+		ch, err := scanOTPChallenge(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		challenges = append(challenges, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenges, nil
+}
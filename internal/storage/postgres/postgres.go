@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// uniqueViolation is SQLSTATE of unique constraint violation
+const uniqueViolation = "23505"
+
+type Storage struct {
+	db      *sql.DB
+	log     *slog.Logger
+	keyring *secrets.Keyring
+}
+
+// New instance of storage. Keyring is used to encrypt app secrets at rest
+func New(log *slog.Logger, dbCfg config.DBConfig, keyring *secrets.Keyring) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	db, err := sql.Open("postgres", dbCfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{
+		db:      db,
+		log:     log,
+		keyring: keyring}, nil
+}
+
+// SaveUser saving new user
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "storage.postgres.SaveUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO users(email, email_canonical, pass_hash) VALUES($1, $2, $3) RETURNING id",
+		email, identifiers.CanonicalEmail(email), passHash).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
+
+	event := models.Event{Type: models.EventUserRegistered, UserID: id}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveOrgUser saving new user with email unique in namespace
+// and makes him a member of organization
+func (s *Storage) SaveOrgUser(
+	ctx context.Context,
+	namespace int64,
+	orgID int64,
+	email string,
+	passHash []byte,
+) (int64, error) {
+	const op = "storage.postgres.SaveOrgUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := orgExists(ctx, tx, orgID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO users(org_id, email, email_canonical, pass_hash) VALUES($1, $2, $3, $4) RETURNING id",
+		namespace, email, identifiers.CanonicalEmail(email), passHash).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO org_members(org_id, user_id, role) VALUES($1, $2, $3)", orgID, id, models.OrgRoleMember)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.Event{Type: models.EventUserRegistered, UserID: id, OrgID: orgID}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// User returns user by case-insensitive email from the shared namespace
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.postgres.User"
+
+	user, err := s.namespaceUser(ctx, 0, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// OrgUser returns user by case-insensitive email from the namespace
+func (s *Storage) OrgUser(ctx context.Context, namespace int64, email string) (models.User, error) {
+	const op = "storage.postgres.OrgUser"
+
+	user, err := s.namespaceUser(ctx, namespace, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// namespaceUser looks user up by canonical email. Unresolved duplicates
+// have no canonical email and are found by exact email, which wins
+func (s *Storage) namespaceUser(ctx context.Context, namespace int64, email string) (models.User, error) {
+	stmt, err := s.db.Prepare("SELECT " + userColumns + ` FROM users
+		WHERE org_id = $1 AND (email_canonical = $2 OR (email_canonical IS NULL AND email = $3))
+		ORDER BY email = $3 DESC
+		LIMIT 1`)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowContext(ctx, namespace, identifiers.CanonicalEmail(email), email)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storage.ErrUserNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// IsAdmin check user is admin. User is admin if he has admin flag
+// or admin role assigned directly or through a group
+func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.postgres.IsAdmin"
+
+	stmt, err := s.db.Prepare(`
+		SELECT is_admin
+			OR EXISTS (SELECT 1 FROM user_roles WHERE user_id = users.id AND role = $1)
+			OR EXISTS (SELECT 1 FROM group_roles gr
+				JOIN group_members gm ON gm.group_id = gr.group_id
+				WHERE gm.user_id = users.id AND gr.role = $1)
+		FROM users WHERE id = $2`)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowContext(ctx, models.RoleAdmin, userID)
+
+	var isAdmin bool
+	err = row.Scan(&isAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isAdmin, nil
+}
+
+// App returns some info about current app
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "storage.postgres.App"
+
+	stmt, err := s.db.Prepare("SELECT " + appColumns + " FROM apps WHERE id = $1 ")
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowContext(ctx, appID)
+
+	app, err := s.scanApp(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, storage.ErrAppNotFound
+		}
+
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+func userConstraintErr(err error) error {
+	var pqErr *pq.Error
+
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			switch pqErr.Constraint {
+			case "idx_users_username":
+				return storage.ErrUsernameExists
+			case "idx_users_phone":
+				return storage.ErrPhoneExists
+			}
+
+			return storage.ErrUserExists
+		default:
+			return fmt.Errorf("postgres error [%s]: %w", pqErr.Code, err)
+		}
+	}
+
+	return err
+}
+
+// isUniqueViolation reports whether err is violation of unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// arg appends value to query args and returns its placeholder
+func arg(args *[]any, value any) string {
+	*args = append(*args, value)
+
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// Close closing storage
+func (s *Storage) Close() {
+	if err := s.db.Close(); err != nil {
+		s.log.Error(err.Error())
+		return
+	}
+	s.log.Info("storage stopped successfully")
+}
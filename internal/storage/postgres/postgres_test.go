@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/m1al04949/sso-gRPC/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dsnEnv names URL of Postgres the tests run against. Tests are skipped
+// without it
+const dsnEnv = "SSO_TEST_POSTGRES_DSN"
+
+const testKEK = "b2xkLWtleS1lbmNyeXB0aW9uLWtleS0zMi1ieXRlcyE="
+
+// newTestStorage migrates a fresh schema and opens storage in it.
+// The schema is dropped when the test ends
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnEnv)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	schema := fmt.Sprintf("sso_test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	q.Set("x-migrations-table", "migrations")
+	migrationsURL := *u
+	migrationsURL.RawQuery = q.Encode()
+
+	m, err := migrate.New("file://../../../migrations/postgres", migrationsURL.String())
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	m.Close()
+
+	keyring, err := secrets.New("v1", testKEK, nil)
+	require.NoError(t, err)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := New(log, config.DBConfig{DSN: u.String()}, keyring)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestUsers(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveUser(ctx, "User@Example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	_, err = s.SaveUser(ctx, "user@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	user, err := s.User(ctx, "USER@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "User@Example.com", user.Email)
+	assert.Equal(t, []byte("hash"), user.PassHash)
+
+	_, err = s.User(ctx, "missing@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	_, err = s.UserByID(ctx, id+1)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	other, err := s.SaveUser(ctx, "other@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserIdentifiers(ctx, id, "user", ""))
+	assert.ErrorIs(t, s.SetUserIdentifiers(ctx, other, "user", ""), storage.ErrUsernameExists)
+
+	users, err := s.Users(ctx, models.UserFilter{Email: "EXAMPLE"}, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	events, err := s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventUserRegistered, events[0].Type)
+}
+
+func TestApps(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id, err := s.SaveApp(ctx, "app", "secret", 0)
+	require.NoError(t, err)
+
+	_, err = s.SaveApp(ctx, "app", "other", 0)
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+
+	app, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "app", app.Name)
+	assert.Equal(t, "secret", app.Secret)
+
+	_, err = s.App(ctx, id+1)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	require.NoError(t, s.RotateAppSecret(ctx, id, "rotated", time.Now().Add(time.Hour)))
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", app.Secret)
+	assert.Equal(t, "secret", app.PrevSecret)
+
+	apps, err := s.Apps(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, apps, 1)
+
+	assert.ErrorIs(t, s.UpdateApp(ctx, id+1, "name"), storage.ErrAppNotFound)
+}
+
+func TestAuditChain(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	for i := range 3 {
+		_, err := s.SaveAuditEvent(ctx, models.AuditEvent{
+			Time:   time.Now(),
+			Action: fmt.Sprintf("action.%d", i),
+			Result: "OK",
+		}, true)
+		require.NoError(t, err)
+	}
+
+	events, err := s.ChainedAuditEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, events[0].Hash, events[1].PrevHash)
+	assert.Equal(t, events[1].Hash, events[2].PrevHash)
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM audit_events")
+	assert.Error(t, err)
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage { return newTestStorage(t) })
+}
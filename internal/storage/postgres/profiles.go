@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// UserProfile returns profile of user. User without saved profile
+// has empty one
+func (s *Storage) UserProfile(ctx context.Context, userID int64) (models.Profile, error) {
+	const op = "storage.postgres.UserProfile"
+
+	var (
+		profile                 = models.Profile{UserID: userID}
+		metadata, adminMetadata string
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(p.display_name, ''), COALESCE(p.locale, ''), COALESCE(p.time_zone, ''),
+			COALESCE(p.avatar_url, ''), COALESCE(p.metadata, '{}'), COALESCE(p.admin_metadata, '{}')
+		FROM users u LEFT JOIN user_profiles p ON p.user_id = u.id
+		WHERE u.id = $1`, userID).Scan(
+		&profile.DisplayName,
+		&profile.Locale,
+		&profile.TimeZone,
+		&profile.AvatarURL,
+		&metadata,
+		&adminMetadata,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Profile{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := json.Unmarshal([]byte(metadata), &profile.Metadata); err != nil {
+		return models.Profile{}, fmt.Errorf("%s: metadata: %w", op, err)
+	}
+	if err := json.Unmarshal([]byte(adminMetadata), &profile.AdminMetadata); err != nil {
+		return models.Profile{}, fmt.Errorf("%s: admin metadata: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// SaveUserProfile creates or replaces profile of user
+func (s *Storage) SaveUserProfile(ctx context.Context, profile models.Profile) error {
+	const op = "storage.postgres.SaveUserProfile"
+
+	metadata, err := marshalMetadata(profile.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s: metadata: %w", op, err)
+	}
+	adminMetadata, err := marshalMetadata(profile.AdminMetadata)
+	if err != nil {
+		return fmt.Errorf("%s: admin metadata: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := userExists(ctx, tx, profile.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_profiles(user_id, display_name, locale, time_zone, avatar_url, metadata, admin_metadata)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT(user_id) DO UPDATE SET
+			display_name = excluded.display_name,
+			locale = excluded.locale,
+			time_zone = excluded.time_zone,
+			avatar_url = excluded.avatar_url,
+			metadata = excluded.metadata,
+			admin_metadata = excluded.admin_metadata`,
+		profile.UserID,
+		profile.DisplayName,
+		profile.Locale,
+		profile.TimeZone,
+		profile.AvatarURL,
+		metadata,
+		adminMetadata,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func marshalMetadata(metadata map[string]any) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveTrustedDevice saving device of user identified by hash of its
+// token. Expired devices of the user are deleted along the way
+func (s *Storage) SaveTrustedDevice(
+	ctx context.Context,
+	device models.TrustedDevice,
+	tokenHash string,
+) (int64, error) {
+	const op = "storage.postgres.SaveTrustedDevice"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM trusted_devices WHERE user_id = $1 AND expires_at <= $2", device.UserID, device.CreatedAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO trusted_devices(user_id, token_hash, user_agent, ip, created_at, expires_at)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		device.UserID, tokenHash, device.UserAgent, device.IP, device.CreatedAt.Unix(), device.ExpiresAt.Unix()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UseTrustedDevice marks unexpired device of user with the token hash as
+// used. Returns storage.ErrTrustedDeviceNotFound if there is no such device
+func (s *Storage) UseTrustedDevice(ctx context.Context, userID int64, tokenHash string, now time.Time) error {
+	const op = "storage.postgres.UseTrustedDevice"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE trusted_devices SET last_used_at = $1
+		WHERE user_id = $2 AND token_hash = $3 AND expires_at > $4`,
+		now.Unix(), userID, tokenHash, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrTrustedDeviceNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TrustedDevices returns unexpired devices of user, newest first.
+// Zero now returns expired devices kept so far too
+func (s *Storage) TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error) {
+	const op = "storage.postgres.TrustedDevices"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, user_agent, ip, created_at, expires_at, last_used_at
+		FROM trusted_devices WHERE user_id = $1 AND expires_at > $2
+		ORDER BY id DESC`, userID, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var devices []models.TrustedDevice
+	for rows.Next() {
+		var (
+			device               models.TrustedDevice
+			createdAt, expiresAt int64
+			lastUsedAt           sql.NullInt64
+		)
+		err := rows.Scan(&device.ID, &device.UserID, &device.UserAgent, &device.IP,
+			&createdAt, &expiresAt, &lastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		device.CreatedAt = time.Unix(createdAt, 0)
+		device.ExpiresAt = time.Unix(expiresAt, 0)
+		device.LastUsedAt = unixOrZero(lastUsedAt)
+
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// DeleteTrustedDevice deletes device of user
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.postgres.DeleteTrustedDevice"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrTrustedDeviceNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteTrustedDevices deletes all devices of user and returns how many
+// were deleted
+func (s *Storage) DeleteTrustedDevices(ctx context.Context, userID int64) (int64, error) {
+	const op = "storage.postgres.DeleteTrustedDevices"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
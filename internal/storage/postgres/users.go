@@ -0,0 +1,520 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const userColumns = "id, org_id, email, COALESCE(username, ''), COALESCE(phone, ''), " +
+	"pass_hash, is_admin, disabled, pass_reset_required, status, status_reason, status_changed_at, mfa_channel"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (models.User, error) {
+	var (
+		user            models.User
+		statusChangedAt sql.NullInt64
+	)
+	err := row.Scan(&user.ID, &user.OrgID, &user.Email, &user.Username, &user.Phone, &user.PassHash,
+		&user.IsAdmin, &user.Disabled, &user.PassResetRequired,
+		&user.Status, &user.StatusReason, &statusChangedAt, &user.MFAChannel)
+	user.StatusChangedAt = unixOrZero(statusChangedAt)
+
+	return user, err
+}
+
+// UserByID returns user by id
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.postgres.UserByID"
+
+	stmt, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE id = $1")
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByUsername returns user by canonical username from the namespace
+func (s *Storage) UserByUsername(ctx context.Context, namespace int64, username string) (models.User, error) {
+	const op = "storage.postgres.UserByUsername"
+
+	user, err := s.userBy(ctx, "username", namespace, username)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByPhone returns user by canonical phone number from the namespace
+func (s *Storage) UserByPhone(ctx context.Context, namespace int64, phone string) (models.User, error) {
+	const op = "storage.postgres.UserByPhone"
+
+	user, err := s.userBy(ctx, "phone", namespace, phone)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) userBy(ctx context.Context, column string, namespace int64, value string) (models.User, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE org_id = $1 AND "+column+" = $2", namespace, value)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storage.ErrUserNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// SetUserIdentifiers sets canonical username and phone number of user.
+// Empty values remove identifiers
+func (s *Storage) SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error {
+	const op = "storage.postgres.SetUserIdentifiers"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET username = NULLIF($1, ''), phone = NULLIF($2, '') WHERE id = $3", username, phone, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
+	if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Users returns up to limit users with id greater than afterID, ordered by id
+func (s *Storage) Users(
+	ctx context.Context,
+	filter models.UserFilter,
+	afterID int64,
+	limit int,
+) ([]models.User, error) {
+	const op = "storage.postgres.Users"
+
+	var args []any
+	where := []string{"id > " + arg(&args, afterID)}
+
+	if filter.Email != "" {
+		where = append(where, "email ILIKE "+arg(&args, "%"+storage.EscapeLike(filter.Email)+"%")+` ESCAPE '\'`)
+	}
+	if filter.IsAdmin != nil {
+		where = append(where, "is_admin = "+arg(&args, *filter.IsAdmin))
+	}
+	if filter.Disabled != nil {
+		where = append(where, "disabled = "+arg(&args, *filter.Disabled))
+	}
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(&args, filter.Status))
+	}
+	if filter.OrgID != 0 {
+		where = append(where, "id IN (SELECT user_id FROM org_members WHERE org_id = "+arg(&args, filter.OrgID)+")")
+	}
+	if filter.Role != "" {
+		where = append(where, "id IN (SELECT user_id FROM user_roles WHERE role = "+arg(&args, filter.Role)+")")
+	}
+
+	query := "SELECT " + userColumns + " FROM users WHERE " +
+		strings.Join(where, " AND ") + " ORDER BY id LIMIT " + arg(&args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// UserRoles returns roles assigned to user
+func (s *Storage) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	const op = "storage.postgres.UserRoles"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// SetUserStatus moves user from status from to status to. Returns
+// storage.ErrStatusConflict if user status is not from anymore.
+// Users are disabled unless they are active
+func (s *Storage) SetUserStatus(
+	ctx context.Context,
+	userID int64,
+	from models.AccountStatus,
+	to models.AccountStatus,
+	reason string,
+	now time.Time,
+) error {
+	const op = "storage.postgres.SetUserStatus"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var orgID int64
+	err = tx.QueryRowContext(ctx, "SELECT org_id FROM users WHERE id = $1", userID).Scan(&orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users SET status = $1, status_reason = $2, status_changed_at = $3, disabled = $4
+		WHERE id = $5 AND status = $6`,
+		to, reason, now.Unix(), to != models.StatusActive, userID, from)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrStatusConflict); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.Event{
+		Type:   models.EventUserStatusChanged,
+		Time:   now,
+		UserID: userID,
+		OrgID:  orgID,
+		Data:   map[string]string{"from": string(from), "to": string(to), "reason": reason},
+	}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users deleted before given time.
+// Erased users are kept. Returns number of deleted users
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const op = "storage.postgres.PurgeDeletedUsers"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM users
+		WHERE status = $1 AND status_changed_at < $2 AND id NOT IN (SELECT user_id FROM erasures)`,
+		models.StatusDeleted, deletedBefore.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, id := range ids {
+		if err := deleteUserData(ctx, tx, id); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int64(len(ids)), nil
+}
+
+// SetPassResetRequired marks that user has to reset password before next login
+func (s *Storage) SetPassResetRequired(ctx context.Context, userID int64, required bool) error {
+	const op = "storage.postgres.SetPassResetRequired"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET pass_reset_required = $1 WHERE id = $2", required, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if required {
+		orgID, err := userOrgID(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		event := models.Event{Type: models.EventUserPassResetRequired, UserID: userID, OrgID: orgID}
+		if err := insertOutboxEvent(ctx, tx, event); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetPassword replaces password hash of user and clears the password
+// reset flag
+func (s *Storage) SetPassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.postgres.SetPassword"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET pass_hash = $1, pass_reset_required = FALSE WHERE id = $2", passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	orgID, err := userOrgID(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.Event{Type: models.EventUserPasswordChanged, UserID: userID, OrgID: orgID}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAdmin grants or revokes admin flag
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.postgres.SetAdmin"
+
+	return s.updateUser(ctx, op, "UPDATE users SET is_admin = $1 WHERE id = $2", isAdmin, userID)
+}
+
+// SetUserMFAChannel sets channel of second factor codes, empty channel
+// turns second factor off
+func (s *Storage) SetUserMFAChannel(ctx context.Context, userID int64, channel string) error {
+	const op = "storage.postgres.SetUserMFAChannel"
+
+	return s.updateUser(ctx, op, "UPDATE users SET mfa_channel = $1 WHERE id = $2", channel, userID)
+}
+
+// SetUserRoles replaces all roles of user with given ones
+func (s *Storage) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	const op = "storage.postgres.SetUserRoles"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := userExists(ctx, tx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, role := range roles {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_roles(user_id, role) VALUES($1, $2) ON CONFLICT DO NOTHING", userID, role)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// deleteUserData deletes rows of tables referencing user
+func deleteUserData(ctx context.Context, tx *sql.Tx, userID int64) error {
+	for _, query := range []string{
+		"DELETE FROM user_roles WHERE user_id = $1",
+		"DELETE FROM org_members WHERE user_id = $1",
+		"DELETE FROM group_members WHERE user_id = $1",
+		"DELETE FROM user_profiles WHERE user_id = $1",
+		"DELETE FROM email_changes WHERE user_id = $1",
+		"DELETE FROM magic_links WHERE user_id = $1",
+		"DELETE FROM otp_challenges WHERE user_id = $1",
+		"DELETE FROM webauthn_credentials WHERE user_id = $1",
+		"DELETE FROM webauthn_sessions WHERE user_id = $1",
+		"DELETE FROM login_attempts WHERE user_id = $1",
+		"DELETE FROM trusted_devices WHERE user_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) updateUser(ctx context.Context, op string, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func userExists(ctx context.Context, tx *sql.Tx, userID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1", userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrUserNotFound
+	}
+
+	return err
+}
+
+// checkAffected returns notFound error if statement changed nothing
+func checkAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+// AddUserRole assigns role to user keeping his other roles
+func (s *Storage) AddUserRole(ctx context.Context, userID int64, role string) error {
+	const op = "storage.postgres.AddUserRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := userExists(ctx, tx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_roles(user_id, role) VALUES($1, $2) ON CONFLICT DO NOTHING", userID, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// userOrgID returns organization namespace of user, events of the user
+// are bound to it
+func userOrgID(ctx context.Context, tx *sql.Tx, userID int64) (int64, error) {
+	var orgID int64
+	err := tx.QueryRowContext(ctx, "SELECT org_id FROM users WHERE id = $1", userID).Scan(&orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrUserNotFound
+	}
+
+	return orgID, err
+}
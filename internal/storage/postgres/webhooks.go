@@ -0,0 +1,343 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// webhookColumns are selected from webhooks w joined with apps a
+const webhookColumns = "w.id, w.app_id, COALESCE(a.org_id, 0), w.url, w.secret, w.event_types, w.created_at"
+
+// SaveWebhook saves webhook of app. Secret is encrypted at rest
+func (s *Storage) SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error) {
+	const op = "storage.postgres.SaveWebhook"
+
+	encrypted, err := s.keyring.Encrypt(webhook.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var appID int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM apps WHERE id = $1", webhook.AppID).Scan(&appID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO webhooks(app_id, url, secret, event_types, created_at) VALUES($1, $2, $3, $4, $5) RETURNING id",
+		webhook.AppID, webhook.URL, encrypted, strings.Join(webhook.EventTypes, ","), webhook.CreatedAt.Unix()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Webhooks returns webhooks ordered by id. Zero appID returns webhooks of all apps
+func (s *Storage) Webhooks(ctx context.Context, appID int) ([]models.Webhook, error) {
+	const op = "storage.postgres.Webhooks"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+webhookColumns+" FROM webhooks w JOIN apps a ON a.id = w.app_id WHERE $1::bigint = 0 OR w.app_id = $1 ORDER BY w.id", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := s.scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook with its deliveries
+func (s *Storage) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	const op = "storage.postgres.DeleteWebhook"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", webhookID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrWebhookNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = $1", webhookID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DueWebhookDeliveries returns pending deliveries which next attempt is due
+func (s *Storage) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.DueWebhookDeliveries"
+
+	deliveries, err := s.queryDeliveries(ctx,
+		"WHERE d.status = $1 AND d.next_attempt_at <= $2 ORDER BY d.next_attempt_at, d.id LIMIT $3",
+		models.DeliveryPending, now.Unix(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// WebhookDeliveries returns the latest deliveries of webhook, newest
+// first. Empty status returns deliveries in any status
+func (s *Storage) WebhookDeliveries(
+	ctx context.Context,
+	webhookID int64,
+	status models.DeliveryStatus,
+	limit int,
+) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.WebhookDeliveries"
+
+	deliveries, err := s.queryDeliveries(ctx,
+		"WHERE d.webhook_id = $1 AND ($2::text = '' OR d.status = $2) ORDER BY d.id DESC LIMIT $3",
+		webhookID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery saves result of delivery attempt
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	const op = "storage.postgres.UpdateWebhookDelivery"
+
+	var deliveredAt sql.NullInt64
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt = sql.NullInt64{Int64: delivery.DeliveredAt.Unix(), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, delivered_at = $5
+		WHERE id = $6`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt.Unix(), delivery.LastError, deliveredAt,
+		delivery.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrWebhookDeliveryNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReplayWebhookDeliveries schedules deliveries of webhook to be retried
+// from scratch. Zero deliveryID replays all dead deliveries, otherwise
+// only the given delivery in any status. Returns number of replayed deliveries
+func (s *Storage) ReplayWebhookDeliveries(
+	ctx context.Context,
+	webhookID int64,
+	deliveryID int64,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.postgres.ReplayWebhookDeliveries"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM webhooks WHERE id = $1", webhookID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = $2, last_error = '', delivered_at = NULL
+		WHERE webhook_id = $3 AND (($4::bigint = 0 AND status = $5) OR id = $4)`,
+		models.DeliveryPending, now.Unix(), webhookID, deliveryID, models.DeliveryDead)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deliveryID != 0 && n == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// ReencryptWebhookSecrets re-encrypts webhook secrets encrypted with
+// previous KEKs using the current KEK. Returns number of updated webhooks
+func (s *Storage) ReencryptWebhookSecrets(ctx context.Context) (int, error) {
+	const op = "storage.postgres.ReencryptWebhookSecrets"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, secret FROM webhooks")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	outdated := make(map[int64]string)
+	for rows.Next() {
+		var (
+			id     int64
+			secret string
+		)
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if s.keyring.NeedsReencrypt(secret) {
+			outdated[id] = secret
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for id, secret := range outdated {
+		plain, err := s.keyring.Decrypt(secret)
+		if err != nil {
+			return 0, fmt.Errorf("%s: webhook %d: %w", op, id, err)
+		}
+		encrypted, err := s.keyring.Encrypt(plain)
+		if err != nil {
+			return 0, fmt.Errorf("%s: webhook %d: %w", op, id, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE webhooks SET secret = $1 WHERE id = $2", encrypted, id); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(outdated), nil
+}
+
+func (s *Storage) scanWebhook(row scanner) (models.Webhook, error) {
+	var (
+		webhook   models.Webhook
+		secret    string
+		types     string
+		createdAt int64
+	)
+	if err := row.Scan(&webhook.ID, &webhook.AppID, &webhook.OrgID, &webhook.URL, &secret, &types, &createdAt); err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.CreatedAt = time.Unix(createdAt, 0)
+	if types != "" {
+		webhook.EventTypes = strings.Split(types, ",")
+	}
+
+	var err error
+	if webhook.Secret, err = s.keyring.Decrypt(secret); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (s *Storage) queryDeliveries(ctx context.Context, where string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.webhook_id, d.status, d.attempts, d.next_attempt_at, d.last_error, d.delivered_at,
+			e.id, e.type, e.time, e.user_id, e.org_id, e.app_id, e.data
+		FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.event_id `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var (
+			delivery      models.WebhookDelivery
+			nextAttemptAt int64
+			deliveredAt   sql.NullInt64
+			eventTime     int64
+			data          []byte
+		)
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Status, &delivery.Attempts,
+			&nextAttemptAt, &delivery.LastError, &deliveredAt,
+			&delivery.Event.ID, &delivery.Event.Type, &eventTime, &delivery.Event.UserID,
+			&delivery.Event.OrgID, &delivery.Event.AppID, &data)
+		if err != nil {
+			return nil, err
+		}
+		delivery.NextAttemptAt = time.Unix(nextAttemptAt, 0)
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = time.Unix(deliveredAt.Int64, 0)
+		}
+		delivery.Event.Time = time.Unix(eventTime, 0)
+		if err := json.Unmarshal(data, &delivery.Event.Data); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,319 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"modernc.org/sqlite"
+	sqlerr "modernc.org/sqlite/lib"
+)
+
+const appColumns = "id, name, secret, prev_secret, prev_secret_expires_at, org_id"
+
+// scanApp scans app row and decrypts its secrets
+func (s *Storage) scanApp(row scanner) (models.App, error) {
+	var (
+		app       models.App
+		secret    string
+		prev      sql.NullString
+		expiresAt sql.NullInt64
+		orgID     sql.NullInt64
+	)
+
+	if err := row.Scan(&app.ID, &app.Name, &secret, &prev, &expiresAt, &orgID); err != nil {
+		return models.App{}, err
+	}
+	app.OrgID = orgID.Int64
+
+	var err error
+	if app.Secret, err = s.decryptSecret(app.ID, secret); err != nil {
+		return models.App{}, err
+	}
+	if prev.Valid {
+		if app.PrevSecret, err = s.decryptSecret(app.ID, prev.String); err != nil {
+			return models.App{}, err
+		}
+	}
+	if expiresAt.Valid {
+		app.PrevSecretExpiresAt = time.Unix(expiresAt.Int64, 0)
+	}
+
+	return app, nil
+}
+
+// decryptSecret decrypts app secret. Plaintext secret is returned as is
+// unless keyring requires encryption
+func (s *Storage) decryptSecret(appID int, value string) (string, error) {
+	if !secrets.IsEncrypted(value) {
+		if s.keyring.RequireEncrypted() {
+			s.log.Error("app secret is stored in plaintext, run migrator with --encrypt-secrets",
+				slog.Int("app_id", appID))
+
+			return "", secrets.ErrNotEncrypted
+		}
+
+		s.log.Warn("app secret is stored in plaintext, run migrator with --encrypt-secrets",
+			slog.Int("app_id", appID))
+
+		return value, nil
+	}
+
+	return s.keyring.Decrypt(value)
+}
+
+// SaveApp saving new app. Zero orgID means app doesn't belong to any organization
+func (s *Storage) SaveApp(ctx context.Context, name string, secret string, orgID int64) (int, error) {
+	const op = "storage.sqlite.SaveApp"
+
+	encrypted, err := s.keyring.Encrypt(secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	org := sql.NullInt64{Int64: orgID, Valid: orgID != 0}
+	if org.Valid {
+		if err := orgExists(ctx, tx, orgID); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO apps(name, secret, org_id) VALUES(?, ?, ?)", name, encrypted, org)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, appConstraintErr(err))
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(id), nil
+}
+
+// Apps returns apps ordered by id. Non-zero orgID returns only apps of the organization
+func (s *Storage) Apps(ctx context.Context, orgID int64) ([]models.App, error) {
+	const op = "storage.sqlite.Apps"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT "+appColumns+" FROM apps WHERE ? = 0 OR org_id = ? ORDER BY id", orgID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := s.scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// UpdateApp renames app
+func (s *Storage) UpdateApp(ctx context.Context, appID int, name string) error {
+	const op = "storage.sqlite.UpdateApp"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET name = ? WHERE id = ?", name, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, appConstraintErr(err))
+	}
+	if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RotateAppSecret replaces app secret with a new one. Current secret
+// is kept as previous until prevExpiresAt
+func (s *Storage) RotateAppSecret(
+	ctx context.Context,
+	appID int,
+	secret string,
+	prevExpiresAt time.Time,
+) error {
+	const op = "storage.sqlite.RotateAppSecret"
+
+	encrypted, err := s.keyring.Encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE apps
+		SET prev_secret = secret, prev_secret_expires_at = ?, secret = ?
+		WHERE id = ?`, prevExpiresAt.Unix(), encrypted, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteApp deletes app with its webhooks, magic links and one-time codes
+func (s *Storage) DeleteApp(ctx context.Context, appID int) error {
+	const op = "storage.sqlite.DeleteApp"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM apps WHERE id = ?", appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE app_id = ?)", appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM magic_links WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM otp_challenges WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReencryptAppSecrets encrypts plaintext app secrets and secrets encrypted
+// with previous KEKs using the current KEK. Returns number of updated apps
+func (s *Storage) ReencryptAppSecrets(ctx context.Context) (int, error) {
+	const op = "storage.sqlite.ReencryptAppSecrets"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+appColumns+" FROM apps")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	type stored struct {
+		id           int
+		secret, prev string
+	}
+	var outdated []stored
+	for rows.Next() {
+		var (
+			row       stored
+			name      string
+			prev      sql.NullString
+			expiresAt sql.NullInt64
+			orgID     sql.NullInt64
+		)
+		if err := rows.Scan(&row.id, &name, &row.secret, &prev, &expiresAt, &orgID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		row.prev = prev.String
+
+		if s.keyring.NeedsReencrypt(row.secret) || (prev.Valid && s.keyring.NeedsReencrypt(row.prev)) {
+			outdated = append(outdated, row)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, row := range outdated {
+		secret, err := s.reencrypt(row.secret)
+		if err != nil {
+			return 0, fmt.Errorf("%s: app %d: %w", op, row.id, err)
+		}
+
+		var prev sql.NullString
+		if row.prev != "" {
+			if prev.String, err = s.reencrypt(row.prev); err != nil {
+				return 0, fmt.Errorf("%s: app %d: %w", op, row.id, err)
+			}
+			prev.Valid = true
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE apps SET secret = ?, prev_secret = ? WHERE id = ?", secret, prev, row.id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(outdated), nil
+}
+
+func (s *Storage) reencrypt(value string) (string, error) {
+	if !s.keyring.NeedsReencrypt(value) {
+		return value, nil
+	}
+
+	// Plaintext secrets are read as is to encrypt them
+	// even if keyring requires encryption
+	plaintext := value
+	if secrets.IsEncrypted(value) {
+		var err error
+		if plaintext, err = s.keyring.Decrypt(value); err != nil {
+			return "", err
+		}
+	}
+
+	return s.keyring.Encrypt(plaintext)
+}
+
+func appConstraintErr(err error) error {
+	var sqliteErr *sqlite.Error
+
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlerr.SQLITE_CONSTRAINT_UNIQUE {
+		return storage.ErrAppExists
+	}
+
+	return err
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const newKEK = "bmV3LWtleS1lbmNyeXB0aW9uLWtleS0zMi1ieXRlcyE="
+
+func TestReencryptSecrets(t *testing.T) {
+	s := newTestStorage(t, 4)
+	ctx := context.Background()
+
+	encrypted, err := s.SaveApp(ctx, "encrypted", "encrypted-secret", 0)
+	require.NoError(t, err)
+	plain := savePlaintextApp(t, s, "plain", "plain-secret")
+	_, err = s.SaveWebhook(ctx, models.Webhook{AppID: encrypted, URL: "http://localhost/hook", Secret: "hook-secret"})
+	require.NoError(t, err)
+
+	// KEK is rotated, secrets encrypted with the old one are still read
+	s.keyring, err = secrets.New("v2", newKEK, map[string]string{"v1": testKEK})
+	require.NoError(t, err)
+
+	n, err := s.ReencryptAppSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = s.ReencryptWebhookSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Old KEK is no longer needed
+	s.keyring, err = secrets.New("v2", newKEK, nil)
+	require.NoError(t, err)
+
+	for id, secret := range map[int]string{encrypted: "encrypted-secret", plain: "plain-secret"} {
+		app, err := s.App(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, secret, app.Secret)
+		assert.True(t, strings.HasPrefix(storedSecret(t, s, id), "enc:v1:v2:"))
+	}
+
+	webhooks, err := s.Webhooks(ctx, encrypted)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, "hook-secret", webhooks[0].Secret)
+
+	n, err = s.ReencryptAppSecrets(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = s.ReencryptWebhookSecrets(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRequireEncrypted(t *testing.T) {
+	s := newTestStorage(t, 4)
+	ctx := context.Background()
+
+	var err error
+	s.keyring, err = secrets.FromConfig(config.SecretsConfig{KEKID: "v1", KEK: testKEK, RequireEncrypted: true})
+	require.NoError(t, err)
+
+	encrypted, err := s.SaveApp(ctx, "encrypted", "encrypted-secret", 0)
+	require.NoError(t, err)
+	plain := savePlaintextApp(t, s, "plain", "plain-secret")
+
+	_, err = s.App(ctx, encrypted)
+	require.NoError(t, err)
+	_, err = s.App(ctx, plain)
+	assert.ErrorIs(t, err, secrets.ErrNotEncrypted)
+	_, err = s.Apps(ctx, 0)
+	assert.ErrorIs(t, err, secrets.ErrNotEncrypted)
+
+	// Migrator encrypts plaintext secrets under the same config
+	n, err := s.ReencryptAppSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	app, err := s.App(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, "plain-secret", app.Secret)
+}
+
+// savePlaintextApp saves app the way it was stored before
+// encryption at rest
+func savePlaintextApp(t *testing.T, s *Storage, name string, secret string) int {
+	t.Helper()
+
+	res, err := s.db.Exec("INSERT INTO apps(name, secret) VALUES(?, ?)", name, secret)
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	return int(id)
+}
+
+func storedSecret(t *testing.T, s *Storage, appID int) string {
+	t.Helper()
+
+	var secret string
+	require.NoError(t, s.db.QueryRow("SELECT secret FROM apps WHERE id = ?", appID).Scan(&secret))
+
+	return secret
+}
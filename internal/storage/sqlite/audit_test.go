@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditChainAcrossProcesses(t *testing.T) {
+	path := newTestDB(t)
+	storages := []*Storage{openTestStorage(t, path, 1), openTestStorage(t, path, 1)}
+	ctx := context.Background()
+
+	const perStorage = 20
+
+	var wg sync.WaitGroup
+	for _, s := range storages {
+		for range perStorage {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := s.SaveAuditEvent(ctx, models.AuditEvent{Time: time.Now(), Action: "login", Result: "OK"}, true)
+				assert.NoError(t, err)
+			}()
+		}
+	}
+	wg.Wait()
+
+	chain, err := storages[0].ChainedAuditEvents(ctx, 0, 2*perStorage+1)
+	require.NoError(t, err)
+	require.Len(t, chain, 2*perStorage)
+
+	var prevHash string
+	for _, event := range chain {
+		assert.Equal(t, prevHash, event.PrevHash, "event %d", event.ID)
+		assert.Equal(t, event.ChainHash(prevHash), event.Hash, "event %d", event.ID)
+		prevHash = event.Hash
+	}
+}
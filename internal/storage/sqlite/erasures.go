@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// erasedEmail returns email erased user is pseudonymized with
+func erasedEmail(userID int64) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", userID)
+}
+
+// EraseUser irreversibly pseudonymizes user: personal data, roles and
+// memberships are deleted, email is replaced with a pseudonym, password
+// hash is cleared and the user is deleted. The erasure is recorded.
+// Audit events and failed logins keep the fact of action but lose
+// identifiers, ip and user agent of user
+func (s *Storage) EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error {
+	const op = "storage.sqlite.EraseUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		email           string
+		username, phone sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, "SELECT email, username, phone FROM users WHERE id = ?", userID).Scan(&email, &username, &phone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	pseudonym := erasedEmail(userID)
+
+	if err := deleteUserData(ctx, tx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	identifiers := []string{email}
+	for _, identifier := range []sql.NullString{username, phone} {
+		if identifier.String != "" {
+			identifiers = append(identifiers, identifier.String)
+		}
+	}
+	if err := redactUserEvents(ctx, tx, userID, pseudonym, identifiers); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE invitations SET email = ? WHERE lower(email) = lower(?) OR accepted_by = ?",
+		pseudonym, email, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET email = ?, email_canonical = ?, username = NULL, phone = NULL, pass_hash = X'',
+			is_admin = FALSE, disabled = TRUE, pass_reset_required = FALSE,
+			status = ?, status_reason = ?, status_changed_at = ?
+		WHERE id = ?`,
+		pseudonym, pseudonym, models.StatusDeleted, models.StatusReasonErased, now.Unix(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO erasures(user_id, erased_by, erased_at) VALUES(?, ?, ?) ON CONFLICT(user_id) DO NOTHING",
+		userID, erasedBy, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// redactUserEvents removes personal data of user from the audit log and
+// the outbox. Audit targets naming user by identifier are replaced with
+// user id, ip and user agent of events user acted in are cleared. Login
+// of failed logins is replaced with pseudonym
+func redactUserEvents(ctx context.Context, tx *sql.Tx, userID int64, pseudonym string, identifiers []string) error {
+	target := models.AuditTarget(models.AuditTargetUser, userID)
+
+	for _, identifier := range identifiers {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE audit_events SET target = ?, ip = '', user_agent = '', redacted = TRUE
+			WHERE lower(target) = lower(?)`,
+			target, models.AuditTarget(models.AuditTargetIdentifier, identifier))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE outbox_events SET data = json_set(data, '$.login', ?)
+			WHERE type = ? AND lower(json_extract(data, '$.login')) = lower(?)`,
+			pseudonym, models.EventUserLoginFailed, identifier)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"UPDATE audit_events SET ip = '', user_agent = '', redacted = TRUE WHERE actor_id = ?", userID)
+
+	return err
+}
@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEraseUserLeavesNoIdentifiers(t *testing.T) {
+	s := newTestStorage(t, 4)
+	ctx := context.Background()
+	now := time.Now()
+
+	id, err := s.SaveUser(ctx, "erased@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserIdentifiers(ctx, id, "erased-name", "+15550001"))
+
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		Email:     "Erased@Example.com",
+		Role:      "viewer",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "invitation")
+	require.NoError(t, err)
+	_, err = s.SaveEmailChange(ctx, models.EmailChange{
+		UserID:    id,
+		OldEmail:  "erased@example.com",
+		NewEmail:  "new@example.com",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "code", "cancel")
+	require.NoError(t, err)
+
+	for _, login := range []string{"ERASED@example.com", "erased-name", "+15550001"} {
+		_, err = s.SaveAuditEvent(ctx, models.AuditEvent{
+			Time:      now,
+			Action:    "login",
+			Target:    models.AuditTarget(models.AuditTargetIdentifier, login),
+			IP:        "10.0.0.1",
+			UserAgent: "agent",
+			Result:    "ok",
+		}, true)
+		require.NoError(t, err)
+		require.NoError(t, s.SaveEvent(ctx, models.Event{
+			Type: models.EventUserLoginFailed,
+			Time: now,
+			Data: map[string]string{"login": login, "reason": "invalid_credentials"},
+		}))
+	}
+
+	require.NoError(t, s.EraseUser(ctx, id, 0, now))
+
+	tables, err := s.db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	require.NoError(t, err)
+	var names []string
+	for tables.Next() {
+		var name string
+		require.NoError(t, tables.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, tables.Err())
+	tables.Close()
+
+	// No column of any table holds identifiers or ip of user
+	for _, table := range names {
+		columns, err := s.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+		require.NoError(t, err)
+		var cols []string
+		for columns.Next() {
+			var col string
+			require.NoError(t, columns.Scan(&col))
+			cols = append(cols, col)
+		}
+		require.NoError(t, columns.Err())
+		columns.Close()
+
+		for _, col := range cols {
+			for _, value := range []string{"erased@example.com", "erased-name", "+15550001", "10.0.0.1"} {
+				var n int
+				err := s.db.QueryRowContext(ctx,
+					`SELECT count(*) FROM "`+table+`" WHERE instr(lower(CAST("`+col+`" AS TEXT)), ?) > 0`,
+					value).Scan(&n)
+				require.NoError(t, err)
+				assert.Zero(t, n, "%s.%s holds %s", table, col, value)
+			}
+		}
+	}
+
+	// Redaction does not open audit log to other updates
+	_, err = s.db.ExecContext(ctx, "UPDATE audit_events SET result = 'failed'")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = s.db.ExecContext(ctx, "UPDATE audit_events SET target = 'user:1', redacted = TRUE, ip = '10.0.0.2'")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = s.db.ExecContext(ctx, "UPDATE audit_events SET target = 'app:1', redacted = TRUE, ip = '', user_agent = ''")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = s.db.ExecContext(ctx,
+		"UPDATE audit_events SET target = 'user:999', ip = '', user_agent = '' WHERE redacted AND target LIKE 'user:%'")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = s.db.ExecContext(ctx, "DELETE FROM audit_events")
+	assert.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,310 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"modernc.org/sqlite"
+	sqlerr "modernc.org/sqlite/lib"
+)
+
+// SaveGroup saving new group with roles
+func (s *Storage) SaveGroup(ctx context.Context, name string, roles []string) (int64, error) {
+	const op = "storage.sqlite.SaveGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO groups(name) VALUES(?)", name)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlerr.SQLITE_CONSTRAINT_UNIQUE {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertGroupRoles(ctx, tx, id, roles); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Groups returns all groups with their roles ordered by id
+func (s *Storage) Groups(ctx context.Context) ([]models.Group, error) {
+	const op = "storage.sqlite.Groups"
+
+	groups, err := s.queryGroups(ctx, "SELECT id, name FROM groups ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// UserGroups returns groups user belongs to ordered by name
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "storage.sqlite.UserGroups"
+
+	groups, err := s.queryGroups(ctx, `
+		SELECT g.id, g.name FROM groups g
+		JOIN group_members gm ON gm.group_id = g.id
+		WHERE gm.user_id = ?
+		ORDER BY g.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// SetGroupRoles replaces all roles of group with given ones
+func (s *Storage) SetGroupRoles(ctx context.Context, groupID int64, roles []string) error {
+	const op = "storage.sqlite.SetGroupRoles"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := groupExists(ctx, tx, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM group_roles WHERE group_id = ?", groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertGroupRoles(ctx, tx, groupID, roles); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes group with its roles and memberships
+func (s *Storage) DeleteGroup(ctx context.Context, groupID int64) error {
+	const op = "storage.sqlite.DeleteGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM group_roles WHERE group_id = ?",
+		"DELETE FROM group_members WHERE group_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, groupID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM groups WHERE id = ?", groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrGroupNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveGroupMember adds user to group
+func (s *Storage) SaveGroupMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "storage.sqlite.SaveGroupMember"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := groupExists(ctx, tx, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := userExists(ctx, tx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO group_members(group_id, user_id) VALUES(?, ?) ON CONFLICT DO NOTHING", groupID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroupMember removes user from group
+func (s *Storage) DeleteGroupMember(ctx context.Context, groupID int64, userID int64) error {
+	const op = "storage.sqlite.DeleteGroupMember"
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrGroupMemberNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GroupMembers returns ids of group members
+func (s *Storage) GroupMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	const op = "storage.sqlite.GroupMembers"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT user_id FROM group_members WHERE group_id = ? ORDER BY user_id", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// EffectiveRoles returns roles assigned to user directly and through groups
+func (s *Storage) EffectiveRoles(ctx context.Context, userID int64) ([]string, error) {
+	const op = "storage.sqlite.EffectiveRoles"
+
+	rows, err := s.read.QueryContext(ctx, `
+		SELECT role FROM user_roles WHERE user_id = ?
+		UNION
+		SELECT gr.role FROM group_roles gr
+		JOIN group_members gm ON gm.group_id = gr.group_id
+		WHERE gm.user_id = ?
+		ORDER BY role`, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// queryGroups runs query selecting group id and name and loads roles of found groups
+func (s *Storage) queryGroups(ctx context.Context, query string, args ...any) ([]models.Group, error) {
+	rows, err := s.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	for rows.Next() {
+		var group models.Group
+		if err := rows.Scan(&group.ID, &group.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		roles, err := s.read.QueryContext(ctx,
+			"SELECT role FROM group_roles WHERE group_id = ? ORDER BY role", groups[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for roles.Next() {
+			var role string
+			if err := roles.Scan(&role); err != nil {
+				roles.Close()
+				return nil, err
+			}
+			groups[i].Roles = append(groups[i].Roles, role)
+		}
+		roles.Close()
+		if err := roles.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
+func insertGroupRoles(ctx context.Context, tx *sql.Tx, groupID int64, roles []string) error {
+	for _, role := range roles {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO group_roles(group_id, role) VALUES(?, ?) ON CONFLICT DO NOTHING", groupID, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func groupExists(ctx context.Context, tx *sql.Tx, groupID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM groups WHERE id = ?", groupID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrGroupNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+const invitationColumns = `id, email, org_id, app_id, role, created_by, created_at,
+	expires_at, accepted_at, accepted_by, revoked_at`
+
+func scanInvitation(row scanner) (models.Invitation, error) {
+	var (
+		inv                      models.Invitation
+		orgID, appID, acceptedBy sql.NullInt64
+		createdAt, expiresAt     int64
+		acceptedAt, revokedAt    sql.NullInt64
+	)
+
+	err := row.Scan(&inv.ID, &inv.Email, &orgID, &appID, &inv.Role, &inv.CreatedBy,
+		&createdAt, &expiresAt, &acceptedAt, &acceptedBy, &revokedAt)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	inv.OrgID = orgID.Int64
+	inv.AppID = int(appID.Int64)
+	inv.AcceptedBy = acceptedBy.Int64
+	inv.CreatedAt = time.Unix(createdAt, 0)
+	inv.ExpiresAt = time.Unix(expiresAt, 0)
+	inv.AcceptedAt = unixOrZero(acceptedAt)
+	inv.RevokedAt = unixOrZero(revokedAt)
+
+	return inv, nil
+}
+
+// SaveInvitation saving new invitation identified by hash of its code
+func (s *Storage) SaveInvitation(ctx context.Context, inv models.Invitation, codeHash string) (int64, error) {
+	const op = "storage.sqlite.SaveInvitation"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO invitations(code_hash, email, org_id, app_id, role, created_by, created_at, expires_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		codeHash, inv.Email,
+		sql.NullInt64{Int64: inv.OrgID, Valid: inv.OrgID != 0},
+		sql.NullInt64{Int64: int64(inv.AppID), Valid: inv.AppID != 0},
+		inv.Role, inv.CreatedBy, inv.CreatedAt.Unix(), inv.ExpiresAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Invitation returns invitation by id
+func (s *Storage) Invitation(ctx context.Context, id int64) (models.Invitation, error) {
+	const op = "storage.sqlite.Invitation"
+
+	inv, err := scanInvitation(s.read.QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inv, nil
+}
+
+// InvitationByCode returns invitation by hash of its code
+func (s *Storage) InvitationByCode(ctx context.Context, codeHash string) (models.Invitation, error) {
+	const op = "storage.sqlite.InvitationByCode"
+
+	inv, err := scanInvitation(s.read.QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE code_hash = ?", codeHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inv, nil
+}
+
+// Invitations returns invitations ordered by id. Non-zero orgID returns
+// only invitations to the organization
+func (s *Storage) Invitations(ctx context.Context, orgID int64) ([]models.Invitation, error) {
+	const op = "storage.sqlite.Invitations"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE ? = 0 OR org_id = ? ORDER BY id",
+		orgID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invs []models.Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invs = append(invs, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invs, nil
+}
+
+// UserInvitations returns invitations sent to email or accepted by user
+func (s *Storage) UserInvitations(ctx context.Context, userID int64, email string) ([]models.Invitation, error) {
+	const op = "storage.sqlite.UserInvitations"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE lower(email) = lower(?) OR accepted_by = ? ORDER BY id",
+		email, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invs []models.Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invs = append(invs, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invs, nil
+}
+
+// AcceptInvitation marks pending invitation as accepted by user.
+// Returns ErrInvitationNotFound if invitation is not pending anymore
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, userID int64, now time.Time) error {
+	const op = "storage.sqlite.AcceptInvitation"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE invitations SET accepted_at = ?, accepted_by = ?
+		WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?`,
+		now.Unix(), userID, id, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrInvitationNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeInvitation marks pending invitation as revoked
+func (s *Storage) RevokeInvitation(ctx context.Context, id int64, now time.Time) error {
+	const op = "storage.sqlite.RevokeInvitation"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE invitations SET revoked_at = ?
+		WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL`, now.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrInvitationNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func unixOrZero(t sql.NullInt64) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+
+	return time.Unix(t.Int64, 0)
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveMagicLink saving new magic link identified by hash of its code.
+// Unused links of the user for the app are deleted, so only the latest
+// link works
+func (s *Storage) SaveMagicLink(ctx context.Context, link models.MagicLink, codeHash string) (int64, error) {
+	const op = "storage.sqlite.SaveMagicLink"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM magic_links WHERE user_id = ? AND app_id = ? AND consumed_at IS NULL",
+		link.UserID, link.AppID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO magic_links(user_id, app_id, code_hash, created_at, expires_at)
+		VALUES(?, ?, ?, ?, ?)`,
+		link.UserID, link.AppID, codeHash, link.CreatedAt.Unix(), link.ExpiresAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ConsumeMagicLink marks unused and unexpired magic link of the app as
+// consumed and returns it. Link can be consumed only once, otherwise
+// storage.ErrMagicLinkNotFound is returned
+func (s *Storage) ConsumeMagicLink(
+	ctx context.Context,
+	codeHash string,
+	appID int,
+	now time.Time,
+) (models.MagicLink, error) {
+	const op = "storage.sqlite.ConsumeMagicLink"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		link                 models.MagicLink
+		createdAt, expiresAt int64
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, app_id, created_at, expires_at FROM magic_links
+		WHERE code_hash = ? AND app_id = ?`, codeHash, appID).
+		Scan(&link.ID, &link.UserID, &link.AppID, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MagicLink{}, fmt.Errorf("%s: %w", op, storage.ErrMagicLinkNotFound)
+		}
+
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The condition makes concurrent consumers of the same link race
+	// for a single row update
+	res, err := tx.ExecContext(ctx, `
+		UPDATE magic_links SET consumed_at = ?
+		WHERE id = ? AND consumed_at IS NULL AND expires_at > ?`,
+		now.Unix(), link.ID, now.Unix())
+	if err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrMagicLinkNotFound); err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	link.CreatedAt = time.Unix(createdAt, 0)
+	link.ExpiresAt = time.Unix(expiresAt, 0)
+	link.ConsumedAt = time.Unix(now.Unix(), 0)
+
+	return link, nil
+}
+
+// UserMagicLinks returns magic links of user in order they were sent
+func (s *Storage) UserMagicLinks(ctx context.Context, userID int64) ([]models.MagicLink, error) {
+	const op = "storage.sqlite.UserMagicLinks"
+
+	rows, err := s.read.QueryContext(ctx, `
+		SELECT id, user_id, app_id, created_at, expires_at, consumed_at FROM magic_links
+		WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []models.MagicLink
+	for rows.Next() {
+		var (
+			link                 models.MagicLink
+			createdAt, expiresAt int64
+			consumedAt           sql.NullInt64
+		)
+		err := rows.Scan(&link.ID, &link.UserID, &link.AppID, &createdAt, &expiresAt, &consumedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		link.CreatedAt = time.Unix(createdAt, 0)
+		link.ExpiresAt = time.Unix(expiresAt, 0)
+		link.ConsumedAt = unixOrZero(consumedAt)
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
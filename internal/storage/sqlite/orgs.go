@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"modernc.org/sqlite"
+	sqlerr "modernc.org/sqlite/lib"
+)
+
+// SaveOrg saving new organization
+func (s *Storage) SaveOrg(ctx context.Context, name string) (int64, error) {
+	const op = "storage.sqlite.SaveOrg"
+
+	res, err := s.db.ExecContext(ctx, "INSERT INTO organizations(name) VALUES(?)", name)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlerr.SQLITE_CONSTRAINT_UNIQUE {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Org returns organization by id
+func (s *Storage) Org(ctx context.Context, orgID int64) (models.Org, error) {
+	const op = "storage.sqlite.Org"
+
+	var org models.Org
+	err := s.read.QueryRowContext(ctx,
+		"SELECT id, name FROM organizations WHERE id = ?", orgID).Scan(&org.ID, &org.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Org{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+// Orgs returns organizations ordered by id. Non-zero userID returns
+// only organizations the user is a member of
+func (s *Storage) Orgs(ctx context.Context, userID int64) ([]models.Org, error) {
+	const op = "storage.sqlite.Orgs"
+
+	rows, err := s.read.QueryContext(ctx, `
+		SELECT id, name FROM organizations
+		WHERE ? = 0 OR id IN (SELECT org_id FROM org_members WHERE user_id = ?)
+		ORDER BY id`, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var orgs []models.Org
+	for rows.Next() {
+		var org models.Org
+		if err := rows.Scan(&org.ID, &org.Name); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrg deletes organization with its memberships.
+// Organization that still has apps can't be deleted
+func (s *Storage) DeleteOrg(ctx context.Context, orgID int64) error {
+	const op = "storage.sqlite.DeleteOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var apps int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM apps WHERE org_id = ?", orgID).Scan(&apps); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if apps > 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgHasApps)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM org_members WHERE org_id = ?", orgID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM organizations WHERE id = ?", orgID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrOrgNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveOrgMember adds user to organization or changes his role
+func (s *Storage) SaveOrgMember(ctx context.Context, member models.OrgMember) error {
+	const op = "storage.sqlite.SaveOrgMember"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := orgExists(ctx, tx, member.OrgID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := userExists(ctx, tx, member.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO org_members(org_id, user_id, role) VALUES(?, ?, ?)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = excluded.role`,
+		member.OrgID, member.UserID, member.Role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// OrgMember returns membership of user in organization
+func (s *Storage) OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error) {
+	const op = "storage.sqlite.OrgMember"
+
+	member := models.OrgMember{OrgID: orgID, UserID: userID}
+	err := s.read.QueryRowContext(ctx,
+		"SELECT role FROM org_members WHERE org_id = ? AND user_id = ?", orgID, userID).Scan(&member.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrgMember{}, fmt.Errorf("%s: %w", op, storage.ErrOrgMemberNotFound)
+		}
+
+		return models.OrgMember{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return member, nil
+}
+
+// OrgMembers returns members of organization ordered by user id
+func (s *Storage) OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error) {
+	const op = "storage.sqlite.OrgMembers"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT org_id, user_id, role FROM org_members WHERE org_id = ? ORDER BY user_id", orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var members []models.OrgMember
+	for rows.Next() {
+		var member models.OrgMember
+		if err := rows.Scan(&member.OrgID, &member.UserID, &member.Role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// UserOrgMembers returns memberships of user in organizations
+func (s *Storage) UserOrgMembers(ctx context.Context, userID int64) ([]models.OrgMember, error) {
+	const op = "storage.sqlite.UserOrgMembers"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT org_id, user_id, role FROM org_members WHERE user_id = ? ORDER BY org_id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var members []models.OrgMember
+	for rows.Next() {
+		var member models.OrgMember
+		if err := rows.Scan(&member.OrgID, &member.UserID, &member.Role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// DeleteOrgMember removes user from organization
+func (s *Storage) DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.sqlite.DeleteOrgMember"
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM org_members WHERE org_id = ? AND user_id = ?", orgID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrOrgMemberNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func orgExists(ctx context.Context, tx *sql.Tx, orgID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM organizations WHERE id = ?", orgID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrOrgNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertOutboxEvent writes event to the outbox. Called within transaction
+// of the state change the event is about
+func insertOutboxEvent(ctx context.Context, db execer, event models.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO outbox_events(type, time, user_id, org_id, app_id, data) VALUES(?, ?, ?, ?, ?, ?)",
+		event.Type, event.Time.Unix(), event.UserID, event.OrgID, event.AppID, data)
+
+	return err
+}
+
+// SaveEvent writes event which is not caused by a state change to the outbox
+func (s *Storage) SaveEvent(ctx context.Context, event models.Event) error {
+	const op = "storage.sqlite.SaveEvent"
+
+	if err := insertOutboxEvent(ctx, s.db, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnrelayedEvents returns outbox events not relayed yet in order they were written
+func (s *Storage) UnrelayedEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	const op = "storage.sqlite.UnrelayedEvents"
+
+	events, err := s.queryEvents(ctx, `
+		SELECT id, type, time, user_id, org_id, app_id, data FROM outbox_events
+		WHERE relayed_at IS NULL ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// UserLoginEvents returns events of logins and reauthentications of
+// user in order they were written. Each of them started a session
+func (s *Storage) UserLoginEvents(ctx context.Context, userID int64) ([]models.Event, error) {
+	const op = "storage.sqlite.UserLoginEvents"
+
+	events, err := s.queryEvents(ctx, `
+		SELECT id, type, time, user_id, org_id, app_id, data FROM outbox_events
+		WHERE user_id = ? AND type IN (?, ?) ORDER BY id`,
+		userID, models.EventUserLoggedIn, models.EventUserReauthenticated)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) queryEvents(ctx context.Context, query string, args ...any) ([]models.Event, error) {
+	rows, err := s.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// RelayEvent marks outbox event relayed and schedules its delivery to webhooks
+func (s *Storage) RelayEvent(ctx context.Context, eventID int64, webhookIDs []int64, now time.Time) error {
+	const op = "storage.sqlite.RelayEvent"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, webhookID := range webhookIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries(webhook_id, event_id, next_attempt_at) VALUES(?, ?, ?)
+			ON CONFLICT(webhook_id, event_id) DO NOTHING`,
+			webhookID, eventID, now.Unix())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE outbox_events SET relayed_at = ? WHERE id = ?", now.Unix(), eventID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanEvent(row scanner) (models.Event, error) {
+	var (
+		event models.Event
+		ts    int64
+		data  []byte
+	)
+	if err := row.Scan(&event.ID, &event.Type, &ts, &event.UserID, &event.OrgID, &event.AppID, &data); err != nil {
+		return models.Event{}, err
+	}
+	event.Time = time.Unix(ts, 0)
+
+	if err := json.Unmarshal(data, &event.Data); err != nil {
+		return models.Event{}, err
+	}
+
+	return event, nil
+}
@@ -6,111 +6,245 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
 
 	"github.com/m1al04949/sso-gRPC/internal/config"
 	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/identifiers"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
 	"github.com/m1al04949/sso-gRPC/internal/storage"
 	"modernc.org/sqlite"
 	sqlerr "modernc.org/sqlite/lib"
 )
 
 type Storage struct {
-	db  *sql.DB
-	log *slog.Logger
+	// db is the only connection that writes, so writers queue in the
+	// pool instead of failing with SQLITE_BUSY
+	db *sql.DB
+	// read is a pool of query-only connections. In WAL mode they run
+	// alongside the writer
+	read    *sql.DB
+	stmts   *stmts
+	log     *slog.Logger
+	keyring *secrets.Keyring
 }
 
-// New instance of storage
-func New(log *slog.Logger, dbCfg config.DBConfig) (*Storage, error) {
+// New instance of storage. Keyring is used to encrypt app secrets at rest
+func New(log *slog.Logger, dbCfg config.DBConfig, keyring *secrets.Keyring) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite",
-		fmt.Sprintf("file:%s?_busy_timeout=%s&_journal_mode=%s",
-			dbCfg.StoragePath, dbCfg.BusyTimeout, dbCfg.JournalMode))
+	ctx := context.Background()
+
+	db, err := open(ctx, dsn(dbCfg, false), 1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	read, err := open(ctx, dsn(dbCfg, true), max(dbCfg.MaxReadConns, 1))
 	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	st, err := prepareStmts(ctx, read)
+	if err != nil {
+		read.Close()
+		db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return &Storage{
-		db:  db,
-		log: log}, nil
+		db:      db,
+		read:    read,
+		stmts:   st,
+		log:     log,
+		keyring: keyring}, nil
+}
+
+// open opens pool of at most maxConns connections and checks database
+// can be reached
+func open(ctx context.Context, dsn string, maxConns int) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// dsn builds data source name of the database file. Writer transactions
+// take the write lock when they begin, so they wait for other processes
+// for busy timeout instead of failing on lock upgrade
+func dsn(dbCfg config.DBConfig, readOnly bool) string {
+	q := url.Values{}
+	if dbCfg.BusyTimeout != "" {
+		q.Add("_pragma", fmt.Sprintf("busy_timeout(%s)", dbCfg.BusyTimeout))
+	}
+
+	if readOnly {
+		q.Add("_pragma", "query_only(1)")
+	} else {
+		q.Set("_txlock", "immediate")
+		if dbCfg.JournalMode != "" {
+			q.Add("_pragma", fmt.Sprintf("journal_mode(%s)", dbCfg.JournalMode))
+		}
+		if dbCfg.Synchronous != "" {
+			q.Add("_pragma", fmt.Sprintf("synchronous(%s)", dbCfg.Synchronous))
+		}
+		if dbCfg.ForeignKeys {
+			q.Add("_pragma", "foreign_keys(1)")
+		}
+	}
+
+	return "file:" + dbCfg.StoragePath + "?" + q.Encode()
 }
 
 // SaveUser saving new user
 func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 
-	stmt, err := s.db.Prepare("INSERT INTO users(email, pass_hash) VALUES(?, ?)")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	res, err := stmt.ExecContext(ctx, email, passHash)
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO users(email, email_canonical, pass_hash) VALUES(?, ?, ?)",
+		email, identifiers.CanonicalEmail(email), passHash)
 	if err != nil {
-		var sqliteErr *sqlite.Error
+		return 0, fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
 
-		if errors.As(err, &sqliteErr) {
-			switch sqliteErr.Code() {
-			case sqlerr.SQLITE_CONSTRAINT_UNIQUE:
-				return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
-			default:
-				return 0, fmt.Errorf("%s: sqlite error [%d]: %w", op, sqliteErr.Code(), err)
-			}
-		}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
+	event := models.Event{Type: models.EventUserRegistered, UserID: id}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveOrgUser saving new user with email unique in namespace
+// and makes him a member of organization
+func (s *Storage) SaveOrgUser(
+	ctx context.Context,
+	namespace int64,
+	orgID int64,
+	email string,
+	passHash []byte,
+) (int64, error) {
+	const op = "storage.sqlite.SaveOrgUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := orgExists(ctx, tx, orgID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO users(org_id, email, email_canonical, pass_hash) VALUES(?, ?, ?, ?)",
+		namespace, email, identifiers.CanonicalEmail(email), passHash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, userConstraintErr(err))
+	}
+
 	id, err := res.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO org_members(org_id, user_id, role) VALUES(?, ?, ?)", orgID, id, models.OrgRoleMember)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.Event{Type: models.EventUserRegistered, UserID: id, OrgID: orgID}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return id, nil
 }
 
-// User returns user by email
+// User returns user by case-insensitive email from the shared namespace
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE email = ?")
+	user, err := s.namespaceUser(ctx, 0, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// OrgUser returns user by case-insensitive email from the namespace
+func (s *Storage) OrgUser(ctx context.Context, namespace int64, email string) (models.User, error) {
+	const op = "storage.sqlite.OrgUser"
+
+	user, err := s.namespaceUser(ctx, namespace, email)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
 
-	row := stmt.QueryRowContext(ctx, email)
+	return user, nil
+}
+
+// namespaceUser looks user up by canonical email. Unresolved duplicates
+// have no canonical email and are found by exact email, which wins
+func (s *Storage) namespaceUser(ctx context.Context, namespace int64, email string) (models.User, error) {
+	row := s.stmts.namespaceUser.QueryRowContext(ctx, namespace, identifiers.CanonicalEmail(email), email, email)
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			return models.User{}, storage.ErrUserNotFound
 		}
 
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return models.User{}, err
 	}
 
 	return user, nil
 }
 
-// IsAdmin check user is admin
+// IsAdmin check user is admin. User is admin if he has admin flag
+// or admin role assigned directly or through a group
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users WHERE  id = ?")
-	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
-	}
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, userID)
+	row := s.stmts.isAdmin.QueryRowContext(ctx, models.RoleAdmin, models.RoleAdmin, userID)
 
 	var isAdmin bool
-	err = row.Scan(&isAdmin)
+	err := row.Scan(&isAdmin)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
 
 		return false, fmt.Errorf("%s: %w", op, err)
@@ -123,16 +257,9 @@ func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ? ")
-	if err != nil {
-		return models.App{}, fmt.Errorf("%s: %w", op, err)
-	}
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, appID)
+	row := s.stmts.app.QueryRowContext(ctx, appID)
 
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	app, err := s.scanApp(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, storage.ErrAppNotFound
@@ -144,9 +271,31 @@ func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	return app, nil
 }
 
+func userConstraintErr(err error) error {
+	var sqliteErr *sqlite.Error
+
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlerr.SQLITE_CONSTRAINT_UNIQUE:
+			switch {
+			case strings.Contains(sqliteErr.Error(), "users.username"):
+				return storage.ErrUsernameExists
+			case strings.Contains(sqliteErr.Error(), "users.phone"):
+				return storage.ErrPhoneExists
+			}
+
+			return storage.ErrUserExists
+		default:
+			return fmt.Errorf("sqlite error [%d]: %w", sqliteErr.Code(), err)
+		}
+	}
+
+	return err
+}
+
 // Close closing storage
 func (s *Storage) Close() {
-	if err := s.db.Close(); err != nil {
+	if err := errors.Join(s.stmts.close(), s.read.Close(), s.db.Close()); err != nil {
 		s.log.Error(err.Error())
 		return
 	}
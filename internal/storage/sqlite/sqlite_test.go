@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/m1al04949/sso-gRPC/internal/config"
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/lib/secrets"
+	"github.com/m1al04949/sso-gRPC/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+const testKEK = "b2xkLWtleS1lbmNyeXB0aW9uLWtleS0zMi1ieXRlcyE="
+
+// newTestStorage migrates a fresh database in a temporary directory
+// and opens storage with pool of maxReadConns readers in it
+func newTestStorage(t testing.TB, maxReadConns int) *Storage {
+	t.Helper()
+
+	return openTestStorage(t, newTestDB(t), maxReadConns)
+}
+
+// newTestDB migrates a fresh database in a temporary directory and
+// returns its path
+func newTestDB(t testing.TB) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+
+	m, err := migrate.New("file://../../../migrations",
+		fmt.Sprintf("sqlite://%s?x-migrations-table=migrations", path))
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	m.Close()
+
+	return path
+}
+
+// openTestStorage opens another storage on the database file, as
+// another process would
+func openTestStorage(t testing.TB, path string, maxReadConns int) *Storage {
+	t.Helper()
+
+	keyring, err := secrets.New("v1", testKEK, nil)
+	require.NoError(t, err)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := New(log, config.DBConfig{
+		StoragePath:  path,
+		BusyTimeout:  "5000",
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		ForeignKeys:  true,
+		MaxReadConns: maxReadConns,
+	}, keyring)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage { return newTestStorage(t, 4) })
+}
+
+// BenchmarkApp compares statement prepared at startup with preparing it
+// on every call
+func BenchmarkApp(b *testing.B) {
+	s := newTestStorage(b, 4)
+	ctx := context.Background()
+
+	appID, err := s.SaveApp(ctx, "app", "secret", 0)
+	require.NoError(b, err)
+
+	b.Run("prepared", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := s.App(ctx, appID); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+
+	b.Run("per_call", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				stmt, err := s.read.PrepareContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id = ?")
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				if _, err := s.scanApp(stmt.QueryRowContext(ctx, appID)); err != nil {
+					b.Error(err)
+				}
+				stmt.Close()
+			}
+		})
+	})
+}
+
+// BenchmarkReadConns shows reads scale with the reader pool while
+// another goroutine keeps writing
+func BenchmarkReadConns(b *testing.B) {
+	for _, conns := range []int{1, 4} {
+		b.Run(fmt.Sprintf("conns=%d", conns), func(b *testing.B) {
+			s := newTestStorage(b, conns)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			userID, err := s.SaveUser(ctx, "user@example.com", []byte("hash"))
+			require.NoError(b, err)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for ctx.Err() == nil {
+					s.SaveAuditEvent(ctx, models.AuditEvent{Time: time.Now(), ActorID: userID, Action: "login"}, false)
+				}
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.UserByID(ctx, userID); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
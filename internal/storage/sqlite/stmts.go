@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// stmts are queries of the login and token paths prepared once on the
+// reader pool. database/sql re-prepares them on new connections itself
+type stmts struct {
+	namespaceUser  *sql.Stmt
+	userByID       *sql.Stmt
+	userByUsername *sql.Stmt
+	userByPhone    *sql.Stmt
+	isAdmin        *sql.Stmt
+	app            *sql.Stmt
+}
+
+func prepareStmts(ctx context.Context, db *sql.DB) (*stmts, error) {
+	st := &stmts{}
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&st.namespaceUser, "SELECT " + userColumns + ` FROM users
+			WHERE org_id = ? AND (email_canonical = ? OR (email_canonical IS NULL AND email = ?))
+			ORDER BY email = ? DESC
+			LIMIT 1`},
+		{&st.userByID, "SELECT " + userColumns + " FROM users WHERE id = ?"},
+		{&st.userByUsername, "SELECT " + userColumns + " FROM users WHERE org_id = ? AND username = ?"},
+		{&st.userByPhone, "SELECT " + userColumns + " FROM users WHERE org_id = ? AND phone = ?"},
+		{&st.isAdmin, `
+			SELECT is_admin
+				OR EXISTS (SELECT 1 FROM user_roles WHERE user_id = users.id AND role = ?)
+				OR EXISTS (SELECT 1 FROM group_roles gr
+					JOIN group_members gm ON gm.group_id = gr.group_id
+					WHERE gm.user_id = users.id AND gr.role = ?)
+			FROM users WHERE id = ?`},
+		{&st.app, "SELECT " + appColumns + " FROM apps WHERE id = ?"},
+	}
+
+	for _, q := range queries {
+		stmt, err := db.PrepareContext(ctx, q.query)
+		if err != nil {
+			st.close()
+
+			return nil, fmt.Errorf("prepare %q: %w", q.query, err)
+		}
+		*q.stmt = stmt
+	}
+
+	return st, nil
+}
+
+func (st *stmts) close() error {
+	var errs []error
+	for _, stmt := range []*sql.Stmt{
+		st.namespaceUser, st.userByID, st.userByUsername, st.userByPhone, st.isAdmin, st.app,
+	} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+)
+
+// SaveTrustedDevice saving device of user identified by hash of its
+// token. Expired devices of the user are deleted along the way
+func (s *Storage) SaveTrustedDevice(
+	ctx context.Context,
+	device models.TrustedDevice,
+	tokenHash string,
+) (int64, error) {
+	const op = "storage.sqlite.SaveTrustedDevice"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM trusted_devices WHERE user_id = ? AND expires_at <= ?", device.UserID, device.CreatedAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO trusted_devices(user_id, token_hash, user_agent, ip, created_at, expires_at)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		device.UserID, tokenHash, device.UserAgent, device.IP, device.CreatedAt.Unix(), device.ExpiresAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UseTrustedDevice marks unexpired device of user with the token hash as
+// used. Returns storage.ErrTrustedDeviceNotFound if there is no such device
+func (s *Storage) UseTrustedDevice(ctx context.Context, userID int64, tokenHash string, now time.Time) error {
+	const op = "storage.sqlite.UseTrustedDevice"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE trusted_devices SET last_used_at = ?
+		WHERE user_id = ? AND token_hash = ? AND expires_at > ?`,
+		now.Unix(), userID, tokenHash, now.Unix())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrTrustedDeviceNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TrustedDevices returns unexpired devices of user, newest first.
+// Zero now returns expired devices kept so far too
+func (s *Storage) TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error) {
+	const op = "storage.sqlite.TrustedDevices"
+
+	rows, err := s.read.QueryContext(ctx, `
+		SELECT id, user_id, user_agent, ip, created_at, expires_at, last_used_at
+		FROM trusted_devices WHERE user_id = ? AND expires_at > ?
+		ORDER BY id DESC`, userID, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var devices []models.TrustedDevice
+	for rows.Next() {
+		var (
+			device               models.TrustedDevice
+			createdAt, expiresAt int64
+			lastUsedAt           sql.NullInt64
+		)
+		err := rows.Scan(&device.ID, &device.UserID, &device.UserAgent, &device.IP,
+			&createdAt, &expiresAt, &lastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		device.CreatedAt = time.Unix(createdAt, 0)
+		device.ExpiresAt = time.Unix(expiresAt, 0)
+		device.LastUsedAt = unixOrZero(lastUsedAt)
+
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// DeleteTrustedDevice deletes device of user
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.sqlite.DeleteTrustedDevice"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrTrustedDeviceNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteTrustedDevices deletes all devices of user and returns how many
+// were deleted
+func (s *Storage) DeleteTrustedDevices(ctx context.Context, userID int64) (int64, error) {
+	const op = "storage.sqlite.DeleteTrustedDevices"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,230 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"modernc.org/sqlite"
+	sqlerr "modernc.org/sqlite/lib"
+)
+
+const webAuthnCredentialColumns = `id, user_id, name, credential_id, public_key, attestation_type,
+	COALESCE(aaguid, x''), sign_count, transports, backup_eligible, backup_state, created_at, last_used_at`
+
+func scanWebAuthnCredential(row scanner) (models.WebAuthnCredential, error) {
+	var (
+		cred       models.WebAuthnCredential
+		transports string
+		createdAt  int64
+		lastUsedAt sql.NullInt64
+	)
+
+	err := row.Scan(&cred.ID, &cred.UserID, &cred.Name, &cred.CredentialID, &cred.PublicKey,
+		&cred.AttestationType, &cred.AAGUID, &cred.SignCount, &transports,
+		&cred.BackupEligible, &cred.BackupState, &createdAt, &lastUsedAt)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+	cred.CreatedAt = time.Unix(createdAt, 0)
+	cred.LastUsedAt = unixOrZero(lastUsedAt)
+
+	return cred, nil
+}
+
+// SaveWebAuthnCredential saving new passkey of user. Returns
+// storage.ErrWebAuthnCredentialExists if credential id is registered
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (int64, error) {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO webauthn_credentials(user_id, name, credential_id, public_key, attestation_type,
+			aaguid, sign_count, transports, backup_eligible, backup_state, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		cred.UserID, cred.Name, cred.CredentialID, cred.PublicKey, cred.AttestationType,
+		cred.AAGUID, cred.SignCount, strings.Join(cred.Transports, ","),
+		cred.BackupEligible, cred.BackupState, cred.CreatedAt.Unix())
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlerr.SQLITE_CONSTRAINT_UNIQUE {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// WebAuthnCredentials returns passkeys of user ordered by id
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.WebAuthnCredentials"
+
+	rows, err := s.read.QueryContext(ctx,
+		"SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		creds = append(creds, cred)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+// UpdateWebAuthnCredentialUse saves signature counter and backup state
+// reported by the last assertion of passkey. Counter only grows, so
+// concurrent assertions by a cloned authenticator don't roll it back
+func (s *Storage) UpdateWebAuthnCredentialUse(
+	ctx context.Context,
+	id int64,
+	signCount uint32,
+	backupState bool,
+	now time.Time,
+) error {
+	const op = "storage.sqlite.UpdateWebAuthnCredentialUse"
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE webauthn_credentials SET sign_count = MAX(sign_count, ?), backup_state = ?, last_used_at = ?
+		WHERE id = ?`, signCount, backupState, now.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrWebAuthnCredentialNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteWebAuthnCredential deletes passkey of user
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.sqlite.DeleteWebAuthnCredential"
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrWebAuthnCredentialNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveWebAuthnSession saving state of started ceremony identified by
+// hash of its id. Expired sessions are deleted along the way
+func (s *Storage) SaveWebAuthnSession(
+	ctx context.Context,
+	session models.WebAuthnSession,
+	sessionHash string,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.SaveWebAuthnSession"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webauthn_sessions WHERE expires_at <= ?", now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO webauthn_sessions(session_hash, user_id, app_id, purpose, data, expires_at)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		sessionHash, session.UserID, session.AppID, session.Purpose, session.Data, session.ExpiresAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// TakeWebAuthnSession deletes unexpired session by hash of its id and
+// returns it. Session can be taken only once, otherwise
+// storage.ErrWebAuthnSessionNotFound is returned
+func (s *Storage) TakeWebAuthnSession(
+	ctx context.Context,
+	sessionHash string,
+	now time.Time,
+) (models.WebAuthnSession, error) {
+	const op = "storage.sqlite.TakeWebAuthnSession"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		session   models.WebAuthnSession
+		expiresAt int64
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, app_id, purpose, data, expires_at FROM webauthn_sessions
+		WHERE session_hash = ?`, sessionHash).
+		Scan(&session.ID, &session.UserID, &session.AppID, &session.Purpose, &session.Data, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+		}
+
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM webauthn_sessions WHERE id = ?", session.ID)
+	if err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAffected(res, storage.ErrWebAuthnSessionNotFound); err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !now.Before(session.ExpiresAt) {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+	}
+
+	return session, nil
+}
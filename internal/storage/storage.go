@@ -3,7 +3,35 @@ package storage
 import "errors"
 
 var (
-	ErrUserExists   = errors.New("user already exists")
-	ErrUserNotFound = errors.New("user not found")
-	ErrAppNotFound  = errors.New("app not found")
+	ErrUserExists     = errors.New("user already exists")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrUsernameExists = errors.New("username already taken")
+	ErrPhoneExists    = errors.New("phone already taken")
+	ErrStatusConflict = errors.New("user status changed concurrently")
+	ErrAppNotFound    = errors.New("app not found")
+	ErrAppExists      = errors.New("app already exists")
+
+	ErrOrgExists         = errors.New("organization already exists")
+	ErrOrgNotFound       = errors.New("organization not found")
+	ErrOrgHasApps        = errors.New("organization has apps")
+	ErrOrgMemberNotFound = errors.New("organization member not found")
+
+	ErrGroupExists         = errors.New("group already exists")
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrGroupMemberNotFound = errors.New("group member not found")
+
+	ErrInvitationNotFound = errors.New("invitation not found")
+
+	ErrEmailChangeNotFound = errors.New("email change not found")
+
+	ErrMagicLinkNotFound    = errors.New("magic link not found")
+	ErrOTPChallengeNotFound = errors.New("otp challenge not found")
+
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential already registered")
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebAuthnSessionNotFound    = errors.New("webauthn session not found")
+	ErrTrustedDeviceNotFound      = errors.New("trusted device not found")
+
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
 )
@@ -0,0 +1,212 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testApps(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id, err := s.SaveApp(ctx, "app", "secret", 0)
+	require.NoError(t, err)
+
+	_, err = s.SaveApp(ctx, "app", "other-secret", 0)
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+	_, err = s.SaveApp(ctx, "org-app", "secret", 100)
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	app, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.App{ID: id, Name: "app", Secret: "secret"}, app)
+
+	_, err = s.App(ctx, id+100)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	other := newApp(t, s, "other")
+	assert.ErrorIs(t, s.UpdateApp(ctx, other, "app"), storage.ErrAppExists)
+	assert.ErrorIs(t, s.UpdateApp(ctx, id+100, "name"), storage.ErrAppNotFound)
+	require.NoError(t, s.UpdateApp(ctx, id, "renamed"))
+
+	expiresAt := now.Add(time.Hour)
+	require.NoError(t, s.RotateAppSecret(ctx, id, "rotated", expiresAt))
+	assert.ErrorIs(t, s.RotateAppSecret(ctx, id+100, "rotated", expiresAt), storage.ErrAppNotFound)
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", app.Name)
+	assert.Equal(t, "rotated", app.Secret)
+	assert.Equal(t, "secret", app.PrevSecret)
+	assert.True(t, expiresAt.Equal(app.PrevSecretExpiresAt))
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	orgApp, err := s.SaveApp(ctx, "org-app", "org-secret", orgID)
+	require.NoError(t, err)
+
+	apps, err := s.Apps(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{id, other, orgApp}, appIDs(apps))
+
+	apps, err = s.Apps(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{orgApp}, appIDs(apps))
+	assert.Equal(t, orgID, apps[0].OrgID)
+
+	// App is deleted with its webhooks
+	webhookID, err := s.SaveWebhook(ctx, models.Webhook{AppID: other, URL: "http://hook", Secret: "s", CreatedAt: now})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteApp(ctx, other))
+	assert.ErrorIs(t, s.DeleteApp(ctx, other), storage.ErrAppNotFound)
+	_, err = s.App(ctx, other)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	assert.ErrorIs(t, s.DeleteWebhook(ctx, webhookID), storage.ErrWebhookNotFound)
+}
+
+func testOrgs(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	_, err = s.SaveOrg(ctx, "org")
+	assert.ErrorIs(t, err, storage.ErrOrgExists)
+	otherOrg, err := s.SaveOrg(ctx, "other")
+	require.NoError(t, err)
+
+	org, err := s.Org(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Org{ID: orgID, Name: "org"}, org)
+	_, err = s.Org(ctx, orgID+100)
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	first := newUser(t, s, "first@example.com")
+	second := newUser(t, s, "second@example.com")
+
+	require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: second, Role: models.OrgRoleMember}))
+	require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: first, Role: models.OrgRoleMember}))
+	require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: first, Role: models.OrgRoleAdmin}))
+	require.NoError(t, s.SaveOrgMember(ctx, models.OrgMember{OrgID: otherOrg, UserID: first, Role: models.OrgRoleMember}))
+
+	err = s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID + 100, UserID: first, Role: models.OrgRoleMember})
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+	err = s.SaveOrgMember(ctx, models.OrgMember{OrgID: orgID, UserID: first + 100, Role: models.OrgRoleMember})
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	member, err := s.OrgMember(ctx, orgID, first)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrgRoleAdmin, member.Role)
+
+	members, err := s.OrgMembers(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.OrgMember{
+		{OrgID: orgID, UserID: first, Role: models.OrgRoleAdmin},
+		{OrgID: orgID, UserID: second, Role: models.OrgRoleMember},
+	}, members)
+
+	members, err = s.UserOrgMembers(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, []models.OrgMember{
+		{OrgID: orgID, UserID: first, Role: models.OrgRoleAdmin},
+		{OrgID: otherOrg, UserID: first, Role: models.OrgRoleMember},
+	}, members)
+
+	orgs, err := s.Orgs(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Org{{ID: orgID, Name: "org"}}, orgs)
+	orgs, err = s.Orgs(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, orgs, 2)
+
+	require.NoError(t, s.DeleteOrgMember(ctx, orgID, second))
+	assert.ErrorIs(t, s.DeleteOrgMember(ctx, orgID, second), storage.ErrOrgMemberNotFound)
+	_, err = s.OrgMember(ctx, orgID, second)
+	assert.ErrorIs(t, err, storage.ErrOrgMemberNotFound)
+
+	// Organization with apps can't be deleted
+	appID, err := s.SaveApp(ctx, "app", "secret", orgID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.DeleteOrg(ctx, orgID), storage.ErrOrgHasApps)
+
+	require.NoError(t, s.DeleteApp(ctx, appID))
+	require.NoError(t, s.DeleteOrg(ctx, orgID))
+	assert.ErrorIs(t, s.DeleteOrg(ctx, orgID), storage.ErrOrgNotFound)
+
+	members, err = s.UserOrgMembers(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, []models.OrgMember{{OrgID: otherOrg, UserID: first, Role: models.OrgRoleMember}}, members)
+}
+
+func testGroups(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	groupID, err := s.SaveGroup(ctx, "staff", []string{"viewer", "editor", "viewer"})
+	require.NoError(t, err)
+	_, err = s.SaveGroup(ctx, "staff", nil)
+	assert.ErrorIs(t, err, storage.ErrGroupExists)
+	emptyID, err := s.SaveGroup(ctx, "empty", nil)
+	require.NoError(t, err)
+
+	groups, err := s.Groups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Group{
+		{ID: groupID, Name: "staff", Roles: []string{"editor", "viewer"}},
+		{ID: emptyID, Name: "empty"},
+	}, groups)
+
+	require.NoError(t, s.SetGroupRoles(ctx, groupID, []string{"editor", "auditor"}))
+	assert.ErrorIs(t, s.SetGroupRoles(ctx, groupID+100, nil), storage.ErrGroupNotFound)
+
+	user := newUser(t, s, "user@example.com")
+	require.NoError(t, s.AddUserRole(ctx, user, "viewer"))
+
+	require.NoError(t, s.SaveGroupMember(ctx, groupID, user))
+	require.NoError(t, s.SaveGroupMember(ctx, groupID, user))
+	require.NoError(t, s.SaveGroupMember(ctx, emptyID, user))
+	assert.ErrorIs(t, s.SaveGroupMember(ctx, groupID+100, user), storage.ErrGroupNotFound)
+	assert.ErrorIs(t, s.SaveGroupMember(ctx, groupID, user+100), storage.ErrUserNotFound)
+
+	members, err := s.GroupMembers(ctx, groupID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{user}, members)
+
+	groups, err = s.UserGroups(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Group{
+		{ID: emptyID, Name: "empty"},
+		{ID: groupID, Name: "staff", Roles: []string{"auditor", "editor"}},
+	}, groups)
+
+	roles, err := s.EffectiveRoles(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auditor", "editor", "viewer"}, roles)
+
+	require.NoError(t, s.DeleteGroupMember(ctx, emptyID, user))
+	assert.ErrorIs(t, s.DeleteGroupMember(ctx, emptyID, user), storage.ErrGroupMemberNotFound)
+
+	require.NoError(t, s.DeleteGroup(ctx, groupID))
+	assert.ErrorIs(t, s.DeleteGroup(ctx, groupID), storage.ErrGroupNotFound)
+
+	roles, err = s.EffectiveRoles(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, roles)
+
+	groups, err = s.UserGroups(ctx, user)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func appIDs(apps []models.App) []int {
+	var ids []int
+	for _, app := range apps {
+		ids = append(ids, app.ID)
+	}
+
+	return ids
+}
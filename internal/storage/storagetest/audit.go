@@ -0,0 +1,287 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAudit(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	target := models.AuditTarget(models.AuditTargetUser, 7)
+	events := []struct {
+		event   models.AuditEvent
+		chained bool
+	}{
+		{models.AuditEvent{Time: now.Add(-time.Hour), ActorID: 1, Action: "login", AppID: 1, Result: "ok"}, true},
+		{models.AuditEvent{Time: now, ActorID: 2, Action: "login", AppID: 2, Result: "failed"}, false},
+		{models.AuditEvent{Time: now, ActorID: 1, Action: "delete", Target: target, IP: "10.0.0.1", UserAgent: "agent"}, true},
+		{models.AuditEvent{Time: now, ActorID: 1, Action: "login", AppID: 1, Result: "ok"}, true},
+	}
+
+	var ids []int64
+	for _, e := range events {
+		id, err := s.SaveAuditEvent(ctx, e.event, e.chained)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	got, err := s.AuditEvents(ctx, models.AuditFilter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[3], ids[2], ids[1], ids[0]}, auditIDs(got))
+	assert.Equal(t, target, got[1].Target)
+	assert.Equal(t, "10.0.0.1", got[1].IP)
+	assert.Equal(t, "agent", got[1].UserAgent)
+	assertTime(t, now, got[1].Time)
+
+	// Pages go back from beforeID
+	got, err = s.AuditEvents(ctx, models.AuditFilter{}, ids[2], 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[1]}, auditIDs(got))
+
+	filters := []struct {
+		filter models.AuditFilter
+		want   []int64
+	}{
+		{models.AuditFilter{ActorID: 1}, []int64{ids[3], ids[2], ids[0]}},
+		{models.AuditFilter{Action: "login", AppID: 1}, []int64{ids[3], ids[0]}},
+		{models.AuditFilter{Target: target}, []int64{ids[2]}},
+		{models.AuditFilter{Result: "failed"}, []int64{ids[1]}},
+		{models.AuditFilter{From: now}, []int64{ids[3], ids[2], ids[1]}},
+		{models.AuditFilter{To: now}, []int64{ids[0]}},
+	}
+	for _, f := range filters {
+		got, err = s.AuditEvents(ctx, f.filter, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, f.want, auditIDs(got), "filter %+v", f.filter)
+	}
+
+	// Only chained events are linked, each to the previous chained one
+	chain, err := s.ChainedAuditEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []int64{ids[0], ids[2], ids[3]}, auditIDs(chain))
+	assertChain(t, chain)
+
+	chain, err = s.ChainedAuditEvents(ctx, ids[0], 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[2]}, auditIDs(chain))
+
+	got, err = s.AuditEvents(ctx, models.AuditFilter{ActorID: 2}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Empty(t, got[0].PrevHash)
+	assert.Empty(t, got[0].Hash)
+
+	// User events are the ones user did or was the target of, oldest first
+	got, err = s.UserAuditEvents(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[2]}, auditIDs(got))
+	got, err = s.UserAuditEvents(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[1]}, auditIDs(got))
+}
+
+func testWebhooks(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	appID := newApp(t, s, "app")
+	otherApp := newApp(t, s, "other")
+
+	_, err := s.SaveWebhook(ctx, models.Webhook{AppID: otherApp + 100, URL: "http://hook", CreatedAt: now})
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	webhook := models.Webhook{
+		AppID:      appID,
+		URL:        "http://hook",
+		Secret:     "secret",
+		EventTypes: []string{models.EventUserRegistered},
+		CreatedAt:  now,
+	}
+	webhookID, err := s.SaveWebhook(ctx, webhook)
+	require.NoError(t, err)
+	otherID, err := s.SaveWebhook(ctx, models.Webhook{AppID: otherApp, URL: "http://other", Secret: "s", CreatedAt: now})
+	require.NoError(t, err)
+
+	webhooks, err := s.Webhooks(ctx, appID)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, webhookID, webhooks[0].ID)
+	assert.Equal(t, "http://hook", webhooks[0].URL)
+	assert.Equal(t, "secret", webhooks[0].Secret)
+	assert.Equal(t, []string{models.EventUserRegistered}, webhooks[0].EventTypes)
+	webhooks, err = s.Webhooks(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, webhooks, 2)
+	assert.Empty(t, webhooks[1].EventTypes)
+
+	// Outbox keeps events in order until they are relayed
+	require.NoError(t, s.SaveEvent(ctx, models.Event{
+		Type:  models.EventUserRegistered,
+		Time:  now,
+		AppID: appID,
+		Data:  map[string]string{"key": "value"},
+	}))
+	require.NoError(t, s.SaveEvent(ctx, models.Event{Type: models.EventUserRegistered, Time: now}))
+
+	events, err := s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, appID, events[0].AppID)
+	assert.Equal(t, map[string]string{"key": "value"}, events[0].Data)
+	assertTime(t, now, events[0].Time)
+
+	first, second := events[0].ID, events[1].ID
+	require.NoError(t, s.RelayEvent(ctx, first, []int64{webhookID, otherID}, now))
+	require.NoError(t, s.RelayEvent(ctx, first, []int64{webhookID}, now))
+	require.NoError(t, s.RelayEvent(ctx, second, []int64{webhookID}, now.Add(time.Minute)))
+
+	events, err = s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Event is scheduled once per webhook
+	due, err := s.DueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, first, due[0].Event.ID)
+	assert.Equal(t, first, due[1].Event.ID)
+	assert.Equal(t, models.DeliveryPending, due[0].Status)
+	assert.Equal(t, map[string]string{"key": "value"}, due[0].Event.Data)
+
+	due, err = s.DueWebhookDeliveries(ctx, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 3)
+
+	deliveries, err := s.WebhookDeliveries(ctx, webhookID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, second, deliveries[0].Event.ID)
+	assert.Equal(t, first, deliveries[1].Event.ID)
+
+	dead := deliveries[1]
+	dead.Status = models.DeliveryDead
+	dead.Attempts = 5
+	dead.LastError = "timeout"
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, dead))
+
+	delivered := deliveries[0]
+	delivered.Status = models.DeliveryDelivered
+	delivered.Attempts = 1
+	delivered.DeliveredAt = now
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, delivered))
+
+	missing := dead
+	missing.ID = delivered.ID + 100
+	assert.ErrorIs(t, s.UpdateWebhookDelivery(ctx, missing), storage.ErrWebhookDeliveryNotFound)
+
+	deliveries, err = s.WebhookDeliveries(ctx, webhookID, models.DeliveryDead, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, dead.ID, deliveries[0].ID)
+	assert.Equal(t, 5, deliveries[0].Attempts)
+	assert.Equal(t, "timeout", deliveries[0].LastError)
+
+	due, err = s.DueWebhookDeliveries(ctx, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 1)
+
+	// Replay without delivery id retries dead deliveries only
+	n, err := s.ReplayWebhookDeliveries(ctx, webhookID, 0, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = s.ReplayWebhookDeliveries(ctx, webhookID, delivered.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = s.ReplayWebhookDeliveries(ctx, webhookID, delivered.ID+100, now)
+	assert.ErrorIs(t, err, storage.ErrWebhookDeliveryNotFound)
+	_, err = s.ReplayWebhookDeliveries(ctx, otherID+100, 0, now)
+	assert.ErrorIs(t, err, storage.ErrWebhookNotFound)
+
+	deliveries, err = s.WebhookDeliveries(ctx, webhookID, models.DeliveryPending, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	for _, d := range deliveries {
+		assert.Equal(t, 0, d.Attempts)
+		assert.Empty(t, d.LastError)
+		assert.True(t, d.DeliveredAt.IsZero())
+		assertTime(t, now, d.NextAttemptAt)
+	}
+
+	require.NoError(t, s.DeleteWebhook(ctx, webhookID))
+	assert.ErrorIs(t, s.DeleteWebhook(ctx, webhookID), storage.ErrWebhookNotFound)
+
+	deliveries, err = s.WebhookDeliveries(ctx, webhookID, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// Webhooks carry organization of their app
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	orgApp, err := s.SaveApp(ctx, "org-app", "org-app-secret", orgID)
+	require.NoError(t, err)
+	_, err = s.SaveWebhook(ctx, models.Webhook{AppID: orgApp, URL: "http://org", Secret: "s", CreatedAt: now})
+	require.NoError(t, err)
+
+	webhooks, err = s.Webhooks(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.Zero(t, webhooks[0].OrgID)
+	assert.Equal(t, orgID, webhooks[1].OrgID)
+}
+
+func auditIDs(events []models.AuditEvent) []int64 {
+	var ids []int64
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	return ids
+}
+
+// assertChain checks every event is linked to the previous one and
+// every event but redacted ones matches its hash
+func assertChain(t *testing.T, chain []models.AuditEvent) {
+	t.Helper()
+
+	var prevHash string
+	for _, event := range chain {
+		assert.Equal(t, prevHash, event.PrevHash, "event %d", event.ID)
+		if !event.Redacted {
+			assert.Equal(t, event.ChainHash(event.PrevHash), event.Hash, "event %d", event.ID)
+		}
+		prevHash = event.Hash
+	}
+}
+
+func testLoginEvents(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	other := newUser(t, s, "other@example.com")
+
+	for _, event := range []models.Event{
+		{Type: models.EventUserLoggedIn, Time: now, UserID: user, AppID: 1, Data: map[string]string{"method": "password"}},
+		{Type: models.EventUserLoginFailed, Time: now, UserID: user},
+		{Type: models.EventUserLoggedIn, Time: now, UserID: other},
+		{Type: models.EventUserReauthenticated, Time: now, UserID: user},
+	} {
+		require.NoError(t, s.SaveEvent(ctx, event))
+	}
+
+	events, err := s.UserLoginEvents(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventUserLoggedIn, events[0].Type)
+	assert.Equal(t, 1, events[0].AppID)
+	assert.Equal(t, map[string]string{"method": "password"}, events[0].Data)
+	assertTime(t, now, events[0].Time)
+	assert.Equal(t, models.EventUserReauthenticated, events[1].Type)
+}
@@ -0,0 +1,166 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workers is how many goroutines race for the same row
+const workers = 8
+
+// testConcurrency checks racing callers can't both win what only one may:
+// an email, a single-use code or the next link of the audit chain
+func testConcurrency(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	appID := newApp(t, s, "app")
+
+	wins := race(t, func(int) error {
+		_, err := s.SaveUser(ctx, "racer@example.com", []byte("hash"))
+		return expect(err, storage.ErrUserExists)
+	})
+	assert.Equal(t, 1, wins, "SaveUser")
+
+	_, err := s.SaveMagicLink(ctx, models.MagicLink{
+		UserID:    user,
+		AppID:     appID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "link")
+	require.NoError(t, err)
+
+	wins = race(t, func(int) error {
+		_, err := s.ConsumeMagicLink(ctx, "link", appID, now)
+		return expect(err, storage.ErrMagicLinkNotFound)
+	})
+	assert.Equal(t, 1, wins, "ConsumeMagicLink")
+
+	otpID, err := s.SaveOTPChallenge(ctx, models.OTPChallenge{
+		UserID:     user,
+		AppID:      appID,
+		Purpose:    models.OTPPurposeLogin,
+		Channel:    "email",
+		CodeHash:   "code",
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour),
+		LastSentAt: now,
+	}, "challenge")
+	require.NoError(t, err)
+
+	wins = race(t, func(int) error {
+		return expect(s.AddOTPAttempt(ctx, otpID, 3), storage.ErrOTPChallengeNotFound)
+	})
+	assert.Equal(t, 3, wins, "AddOTPAttempt")
+
+	wins = race(t, func(int) error {
+		return expect(s.ConsumeOTPChallenge(ctx, otpID, now), storage.ErrOTPChallengeNotFound)
+	})
+	assert.Equal(t, 1, wins, "ConsumeOTPChallenge")
+
+	_, err = s.SaveWebAuthnSession(ctx, models.WebAuthnSession{
+		UserID:    user,
+		Purpose:   models.WebAuthnPurposeLogin,
+		Data:      []byte("data"),
+		ExpiresAt: now.Add(time.Minute),
+	}, "session", now)
+	require.NoError(t, err)
+
+	wins = race(t, func(int) error {
+		_, err := s.TakeWebAuthnSession(ctx, "session", now)
+		return expect(err, storage.ErrWebAuthnSessionNotFound)
+	})
+	assert.Equal(t, 1, wins, "TakeWebAuthnSession")
+
+	// Pending changes of different users onto the same email
+	changes := make([]int64, workers)
+	for i := range workers {
+		email := fmt.Sprintf("changer%d@example.com", i)
+		changes[i], err = s.SaveEmailChange(ctx, models.EmailChange{
+			UserID:    newUser(t, s, email),
+			OldEmail:  email,
+			NewEmail:  "wanted@example.com",
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+		}, fmt.Sprintf("code%d", i), fmt.Sprintf("cancel%d", i))
+		require.NoError(t, err)
+	}
+
+	wins = race(t, func(i int) error {
+		return expect(s.ConfirmEmailChange(ctx, changes[i], now), storage.ErrUserExists)
+	})
+	assert.Equal(t, 1, wins, "ConfirmEmailChange")
+
+	wins = race(t, func(i int) error {
+		_, err := s.SaveAuditEvent(ctx, models.AuditEvent{
+			Time:    now,
+			ActorID: int64(i),
+			Action:  "login",
+		}, true)
+		return err
+	})
+	assert.Equal(t, workers, wins, "SaveAuditEvent")
+
+	chain, err := s.ChainedAuditEvents(ctx, 0, workers+1)
+	require.NoError(t, err)
+	assert.Len(t, chain, workers)
+	assertChain(t, chain)
+}
+
+// race runs fn in workers goroutines at once and returns how many of
+// them succeeded. fn returns nil on success and errLost if it lost the race
+func race(t *testing.T, fn func(i int) error) int {
+	t.Helper()
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		wins  atomic.Int32
+		errs  = make(chan error, workers)
+	)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			switch err := fn(i); err {
+			case nil:
+				wins.Add(1)
+			case errLost:
+			default:
+				errs <- err
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	return int(wins.Load())
+}
+
+var errLost = errors.New("lost the race")
+
+// expect turns the error of a caller that lost the race into errLost
+func expect(err error, lost error) error {
+	if errors.Is(err, lost) {
+		return errLost
+	}
+
+	return err
+}
@@ -0,0 +1,203 @@
+// Package storagetest is a conformance suite every storage backend runs,
+// so backends agree on errors, ordering, atomicity and concurrency
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage is what services expect from storage backend
+type Storage interface {
+	// Users
+	SaveUser(ctx context.Context, email string, passHash []byte) (int64, error)
+	SaveOrgUser(ctx context.Context, namespace int64, orgID int64, email string, passHash []byte) (int64, error)
+	User(ctx context.Context, email string) (models.User, error)
+	OrgUser(ctx context.Context, namespace int64, email string) (models.User, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	UserByUsername(ctx context.Context, namespace int64, username string) (models.User, error)
+	UserByPhone(ctx context.Context, namespace int64, phone string) (models.User, error)
+	SetUserIdentifiers(ctx context.Context, userID int64, username string, phone string) error
+	Users(ctx context.Context, filter models.UserFilter, afterID int64, limit int) ([]models.User, error)
+	UserRoles(ctx context.Context, userID int64) ([]string, error)
+	SetUserStatus(ctx context.Context, userID int64, from models.AccountStatus, to models.AccountStatus, reason string, now time.Time) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	SetPassResetRequired(ctx context.Context, userID int64, required bool) error
+	SetPassword(ctx context.Context, userID int64, passHash []byte) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	SetUserMFAChannel(ctx context.Context, userID int64, channel string) error
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+	AddUserRole(ctx context.Context, userID int64, role string) error
+
+	// Erasures
+	EraseUser(ctx context.Context, userID int64, erasedBy int64, now time.Time) error
+
+	// Profiles
+	UserProfile(ctx context.Context, userID int64) (models.Profile, error)
+	SaveUserProfile(ctx context.Context, profile models.Profile) error
+
+	// Apps
+	App(ctx context.Context, appID int) (models.App, error)
+	SaveApp(ctx context.Context, name string, secret string, orgID int64) (int, error)
+	Apps(ctx context.Context, orgID int64) ([]models.App, error)
+	UpdateApp(ctx context.Context, appID int, name string) error
+	RotateAppSecret(ctx context.Context, appID int, secret string, prevExpiresAt time.Time) error
+	DeleteApp(ctx context.Context, appID int) error
+
+	// Organizations
+	SaveOrg(ctx context.Context, name string) (int64, error)
+	Org(ctx context.Context, orgID int64) (models.Org, error)
+	Orgs(ctx context.Context, userID int64) ([]models.Org, error)
+	DeleteOrg(ctx context.Context, orgID int64) error
+	SaveOrgMember(ctx context.Context, member models.OrgMember) error
+	OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error)
+	OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error)
+	UserOrgMembers(ctx context.Context, userID int64) ([]models.OrgMember, error)
+	DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error
+
+	// Groups
+	SaveGroup(ctx context.Context, name string, roles []string) (int64, error)
+	Groups(ctx context.Context) ([]models.Group, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	SetGroupRoles(ctx context.Context, groupID int64, roles []string) error
+	DeleteGroup(ctx context.Context, groupID int64) error
+	SaveGroupMember(ctx context.Context, groupID int64, userID int64) error
+	DeleteGroupMember(ctx context.Context, groupID int64, userID int64) error
+	GroupMembers(ctx context.Context, groupID int64) ([]int64, error)
+	EffectiveRoles(ctx context.Context, userID int64) ([]string, error)
+
+	// Invitations
+	SaveInvitation(ctx context.Context, inv models.Invitation, codeHash string) (int64, error)
+	Invitation(ctx context.Context, id int64) (models.Invitation, error)
+	InvitationByCode(ctx context.Context, codeHash string) (models.Invitation, error)
+	Invitations(ctx context.Context, orgID int64) ([]models.Invitation, error)
+	UserInvitations(ctx context.Context, userID int64, email string) ([]models.Invitation, error)
+	AcceptInvitation(ctx context.Context, id int64, userID int64, now time.Time) error
+	RevokeInvitation(ctx context.Context, id int64, now time.Time) error
+
+	// Email changes
+	SaveEmailChange(ctx context.Context, change models.EmailChange, codeHash string, cancelHash string) (int64, error)
+	EmailChangeByCode(ctx context.Context, codeHash string) (models.EmailChange, error)
+	EmailChangeByCancelCode(ctx context.Context, cancelHash string) (models.EmailChange, error)
+	UserEmailChanges(ctx context.Context, userID int64) ([]models.EmailChange, error)
+	ConfirmEmailChange(ctx context.Context, id int64, now time.Time) error
+	CancelEmailChange(ctx context.Context, id int64, now time.Time) error
+
+	// Magic links
+	SaveMagicLink(ctx context.Context, link models.MagicLink, codeHash string) (int64, error)
+	ConsumeMagicLink(ctx context.Context, codeHash string, appID int, now time.Time) (models.MagicLink, error)
+	UserMagicLinks(ctx context.Context, userID int64) ([]models.MagicLink, error)
+
+	// One-time codes
+	SaveOTPChallenge(ctx context.Context, ch models.OTPChallenge, challengeHash string) (int64, error)
+	OTPChallenge(ctx context.Context, challengeHash string) (models.OTPChallenge, error)
+	LatestOTPChallenge(ctx context.Context, userID int64, appID int) (models.OTPChallenge, error)
+	AddOTPAttempt(ctx context.Context, id int64, maxAttempts int) error
+	ResendOTPChallenge(ctx context.Context, id int64, codeHash string, now time.Time) error
+	ConsumeOTPChallenge(ctx context.Context, id int64, now time.Time) error
+	UserOTPChallenges(ctx context.Context, userID int64) ([]models.OTPChallenge, error)
+
+	// Passkeys
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (int64, error)
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnCredentialUse(ctx context.Context, id int64, signCount uint32, backupState bool, now time.Time) error
+	DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error
+	SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession, sessionHash string, now time.Time) (int64, error)
+	TakeWebAuthnSession(ctx context.Context, sessionHash string, now time.Time) (models.WebAuthnSession, error)
+
+	// Login attempts
+	SaveLoginAttempt(ctx context.Context, attempt models.LoginAttempt, keepAfter time.Time) (int64, error)
+	LoginAttempts(ctx context.Context, userID int64, since time.Time, limit int) ([]models.LoginAttempt, error)
+	UserLoginAttempts(ctx context.Context, userID int64) ([]models.LoginAttempt, error)
+
+	// Trusted devices
+	SaveTrustedDevice(ctx context.Context, device models.TrustedDevice, tokenHash string) (int64, error)
+	UseTrustedDevice(ctx context.Context, userID int64, tokenHash string, now time.Time) error
+	TrustedDevices(ctx context.Context, userID int64, now time.Time) ([]models.TrustedDevice, error)
+	DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error
+	DeleteTrustedDevices(ctx context.Context, userID int64) (int64, error)
+
+	// Audit log
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent, chained bool) (int64, error)
+	AuditEvents(ctx context.Context, filter models.AuditFilter, beforeID int64, limit int) ([]models.AuditEvent, error)
+	ChainedAuditEvents(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error)
+	UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error)
+
+	// Outbox
+	SaveEvent(ctx context.Context, event models.Event) error
+	UnrelayedEvents(ctx context.Context, limit int) ([]models.Event, error)
+	UserLoginEvents(ctx context.Context, userID int64) ([]models.Event, error)
+	RelayEvent(ctx context.Context, eventID int64, webhookIDs []int64, now time.Time) error
+
+	// Webhooks
+	SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error)
+	Webhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int64) error
+	DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	WebhookDeliveries(ctx context.Context, webhookID int64, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	ReplayWebhookDeliveries(ctx context.Context, webhookID int64, deliveryID int64, now time.Time) (int64, error)
+}
+
+// Run runs the suite. newStorage returns empty storage for each test
+// and releases it with t.Cleanup
+func Run(t *testing.T, newStorage func(t *testing.T) Storage) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s Storage)
+	}{
+		{"Users", testUsers},
+		{"UserIdentifiers", testUserIdentifiers},
+		{"UserStatus", testUserStatus},
+		{"Admins", testAdmins},
+		{"Erasure", testErasure},
+		{"Profiles", testProfiles},
+		{"Apps", testApps},
+		{"Orgs", testOrgs},
+		{"Groups", testGroups},
+		{"Invitations", testInvitations},
+		{"EmailChanges", testEmailChanges},
+		{"MagicLinks", testMagicLinks},
+		{"OTPChallenges", testOTPChallenges},
+		{"WebAuthn", testWebAuthn},
+		{"LoginAttempts", testLoginAttempts},
+		{"TrustedDevices", testTrustedDevices},
+		{"Audit", testAudit},
+		{"Webhooks", testWebhooks},
+		{"LoginEvents", testLoginEvents},
+		{"Transactions", testTransactions},
+		{"Concurrency", testConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage(t))
+		})
+	}
+}
+
+// now is the time tests run at. SQL backends keep seconds only
+var now = time.Unix(time.Now().Unix(), 0)
+
+func newUser(t *testing.T, s Storage, email string) int64 {
+	t.Helper()
+
+	id, err := s.SaveUser(context.Background(), email, []byte("hash"))
+	require.NoError(t, err)
+
+	return id
+}
+
+func newApp(t *testing.T, s Storage, name string) int {
+	t.Helper()
+
+	id, err := s.SaveApp(context.Background(), name, name+"-secret", 0)
+	require.NoError(t, err)
+
+	return id
+}
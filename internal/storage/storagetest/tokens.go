@@ -0,0 +1,469 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInvitations(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	admin := newUser(t, s, "admin@example.com")
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+
+	inv := models.Invitation{
+		Email:     "Invitee@Example.com",
+		OrgID:     orgID,
+		Role:      models.OrgRoleMember,
+		CreatedBy: admin,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	id, err := s.SaveInvitation(ctx, inv, "code")
+	require.NoError(t, err)
+
+	inv.OrgID = 0
+	inv.Email = "other@example.com"
+	otherID, err := s.SaveInvitation(ctx, inv, "other-code")
+	require.NoError(t, err)
+
+	got, err := s.Invitation(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Invitee@Example.com", got.Email)
+	assert.Equal(t, orgID, got.OrgID)
+	assert.Equal(t, admin, got.CreatedBy)
+	assertTime(t, now.Add(time.Hour), got.ExpiresAt)
+	assert.True(t, got.Pending(now))
+
+	_, err = s.Invitation(ctx, otherID+100)
+	assert.ErrorIs(t, err, storage.ErrInvitationNotFound)
+
+	got, err = s.InvitationByCode(ctx, "other-code")
+	require.NoError(t, err)
+	assert.Equal(t, otherID, got.ID)
+	_, err = s.InvitationByCode(ctx, "unknown")
+	assert.ErrorIs(t, err, storage.ErrInvitationNotFound)
+
+	invs, err := s.Invitations(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{id}, invitationIDs(invs))
+	invs, err = s.Invitations(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{id, otherID}, invitationIDs(invs))
+
+	// Expired invitation can't be accepted
+	assert.ErrorIs(t, s.AcceptInvitation(ctx, id, admin, now.Add(time.Hour)), storage.ErrInvitationNotFound)
+
+	invitee := newUser(t, s, "invitee@example.com")
+	require.NoError(t, s.AcceptInvitation(ctx, id, invitee, now))
+	assert.ErrorIs(t, s.AcceptInvitation(ctx, id, invitee, now), storage.ErrInvitationNotFound)
+	assert.ErrorIs(t, s.RevokeInvitation(ctx, id, now), storage.ErrInvitationNotFound)
+
+	got, err = s.Invitation(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, invitee, got.AcceptedBy)
+	assertTime(t, now, got.AcceptedAt)
+
+	require.NoError(t, s.RevokeInvitation(ctx, otherID, now))
+	assert.ErrorIs(t, s.RevokeInvitation(ctx, otherID, now), storage.ErrInvitationNotFound)
+	assert.ErrorIs(t, s.AcceptInvitation(ctx, otherID, invitee, now), storage.ErrInvitationNotFound)
+
+	// Invitations are found by email regardless of case and by acceptor
+	invs, err = s.UserInvitations(ctx, invitee, "nobody@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{id}, invitationIDs(invs))
+	invs, err = s.UserInvitations(ctx, 0, "OTHER@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{otherID}, invitationIDs(invs))
+}
+
+func testEmailChanges(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "old@example.com")
+
+	change := models.EmailChange{
+		UserID:    user,
+		OldEmail:  "old@example.com",
+		NewEmail:  "first@example.com",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	firstID, err := s.SaveEmailChange(ctx, change, "first-code", "first-cancel")
+	require.NoError(t, err)
+
+	// New change cancels the pending one
+	change.NewEmail = "new@example.com"
+	id, err := s.SaveEmailChange(ctx, change, "code", "cancel")
+	require.NoError(t, err)
+
+	changes, err := s.UserEmailChanges(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, firstID, changes[0].ID)
+	assertTime(t, now, changes[0].CancelledAt)
+	assert.Equal(t, id, changes[1].ID)
+	assert.True(t, changes[1].Pending(now))
+
+	got, err := s.EmailChangeByCode(ctx, "code")
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "new@example.com", got.NewEmail)
+	got, err = s.EmailChangeByCancelCode(ctx, "cancel")
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+
+	_, err = s.EmailChangeByCode(ctx, "cancel")
+	assert.ErrorIs(t, err, storage.ErrEmailChangeNotFound)
+	_, err = s.EmailChangeByCancelCode(ctx, "code")
+	assert.ErrorIs(t, err, storage.ErrEmailChangeNotFound)
+
+	assert.ErrorIs(t, s.ConfirmEmailChange(ctx, firstID, now), storage.ErrEmailChangeNotFound)
+	assert.ErrorIs(t, s.ConfirmEmailChange(ctx, id, now.Add(time.Hour)), storage.ErrEmailChangeNotFound)
+
+	require.NoError(t, s.ConfirmEmailChange(ctx, id, now))
+	assert.ErrorIs(t, s.ConfirmEmailChange(ctx, id, now), storage.ErrEmailChangeNotFound)
+	assert.ErrorIs(t, s.CancelEmailChange(ctx, id, now), storage.ErrEmailChangeNotFound)
+
+	u, err := s.UserByID(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", u.Email)
+	_, err = s.User(ctx, "old@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	change.NewEmail = "cancelled@example.com"
+	id, err = s.SaveEmailChange(ctx, change, "next-code", "next-cancel")
+	require.NoError(t, err)
+	require.NoError(t, s.CancelEmailChange(ctx, id, now))
+	assert.ErrorIs(t, s.CancelEmailChange(ctx, id, now), storage.ErrEmailChangeNotFound)
+	assert.ErrorIs(t, s.ConfirmEmailChange(ctx, id, now), storage.ErrEmailChangeNotFound)
+}
+
+func testMagicLinks(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	appID := newApp(t, s, "app")
+	otherApp := newApp(t, s, "other")
+
+	link := models.MagicLink{UserID: user, AppID: appID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	_, err := s.SaveMagicLink(ctx, link, "first")
+	require.NoError(t, err)
+
+	// Only the latest link works
+	id, err := s.SaveMagicLink(ctx, link, "second")
+	require.NoError(t, err)
+	_, err = s.ConsumeMagicLink(ctx, "first", appID, now)
+	assert.ErrorIs(t, err, storage.ErrMagicLinkNotFound)
+
+	_, err = s.ConsumeMagicLink(ctx, "second", otherApp, now)
+	assert.ErrorIs(t, err, storage.ErrMagicLinkNotFound)
+	_, err = s.ConsumeMagicLink(ctx, "second", appID, now.Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrMagicLinkNotFound)
+
+	got, err := s.ConsumeMagicLink(ctx, "second", appID, now)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, user, got.UserID)
+	assert.Equal(t, appID, got.AppID)
+
+	_, err = s.ConsumeMagicLink(ctx, "second", appID, now)
+	assert.ErrorIs(t, err, storage.ErrMagicLinkNotFound)
+
+	links, err := s.UserMagicLinks(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, id, links[0].ID)
+	assertTime(t, now, links[0].ConsumedAt)
+
+	links, err = s.UserMagicLinks(ctx, user+100)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
+
+func testOTPChallenges(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	appID := newApp(t, s, "app")
+
+	ch := models.OTPChallenge{
+		UserID:     user,
+		AppID:      appID,
+		Purpose:    models.OTPPurposeLogin,
+		Channel:    "email",
+		CodeHash:   "code",
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour),
+		LastSentAt: now,
+	}
+	_, err := s.SaveOTPChallenge(ctx, ch, "first")
+	require.NoError(t, err)
+
+	// Only the latest challenge works
+	id, err := s.SaveOTPChallenge(ctx, ch, "second")
+	require.NoError(t, err)
+	_, err = s.OTPChallenge(ctx, "first")
+	assert.ErrorIs(t, err, storage.ErrOTPChallengeNotFound)
+
+	got, err := s.OTPChallenge(ctx, "second")
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, models.OTPPurposeLogin, got.Purpose)
+	assert.Equal(t, "email", got.Channel)
+	assert.Equal(t, "code", got.CodeHash)
+	assert.Equal(t, 0, got.Attempts)
+	assert.Equal(t, 1, got.Sends)
+
+	require.NoError(t, s.AddOTPAttempt(ctx, id, 2))
+	require.NoError(t, s.AddOTPAttempt(ctx, id, 2))
+	assert.ErrorIs(t, s.AddOTPAttempt(ctx, id, 2), storage.ErrOTPChallengeNotFound)
+
+	require.NoError(t, s.ResendOTPChallenge(ctx, id, "new-code", now.Add(time.Minute)))
+
+	got, err = s.LatestOTPChallenge(ctx, user, appID)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "new-code", got.CodeHash)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, 2, got.Sends)
+	assertTime(t, now.Add(time.Minute), got.LastSentAt)
+
+	_, err = s.LatestOTPChallenge(ctx, user, appID+100)
+	assert.ErrorIs(t, err, storage.ErrOTPChallengeNotFound)
+
+	assert.ErrorIs(t, s.ConsumeOTPChallenge(ctx, id, now.Add(time.Hour)), storage.ErrOTPChallengeNotFound)
+	require.NoError(t, s.ConsumeOTPChallenge(ctx, id, now))
+	assert.ErrorIs(t, s.ConsumeOTPChallenge(ctx, id, now), storage.ErrOTPChallengeNotFound)
+	assert.ErrorIs(t, s.ResendOTPChallenge(ctx, id, "code", now), storage.ErrOTPChallengeNotFound)
+	assert.ErrorIs(t, s.AddOTPAttempt(ctx, id, 10), storage.ErrOTPChallengeNotFound)
+
+	// Consumed challenge is kept
+	got, err = s.LatestOTPChallenge(ctx, user, appID)
+	require.NoError(t, err)
+	assertTime(t, now, got.ConsumedAt)
+
+	challenges, err := s.UserOTPChallenges(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, challenges, 1)
+	assert.Equal(t, id, challenges[0].ID)
+	assert.Equal(t, models.OTPPurposeLogin, challenges[0].Purpose)
+	assertTime(t, now, challenges[0].ConsumedAt)
+
+	challenges, err = s.UserOTPChallenges(ctx, user+100)
+	require.NoError(t, err)
+	assert.Empty(t, challenges)
+}
+
+func testWebAuthn(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	other := newUser(t, s, "other@example.com")
+
+	cred := models.WebAuthnCredential{
+		UserID:          user,
+		Name:            "laptop",
+		CredentialID:    []byte("cred"),
+		PublicKey:       []byte("key"),
+		AttestationType: "none",
+		AAGUID:          []byte("aaguid"),
+		SignCount:       5,
+		Transports:      []string{"internal", "usb"},
+		BackupEligible:  true,
+		CreatedAt:       now,
+	}
+	id, err := s.SaveWebAuthnCredential(ctx, cred)
+	require.NoError(t, err)
+
+	_, err = s.SaveWebAuthnCredential(ctx, cred)
+	assert.ErrorIs(t, err, storage.ErrWebAuthnCredentialExists)
+
+	cred.CredentialID = []byte("cred-2")
+	secondID, err := s.SaveWebAuthnCredential(ctx, cred)
+	require.NoError(t, err)
+
+	// Counter only grows
+	require.NoError(t, s.UpdateWebAuthnCredentialUse(ctx, id, 3, true, now))
+	assert.ErrorIs(t, s.UpdateWebAuthnCredentialUse(ctx, secondID+100, 3, true, now),
+		storage.ErrWebAuthnCredentialNotFound)
+
+	creds, err := s.WebAuthnCredentials(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	assert.Equal(t, id, creds[0].ID)
+	assert.Equal(t, []byte("cred"), creds[0].CredentialID)
+	assert.Equal(t, []byte("key"), creds[0].PublicKey)
+	assert.Equal(t, []byte("aaguid"), creds[0].AAGUID)
+	assert.Equal(t, []string{"internal", "usb"}, creds[0].Transports)
+	assert.Equal(t, uint32(5), creds[0].SignCount)
+	assert.True(t, creds[0].BackupEligible)
+	assert.True(t, creds[0].BackupState)
+	assertTime(t, now, creds[0].LastUsedAt)
+	assert.Equal(t, secondID, creds[1].ID)
+	assert.True(t, creds[1].LastUsedAt.IsZero())
+
+	assert.ErrorIs(t, s.DeleteWebAuthnCredential(ctx, other, id), storage.ErrWebAuthnCredentialNotFound)
+	require.NoError(t, s.DeleteWebAuthnCredential(ctx, user, id))
+	assert.ErrorIs(t, s.DeleteWebAuthnCredential(ctx, user, id), storage.ErrWebAuthnCredentialNotFound)
+
+	session := models.WebAuthnSession{
+		UserID:    user,
+		Purpose:   models.WebAuthnPurposeRegistration,
+		Data:      []byte("data"),
+		ExpiresAt: now.Add(time.Minute),
+	}
+	_, err = s.SaveWebAuthnSession(ctx, session, "session", now)
+	require.NoError(t, err)
+	_, err = s.SaveWebAuthnSession(ctx, session, "expired", now)
+	require.NoError(t, err)
+
+	_, err = s.TakeWebAuthnSession(ctx, "expired", now.Add(time.Minute))
+	assert.ErrorIs(t, err, storage.ErrWebAuthnSessionNotFound)
+
+	got, err := s.TakeWebAuthnSession(ctx, "session", now)
+	require.NoError(t, err)
+	assert.Equal(t, user, got.UserID)
+	assert.Equal(t, models.WebAuthnPurposeRegistration, got.Purpose)
+	assert.Equal(t, []byte("data"), got.Data)
+
+	_, err = s.TakeWebAuthnSession(ctx, "session", now)
+	assert.ErrorIs(t, err, storage.ErrWebAuthnSessionNotFound)
+}
+
+func testLoginAttempts(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	appID := newApp(t, s, "app")
+
+	attempt := models.LoginAttempt{
+		UserID:     user,
+		AppID:      appID,
+		DeviceHash: "device",
+		IP:         "10.0.0.1",
+		UserAgent:  "agent",
+		CreatedAt:  now.Add(-2 * time.Hour),
+	}
+	_, err := s.SaveLoginAttempt(ctx, attempt, now.Add(-3*time.Hour))
+	require.NoError(t, err)
+
+	attempt.CreatedAt = now
+	attempt.Succeeded = true
+	first, err := s.SaveLoginAttempt(ctx, attempt, now.Add(-3*time.Hour))
+	require.NoError(t, err)
+	second, err := s.SaveLoginAttempt(ctx, attempt, now.Add(-3*time.Hour))
+	require.NoError(t, err)
+
+	// Newest first, ties broken by id
+	attempts, err := s.LoginAttempts(ctx, user, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, second, attempts[0].ID)
+	assert.Equal(t, first, attempts[1].ID)
+	assert.Equal(t, "device", attempts[0].DeviceHash)
+	assert.Equal(t, "10.0.0.1", attempts[0].IP)
+	assert.Equal(t, "agent", attempts[0].UserAgent)
+	assert.True(t, attempts[0].Succeeded)
+
+	attempts, err = s.LoginAttempts(ctx, user, now.Add(-3*time.Hour), 1)
+	require.NoError(t, err)
+	assert.Len(t, attempts, 1)
+
+	// Old attempts are deleted on save
+	_, err = s.SaveLoginAttempt(ctx, attempt, now.Add(-time.Hour))
+	require.NoError(t, err)
+	attempts, err = s.LoginAttempts(ctx, user, now.Add(-3*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Len(t, attempts, 3)
+
+	attempts, err = s.UserLoginAttempts(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	assert.Equal(t, first, attempts[0].ID)
+	assert.Equal(t, second, attempts[1].ID)
+}
+
+func testTrustedDevices(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s, "user@example.com")
+	other := newUser(t, s, "other@example.com")
+
+	device := models.TrustedDevice{
+		UserID:    user,
+		UserAgent: "agent",
+		IP:        "10.0.0.1",
+		CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour),
+	}
+	_, err := s.SaveTrustedDevice(ctx, device, "expired")
+	require.NoError(t, err)
+
+	device.CreatedAt = now
+	device.ExpiresAt = now.Add(time.Hour)
+	first, err := s.SaveTrustedDevice(ctx, device, "first")
+	require.NoError(t, err)
+	second, err := s.SaveTrustedDevice(ctx, device, "second")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.UseTrustedDevice(ctx, user, "expired", now), storage.ErrTrustedDeviceNotFound)
+	assert.ErrorIs(t, s.UseTrustedDevice(ctx, other, "first", now), storage.ErrTrustedDeviceNotFound)
+	assert.ErrorIs(t, s.UseTrustedDevice(ctx, user, "first", now.Add(time.Hour)), storage.ErrTrustedDeviceNotFound)
+	require.NoError(t, s.UseTrustedDevice(ctx, user, "first", now.Add(time.Minute)))
+
+	devices, err := s.TrustedDevices(ctx, user, now)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, second, devices[0].ID)
+	assert.True(t, devices[0].LastUsedAt.IsZero())
+	assert.Equal(t, first, devices[1].ID)
+	assert.Equal(t, "agent", devices[1].UserAgent)
+	assert.Equal(t, "10.0.0.1", devices[1].IP)
+	assertTime(t, now.Add(time.Minute), devices[1].LastUsedAt)
+
+	assert.ErrorIs(t, s.DeleteTrustedDevice(ctx, other, first), storage.ErrTrustedDeviceNotFound)
+	require.NoError(t, s.DeleteTrustedDevice(ctx, user, first))
+	assert.ErrorIs(t, s.DeleteTrustedDevice(ctx, user, first), storage.ErrTrustedDeviceNotFound)
+
+	// Expired device was deleted when the first one was saved
+	n, err := s.DeleteTrustedDevices(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	devices, err = s.TrustedDevices(ctx, user, now)
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+
+	device.ExpiresAt = now.Add(-time.Hour)
+	expired, err := s.SaveTrustedDevice(ctx, device, "expired")
+	require.NoError(t, err)
+	devices, err = s.TrustedDevices(ctx, user, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, expired, devices[0].ID)
+}
+
+func invitationIDs(invs []models.Invitation) []int64 {
+	var ids []int64
+	for _, inv := range invs {
+		ids = append(ids, inv.ID)
+	}
+
+	return ids
+}
+
+// assertTime compares instants, since backends return times in
+// different locations
+func assertTime(t *testing.T, want, got time.Time) {
+	t.Helper()
+
+	assert.True(t, want.Equal(got), "want %s, got %s", want, got)
+}
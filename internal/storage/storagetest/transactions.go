@@ -0,0 +1,74 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTransactions checks failed operations leave no partial changes
+// and no outbox events behind
+func testTransactions(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+	_, err = s.SaveOrgUser(ctx, orgID, orgID, "member@example.com", []byte("hash"))
+	require.NoError(t, err)
+	user := newUser(t, s, "user@example.com")
+	newUser(t, s, "taken@example.com")
+
+	events := unrelayed(t, s)
+
+	_, err = s.SaveUser(ctx, "USER@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	_, err = s.SaveOrgUser(ctx, orgID, orgID, "member@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+	_, err = s.SaveOrgUser(ctx, orgID+100, orgID+100, "new@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+	_, err = s.OrgUser(ctx, orgID+100, "new@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	members, err := s.OrgMembers(ctx, orgID)
+	require.NoError(t, err)
+	assert.Len(t, members, 1)
+
+	err = s.SetUserStatus(ctx, user, models.StatusSuspended, models.StatusActive, "", now)
+	assert.ErrorIs(t, err, storage.ErrStatusConflict)
+
+	assert.Equal(t, events, unrelayed(t, s))
+
+	// Email change onto a taken email stays pending
+	id, err := s.SaveEmailChange(ctx, models.EmailChange{
+		UserID:    user,
+		OldEmail:  "user@example.com",
+		NewEmail:  "taken@example.com",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "code", "cancel")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.ConfirmEmailChange(ctx, id, now), storage.ErrUserExists)
+
+	change, err := s.EmailChangeByCode(ctx, "code")
+	require.NoError(t, err)
+	assert.True(t, change.Pending(now))
+
+	u, err := s.UserByID(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", u.Email)
+}
+
+func unrelayed(t *testing.T, s Storage) int {
+	t.Helper()
+
+	events, err := s.UnrelayedEvents(context.Background(), 1000)
+	require.NoError(t, err)
+
+	return len(events)
+}
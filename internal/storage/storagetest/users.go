@@ -0,0 +1,415 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/sso-gRPC/internal/domain/models"
+	"github.com/m1al04949/sso-gRPC/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUsers(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id, err := s.SaveUser(ctx, "User@Example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	_, err = s.SaveUser(ctx, "user@EXAMPLE.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	user, err := s.User(ctx, "USER@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "User@Example.com", user.Email)
+	assert.Equal(t, []byte("hash"), user.PassHash)
+	assert.Equal(t, models.StatusActive, user.Status)
+	assert.False(t, user.Disabled)
+
+	byID, err := s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, user, byID)
+
+	_, err = s.User(ctx, "missing@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByID(ctx, id+100)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	orgID, err := s.SaveOrg(ctx, "org")
+	require.NoError(t, err)
+
+	// Organization namespace doesn't clash with the shared one
+	orgUser, err := s.SaveOrgUser(ctx, orgID, orgID, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	_, err = s.SaveOrgUser(ctx, orgID, orgID, "USER@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+	_, err = s.SaveOrgUser(ctx, orgID+100, orgID+100, "new@example.com", []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	user, err = s.OrgUser(ctx, orgID, "User@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, orgUser, user.ID)
+	assert.Equal(t, orgID, user.OrgID)
+	_, err = s.OrgUser(ctx, orgID, "other@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	member, err := s.OrgMember(ctx, orgID, orgUser)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrgRoleMember, member.Role)
+
+	events, err := s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventUserRegistered, events[0].Type)
+	assert.Equal(t, id, events[0].UserID)
+	assert.Equal(t, orgID, events[1].OrgID)
+
+	// Events of organization users are bound to their organization
+	require.NoError(t, s.SetPassResetRequired(ctx, orgUser, true))
+	require.NoError(t, s.SetPassword(ctx, orgUser, []byte("new-hash")))
+	events, err = s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, models.EventUserPassResetRequired, events[2].Type)
+	assert.Equal(t, orgID, events[2].OrgID)
+	assert.Equal(t, models.EventUserPasswordChanged, events[3].Type)
+	assert.Equal(t, orgID, events[3].OrgID)
+
+	// Listing
+	admin := newUser(t, s, "admin@test.org")
+	require.NoError(t, s.SetAdmin(ctx, admin, true))
+	require.NoError(t, s.AddUserRole(ctx, admin, "editor"))
+
+	users, err := s.Users(ctx, models.UserFilter{Email: "EXAMPLE"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{id, orgUser}, userIDs(users))
+
+	// Wildcards of email filter match literally
+	percent := newUser(t, s, "100%_off@test.org")
+	for _, email := range []string{"%", "_", "0%_o"} {
+		users, err = s.Users(ctx, models.UserFilter{Email: email}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{percent}, userIDs(users), email)
+	}
+	users, err = s.Users(ctx, models.UserFilter{Email: `\%`}, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	users, err = s.Users(ctx, models.UserFilter{}, id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{orgUser}, userIDs(users))
+
+	isAdmin := true
+	users, err = s.Users(ctx, models.UserFilter{IsAdmin: &isAdmin}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{admin}, userIDs(users))
+
+	users, err = s.Users(ctx, models.UserFilter{Role: "editor"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{admin}, userIDs(users))
+
+	users, err = s.Users(ctx, models.UserFilter{OrgID: orgID}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{orgUser}, userIDs(users))
+
+	// Roles
+	require.NoError(t, s.SetUserRoles(ctx, id, []string{"viewer", "editor", "viewer"}))
+	require.NoError(t, s.AddUserRole(ctx, id, "auditor"))
+	roles, err := s.UserRoles(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auditor", "editor", "viewer"}, roles)
+
+	assert.ErrorIs(t, s.SetUserRoles(ctx, id+100, []string{"viewer"}), storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.AddUserRole(ctx, id+100, "viewer"), storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.SetAdmin(ctx, id+100, true), storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.SetUserMFAChannel(ctx, id+100, "email"), storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.SetPassResetRequired(ctx, id+100, true), storage.ErrUserNotFound)
+
+	require.NoError(t, s.SetUserMFAChannel(ctx, id, "email"))
+	require.NoError(t, s.SetPassResetRequired(ctx, id, true))
+	user, err = s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "email", user.MFAChannel)
+	assert.True(t, user.PassResetRequired)
+
+	// New password clears the reset flag
+	assert.ErrorIs(t, s.SetPassword(ctx, id+100, []byte("new")), storage.ErrUserNotFound)
+	require.NoError(t, s.SetPassword(ctx, id, []byte("new")))
+	user, err = s.User(ctx, "user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), user.PassHash)
+	assert.False(t, user.PassResetRequired)
+}
+
+func testUserIdentifiers(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	first := newUser(t, s, "first@example.com")
+	second := newUser(t, s, "second@example.com")
+
+	require.NoError(t, s.SetUserIdentifiers(ctx, first, "first", "+15550001"))
+	require.NoError(t, s.SetUserIdentifiers(ctx, second, "", ""))
+
+	assert.ErrorIs(t, s.SetUserIdentifiers(ctx, second, "first", ""), storage.ErrUsernameExists)
+	assert.ErrorIs(t, s.SetUserIdentifiers(ctx, second, "", "+15550001"), storage.ErrPhoneExists)
+	assert.ErrorIs(t, s.SetUserIdentifiers(ctx, first+100, "other", ""), storage.ErrUserNotFound)
+
+	user, err := s.UserByUsername(ctx, 0, "first")
+	require.NoError(t, err)
+	assert.Equal(t, first, user.ID)
+	assert.Equal(t, "+15550001", user.Phone)
+
+	user, err = s.UserByPhone(ctx, 0, "+15550001")
+	require.NoError(t, err)
+	assert.Equal(t, first, user.ID)
+
+	_, err = s.UserByUsername(ctx, 0, "second")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByPhone(ctx, 0, "")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByUsername(ctx, 1, "first")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// Removed identifiers are free to take
+	require.NoError(t, s.SetUserIdentifiers(ctx, first, "", ""))
+	require.NoError(t, s.SetUserIdentifiers(ctx, second, "first", "+15550001"))
+
+	user, err = s.UserByUsername(ctx, 0, "first")
+	require.NoError(t, err)
+	assert.Equal(t, second, user.ID)
+}
+
+func testUserStatus(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id := newUser(t, s, "user@example.com")
+
+	err := s.SetUserStatus(ctx, id, models.StatusActive, models.StatusSuspended, "abuse", now)
+	require.NoError(t, err)
+
+	user, err := s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuspended, user.Status)
+	assert.Equal(t, "abuse", user.StatusReason)
+	assert.True(t, user.Disabled)
+	assert.True(t, now.Equal(user.StatusChangedAt))
+
+	err = s.SetUserStatus(ctx, id, models.StatusActive, models.StatusLocked, "", now)
+	assert.ErrorIs(t, err, storage.ErrStatusConflict)
+	err = s.SetUserStatus(ctx, id+100, models.StatusActive, models.StatusLocked, "", now)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	require.NoError(t, s.SetUserStatus(ctx, id, models.StatusSuspended, models.StatusActive, "", now))
+	user, err = s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, user.Disabled)
+
+	events, err := s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, models.EventUserStatusChanged, events[1].Type)
+	assert.Equal(t, map[string]string{"from": "active", "to": "suspended", "reason": "abuse"}, events[1].Data)
+
+	// Only users deleted before the time are purged
+	deleted := newUser(t, s, "deleted@example.com")
+	recent := newUser(t, s, "recent@example.com")
+	require.NoError(t, s.SetUserStatus(ctx, deleted, models.StatusActive, models.StatusDeleted, "", now.Add(-time.Hour)))
+	require.NoError(t, s.SetUserStatus(ctx, recent, models.StatusActive, models.StatusDeleted, "", now))
+	require.NoError(t, s.AddUserRole(ctx, deleted, "viewer"))
+
+	n, err := s.PurgeDeletedUsers(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	_, err = s.UserByID(ctx, deleted)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByID(ctx, recent)
+	assert.NoError(t, err)
+
+	roles, err := s.UserRoles(ctx, deleted)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func testAdmins(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	flagged := newUser(t, s, "flagged@example.com")
+	direct := newUser(t, s, "direct@example.com")
+	grouped := newUser(t, s, "grouped@example.com")
+	regular := newUser(t, s, "regular@example.com")
+
+	require.NoError(t, s.SetAdmin(ctx, flagged, true))
+	require.NoError(t, s.AddUserRole(ctx, direct, models.RoleAdmin))
+
+	groupID, err := s.SaveGroup(ctx, "admins", []string{models.RoleAdmin})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveGroupMember(ctx, groupID, grouped))
+
+	for _, id := range []int64{flagged, direct, grouped} {
+		isAdmin, err := s.IsAdmin(ctx, id)
+		require.NoError(t, err)
+		assert.True(t, isAdmin, "user %d", id)
+	}
+
+	isAdmin, err := s.IsAdmin(ctx, regular)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+
+	_, err = s.IsAdmin(ctx, regular+100)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testErasure(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id := newUser(t, s, "Erased@Example.com")
+	other := newUser(t, s, "other@example.com")
+	require.NoError(t, s.SetUserIdentifiers(ctx, id, "erased", "+15550001"))
+	require.NoError(t, s.SetAdmin(ctx, id, true))
+	require.NoError(t, s.AddUserRole(ctx, id, "viewer"))
+	require.NoError(t, s.SaveUserProfile(ctx, models.Profile{UserID: id, DisplayName: "Erased"}))
+
+	invID, err := s.SaveInvitation(ctx, models.Invitation{
+		Email:     "erased@example.com",
+		Role:      "viewer",
+		CreatedBy: other,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, "code")
+	require.NoError(t, err)
+
+	// Login by identifier, action of user and action of admin on user
+	userTarget := models.AuditTarget(models.AuditTargetUser, id)
+	for _, event := range []models.AuditEvent{
+		{Time: now, Action: "login", Target: models.AuditTarget(models.AuditTargetIdentifier, "ERASED@example.com"),
+			IP: "10.0.0.1", UserAgent: "agent", Result: "ok"},
+		{Time: now, ActorID: id, Action: "update", IP: "10.0.0.1", UserAgent: "agent", Result: "ok"},
+		{Time: now, ActorID: other, Action: "erase", Target: userTarget, IP: "10.0.0.2", UserAgent: "admin", Result: "ok"},
+	} {
+		_, err := s.SaveAuditEvent(ctx, event, true)
+		require.NoError(t, err)
+	}
+	for _, login := range []string{"+15550001", "other@example.com"} {
+		require.NoError(t, s.SaveEvent(ctx, models.Event{
+			Type: models.EventUserLoginFailed,
+			Time: now,
+			Data: map[string]string{"login": login, "reason": "invalid_credentials"},
+		}))
+	}
+
+	require.NoError(t, s.EraseUser(ctx, id, other, now))
+	assert.ErrorIs(t, s.EraseUser(ctx, id+100, other, now), storage.ErrUserNotFound)
+
+	user, err := s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.NotContains(t, user.Email, "xample")
+	assert.Empty(t, user.Username)
+	assert.Empty(t, user.Phone)
+	assert.Empty(t, user.PassHash)
+	assert.False(t, user.IsAdmin)
+	assert.True(t, user.Disabled)
+	assert.Equal(t, models.StatusDeleted, user.Status)
+	assert.Equal(t, models.StatusReasonErased, user.StatusReason)
+
+	_, err = s.User(ctx, "erased@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByUsername(ctx, 0, "erased")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	roles, err := s.UserRoles(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	profile, err := s.UserProfile(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, profile.DisplayName)
+
+	inv, err := s.Invitation(ctx, invID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, inv.Email)
+
+	// Audit log keeps actions but not identifiers, ip and user agent
+	events, err := s.UserAuditEvents(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, userTarget, events[0].Target)
+	for _, event := range events[:2] {
+		assert.True(t, event.Redacted, "event %d", event.ID)
+		assert.Empty(t, event.IP, "event %d", event.ID)
+		assert.Empty(t, event.UserAgent, "event %d", event.ID)
+	}
+	assert.False(t, events[2].Redacted)
+	assert.Equal(t, "10.0.0.2", events[2].IP)
+
+	chain, err := s.ChainedAuditEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	assertChain(t, chain)
+
+	outbox, err := s.UnrelayedEvents(ctx, 10)
+	require.NoError(t, err)
+	var logins []string
+	for _, event := range outbox {
+		if event.Type == models.EventUserLoginFailed {
+			logins = append(logins, event.Data["login"])
+			assert.Equal(t, "invalid_credentials", event.Data["reason"])
+		}
+	}
+	assert.Equal(t, []string{user.Email, "other@example.com"}, logins)
+
+	// Erased users are kept by purge
+	n, err := s.PurgeDeletedUsers(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testProfiles(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id := newUser(t, s, "user@example.com")
+
+	profile, err := s.UserProfile(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, profile.UserID)
+	assert.Empty(t, profile.DisplayName)
+	assert.Empty(t, profile.Metadata)
+
+	err = s.SaveUserProfile(ctx, models.Profile{
+		UserID:        id,
+		DisplayName:   "User",
+		Locale:        "en",
+		Metadata:      map[string]any{"plan": "pro", "seats": 3},
+		AdminMetadata: map[string]any{"tier": "gold"},
+	})
+	require.NoError(t, err)
+
+	profile, err = s.UserProfile(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "User", profile.DisplayName)
+	assert.Equal(t, "en", profile.Locale)
+	// Metadata is JSON, numbers come back as float64
+	assert.Equal(t, map[string]any{"plan": "pro", "seats": float64(3)}, profile.Metadata)
+	assert.Equal(t, map[string]any{"tier": "gold"}, profile.AdminMetadata)
+
+	require.NoError(t, s.SaveUserProfile(ctx, models.Profile{UserID: id, Locale: "de"}))
+	profile, err = s.UserProfile(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, profile.DisplayName)
+	assert.Equal(t, "de", profile.Locale)
+	assert.Empty(t, profile.Metadata)
+
+	_, err = s.UserProfile(ctx, id+100)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.SaveUserProfile(ctx, models.Profile{UserID: id + 100}), storage.ErrUserNotFound)
+}
+
+func userIDs(users []models.User) []int64 {
+	var ids []int64
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS email_changes
+(
+    id           INTEGER PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email    TEXT    NOT NULL,
+    new_email    TEXT    NOT NULL,
+    code_hash    TEXT    NOT NULL UNIQUE,
+    cancel_hash  TEXT    NOT NULL UNIQUE,
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL,
+    confirmed_at INTEGER,
+    cancelled_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes (user_id);
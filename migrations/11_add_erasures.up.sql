@@ -0,0 +1,9 @@
+-- Erasures record users whose personal data was erased. The user row is kept
+-- pseudonymized so that records referencing the user id stay consistent
+CREATE TABLE IF NOT EXISTS erasures
+(
+    id        INTEGER PRIMARY KEY,
+    user_id   INTEGER NOT NULL UNIQUE,
+    erased_by INTEGER NOT NULL,
+    erased_at INTEGER NOT NULL
+);
@@ -0,0 +1,11 @@
+-- Disabled flag is kept in sync with status: only active users are enabled
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_changed_at INTEGER;
+
+UPDATE users SET status = 'suspended' WHERE disabled;
+UPDATE users SET status = 'deleted', status_reason = 'erased', status_changed_at = (
+    SELECT erased_at FROM erasures WHERE erasures.user_id = users.id)
+WHERE id IN (SELECT user_id FROM erasures);
+
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status);
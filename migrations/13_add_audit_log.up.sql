@@ -0,0 +1,31 @@
+-- Audit log is append-only. Events with hash chaining on link to the
+-- previous chained event so that edits and deletions can be detected
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         INTEGER PRIMARY KEY,
+    time       INTEGER NOT NULL,
+    actor_id   INTEGER NOT NULL DEFAULT 0,
+    action     TEXT    NOT NULL,
+    target     TEXT    NOT NULL DEFAULT '',
+    app_id     INTEGER NOT NULL DEFAULT 0,
+    ip         TEXT    NOT NULL DEFAULT '',
+    user_agent TEXT    NOT NULL DEFAULT '',
+    result     TEXT    NOT NULL,
+    prev_hash  TEXT    NOT NULL DEFAULT '',
+    hash       TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_time ON audit_events (time);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete
+BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
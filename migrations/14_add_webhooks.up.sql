@@ -0,0 +1,40 @@
+-- Outbox events are written in the same transaction as the state change
+-- and relayed to the event bus and webhook deliveries by the dispatcher
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id         INTEGER PRIMARY KEY,
+    type       TEXT    NOT NULL,
+    time       INTEGER NOT NULL,
+    user_id    INTEGER NOT NULL DEFAULT 0,
+    org_id     INTEGER NOT NULL DEFAULT 0,
+    app_id     INTEGER NOT NULL DEFAULT 0,
+    data       TEXT    NOT NULL DEFAULT '{}',
+    relayed_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unrelayed ON outbox_events (id) WHERE relayed_at IS NULL;
+
+-- Secret is encrypted like app secrets. Empty event types subscribe to all
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id          INTEGER PRIMARY KEY,
+    app_id      INTEGER NOT NULL,
+    url         TEXT    NOT NULL,
+    secret      TEXT    NOT NULL,
+    event_types TEXT    NOT NULL DEFAULT '',
+    created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_app_id ON webhooks (app_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              INTEGER PRIMARY KEY,
+    webhook_id      INTEGER NOT NULL,
+    event_id        INTEGER NOT NULL,
+    status          TEXT    NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL,
+    last_error      TEXT    NOT NULL DEFAULT '',
+    delivered_at    INTEGER,
+    UNIQUE (webhook_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS otp_challenges;
+ALTER TABLE users DROP COLUMN mfa_channel;
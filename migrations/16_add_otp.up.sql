@@ -0,0 +1,22 @@
+-- Empty mfa_channel means user logs in with password only
+ALTER TABLE users ADD COLUMN mfa_channel TEXT NOT NULL DEFAULT '';
+
+-- Challenge is identified by hash of its random id handed out to client,
+-- code is hashed along with the id
+CREATE TABLE IF NOT EXISTS otp_challenges
+(
+    id             INTEGER PRIMARY KEY,
+    challenge_hash TEXT    NOT NULL UNIQUE,
+    user_id        INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id         INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    purpose        TEXT    NOT NULL,
+    channel        TEXT    NOT NULL,
+    code_hash      TEXT    NOT NULL,
+    attempts       INTEGER NOT NULL DEFAULT 0,
+    sends          INTEGER NOT NULL DEFAULT 1,
+    created_at     INTEGER NOT NULL,
+    expires_at     INTEGER NOT NULL,
+    last_sent_at   INTEGER NOT NULL,
+    consumed_at    INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_otp_challenges_user_id ON otp_challenges (user_id, app_id);
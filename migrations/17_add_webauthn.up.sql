@@ -0,0 +1,32 @@
+-- Transports are comma-separated. sign_count detects cloned authenticators
+CREATE TABLE IF NOT EXISTS webauthn_credentials
+(
+    id               INTEGER PRIMARY KEY,
+    user_id          INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name             TEXT    NOT NULL DEFAULT '',
+    credential_id    BLOB    NOT NULL UNIQUE,
+    public_key       BLOB    NOT NULL,
+    attestation_type TEXT    NOT NULL DEFAULT '',
+    aaguid           BLOB,
+    sign_count       INTEGER NOT NULL DEFAULT 0,
+    transports       TEXT    NOT NULL DEFAULT '',
+    backup_eligible  BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at       INTEGER NOT NULL,
+    last_used_at     INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- Ceremony state between begin and finish calls, identified by hash
+-- of its random id handed out to client. Zero user_id is discoverable login
+CREATE TABLE IF NOT EXISTS webauthn_sessions
+(
+    id           INTEGER PRIMARY KEY,
+    session_hash TEXT    NOT NULL UNIQUE,
+    user_id      INTEGER NOT NULL DEFAULT 0,
+    app_id       INTEGER NOT NULL DEFAULT 0,
+    purpose      TEXT    NOT NULL,
+    data         BLOB    NOT NULL,
+    expires_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_sessions_expires_at ON webauthn_sessions (expires_at);
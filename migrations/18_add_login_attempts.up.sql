@@ -0,0 +1,14 @@
+-- Failed password checks and completed logins of users. Risk of login is
+-- assessed against devices and networks of previous logins
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    id          INTEGER PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id      INTEGER NOT NULL DEFAULT 0,
+    device_hash TEXT    NOT NULL DEFAULT '',
+    ip          TEXT    NOT NULL DEFAULT '',
+    user_agent  TEXT    NOT NULL DEFAULT '',
+    succeeded   BOOLEAN NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id, created_at);
@@ -0,0 +1,14 @@
+-- Devices second factor is skipped on, identified by hash of random
+-- token handed out to device
+CREATE TABLE IF NOT EXISTS trusted_devices
+(
+    id           INTEGER PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash   TEXT    NOT NULL UNIQUE,
+    user_agent   TEXT    NOT NULL DEFAULT '',
+    ip           TEXT    NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL,
+    last_used_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices (user_id);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+ALTER TABLE audit_events DROP COLUMN redacted;
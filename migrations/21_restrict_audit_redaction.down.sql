@@ -0,0 +1,18 @@
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+BEFORE UPDATE ON audit_events
+WHEN NOT (NEW.redacted
+    AND NEW.id = OLD.id
+    AND NEW.time = OLD.time
+    AND NEW.actor_id = OLD.actor_id
+    AND NEW.action = OLD.action
+    AND NEW.app_id = OLD.app_id
+    AND NEW.result = OLD.result
+    AND NEW.prev_hash = OLD.prev_hash
+    AND NEW.hash = OLD.hash
+    AND NEW.ip = ''
+    AND NEW.user_agent = '')
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
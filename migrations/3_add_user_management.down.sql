@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_roles;
+ALTER TABLE users DROP COLUMN pass_reset_required;
+ALTER TABLE users DROP COLUMN disabled;
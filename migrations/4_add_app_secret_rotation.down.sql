@@ -0,0 +1,2 @@
+ALTER TABLE apps DROP COLUMN prev_secret_expires_at;
+ALTER TABLE apps DROP COLUMN prev_secret;
@@ -0,0 +1,4 @@
+ALTER TABLE apps
+    ADD COLUMN prev_secret TEXT;
+ALTER TABLE apps
+    ADD COLUMN prev_secret_expires_at INTEGER;
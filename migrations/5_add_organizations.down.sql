@@ -0,0 +1,21 @@
+ALTER TABLE apps DROP COLUMN org_id;
+
+CREATE TABLE users_old
+(
+    id                  INTEGER PRIMARY KEY,
+    email               TEXT    NOT NULL UNIQUE,
+    pass_hash           BLOB    NOT NULL,
+    is_admin            BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled            BOOLEAN NOT NULL DEFAULT FALSE,
+    pass_reset_required BOOLEAN NOT NULL DEFAULT FALSE
+);
+INSERT INTO users_old (id, email, pass_hash, is_admin, disabled, pass_reset_required)
+SELECT id, email, pass_hash, is_admin, disabled, pass_reset_required
+FROM users;
+DROP INDEX IF EXISTS idx_email;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS organizations;
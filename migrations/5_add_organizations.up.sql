@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS organizations
+(
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS org_members
+(
+    org_id  INTEGER NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    TEXT    NOT NULL DEFAULT 'member',
+    PRIMARY KEY (org_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_org_members_user_id ON org_members (user_id);
+
+-- Users email is unique within org_id namespace. Namespace 0 is shared
+-- by all users unless emails are configured to be unique per organization
+CREATE TABLE users_new
+(
+    id                  INTEGER PRIMARY KEY,
+    org_id              INTEGER NOT NULL DEFAULT 0,
+    email               TEXT    NOT NULL,
+    pass_hash           BLOB    NOT NULL,
+    is_admin            BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled            BOOLEAN NOT NULL DEFAULT FALSE,
+    pass_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (org_id, email)
+);
+INSERT INTO users_new (id, email, pass_hash, is_admin, disabled, pass_reset_required)
+SELECT id, email, pass_hash, is_admin, disabled, pass_reset_required
+FROM users;
+DROP INDEX IF EXISTS idx_email;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+ALTER TABLE apps
+    ADD COLUMN org_id INTEGER REFERENCES organizations (id);
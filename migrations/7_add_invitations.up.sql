@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          INTEGER PRIMARY KEY,
+    code_hash   TEXT    NOT NULL UNIQUE,
+    email       TEXT    NOT NULL,
+    org_id      INTEGER REFERENCES organizations (id) ON DELETE CASCADE,
+    app_id      INTEGER REFERENCES apps (id) ON DELETE CASCADE,
+    role        TEXT    NOT NULL DEFAULT '',
+    created_by  INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL,
+    expires_at  INTEGER NOT NULL,
+    accepted_at INTEGER,
+    accepted_by INTEGER,
+    revoked_at  INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_org_id ON invitations (org_id);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
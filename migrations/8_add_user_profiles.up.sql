@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_profiles
+(
+    user_id        INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    display_name   TEXT NOT NULL DEFAULT '',
+    locale         TEXT NOT NULL DEFAULT '',
+    time_zone      TEXT NOT NULL DEFAULT '',
+    avatar_url     TEXT NOT NULL DEFAULT '',
+    metadata       TEXT NOT NULL DEFAULT '{}',
+    admin_metadata TEXT NOT NULL DEFAULT '{}'
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_phone;
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email_canonical;
+DROP TABLE IF EXISTS email_duplicates;
+ALTER TABLE users DROP COLUMN phone;
+ALTER TABLE users DROP COLUMN username;
+ALTER TABLE users DROP COLUMN email_canonical;
@@ -0,0 +1,32 @@
+-- Emails are compared by their canonical (trimmed lowercase) form. Users whose
+-- canonical email clashes with an older user in the same namespace keep NULL
+-- canonical email, can log in with their exact email only and are reported
+-- in email_duplicates to be resolved by admins
+ALTER TABLE users ADD COLUMN email_canonical TEXT;
+ALTER TABLE users ADD COLUMN username TEXT;
+ALTER TABLE users ADD COLUMN phone TEXT;
+
+UPDATE users
+SET email_canonical = lower(trim(email))
+WHERE id = (SELECT MIN(u.id)
+            FROM users u
+            WHERE u.org_id = users.org_id
+              AND lower(trim(u.email)) = lower(trim(users.email)));
+
+CREATE TABLE IF NOT EXISTS email_duplicates
+(
+    user_id         INTEGER PRIMARY KEY,
+    kept_user_id    INTEGER NOT NULL,
+    org_id          INTEGER NOT NULL,
+    email           TEXT    NOT NULL,
+    email_canonical TEXT    NOT NULL
+);
+INSERT INTO email_duplicates (user_id, kept_user_id, org_id, email, email_canonical)
+SELECT u.id, k.id, u.org_id, u.email, k.email_canonical
+FROM users u
+         JOIN users k ON k.org_id = u.org_id AND k.email_canonical = lower(trim(u.email))
+WHERE u.email_canonical IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_canonical ON users (org_id, email_canonical);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (org_id, username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone ON users (org_id, phone);
@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS trusted_devices;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS webauthn_sessions;
+DROP TABLE IF EXISTS webauthn_credentials;
+DROP TABLE IF EXISTS otp_challenges;
+DROP TABLE IF EXISTS magic_links;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP TABLE IF EXISTS erasures;
+DROP TABLE IF EXISTS email_changes;
+DROP TABLE IF EXISTS user_profiles;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS apps;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,281 @@
+CREATE TABLE IF NOT EXISTS organizations
+(
+    id   BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS apps
+(
+    id                     BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name                   TEXT NOT NULL UNIQUE,
+    secret                 TEXT NOT NULL UNIQUE,
+    prev_secret            TEXT,
+    prev_secret_expires_at BIGINT,
+    org_id                 BIGINT REFERENCES organizations (id)
+);
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id                  BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    org_id              BIGINT  NOT NULL DEFAULT 0,
+    email               TEXT    NOT NULL,
+    email_canonical     TEXT,
+    username            TEXT,
+    phone               TEXT,
+    pass_hash           BYTEA   NOT NULL,
+    is_admin            BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled            BOOLEAN NOT NULL DEFAULT FALSE,
+    pass_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+    status              TEXT    NOT NULL DEFAULT 'active',
+    status_reason       TEXT    NOT NULL DEFAULT '',
+    status_changed_at   BIGINT,
+    mfa_channel         TEXT    NOT NULL DEFAULT '',
+    UNIQUE (org_id, email)
+);
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_canonical ON users (org_id, email_canonical);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (org_id, username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone ON users (org_id, phone);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    TEXT   NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles (role);
+
+CREATE TABLE IF NOT EXISTS org_members
+(
+    org_id  BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    TEXT   NOT NULL DEFAULT 'member',
+    PRIMARY KEY (org_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_org_members_user_id ON org_members (user_id);
+
+CREATE TABLE IF NOT EXISTS groups
+(
+    id   BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id  BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS group_roles
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    role     TEXT   NOT NULL,
+    PRIMARY KEY (group_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    code_hash   TEXT   NOT NULL UNIQUE,
+    email       TEXT   NOT NULL,
+    org_id      BIGINT REFERENCES organizations (id) ON DELETE CASCADE,
+    app_id      BIGINT REFERENCES apps (id) ON DELETE CASCADE,
+    role        TEXT   NOT NULL DEFAULT '',
+    created_by  BIGINT NOT NULL,
+    created_at  BIGINT NOT NULL,
+    expires_at  BIGINT NOT NULL,
+    accepted_at BIGINT,
+    accepted_by BIGINT,
+    revoked_at  BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_org_id ON invitations (org_id);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
+
+CREATE TABLE IF NOT EXISTS user_profiles
+(
+    user_id        BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    display_name   TEXT NOT NULL DEFAULT '',
+    locale         TEXT NOT NULL DEFAULT '',
+    time_zone      TEXT NOT NULL DEFAULT '',
+    avatar_url     TEXT NOT NULL DEFAULT '',
+    metadata       TEXT NOT NULL DEFAULT '{}',
+    admin_metadata TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE IF NOT EXISTS email_changes
+(
+    id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email    TEXT   NOT NULL,
+    new_email    TEXT   NOT NULL,
+    code_hash    TEXT   NOT NULL UNIQUE,
+    cancel_hash  TEXT   NOT NULL UNIQUE,
+    created_at   BIGINT NOT NULL,
+    expires_at   BIGINT NOT NULL,
+    confirmed_at BIGINT,
+    cancelled_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes (user_id);
+
+CREATE TABLE IF NOT EXISTS erasures
+(
+    id        BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id   BIGINT NOT NULL UNIQUE,
+    erased_by BIGINT NOT NULL,
+    erased_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    time       BIGINT NOT NULL,
+    actor_id   BIGINT NOT NULL DEFAULT 0,
+    action     TEXT   NOT NULL,
+    target     TEXT   NOT NULL DEFAULT '',
+    app_id     BIGINT NOT NULL DEFAULT 0,
+    ip         TEXT   NOT NULL DEFAULT '',
+    user_agent TEXT   NOT NULL DEFAULT '',
+    result     TEXT   NOT NULL,
+    prev_hash  TEXT   NOT NULL DEFAULT '',
+    hash       TEXT   NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_time ON audit_events (time);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    type       TEXT   NOT NULL,
+    time       BIGINT NOT NULL,
+    user_id    BIGINT NOT NULL DEFAULT 0,
+    org_id     BIGINT NOT NULL DEFAULT 0,
+    app_id     BIGINT NOT NULL DEFAULT 0,
+    data       TEXT   NOT NULL DEFAULT '{}',
+    relayed_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unrelayed ON outbox_events (id) WHERE relayed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    app_id      BIGINT NOT NULL,
+    url         TEXT   NOT NULL,
+    secret      TEXT   NOT NULL,
+    event_types TEXT   NOT NULL DEFAULT '',
+    created_at  BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_app_id ON webhooks (app_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    webhook_id      BIGINT NOT NULL,
+    event_id        BIGINT NOT NULL,
+    status          TEXT   NOT NULL DEFAULT 'pending',
+    attempts        BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at BIGINT NOT NULL,
+    last_error      TEXT   NOT NULL DEFAULT '',
+    delivered_at    BIGINT,
+    UNIQUE (webhook_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS magic_links
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id     BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id      BIGINT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    code_hash   TEXT   NOT NULL UNIQUE,
+    created_at  BIGINT NOT NULL,
+    expires_at  BIGINT NOT NULL,
+    consumed_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id ON magic_links (user_id, app_id);
+
+CREATE TABLE IF NOT EXISTS otp_challenges
+(
+    id             BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    challenge_hash TEXT   NOT NULL UNIQUE,
+    user_id        BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id         BIGINT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    purpose        TEXT   NOT NULL,
+    channel        TEXT   NOT NULL,
+    code_hash      TEXT   NOT NULL,
+    attempts       BIGINT NOT NULL DEFAULT 0,
+    sends          BIGINT NOT NULL DEFAULT 1,
+    created_at     BIGINT NOT NULL,
+    expires_at     BIGINT NOT NULL,
+    last_sent_at   BIGINT NOT NULL,
+    consumed_at    BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_otp_challenges_user_id ON otp_challenges (user_id, app_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials
+(
+    id               BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id          BIGINT  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name             TEXT    NOT NULL DEFAULT '',
+    credential_id    BYTEA   NOT NULL UNIQUE,
+    public_key       BYTEA   NOT NULL,
+    attestation_type TEXT    NOT NULL DEFAULT '',
+    aaguid           BYTEA,
+    sign_count       BIGINT  NOT NULL DEFAULT 0,
+    transports       TEXT    NOT NULL DEFAULT '',
+    backup_eligible  BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at       BIGINT  NOT NULL,
+    last_used_at     BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_sessions
+(
+    id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    session_hash TEXT   NOT NULL UNIQUE,
+    user_id      BIGINT NOT NULL DEFAULT 0,
+    app_id       BIGINT NOT NULL DEFAULT 0,
+    purpose      TEXT   NOT NULL,
+    data         BYTEA  NOT NULL,
+    expires_at   BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_sessions_expires_at ON webauthn_sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id     BIGINT  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id      BIGINT  NOT NULL DEFAULT 0,
+    device_hash TEXT    NOT NULL DEFAULT '',
+    ip          TEXT    NOT NULL DEFAULT '',
+    user_agent  TEXT    NOT NULL DEFAULT '',
+    succeeded   BOOLEAN NOT NULL,
+    created_at  BIGINT  NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS trusted_devices
+(
+    id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash   TEXT   NOT NULL UNIQUE,
+    user_agent   TEXT   NOT NULL DEFAULT '',
+    ip           TEXT   NOT NULL DEFAULT '',
+    created_at   BIGINT NOT NULL,
+    expires_at   BIGINT NOT NULL,
+    last_used_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices (user_id);
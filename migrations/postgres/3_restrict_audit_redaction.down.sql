@@ -0,0 +1,19 @@
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND NEW.redacted
+        AND NEW.id = OLD.id
+        AND NEW.time = OLD.time
+        AND NEW.actor_id = OLD.actor_id
+        AND NEW.action = OLD.action
+        AND NEW.app_id = OLD.app_id
+        AND NEW.result = OLD.result
+        AND NEW.prev_hash = OLD.prev_hash
+        AND NEW.hash = OLD.hash
+        AND NEW.ip = ''
+        AND NEW.user_agent = '' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,26 @@
+-- Redaction may only clear ip and user agent and replace an identifier
+-- in target with user id. Redacted event keeps every other column, so
+-- a redacted event can't be rewritten once more
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND NEW.redacted
+        AND NEW.id = OLD.id
+        AND NEW.time = OLD.time
+        AND NEW.actor_id = OLD.actor_id
+        AND NEW.action = OLD.action
+        AND NEW.app_id = OLD.app_id
+        AND NEW.result = OLD.result
+        AND NEW.prev_hash = OLD.prev_hash
+        AND NEW.hash = OLD.hash
+        AND NEW.ip = ''
+        AND NEW.user_agent = ''
+        AND (NEW.target = OLD.target
+            OR NOT OLD.redacted
+                AND OLD.target LIKE 'identifier:%'
+                AND NEW.target ~ '^user:[0-9]+$') THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;